@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Access Log ---
+
+// The REST endpoints get an access log line per request. WebSocket sessions log their events
+// themselves and are left out.
+
+const (
+	accessLogOff     = "off"
+	accessLogErrors  = "errors"  // 4xx and 5xx answers, the default
+	accessLogAll     = "all"     // Every request
+	accessLogVerbose = "verbose" // Every request, with query parameter names, user agent and response size
+
+	accessLogText = "text"
+	accessLogJSON = "json"
+)
+
+// AccessLogConfig sets what the access log of the REST endpoints records, and how.
+type AccessLogConfig struct {
+	Level  string `yaml:"level" json:"level"`   // "off", "errors" (default), "all" or "verbose"
+	Format string `yaml:"format" json:"format"` // "text" (default, key=value pairs) or "json" (one object per line)
+}
+
+func (c AccessLogConfig) validate() error {
+	switch c.Level {
+	case "", accessLogOff, accessLogErrors, accessLogAll, accessLogVerbose:
+	default:
+		return fmt.Errorf("server.accessLog.level must be off, errors, all or verbose")
+	}
+	switch c.Format {
+	case "", accessLogText, accessLogJSON:
+	default:
+		return fmt.Errorf("server.accessLog.format must be text or json")
+	}
+	return nil
+}
+
+// accessLogEntry is one request of the access log.
+type accessLogEntry struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	RemoteIP   string  `json:"remote_ip"`
+	Query      string  `json:"query,omitempty"` // Parameter names only, values are masked
+	UserAgent  string  `json:"user_agent,omitempty"`
+	Bytes      int64   `json:"bytes,omitempty"`
+}
+
+func (e accessLogEntry) String() string {
+	line := fmt.Sprintf("method=%s path=%q status=%d duration_ms=%.1f remote_ip=%s", e.Method, e.Path, e.Status, e.DurationMs, e.RemoteIP)
+	if e.Query != "" {
+		line += fmt.Sprintf(" query=%q", e.Query)
+	}
+	if e.UserAgent != "" {
+		line += fmt.Sprintf(" user_agent=%q", e.UserAgent)
+	}
+	if e.Bytes > 0 {
+		line += fmt.Sprintf(" bytes=%d", e.Bytes)
+	}
+	return line
+}
+
+// statusRecorder remembers the status and size of an answer.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(data)
+	s.bytes += int64(n)
+	return n, err
+}
+
+// Flush keeps streaming endpoints like /recordings/{name}/follow working.
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// withAccessLog logs the REST requests as server.accessLog says.
+func withAccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := currentConfig().Server.AccessLog
+		if cfg.Level == accessLogOff || websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		if recorder.status < http.StatusBadRequest && cfg.Level != accessLogAll && cfg.Level != accessLogVerbose {
+			return
+		}
+
+		entry := accessLogEntry{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     recorder.status,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			RemoteIP:   remoteIP(r),
+		}
+		if cfg.Level == accessLogVerbose {
+			if r.URL.RawQuery != "" {
+				entry.Query = strings.TrimPrefix(redactSetting(redactURL, "?"+r.URL.RawQuery), "?")
+			}
+			entry.UserAgent = r.UserAgent()
+			entry.Bytes = recorder.bytes
+		}
+		if cfg.Format == accessLogJSON {
+			data, _ := json.Marshal(entry)
+			log.Printf("Access: %s", data)
+			return
+		}
+		log.Printf("Access: %s", entry)
+	})
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Activity Feed ---
+
+// Kinds of feed entries
+const (
+	feedConnected    = "session.connected"
+	feedDisconnected = "session.disconnected"
+	feedEvent        = "event"
+	feedScenario     = "scenario"
+	feedError        = "error"
+)
+
+// Scenario progress reported in feed entries
+const (
+	scenarioRunning   = "running"
+	scenarioCompleted = "completed"
+	scenarioStopped   = "stopped"
+)
+
+const (
+	feedHistory = 200 // Entries kept for feeds that (re)connect
+	feedBuffer  = 256 // Entries buffered per subscriber before further ones are dropped for it
+)
+
+// FeedEntry is one line of the activity feed: something that happened in a live session.
+type FeedEntry struct {
+	Seq         int64            `json:"seq"`
+	Time        time.Time        `json:"time"`
+	Kind        string           `json:"kind"`
+	SessionID   string           `json:"session_id"`
+	Correlation string           `json:"correlation_id,omitempty"`
+	Session     *LiveSessionInfo `json:"session,omitempty"`    // On connects and disconnects
+	Direction   string           `json:"direction,omitempty"`  // Of events and errors: "client" or "server"
+	EventType   string           `json:"event_type,omitempty"` // Of events and errors, and the scenario event being executed
+	Scenario    string           `json:"scenario,omitempty"`
+	Step        int              `json:"step,omitempty"`  // Scenario event, starting at 1
+	Steps       int              `json:"steps,omitempty"` // Events in the scenario
+	Status      string           `json:"status,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	Code        string           `json:"code,omitempty"`
+}
+
+// feedSubscriber is a dashboard or client following the feed.
+type feedSubscriber struct {
+	send    chan FeedEntry
+	session string // Only entries of this session, all without
+	deltas  bool   // Include streaming *.delta and input_audio_buffer.append events
+}
+
+func (s *feedSubscriber) wants(entry FeedEntry) bool {
+	if s.session != "" && entry.SessionID != s.session {
+		return false
+	}
+	return s.deltas || !isStreamingEvent(entry.EventType)
+}
+
+// isStreamingEvent reports the event types sent many times per second while audio or text streams.
+func isStreamingEvent(eventType string) bool {
+	return strings.HasSuffix(eventType, ".delta") || eventType == "input_audio_buffer.append"
+}
+
+// activityFeed fans entries out to all subscribers. Slow subscribers miss entries rather than
+// slowing down sessions.
+var activityFeed = struct {
+	sync.Mutex
+	seq         int64
+	recent      []FeedEntry // Ring of the last feedHistory entries, without streaming events
+	next        int
+	subscribers map[*feedSubscriber]bool
+	active      atomic.Int32 // Subscriber count, read without the lock on every message
+}{subscribers: make(map[*feedSubscriber]bool)}
+
+func publishFeed(entry FeedEntry) {
+	activityFeed.Lock()
+	defer activityFeed.Unlock()
+	activityFeed.seq++
+	entry.Seq = activityFeed.seq
+	entry.Time = time.Now()
+	if !isStreamingEvent(entry.EventType) || entry.Kind != feedEvent {
+		if len(activityFeed.recent) < feedHistory {
+			activityFeed.recent = append(activityFeed.recent, entry)
+		} else {
+			activityFeed.recent[activityFeed.next] = entry
+			activityFeed.next = (activityFeed.next + 1) % feedHistory
+		}
+	}
+	for subscriber := range activityFeed.subscribers {
+		if !subscriber.wants(entry) {
+			continue
+		}
+		select {
+		case subscriber.send <- entry:
+		default:
+		}
+	}
+}
+
+// subscribeFeed registers a subscriber and returns the recent entries it wants after seq.
+func subscribeFeed(subscriber *feedSubscriber, after int64) []FeedEntry {
+	activityFeed.Lock()
+	defer activityFeed.Unlock()
+	activityFeed.subscribers[subscriber] = true
+	activityFeed.active.Add(1)
+
+	var history []FeedEntry
+	n := len(activityFeed.recent)
+	for i := range n {
+		entry := activityFeed.recent[(activityFeed.next+i)%n]
+		if entry.Seq > after && subscriber.wants(entry) {
+			history = append(history, entry)
+		}
+	}
+	return history
+}
+
+func unsubscribeFeed(subscriber *feedSubscriber) {
+	activityFeed.Lock()
+	defer activityFeed.Unlock()
+	if activityFeed.subscribers[subscriber] {
+		delete(activityFeed.subscribers, subscriber)
+		activityFeed.active.Add(-1)
+	}
+}
+
+// feedSession publishes a connect or disconnect of a live session.
+func feedSession(kind string, session *liveSession) {
+	info := session.info()
+	publishFeed(FeedEntry{Kind: kind, SessionID: session.ID, Correlation: info.Correlation, Session: &info})
+}
+
+// feedMessage publishes the type of a message passing a live session's client connection,
+// and error events with their message. Payloads stay out of the feed, /observe/{id} has them.
+// Messages are only parsed while the feed has subscribers.
+func (s *liveSession) feedMessage(direction string, messageType int, data []byte) {
+	if activityFeed.active.Load() == 0 || messageType != websocket.TextMessage {
+		return
+	}
+	var event struct {
+		Type  string `json:"type"`
+		Error *struct {
+			Message string `json:"message"`
+			Code    string `json:"code"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &event) != nil || event.Type == "" {
+		return
+	}
+	entry := FeedEntry{Kind: feedEvent, SessionID: s.ID, Correlation: s.conn.correlationID, Direction: direction, EventType: event.Type}
+	if event.Type == "error" {
+		entry.Kind = feedError
+		if event.Error != nil {
+			entry.Error, entry.Code = event.Error.Message, event.Error.Code
+		}
+	}
+	publishFeed(entry)
+}
+
+// feedScenarioProgress publishes the progress of a mock session's scenario; step is the event
+// being executed, or the last one reached when the scenario stopped.
+func feedScenarioProgress(session *MockSession, scenario Scenario, step int, status string) {
+	entry := FeedEntry{
+		Kind: feedScenario, SessionID: session.ID, Correlation: session.Conn.correlationID,
+		Scenario: scenario.Name, Step: step, Steps: len(scenario.Events), Status: status,
+	}
+	if step > 0 && step <= len(scenario.Events) {
+		entry.EventType = scenario.Events[step-1].Type
+	}
+	publishFeed(entry)
+}
+
+// handleFeed streams the activity of all live sessions, first the recent entries, then new
+// ones as they happen. GET /feed serves server-sent events, WebSocket upgrades get one text
+// message per entry. ?session= follows a single session, ?deltas=true adds streaming events.
+// SSE clients reconnecting with Last-Event-ID (or ?after=) only get what they missed.
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	subscriber := &feedSubscriber{
+		send:    make(chan FeedEntry, feedBuffer),
+		session: query.Get("session"),
+		deltas:  query.Get("deltas") == "true",
+	}
+	after, _ := strconv.ParseInt(cmp.Or(r.Header.Get("Last-Event-ID"), query.Get("after")), 10, 64)
+
+	if websocket.IsWebSocketUpgrade(r) {
+		serveFeedWebSocket(w, r, subscriber, after)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	history := subscribeFeed(subscriber, after)
+	defer unsubscribeFeed(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream
+	send := func(entry FeedEntry) error {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil
+		}
+		_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", entry.Seq, line)
+		return err
+	}
+	for _, entry := range history {
+		if send(entry) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	log.Printf("Feed: Streaming to %s", r.RemoteAddr)
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case entry := <-subscriber.send:
+			if send(entry) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			log.Printf("Feed: %s disconnected", r.RemoteAddr)
+			return
+		}
+	}
+}
+
+func serveFeedWebSocket(w http.ResponseWriter, r *http.Request, subscriber *feedSubscriber, after int64) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Feed: WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+	history := subscribeFeed(subscriber, after)
+	defer unsubscribeFeed(subscriber)
+	log.Printf("Feed: Streaming to %s over WebSocket", conn.RemoteAddr())
+
+	// The feed is read-only, reading only notices when the client goes away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(entry FeedEntry) error {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil
+		}
+		return conn.WriteMessage(websocket.TextMessage, line)
+	}
+	for _, entry := range history {
+		if send(entry) != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case entry := <-subscriber.send:
+			if send(entry) != nil {
+				return
+			}
+		case <-done:
+			log.Printf("Feed: %s disconnected", conn.RemoteAddr())
+			return
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Beta / GA Protocol Versions ---
+
+const (
+	apiVersionBeta = "beta" // OpenAI-Beta: realtime=v1 and the beta event set
+	apiVersionGA   = "ga"   // No beta header, GA event names and session shape
+)
+
+// Server event names that differ between the versions, beta name -> GA name
+var betaToGAEvents = map[string]string{
+	"response.text.delta":             "response.output_text.delta",
+	"response.text.done":              "response.output_text.done",
+	"response.audio.delta":            "response.output_audio.delta",
+	"response.audio.done":             "response.output_audio.done",
+	"response.audio_transcript.delta": "response.output_audio_transcript.delta",
+	"response.audio_transcript.done":  "response.output_audio_transcript.done",
+	"conversation.item.created":       "conversation.item.added",
+}
+
+// Assistant content part types, beta -> GA
+var betaToGAContent = map[string]string{
+	"audio": "output_audio",
+	"text":  "output_text",
+}
+
+// Audio formats, beta name -> GA format type
+var betaToGAFormats = map[string]string{
+	"pcm16":     "audio/pcm",
+	"g711_ulaw": "audio/pcmu",
+	"g711_alaw": "audio/pcma",
+}
+
+// Session fields that moved, beta path -> GA path
+var betaToGASessionFields = [][2]string{
+	{"modalities", "output_modalities"},
+	{"voice", "audio.output.voice"},
+	{"turn_detection", "audio.input.turn_detection"},
+	{"input_audio_transcription", "audio.input.transcription"},
+	{"input_audio_noise_reduction", "audio.input.noise_reduction"},
+	{"max_response_output_tokens", "max_output_tokens"},
+}
+
+var (
+	gaToBetaEvents  = invert(betaToGAEvents)
+	gaToBetaContent = invert(betaToGAContent)
+	gaToBetaFormats = invert(betaToGAFormats)
+)
+
+func invert(m map[string]string) map[string]string {
+	inverted := make(map[string]string, len(m))
+	for key, value := range m {
+		inverted[value] = key
+	}
+	return inverted
+}
+
+func validateAPIVersion(field, version string, allowAuto bool) error {
+	switch version {
+	case "", apiVersionBeta, apiVersionGA:
+		return nil
+	case "auto":
+		if allowAuto {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s has unknown value: %s", field, version)
+}
+
+// apiVersion returns the target's protocol version, beta unless configured otherwise.
+func (t UpstreamTarget) apiVersion() string {
+	if t.APIVersion == "" {
+		return apiVersionBeta
+	}
+	return t.APIVersion
+}
+
+// clientAPIVersion returns the version the client speaks. Without proxy.clientApiVersion it
+// is assumed to match the upstream, so nothing is translated. "auto" detects beta clients by
+// their OpenAI-Beta header or openai-beta.realtime-v1 subprotocol.
+func clientAPIVersion(r *http.Request, upstream string) string {
+	configured := requestConfig(r).Proxy.ClientAPIVersion
+	switch configured {
+	case "":
+		return upstream
+	case "auto":
+		if strings.Contains(r.Header.Get("OpenAI-Beta"), "realtime") {
+			return apiVersionBeta
+		}
+		for _, protocol := range websocket.Subprotocols(r) {
+			if protocol == "openai-beta.realtime-v1" {
+				return apiVersionBeta
+			}
+		}
+		return apiVersionGA
+	}
+	return configured
+}
+
+// translateEvent rewrites an event from one protocol version to the other: event names,
+// assistant content types and the session object of session and response events.
+func translateEvent(msg []byte, from, to string) ([]byte, error) {
+	if from == to {
+		return msg, nil
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return msg, nil
+	}
+
+	events, content := betaToGAEvents, betaToGAContent
+	if to == apiVersionBeta {
+		events, content = gaToBetaEvents, gaToBetaContent
+	}
+	if eventType, _ := event["type"].(string); events[eventType] != "" {
+		event["type"] = events[eventType]
+	}
+	for _, p := range []string{"part.type", "item.content.*.type", "response.output.*.content.*.type"} {
+		eachPath(event, p, false, func(parent interface{}, key string) {
+			if value, ok := getAt(parent, key).(string); ok && content[value] != "" {
+				setAt(parent, key, content[value])
+			}
+		})
+	}
+	if session, ok := event["session"].(map[string]interface{}); ok {
+		if to == apiVersionGA {
+			sessionToGA(session)
+		} else {
+			sessionToBeta(session)
+		}
+	}
+	if response, ok := event["response"].(map[string]interface{}); ok {
+		if to == apiVersionGA {
+			moveField(response, "modalities", "output_modalities")
+		} else {
+			moveField(response, "output_modalities", "modalities")
+		}
+	}
+
+	translated, err := json.Marshal(event)
+	if err != nil {
+		return msg, fmt.Errorf("failed to re-encode translated event: %w", err)
+	}
+	return translated, nil
+}
+
+func sessionToGA(session map[string]interface{}) {
+	if _, ok := session["type"]; !ok {
+		session["type"] = "realtime"
+	}
+	for _, field := range betaToGASessionFields {
+		moveField(session, field[0], field[1])
+	}
+	for beta, ga := range map[string]string{"input_audio_format": "audio.input.format", "output_audio_format": "audio.output.format"} {
+		if format, ok := session[beta].(string); ok {
+			delete(session, beta)
+			gaFormat := map[string]interface{}{"type": betaToGAFormats[format]}
+			if format == "pcm16" {
+				gaFormat["rate"] = 24000
+			}
+			setPath(session, ga, gaFormat)
+		}
+	}
+	delete(session, "temperature") // Not part of the GA session
+}
+
+func sessionToBeta(session map[string]interface{}) {
+	delete(session, "type")
+	for _, field := range betaToGASessionFields {
+		moveField(session, field[1], field[0])
+	}
+	for beta, ga := range map[string]string{"input_audio_format": "audio.input.format", "output_audio_format": "audio.output.format"} {
+		if format, ok := takePath(session, ga).(map[string]interface{}); ok {
+			if formatType, _ := format["type"].(string); gaToBetaFormats[formatType] != "" {
+				session[beta] = gaToBetaFormats[formatType]
+			}
+		}
+	}
+	// Drop the audio object once everything moved out of it
+	if audio, ok := session["audio"].(map[string]interface{}); ok {
+		for _, key := range []string{"input", "output"} {
+			if sub, ok := audio[key].(map[string]interface{}); ok && len(sub) == 0 {
+				delete(audio, key)
+			}
+		}
+		if len(audio) == 0 {
+			delete(session, "audio")
+		}
+	}
+}
+
+// moveField moves a value between dot-separated paths, creating objects as needed.
+func moveField(object map[string]interface{}, from, to string) {
+	if value := takePath(object, from); value != nil {
+		setPath(object, to, value)
+	}
+}
+
+// takePath removes and returns the value at a dot-separated path, nil if there is none.
+func takePath(object map[string]interface{}, p string) interface{} {
+	var value interface{}
+	eachPath(object, p, false, func(parent interface{}, key string) {
+		if parentObject, ok := parent.(map[string]interface{}); ok {
+			value = parentObject[key]
+			delete(parentObject, key)
+		}
+	})
+	return value
+}
+
+func setPath(object map[string]interface{}, p string, value interface{}) {
+	eachPath(object, p, true, func(parent interface{}, key string) {
+		setAt(parent, key, value)
+	})
+}
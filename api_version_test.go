@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// assertJSON fails unless got and want encode the same JSON value.
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+}
+
+func TestTranslateEventRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		beta string
+		ga   string
+	}{
+		{
+			"renamed event",
+			`{"type":"response.audio.delta","delta":"AAA="}`,
+			`{"type":"response.output_audio.delta","delta":"AAA="}`,
+		},
+		{
+			"transcript event",
+			`{"type":"response.audio_transcript.done","transcript":"Hi"}`,
+			`{"type":"response.output_audio_transcript.done","transcript":"Hi"}`,
+		},
+		{
+			"content part",
+			`{"type":"response.content_part.added","part":{"type":"audio","transcript":""}}`,
+			`{"type":"response.content_part.added","part":{"type":"output_audio","transcript":""}}`,
+		},
+		{
+			"item content",
+			`{"type":"conversation.item.created","item":{"role":"assistant","content":[{"type":"text","text":"Hi"},{"type":"audio"}]}}`,
+			`{"type":"conversation.item.added","item":{"role":"assistant","content":[{"type":"output_text","text":"Hi"},{"type":"output_audio"}]}}`,
+		},
+		{
+			"client content types",
+			`{"type":"conversation.item.create","item":{"role":"user","content":[{"type":"input_text","text":"Hi"}]}}`,
+			`{"type":"conversation.item.create","item":{"role":"user","content":[{"type":"input_text","text":"Hi"}]}}`,
+		},
+		{
+			"response",
+			`{"type":"response.done","response":{"modalities":["audio","text"],"output":[{"content":[{"type":"audio"}]},{"content":[{"type":"text"}]}]}}`,
+			`{"type":"response.done","response":{"output_modalities":["audio","text"],"output":[{"content":[{"type":"output_audio"}]},{"content":[{"type":"output_text"}]}]}}`,
+		},
+		{
+			"session",
+			`{"type":"session.updated","session":{"model":"gpt-4o-realtime","modalities":["audio"],"voice":"alloy","turn_detection":{"type":"server_vad"},
+				"input_audio_format":"pcm16","output_audio_format":"g711_ulaw","input_audio_transcription":{"model":"whisper-1"},
+				"input_audio_noise_reduction":{"type":"near_field"},"max_response_output_tokens":"inf"}}`,
+			`{"type":"session.updated","session":{"type":"realtime","model":"gpt-4o-realtime","output_modalities":["audio"],"max_output_tokens":"inf",
+				"audio":{"input":{"format":{"type":"audio/pcm","rate":24000},"turn_detection":{"type":"server_vad"},"transcription":{"model":"whisper-1"},"noise_reduction":{"type":"near_field"}},
+				"output":{"format":{"type":"audio/pcmu"},"voice":"alloy"}}}}`,
+		},
+		{
+			"A-law session",
+			`{"type":"session.update","session":{"input_audio_format":"g711_alaw"}}`,
+			`{"type":"session.update","session":{"type":"realtime","audio":{"input":{"format":{"type":"audio/pcma"}}}}}`,
+		},
+		{
+			"unchanged event",
+			`{"type":"error","error":{"type":"invalid_request_error","message":"text"}}`,
+			`{"type":"error","error":{"type":"invalid_request_error","message":"text"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ga, err := translateEvent([]byte(tt.beta), apiVersionBeta, apiVersionGA)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, ga, tt.ga)
+
+			beta, err := translateEvent(ga, apiVersionGA, apiVersionBeta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, beta, tt.beta)
+		})
+	}
+}
+
+func TestTranslateEventUntouched(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		from, to string
+	}{
+		{"same version", `{"type":"response.audio.delta"}`, apiVersionBeta, apiVersionBeta},
+		{"same GA version", `{"type":"response.output_audio.delta"}`, apiVersionGA, apiVersionGA},
+		{"not JSON", `not json`, apiVersionBeta, apiVersionGA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translateEvent([]byte(tt.msg), tt.from, tt.to)
+			if err != nil || string(got) != tt.msg {
+				t.Errorf("translateEvent = %s, %v, want the message as is", got, err)
+			}
+		})
+	}
+}
+
+func TestSessionTranslation(t *testing.T) {
+	tests := []struct {
+		name    string
+		session string
+		toGA    bool
+		want    string
+	}{
+		{"GA drops temperature", `{"temperature":0.8,"voice":"alloy"}`, true, `{"type":"realtime","audio":{"output":{"voice":"alloy"}}}`},
+		{"GA keeps the session type", `{"type":"transcription"}`, true, `{"type":"transcription"}`},
+		{"GA merges into the audio object", `{"voice":"alloy","audio":{"output":{"speed":1.2}}}`, true, `{"type":"realtime","audio":{"output":{"voice":"alloy","speed":1.2}}}`},
+		{"beta keeps other audio settings", `{"type":"realtime","audio":{"output":{"voice":"alloy","speed":1.2}}}`, false, `{"voice":"alloy","audio":{"output":{"speed":1.2}}}`},
+		{"beta drops the emptied audio object", `{"audio":{"input":{"format":{"type":"audio/pcm","rate":24000}},"output":{}}}`, false, `{"input_audio_format":"pcm16"}`},
+		{"beta drops formats without a beta name", `{"audio":{"input":{"format":{"type":"audio/opus"}}}}`, false, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var session map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.session), &session); err != nil {
+				t.Fatal(err)
+			}
+			if tt.toGA {
+				sessionToGA(session)
+			} else {
+				sessionToBeta(session)
+			}
+			got, err := json.Marshal(session)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
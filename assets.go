@@ -0,0 +1,77 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// --- Embedded Assets ---
+
+// The web UI, the default config and the default audio are built into the binary, so it runs
+// outside the repo. Files in the assets directory (-assets, the working directory by default)
+// replace the built-in ones of the same name: static/..., config.yaml and mock_audio.wav.
+
+//go:embed static config.yaml mock_audio.wav
+var embeddedAssets embed.FS
+
+const (
+	defaultConfigAsset = "config.yaml"
+	defaultAudioAsset  = "mock_audio.wav"
+)
+
+// assetsDir is the directory overriding the built-in assets, "" uses them as built.
+var assetsDir = "."
+
+// assetFS opens a file from the assets directory if it is there, else the built-in one.
+type assetFS struct{ dir string }
+
+func (a assetFS) Open(name string) (fs.File, error) {
+	if a.dir != "" {
+		if f, err := os.DirFS(a.dir).Open(name); err == nil {
+			return f, nil
+		}
+	}
+	return embeddedAssets.Open(name)
+}
+
+func assets() fs.FS {
+	return assetFS{dir: assetsDir}
+}
+
+// staticHandler serves the web UI.
+func staticHandler() http.Handler {
+	static, err := fs.Sub(assets(), "static")
+	if err != nil {
+		panic(err) // Only fails for an invalid path
+	}
+	return http.FileServerFS(static)
+}
+
+// readConfigFile reads a config file. Without a config.yaml in the working directory the
+// default path gets the built-in config.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && path == defaultConfigFlagValue {
+		log.Printf("%s not found, using the built-in config", path)
+		return fs.ReadFile(assets(), defaultConfigAsset)
+	}
+	return data, err
+}
+
+// openAudioFile opens a WAV file. The default audio is built in: a mock_audio.wav that is not
+// on disk is the built-in one.
+func openAudioFile(path string) (fs.File, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) && filepath.Base(path) == defaultAudioAsset {
+		return assets().Open(defaultAudioAsset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
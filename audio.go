@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pcm16BytesPerMs  = 24000 * 2 / 1000 // 24kHz mono PCM16
+	defaultMsPerWord = 400              // Roughly 150 words per minute of speech
+)
+
+// --- Audio Helpers ---
+
+// encodeWAV wraps mono PCM16 samples in a 44-byte RIFF/WAVE header.
+func encodeWAV(pcm []byte, sampleRate int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))           // Subchunk1Size (PCM)
+	binary.Write(&buf, binary.LittleEndian, uint16(1))            // AudioFormat (PCM)
+	binary.Write(&buf, binary.LittleEndian, uint16(1))            // NumChannels (mono)
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))   // SampleRate
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2)) // ByteRate
+	binary.Write(&buf, binary.LittleEndian, uint16(2))            // BlockAlign
+	binary.Write(&buf, binary.LittleEndian, uint16(16))           // BitsPerSample
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+
+	return buf.Bytes()
+}
+
+// resamplePCM16 converts mono PCM16 audio between sample rates using linear interpolation.
+// Good enough for speech fed to STT engines or telephony codecs, not for music.
+func resamplePCM16(pcm []byte, fromRate, toRate int) []byte {
+	if fromRate == toRate || len(pcm) < 4 {
+		return pcm
+	}
+
+	inSamples := len(pcm) / 2
+	outSamples := int(int64(inSamples) * int64(toRate) / int64(fromRate))
+	out := make([]byte, outSamples*2)
+
+	for i := 0; i < outSamples; i++ {
+		pos := float64(i) * float64(fromRate) / float64(toRate)
+		idx := int(pos)
+		frac := pos - float64(idx)
+
+		s0 := float64(int16(binary.LittleEndian.Uint16(pcm[idx*2:])))
+		s1 := s0
+		if idx+1 < inSamples {
+			s1 = float64(int16(binary.LittleEndian.Uint16(pcm[(idx+1)*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(s0+(s1-s0)*frac)))
+	}
+	return out
+}
+
+// loadWavData reads a WAV file and returns the PCM data after the 44-byte header.
+// Multi-channel audio is downmixed to mono.
+func loadWavData(path string) ([]byte, error) {
+	f, err := openAudioFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 44 {
+		return nil, fmt.Errorf("file too short to be a WAV file (%d bytes)", len(data))
+	}
+	if channels := int(binary.LittleEndian.Uint16(data[22:24])); channels > 1 {
+		return downmixPCM16(data[44:], channels), nil
+	}
+	return data[44:], nil
+}
+
+// downmixPCM16 averages the channels of interleaved PCM16 frames into mono samples.
+func downmixPCM16(pcm []byte, channels int) []byte {
+	frameSize := channels * 2
+	frames := len(pcm) / frameSize
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		var sum int
+		for c := 0; c < channels; c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[i*frameSize+c*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(sum/channels)))
+	}
+	return out
+}
+
+// fitAudioToText trims, loops or pads 24kHz PCM16 audio so its duration is proportional
+// to the event's transcript, according to the event's audio_fit setting.
+func fitAudioToText(audio []byte, event Event) []byte {
+	mode := event.AudioFit
+	words := len(strings.Fields(event.Text))
+	if mode == "" || mode == "none" || words == 0 || len(audio) == 0 {
+		return audio
+	}
+
+	msPerWord := event.MsPerWord
+	if msPerWord <= 0 {
+		msPerWord = defaultMsPerWord
+	}
+	targetLen := words * msPerWord * pcm16BytesPerMs
+	if targetLen == len(audio) {
+		return audio
+	}
+
+	// Every mode trims audio that is longer than the transcript
+	if targetLen < len(audio) {
+		return audio[:targetLen]
+	}
+
+	switch mode {
+	case "loop":
+		out := make([]byte, 0, targetLen)
+		for len(out) < targetLen {
+			n := targetLen - len(out)
+			if n > len(audio) {
+				n = len(audio)
+			}
+			out = append(out, audio[:n]...)
+		}
+		return out
+	case "pad":
+		out := make([]byte, targetLen)
+		copy(out, audio)
+		return out
+	default: // "trim" leaves short audio alone
+		return audio
+	}
+}
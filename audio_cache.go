@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// --- Audio Asset Cache ---
+
+const defaultAudioCacheMaxMB = 256
+
+// audioChunk is one slice of response audio, with its base64 form precomputed.
+type audioChunk struct {
+	data        []byte
+	encoded     string
+	decodedSize int // Size in output bytes per bytesPerMs, differs from len(data) for Opus
+}
+
+func newAudioChunk(data []byte) audioChunk {
+	return audioChunk{
+		data:        data,
+		encoded:     base64.StdEncoding.EncodeToString(data),
+		decodedSize: len(data),
+	}
+}
+
+// audioAsset identifies a cached asset: a WAV file with mock.audioNormalize applied, so a
+// reload that changes the normalization gets freshly normalized audio.
+type audioAsset struct {
+	path      string
+	normalize string
+}
+
+// chunkKey identifies one chunked encoding of an asset.
+type chunkKey struct {
+	chunkSize int
+	output    audioOutput
+}
+
+// cachedAudio is a decoded audio asset plus its chunked encodings. done is closed once pcm and
+// err are set, sessions wanting the asset meanwhile wait for the one loading it.
+type cachedAudio struct {
+	done   chan struct{}
+	pcm    []byte
+	err    error
+	chunks map[chunkKey]*cachedChunks
+}
+
+// cachedChunks is one chunked encoding of an asset, set before done is closed.
+type cachedChunks struct {
+	done   chan struct{}
+	chunks []audioChunk
+	err    error
+}
+
+// AudioCache keeps decoded audio assets in memory so responses don't hit the disk.
+// Assets and encodings that would push the cache over its size limit are served uncached.
+// Files are read, decoded and encoded outside the lock, so a cold asset only holds up the
+// sessions waiting for that asset.
+type AudioCache struct {
+	mu       sync.Mutex
+	assets   map[audioAsset]*cachedAudio
+	size     int64 // PCM plus the raw and base64 data of the chunks
+	maxBytes int64 // <= 0 disables caching
+}
+
+var audioCache = NewAudioCache(defaultAudioCacheMaxMB)
+
+// NewAudioCache creates an empty cache holding at most maxMB megabytes of audio.
+func NewAudioCache(maxMB int) *AudioCache {
+	return &AudioCache{
+		assets:   make(map[audioAsset]*cachedAudio),
+		maxBytes: int64(maxMB) * 1024 * 1024,
+	}
+}
+
+// Load returns the PCM data of a WAV asset, reading it from disk on first use.
+// The returned slice is shared and must not be modified.
+func (c *AudioCache) Load(key audioAsset) ([]byte, error) {
+	c.mu.Lock()
+	if asset, ok := c.assets[key]; ok {
+		c.mu.Unlock()
+		<-asset.done
+		return asset.pcm, asset.err
+	}
+	asset := &cachedAudio{done: make(chan struct{}), chunks: make(map[chunkKey]*cachedChunks)}
+	c.assets[key] = asset
+	c.mu.Unlock()
+
+	asset.pcm, asset.err = loadWavData(key.path)
+	if asset.err == nil && needsGain(key.normalize, 0) {
+		asset.pcm = applyGain(asset.pcm, key.normalize, 0)
+	}
+
+	c.mu.Lock()
+	switch {
+	case asset.err != nil:
+		delete(c.assets, key) // Tried again by the next session
+	case !c.reserve(int64(len(asset.pcm))):
+		log.Printf("Audio cache full (%d/%d bytes), serving %s from disk", c.size, c.maxBytes, key.path)
+		delete(c.assets, key)
+	}
+	c.mu.Unlock()
+	close(asset.done)
+	return asset.pcm, asset.err
+}
+
+// reserve counts n more bytes against the limit if they fit. Callers hold the lock.
+func (c *AudioCache) reserve(n int64) bool {
+	if c.size+n > c.maxBytes {
+		return false
+	}
+	c.size += n
+	return true
+}
+
+// Chunks returns the asset transcoded for out and split into pre-encoded chunks of chunkSize bytes.
+func (c *AudioCache) Chunks(asset audioAsset, chunkSize int, out audioOutput) ([]audioChunk, error) {
+	pcm, err := c.Load(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	key := chunkKey{chunkSize: chunkSize, output: out}
+	c.mu.Lock()
+	cached, ok := c.assets[asset]
+	if !ok {
+		c.mu.Unlock()
+		return outputChunks(pcm, out, chunkSize)
+	}
+	if entry, ok := cached.chunks[key]; ok {
+		c.mu.Unlock()
+		<-entry.done
+		return entry.chunks, entry.err
+	}
+	entry := &cachedChunks{done: make(chan struct{})}
+	cached.chunks[key] = entry
+	c.mu.Unlock()
+
+	entry.chunks, entry.err = outputChunks(pcm, out, chunkSize)
+
+	c.mu.Lock()
+	if entry.err != nil || !c.reserve(chunksSize(entry.chunks)) {
+		delete(cached.chunks, key)
+	}
+	c.mu.Unlock()
+	close(entry.done)
+	return entry.chunks, entry.err
+}
+
+// chunksSize returns the memory held by chunks: their data and its base64 form.
+func chunksSize(chunks []audioChunk) int64 {
+	var n int64
+	for _, chunk := range chunks {
+		n += int64(len(chunk.data) + len(chunk.encoded))
+	}
+	return n
+}
+
+// Preload loads and chunks an asset ahead of the first response.
+func (c *AudioCache) Preload(asset audioAsset, chunkSize int, out audioOutput) error {
+	if _, err := c.Chunks(asset, chunkSize, out); err != nil {
+		return fmt.Errorf("failed to preload %s: %w", asset.path, err)
+	}
+	return nil
+}
+
+// chunkAudio splits audio into chunks of chunkSize bytes and base64-encodes each of them.
+func chunkAudio(audio []byte, chunkSize int) []audioChunk {
+	if chunkSize <= 0 {
+		chunkSize = len(audio)
+	}
+	chunks := make([]audioChunk, 0, (len(audio)+chunkSize-1)/chunkSize)
+	for offset := 0; offset < len(audio); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(audio) {
+			end = len(audio)
+		}
+		chunks = append(chunks, newAudioChunk(audio[offset:end]))
+	}
+	return chunks
+}
+
+// responseAudioChunks returns the chunks to stream for a message event.
+// Unmodified assets come straight from the cache; fitted, gain-adjusted or marked audio is chunked on the fly.
+// A positive markerSeq overlays the response marker (see mock.responseMarker).
+func responseAudioChunks(asset audioAsset, event Event, chunkSize int, out audioOutput, marker string, markerSeq int) ([]audioChunk, error) {
+	pcm, err := audioCache.Load(asset)
+	if err != nil {
+		return nil, err
+	}
+	audio := fitAudioToText(pcm, event)
+	modified := len(audio) != len(pcm)
+	if needsGain(event.Normalize, event.GainDb) {
+		audio = applyGain(audio, event.Normalize, event.GainDb)
+		modified = true
+	}
+	if markerSeq > 0 {
+		audio = overlayResponseMarker(audio, marker, markerSeq)
+		modified = true
+	}
+	if !modified {
+		return audioCache.Chunks(asset, chunkSize, out)
+	}
+	return outputChunks(audio, out, chunkSize)
+}
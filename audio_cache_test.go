@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func writeTestWAV(t *testing.T, samples int) string {
+	t.Helper()
+	pcm := make([]byte, samples*2)
+	for i := range pcm {
+		pcm[i] = byte(i)
+	}
+	path := filepath.Join(t.TempDir(), "test.wav")
+	if err := os.WriteFile(path, encodeWAV(pcm, 24000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAudioCacheCountsChunks(t *testing.T) {
+	asset := audioAsset{path: writeTestWAV(t, 500)}
+	pcm16 := audioOutput{Format: "pcm16", SampleRate: 24000}
+	first, err := outputChunks(make([]byte, 1000), pcm16, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for the PCM and one encoding of it
+	c := &AudioCache{assets: make(map[audioAsset]*cachedAudio), maxBytes: 1000 + chunksSize(first)}
+
+	for _, chunkSize := range []int{100, 200} {
+		chunks, err := c.Chunks(asset, chunkSize, pcm16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 1000 / chunkSize; len(chunks) != want {
+			t.Errorf("chunk size %d: %d chunks, want %d", chunkSize, len(chunks), want)
+		}
+	}
+	if c.size != c.maxBytes {
+		t.Errorf("cache holds %d bytes, want %d", c.size, c.maxBytes)
+	}
+	if n := len(c.assets[asset].chunks); n != 1 {
+		t.Errorf("%d encodings cached, want the one that fits", n)
+	}
+}
+
+func TestAudioCacheLoadsOnce(t *testing.T) {
+	asset := audioAsset{path: writeTestWAV(t, 500)}
+	c := NewAudioCache(1)
+	pcm16 := audioOutput{Format: "pcm16", SampleRate: 24000}
+
+	var wg sync.WaitGroup
+	results := make([][]audioChunk, 16)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chunks, err := c.Chunks(asset, 100, pcm16)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = chunks
+		}()
+	}
+	wg.Wait()
+	for i, chunks := range results {
+		if len(chunks) == 0 || &chunks[0] != &results[0][0] {
+			t.Errorf("session %d got its own copy of the chunks", i)
+		}
+	}
+	if want := 1000 + chunksSize(results[0]); c.size != want {
+		t.Errorf("cache holds %d bytes, want %d", c.size, want)
+	}
+}
+
+func TestAudioCacheRetriesFailedLoads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.wav")
+	c := NewAudioCache(1)
+	if _, err := c.Load(audioAsset{path: path}); err == nil {
+		t.Fatal("missing file loaded")
+	}
+	if err := os.WriteFile(path, encodeWAV(make([]byte, 100), 24000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pcm, err := c.Load(audioAsset{path: path}); err != nil || len(pcm) != 100 {
+		t.Errorf("Load after the file appeared = %d bytes, %v", len(pcm), err)
+	}
+}
+
+func TestAudioCacheKeysNormalization(t *testing.T) {
+	path := writeTestWAV(t, 500)
+	c := NewAudioCache(1)
+	plain, err := c.Load(audioAsset{path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := c.Load(audioAsset{path: path, normalize: "peak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(normalized) == string(plain) {
+		t.Error("a changed mock.audioNormalize served the audio cached before")
+	}
+	if len(c.assets) != 2 {
+		t.Errorf("%d assets cached, want one per normalization", len(c.assets))
+	}
+}
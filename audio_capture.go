@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// --- Output Audio Capture ---
+
+// AudioCapture collects the audio deltas of each response flowing through the proxy
+// and writes them to one WAV file per response.
+type AudioCapture struct {
+	dir      string
+	baseName string
+
+	mu        sync.Mutex
+	responses map[string][]byte
+	order     []string // Response IDs in the order they started, for stable flushing on Close
+}
+
+// NewAudioCapture creates a capture writing into the 'recorded' subdirectory of baseDir,
+// next to the NDJSON recordings.
+func NewAudioCapture(baseDir string, baseName string) (*AudioCapture, error) {
+	if baseDir == "" {
+		baseDir = "recordings"
+	}
+	return newAudioCaptureIn(filepath.Join(baseDir, "recorded"), baseName)
+}
+
+// newAudioCaptureIn creates a capture writing <baseName>_<response id>.wav files in targetDir.
+func newAudioCaptureIn(targetDir string, baseName string) (*AudioCapture, error) {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	return &AudioCapture{
+		dir:       targetDir,
+		baseName:  filepath.Base(baseName),
+		responses: make(map[string][]byte),
+	}, nil
+}
+
+// HandleServerEvent inspects a server event, buffering audio deltas and
+// writing the WAV file once the response is done.
+func (c *AudioCapture) HandleServerEvent(msg []byte) {
+	var event struct {
+		Type       string `json:"type"`
+		ResponseID string `json:"response_id"`
+		Delta      string `json:"delta"`
+		Response   struct {
+			ID string `json:"id"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return
+	}
+
+	switch event.Type {
+	case "response.audio.delta", "response.output_audio.delta":
+		audio, err := base64.StdEncoding.DecodeString(event.Delta)
+		if err != nil {
+			log.Printf("Audio capture: skipping undecodable delta for %s: %v", event.ResponseID, err)
+			return
+		}
+		c.mu.Lock()
+		if _, ok := c.responses[event.ResponseID]; !ok {
+			c.order = append(c.order, event.ResponseID)
+		}
+		c.responses[event.ResponseID] = append(c.responses[event.ResponseID], audio...)
+		c.mu.Unlock()
+	case "response.done":
+		c.flush(event.Response.ID)
+	}
+}
+
+// flush writes the buffered audio of a response to disk and forgets it.
+func (c *AudioCapture) flush(responseID string) {
+	c.mu.Lock()
+	audio, ok := c.responses[responseID]
+	delete(c.responses, responseID)
+	c.mu.Unlock()
+
+	if !ok || len(audio) == 0 {
+		return
+	}
+
+	path := filepath.Join(c.dir, fmt.Sprintf("%s_%s.wav", c.baseName, filepath.Base(responseID)))
+	if err := os.WriteFile(path, encodeWAV(audio, 24000), 0644); err != nil {
+		log.Printf("Audio capture: failed to write %s: %v", path, err)
+		return
+	}
+	log.Printf("Audio capture: wrote %d bytes of response audio to %s", len(audio), path)
+}
+
+// Close writes any responses that never received response.done (e.g. cancelled or disconnected).
+func (c *AudioCapture) Close() {
+	c.mu.Lock()
+	pending := c.order
+	c.order = nil
+	c.mu.Unlock()
+
+	for _, responseID := range pending {
+		c.flush(responseID)
+	}
+}
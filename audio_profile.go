@@ -0,0 +1,114 @@
+package main
+
+import "fmt"
+
+// --- Audio Profiles & Formats ---
+
+const sourceSampleRate = 24000 // Audio assets are always 24kHz PCM16
+
+// AudioProfile bundles the audio defaults of a session.
+type AudioProfile struct {
+	InputFormat  string
+	OutputFormat string
+	SampleRate   int // Sample rate of pcm16 audio; G.711 is always 8kHz
+	FrameMs      int // Default output frame duration, 0 uses the mock chunk settings
+}
+
+var audioProfiles = map[string]AudioProfile{
+	"default": {InputFormat: "pcm16", OutputFormat: "pcm16", SampleRate: 24000},
+	// SIP/Twilio style: 8kHz G.711 in 20ms frames
+	"telephony": {InputFormat: "g711_ulaw", OutputFormat: "g711_ulaw", SampleRate: 8000, FrameMs: 20},
+	// WebRTC style: 48kHz Opus packets every 20ms, only available in builds with -tags opus
+	"webrtc": {InputFormat: "pcm16", OutputFormat: "opus", SampleRate: 24000, FrameMs: opusFrameMs},
+}
+
+// lookupAudioProfile returns the named profile, treating "" as "default".
+func lookupAudioProfile(name string) (AudioProfile, error) {
+	if name == "" {
+		name = "default"
+	}
+	profile, ok := audioProfiles[name]
+	if !ok {
+		return AudioProfile{}, fmt.Errorf("unknown audio profile: %s", name)
+	}
+	if isOpus(profile.OutputFormat) && encodeOpusFrames == nil {
+		return AudioProfile{}, fmt.Errorf("audio profile %s needs Opus support, rebuild with -tags opus", name)
+	}
+	return profile, nil
+}
+
+// audioOutput describes how response audio is encoded for a session.
+type audioOutput struct {
+	Format     string // "pcm16", "g711_ulaw", "g711_alaw" or "opus"
+	SampleRate int
+}
+
+// bytesPerMs returns how many encoded bytes one millisecond of audio takes.
+// Opus packets vary in size, so their audio is measured in decoded PCM16 bytes.
+func (o audioOutput) bytesPerMs() int {
+	if isG711(o.Format) {
+		return o.SampleRate / 1000
+	}
+	return o.SampleRate * 2 / 1000
+}
+
+// isG711 reports whether the format is one of the 8kHz G.711 variants.
+func isG711(format string) bool {
+	switch format {
+	case "g711_ulaw", "g711_alaw", "audio/pcmu", "audio/pcma":
+		return true
+	}
+	return false
+}
+
+// isALaw reports whether a G.711 format is A-law rather than mu-law.
+func isALaw(format string) bool {
+	return format == "g711_alaw" || format == "audio/pcma"
+}
+
+// formatSampleRate returns the sample rate audio in this format uses within the profile.
+func formatSampleRate(format string, profile AudioProfile) int {
+	if isG711(format) {
+		return 8000
+	}
+	if isOpus(format) {
+		return opusSampleRate
+	}
+	return profile.SampleRate
+}
+
+// outputChunks converts 24kHz PCM16 source audio into the session's output encoding and splits it
+// into chunks of chunkSize bytes. Opus audio is always split into one packet per 20ms frame.
+func outputChunks(pcm []byte, out audioOutput, chunkSize int) ([]audioChunk, error) {
+	if !isOpus(out.Format) {
+		return chunkAudio(transcodeOutput(pcm, out), chunkSize), nil
+	}
+	packets, err := encodeOpusFrames(resamplePCM16(pcm, sourceSampleRate, out.SampleRate))
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]audioChunk, 0, len(packets))
+	for _, packet := range packets {
+		chunk := newAudioChunk(packet)
+		chunk.decodedSize = opusFrameBytes
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// transcodeOutput converts 24kHz PCM16 source audio into the session's output encoding.
+func transcodeOutput(pcm []byte, out audioOutput) []byte {
+	pcm = resamplePCM16(pcm, sourceSampleRate, out.SampleRate)
+	if isG711(out.Format) {
+		return encodeG711(pcm, isALaw(out.Format))
+	}
+	return pcm
+}
+
+// decodeToPCM16 converts input audio in the given format to PCM16.
+func decodeToPCM16(data []byte, format string) []byte {
+	if isG711(format) {
+		return decodeG711(data, isALaw(format))
+	}
+	return data
+}
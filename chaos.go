@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Fault Injection (Chaos) ---
+
+// chaosReorderFlush is how long a held-back message waits for a successor before it is sent anyway.
+const chaosReorderFlush = 50 * time.Millisecond
+
+// ChaosConfig makes server -> client delivery imperfect, in both mock and proxy mode.
+// Probabilities are between 0 and 1 and only apply to JSON (text) messages.
+type ChaosConfig struct {
+	Drop      map[string]float64 `yaml:"drop,omitempty" json:"drop,omitempty"` // Event type ("*" for any) -> drop probability
+	Duplicate float64            `yaml:"duplicate" json:"duplicate"`           // Send a *.delta event twice
+	Reorder   float64            `yaml:"reorder" json:"reorder"`               // Swap a message with the next one
+	Truncate  float64            `yaml:"truncate" json:"truncate"`             // Cut the JSON short
+	Seed      uint64             `yaml:"seed" json:"seed"`                     // Fixed seed for reproducible runs, 0 picks a random one
+}
+
+func (c ChaosConfig) enabled() bool {
+	return len(c.Drop) > 0 || c.Duplicate > 0 || c.Reorder > 0 || c.Truncate > 0
+}
+
+func (c ChaosConfig) validate() error {
+	check := func(name string, p float64) error {
+		if p < 0 || p > 1 {
+			return fmt.Errorf("chaos.%s must be between 0 and 1, got %v", name, p)
+		}
+		return nil
+	}
+	for eventType, p := range c.Drop {
+		if err := check("drop."+eventType, p); err != nil {
+			return err
+		}
+	}
+	if err := check("duplicate", c.Duplicate); err != nil {
+		return err
+	}
+	if err := check("reorder", c.Reorder); err != nil {
+		return err
+	}
+	return check("truncate", c.Truncate)
+}
+
+// chaosInjector applies a ChaosConfig to the messages written to one connection.
+// It is only used with the connection's write lock held.
+type chaosInjector struct {
+	cfg  ChaosConfig
+	rng  *rand.Rand
+	conn *SafeWebSocket
+
+	held  []byte // Message held back to be delivered after its successor
+	timer *time.Timer
+}
+
+// enableChaos turns on fault injection for messages written to this connection.
+func (s *SafeWebSocket) enableChaos(cfg ChaosConfig) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	s.chaos = &chaosInjector{cfg: cfg, rng: rand.New(rand.NewPCG(seed, seed)), conn: s}
+	s.logf("Client %s: Chaos enabled (seed %d)", s.RemoteAddr(), seed)
+}
+
+// deliver writes a text message, possibly dropped, duplicated, reordered or truncated.
+func (c *chaosInjector) deliver(data []byte) error {
+	var event BaseEvent
+	json.Unmarshal(data, &event)
+
+	if c.roll(c.dropProbability(event.Type)) {
+		c.conn.logf("Client %s: Chaos dropped %s", c.conn.RemoteAddr(), event.Type)
+		return c.flushHeld()
+	}
+	if c.roll(c.cfg.Truncate) && len(data) > 1 {
+		data = data[:1+c.rng.IntN(len(data)-1)]
+		c.conn.logf("Client %s: Chaos truncated %s to %d bytes", c.conn.RemoteAddr(), event.Type, len(data))
+	}
+	if c.held == nil && c.roll(c.cfg.Reorder) {
+		c.held = data
+		c.timer = time.AfterFunc(chaosReorderFlush, c.flushLater)
+		return nil
+	}
+
+	if err := c.conn.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return err
+	}
+	if strings.HasSuffix(event.Type, ".delta") && c.roll(c.cfg.Duplicate) {
+		if err := c.conn.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return err
+		}
+	}
+	return c.flushHeld()
+}
+
+func (c *chaosInjector) dropProbability(eventType string) float64 {
+	if p, ok := c.cfg.Drop[eventType]; ok {
+		return p
+	}
+	return c.cfg.Drop["*"]
+}
+
+func (c *chaosInjector) roll(p float64) bool {
+	return p > 0 && c.rng.Float64() < p
+}
+
+// flushHeld sends the held-back message after the one that overtook it.
+func (c *chaosInjector) flushHeld() error {
+	if c.held == nil {
+		return nil
+	}
+	held := c.held
+	c.held = nil
+	c.timer.Stop()
+	return c.conn.Conn.WriteMessage(websocket.TextMessage, held)
+}
+
+// flushLater delivers a held-back message that no other message overtook in time.
+func (c *chaosInjector) flushLater() {
+	c.conn.Mu.Lock()
+	defer c.conn.Mu.Unlock()
+	c.flushHeld()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --- Proxy Circuit Breaker ---
+
+const (
+	defaultCircuitFailureThreshold = 3
+	defaultCircuitOpenSeconds      = 30
+	defaultCircuitMaxOpenSeconds   = 300
+)
+
+// CircuitBreakerConfig makes the proxy stop dialing an upstream that keeps failing and serve a
+// mock scenario instead. A session fails when the dial fails or the upstream sends a server_error.
+type CircuitBreakerConfig struct {
+	Enabled          bool   `yaml:"enabled" json:"enabled"`
+	FailureThreshold int    `yaml:"failureThreshold" json:"failureThreshold"` // Consecutive failed sessions that open the circuit (default 3)
+	OpenSeconds      int    `yaml:"openSeconds" json:"openSeconds"`           // How long the circuit stays open (default 30)
+	MaxOpenSeconds   int    `yaml:"maxOpenSeconds" json:"maxOpenSeconds"`     // Cap for the open period, doubled each time a trial fails (default 300)
+	FallbackScenario string `yaml:"fallbackScenario" json:"fallbackScenario"` // Served while open (default: first scenario)
+}
+
+func (c CircuitBreakerConfig) validate(scenarios []Scenario) error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.FailureThreshold < 0 || c.OpenSeconds < 0 || c.MaxOpenSeconds < 0 {
+		return fmt.Errorf("proxy.circuitBreaker values must not be negative")
+	}
+	if len(scenarios) == 0 {
+		return fmt.Errorf("proxy.circuitBreaker needs a scenario to fall back to")
+	}
+	if c.FallbackScenario == "" {
+		return nil
+	}
+	for _, s := range scenarios {
+		if s.Name == c.FallbackScenario {
+			return nil
+		}
+	}
+	return fmt.Errorf("proxy.circuitBreaker.fallbackScenario '%s' not found", c.FallbackScenario)
+}
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int           // Consecutive failed sessions
+	openUntil time.Time     // Zero while closed
+	openFor   time.Duration // Current open period
+	trial     bool          // The circuit was open, the next result decides whether it closes
+}
+
+var proxyCircuit = &circuitBreaker{}
+
+// allow reports whether connections may go upstream. Once the open period is over, traffic is
+// let through again as a trial.
+func (c *circuitBreaker) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().After(c.openUntil)
+}
+
+// success closes the circuit after a session that ended without upstream failures.
+func (c *circuitBreaker) success() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.trial {
+		log.Printf("Proxy: Circuit closed, upstream is healthy again")
+	}
+	c.failures = 0
+	c.trial = false
+	c.openFor = 0
+}
+
+// failure counts a failed session and opens the circuit once the threshold is reached.
+// A failed trial reopens it right away for twice as long.
+func (c *circuitBreaker) failure(cfg CircuitBreakerConfig, reason string) {
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultCircuitFailureThreshold
+	}
+	base := time.Duration(cfg.OpenSeconds) * time.Second
+	if base <= 0 {
+		base = defaultCircuitOpenSeconds * time.Second
+	}
+	limit := time.Duration(cfg.MaxOpenSeconds) * time.Second
+	if limit <= 0 {
+		limit = defaultCircuitMaxOpenSeconds * time.Second
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures++
+	if !c.trial && c.failures < threshold {
+		return
+	}
+	if c.trial && c.openFor > 0 {
+		c.openFor = min(c.openFor*2, limit)
+	} else {
+		c.openFor = min(base, limit)
+	}
+	c.openUntil = time.Now().Add(c.openFor)
+	c.trial = true
+	log.Printf("Proxy: Circuit opened for %v after %d failed sessions (last: %s)", c.openFor, c.failures, reason)
+	c.failures = 0
+}
+
+// isServerError reports whether an upstream event is an error of type server_error.
+func isServerError(msg []byte) bool {
+	var event struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	return json.Unmarshal(msg, &event) == nil && event.Type == "error" && event.Error.Type == "server_error"
+}
+
+// serveFallbackScenario answers a proxy connection in mock mode while the circuit is open.
+func serveFallbackScenario(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Del("replaySession")
+	query.Set("scenario", requestConfig(r).Proxy.CircuitBreaker.FallbackScenario)
+	r.URL.RawQuery = query.Encode()
+	handleMockWebSocket(w, r)
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// --- Command Line ---
+
+// The binary is the server and the tooling around its scenarios and recordings. Without a
+// command, or with flags only, it serves.
+
+type cliCommand struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+func cliCommands() []cliCommand {
+	return []cliCommand{
+		{"serve", "serve [-config file] [-assets dir] [-port n] [-print-default-config] [-print-example-scenario]", "Run the server (the default)", cliServe},
+		{"validate", "validate [-config file] [recording ...]", "Check a config and recordings without serving", cliValidate},
+		{"convert", "convert -to format [-o file] file ...", "Convert configs (yaml, json, toml) or recordings (wav, anonymized, chat, evals)", cliConvert},
+		{"replay", "replay [-config file] [-port n] [-replay-mode mode] recording", "Serve a recording to every client", cliReplay},
+		{"record", "record [-config file] [-port n] [-url upstream] [-out dir]", "Proxy clients to the upstream and record their sessions", cliRecord},
+		{"simulate", "simulate [-target url] [-clients n] [-speed x] recording", "Play the client side of a recording against a server", cliSimulate},
+	}
+}
+
+// runCLI runs the command named by the first argument.
+func runCLI(args []string) error {
+	help := len(args) > 0 && slices.Contains([]string{"help", "-h", "-help", "--help"}, args[0])
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !help {
+		return cliServe(args)
+	}
+	for _, command := range cliCommands() {
+		if command.name == args[0] {
+			return command.run(args[1:])
+		}
+	}
+	printUsage()
+	if help {
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, command := range cliCommands() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", command.name, command.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", filepath.Base(os.Args[0]))
+}
+
+// newFlagSet returns the flags of a command, with its usage line in -h.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		for _, command := range cliCommands() {
+			if command.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: %s %s\n\n%s.\n\n", filepath.Base(os.Args[0]), command.usage, command.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// serverFlags are the flags of the commands that run the server.
+type serverFlags struct {
+	config string
+	port   int
+}
+
+func addServerFlags(fs *flag.FlagSet) *serverFlags {
+	var opts serverFlags
+	fs.StringVar(&opts.config, "config", defaultConfigFlagValue, "Path to the configuration file")
+	fs.StringVar(&assetsDir, "assets", assetsDir, "Directory whose static/, config.yaml and mock_audio.wav replace the built-in ones")
+	fs.IntVar(&opts.port, "port", 0, "Port to listen on instead of server.port")
+	return &opts
+}
+
+// setOverrides applies command line settings as environment overrides, so they are validated
+// like the config file and survive reloads.
+func setOverrides(settings map[string]string) {
+	for env, value := range settings {
+		if value != "" {
+			os.Setenv(env, value)
+		}
+	}
+}
+
+func (opts *serverFlags) serve(overrides map[string]string) error {
+	if opts.port != 0 {
+		overrides["MOCK_SERVER_PORT"] = strconv.Itoa(opts.port)
+	}
+	setOverrides(overrides)
+	initConfig(opts.config)
+	serve()
+	return nil
+}
+
+func cliServe(args []string) error {
+	fs := newFlagSet("serve")
+	opts := addServerFlags(fs)
+	printConfig := fs.Bool("print-default-config", false, "Write a commented config with every setting to stdout and exit")
+	printScenario := fs.Bool("print-example-scenario", false, "Write a commented example scenario to stdout and exit")
+	fs.Parse(args)
+	switch {
+	case *printConfig:
+		return printDefaultConfig(os.Stdout)
+	case *printScenario:
+		return printExampleScenario(os.Stdout)
+	}
+	return opts.serve(map[string]string{})
+}
+
+// cliReplay serves a recording to every client that asks for neither a scenario nor another replay.
+func cliReplay(args []string) error {
+	fs := newFlagSet("replay")
+	opts := addServerFlags(fs)
+	mode := fs.String("replay-mode", "", "stream (default) or interactive, see mock.replayMode")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("replay needs one recording")
+	}
+	path, ok := recordingFile(fs.Arg(0))
+	if !ok {
+		return fmt.Errorf("recording %s not found", fs.Arg(0))
+	}
+	defaultReplayPath = path
+	return opts.serve(map[string]string{"MOCK_MODE": "mock", "MOCK_REPLAY_MODE": *mode})
+}
+
+// cliRecord proxies clients to the upstream and records their sessions in both directions.
+func cliRecord(args []string) error {
+	fs := newFlagSet("record")
+	opts := addServerFlags(fs)
+	upstream := fs.String("url", "", "Upstream Realtime endpoint instead of proxy.url")
+	out := fs.String("out", "", "Directory of the recordings instead of proxy.recordingPath")
+	fs.Parse(args)
+	return opts.serve(map[string]string{
+		"MOCK_MODE":                 "proxy",
+		"MOCK_PROXY_URL":            *upstream,
+		"MOCK_PROXY_RECORDING_PATH": *out,
+		"MOCK_LOG_INBOUND":          "true",
+		"MOCK_LOG_OUTBOUND":         "true",
+	})
+}
+
+// cliValidate checks a config and the recordings given as arguments, like the server would
+// load them, and reports what it found.
+func cliValidate(args []string) error {
+	fs := newFlagSet("validate")
+	config := fs.String("config", defaultConfigFlagValue, "Path to the configuration file")
+	fs.StringVar(&assetsDir, "assets", assetsDir, "Directory whose config.yaml replaces the built-in one")
+	fs.Parse(args)
+
+	data, err := readConfigFile(*config)
+	if err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	cfg, err := parseConfig(data, configFormat(*config), filepath.Dir(*config))
+	if err != nil {
+		return fmt.Errorf("validation failed: %s: %w", *config, err)
+	}
+	fmt.Printf("%s: OK (mode %s, %d scenarios)\n", *config, cfg.Mode, len(cfg.Scenarios))
+	if fs.NArg() > 0 {
+		if err := verifyCommand(fs.Args()); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// cliConvert converts config files between formats, or recordings to WAV files, anonymized
+// copies or datasets.
+func cliConvert(args []string) error {
+	fs := newFlagSet("convert")
+	to := fs.String("to", "", "yaml, json or toml for configs; wav, anonymized, chat or evals for recordings")
+	out := fs.String("o", "", "Output file of a config (default stdout) or a dataset (required)")
+	inputAudio := fs.Bool("input-audio", false, "Include the input audio with -to wav")
+	var anonymizeOpts anonymizeOptions
+	fs.BoolVar(&anonymizeOpts.StripAudio, "strip-audio", false, "Remove audio with -to anonymized instead of replacing it with silence")
+	fs.BoolVar(&anonymizeOpts.HashTranscripts, "hash-transcripts", false, "Replace transcripts and text with hashes with -to anonymized")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("convert needs at least one file")
+	}
+
+	var err error
+	switch *to {
+	case configFormatYAML, configFormatJSON, configFormatTOML:
+		if fs.NArg() != 1 {
+			return errors.New("configs are converted one at a time")
+		}
+		err = convertConfigCommand(fs.Arg(0), *to, *out)
+	case "wav":
+		for _, path := range fs.Args() {
+			if err = extractAudioCommand(path, *inputAudio); err != nil {
+				break
+			}
+		}
+	case "anonymized":
+		for _, path := range fs.Args() {
+			if err = anonymizeCommand(path, anonymizeOpts); err != nil {
+				break
+			}
+		}
+	case "chat", "evals":
+		if *out == "" {
+			return errors.New("datasets need an output file (-o)")
+		}
+		err = exportMessagesCommand(*out, fs.Args(), *to)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown format %q", *to)
+	}
+	if err != nil {
+		return fmt.Errorf("conversion failed: %w", err)
+	}
+	return nil
+}
+
+// convertConfigCommand writes a config file in another format. The settings are taken as they
+// are written, without defaults or resolved paths; comments are lost.
+func convertConfigCommand(path, format, out string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc any
+	switch configFormat(path) {
+	case configFormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+	case configFormatTOML:
+		err = toml.Unmarshal(data, &doc)
+	default:
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	doc = plainDocument(doc, format == configFormatTOML)
+
+	var converted bytes.Buffer
+	switch format {
+	case configFormatJSON:
+		encoder := json.NewEncoder(&converted)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(doc)
+	case configFormatTOML:
+		err = toml.NewEncoder(&converted).Encode(doc)
+	default:
+		encoder := yaml.NewEncoder(&converted)
+		encoder.SetIndent(2)
+		err = encoder.Encode(doc)
+	}
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(converted.Bytes())
+		return err
+	}
+	if err := os.WriteFile(out, converted.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", out)
+	return nil
+}
+
+// plainDocument turns JSON numbers into integers or floats, and drops nulls TOML has no value for.
+func plainDocument(v any, dropNulls bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if value == nil && dropNulls {
+				delete(v, key)
+				continue
+			}
+			v[key] = plainDocument(value, dropNulls)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = plainDocument(value, dropNulls)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+func cliSimulate(args []string) error {
+	fs := newFlagSet("simulate")
+	var opts simulateOptions
+	fs.StringVar(&opts.Target, "target", "ws://localhost:8080/v1/realtime", "Realtime endpoint to play the recording against")
+	fs.StringVar(&opts.Model, "model", "", "Model sent as ?model=, default the model of the recording")
+	fs.StringVar(&opts.APIVersion, "api-version", apiVersionBeta, "Protocol version of -target: beta or ga")
+	fs.Float64Var(&opts.Speed, "speed", 1, "Playback speed, 0 sends without pauses")
+	fs.IntVar(&opts.Clients, "clients", 1, "Sessions played in parallel")
+	fs.DurationVar(&opts.Linger, "linger", 3*time.Second, "Close a session once the server is quiet this long after the last message")
+	fs.StringVar(&opts.Out, "out", "", "Record each session to this .ndjson file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("simulate needs one recording")
+	}
+	if err := simulateCommand(fs.Arg(0), opts); err != nil {
+		return fmt.Errorf("simulation failed: %w", err)
+	}
+	return nil
+}
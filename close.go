@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Close Frames ---
+
+// closeFrameType marks recorded close frames and is the event type filters match them as.
+const closeFrameType = "close"
+
+// closeInfo is the code and reason of a close frame, as recorded.
+type closeInfo struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// closeFromError returns the close frame that ended a read. A connection that dropped
+// without one reports 1006 (abnormal closure).
+func closeFromError(err error) closeInfo {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeInfo{Code: closeErr.Code, Reason: closeErr.Text}
+	}
+	return closeInfo{Code: websocket.CloseAbnormalClosure}
+}
+
+// writeCloseFrame sends a close frame with the given code and reason. Abnormal closures
+// (1006, 1015) have no frame on the wire, so the connection is dropped instead.
+func writeCloseFrame(conn *websocket.Conn, info closeInfo) error {
+	switch info.Code {
+	case websocket.CloseAbnormalClosure, websocket.CloseTLSHandshake:
+		return conn.Close()
+	}
+	return conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(info.Code, info.Reason), time.Now().Add(time.Second))
+}
@@ -1,35 +1,158 @@
 package main
 
 import (
-	"flag"
+	"cmp"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
-	"os"
+	"net/url"
 	"path/filepath"
+	"strings"
 
 	"encoding/binary"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/yaml.v3"
 )
 
 // --- Configuration Structs ---
 
 type ServerConfig struct {
-	Port int `yaml:"port" json:"port"`
+	Port          int       `yaml:"port" json:"port"`
+	AdminTokenEnv string    `yaml:"adminTokenEnv" json:"adminTokenEnv"` // Default ADMIN_TOKEN, the bearer token of the admin API (/scenarios)
+	TLS           TLSConfig `yaml:"tls" json:"tls"`                     // Serve wss:// and https://
+	// Browser origins allowed to connect and call the REST API, e.g. https://*.example.com. Empty allows all WebSockets.
+	AllowedOrigins []string `yaml:"allowedOrigins" json:"allowedOrigins"`
+	// Serves the admin API, recordings, metrics and UI on their own port instead of port, 0 for the same port
+	AdminPort int `yaml:"adminPort" json:"adminPort"`
+	// Caps on WebSocket connections and handshakes, so one client can't take the server down
+	Limits ConnectionLimits `yaml:"limits" json:"limits"`
+	// Access log of the REST endpoints
+	AccessLog AccessLogConfig `yaml:"accessLog" json:"accessLog"`
+	// Adds the connection's correlation ID to every event sent to clients under this field,
+	// e.g. x_correlation_id. Empty leaves events as they are.
+	CorrelationField string `yaml:"correlationField" json:"correlationField"`
 }
 
 type MockConfig struct {
 	ResponseDelaySeconds int    `yaml:"responseDelaySeconds" json:"responseDelaySeconds"`
-	AudioWavPath         string `yaml:"audioWavPath" json:"audioWavPath"`
+	AudioWavPath         string `yaml:"audioWavPath" json:"audioWavPath" redact:"path"`
 	ChunkIntervalMs      int    `yaml:"chunkIntervalMs" json:"chunkIntervalMs"`
 	AudioChunkSizeBytes  int    `yaml:"audioChunkSizeBytes" json:"audioChunkSizeBytes"`
+	// Pace audio and transcript so they finish together: "none", "audio" (transcript follows audio) or "transcript" (audio follows transcript)
+	TranscriptSync string `yaml:"transcriptSync" json:"transcriptSync"`
+	// How replays are paced: "stream" (default, everything after the first trigger) or "interactive"
+	// (each recorded response waits for the client's response.create or commit). Overridden by ?replay_mode=
+	ReplayMode string `yaml:"replayMode" json:"replayMode"`
+	// Restart replays from the beginning when they end, pausing replayLoopPauseMs in between. Overridden by ?replay_loop=
+	ReplayLoop        bool `yaml:"replayLoop" json:"replayLoop"`
+	ReplayLoopPauseMs int  `yaml:"replayLoopPauseMs" json:"replayLoopPauseMs"`
+	// Shorten recorded pauses longer than this in replays, 0 keeps them. Overridden by ?replay_max_gap_ms=
+	ReplayMaxGapMs int `yaml:"replayMaxGapMs" json:"replayMaxGapMs"`
+	// What each response of an interactive replay waits for: "auto" (default, the recorded "trigger" or the
+	// client events around it) or "any" (any trigger). Overridden by ?replay_turns=
+	ReplayTurns string `yaml:"replayTurns" json:"replayTurns"`
+	// Welcome events of replays that recorded their own: "both" (default), "mock" (skip the recorded
+	// ones) or "recorded" (send the recorded ones instead of the mock's). Overridden by ?replay_welcome=
+	ReplayWelcome string `yaml:"replayWelcome" json:"replayWelcome"`
+	// Give replayed sessions, conversations, items and responses fresh IDs instead of the recorded ones
+	ReplayFreshIDs bool `yaml:"replayFreshIds" json:"replayFreshIds"`
+	// Per-voice asset directories (voice name -> directory containing a WAV named like audioWavPath)
+	VoiceAudioDirs map[string]string `yaml:"voiceAudioDirs,omitempty" json:"voiceAudioDirs,omitempty" redact:"path"`
+	// Loudness normalization applied to audio assets when they are loaded: "none" (default), "peak" or "rms"
+	AudioNormalize string `yaml:"audioNormalize" json:"audioNormalize"`
+	// Maximum uncommitted input audio per session in milliseconds, 0 means unlimited.
+	// Appends beyond the cap are rejected with an input_audio_buffer_size_exceeded error.
+	InputBufferMaxMs int `yaml:"inputBufferMaxMs" json:"inputBufferMaxMs"`
+	// Marker overlaid on the start of each response's audio: "none" (default), "beep" or "dtmf"
+	ResponseMarker string `yaml:"responseMarker" json:"responseMarker"`
+	// Audio profile for new sessions: "default" (24kHz PCM16) or "telephony" (8kHz G.711 in 20ms frames).
+	// Overridable per connection with ?audio_profile=
+	AudioProfile string `yaml:"audioProfile" json:"audioProfile"`
+	// Send output audio as raw binary PCM frames instead of response.audio.delta (overridable with ?binary_audio=)
+	BinaryAudio bool `yaml:"binaryAudio" json:"binaryAudio"`
+	// Upper bound for decoded audio kept in memory (default 256, negative disables the cache)
+	AudioCacheMaxMB int `yaml:"audioCacheMaxMB" json:"audioCacheMaxMB"`
+	// Default turn detection for new sessions. Unset keeps the legacy behaviour of
+	// starting the scenario on the first input_audio_buffer.append.
+	TurnDetection *TurnDetection `yaml:"turnDetection,omitempty" json:"turnDetection,omitempty"`
+	// How long a disconnected session can be resumed with ?resume=SESSION_ID (default 300, negative disables)
+	ResumeTTLSeconds int `yaml:"resumeTTLSeconds" json:"resumeTTLSeconds"`
+	// Optional STT backend used to transcribe the client's input audio
+	Transcription TranscriptionConfig `yaml:"transcription" json:"transcription"`
+}
+
+type TranscriptionConfig struct {
+	Backend       string `yaml:"backend" json:"backend"`                           // "", "whisper_cpp" or "openai"
+	WhisperBinary string `yaml:"whisperBinary" json:"whisperBinary" redact:"path"` // Path to the whisper.cpp CLI
+	WhisperModel  string `yaml:"whisperModel" json:"whisperModel" redact:"path"`   // Path to the ggml model file
+	OpenAIURL     string `yaml:"openaiUrl" json:"openaiUrl" redact:"url"`          // Defaults to the public transcription endpoint
+	OpenAIModel   string `yaml:"openaiModel" json:"openaiModel"`                   // Defaults to gpt-4o-mini-transcribe
+
+	// Fake confidence (0-1) reported as per-token logprobs in transcription events, nil disables logprobs
+	Confidence *float64 `yaml:"confidence,omitempty" json:"confidence,omitempty"`
 }
 
 type ProxyConfig struct {
-	URL           string `yaml:"url" json:"url"`
-	RecordingPath string `yaml:"recordingPath" json:"recordingPath"`
+	URL           string `yaml:"url" json:"url" redact:"url"`
+	RecordingPath string `yaml:"recordingPath" json:"recordingPath" redact:"path"`
 	Model         string `yaml:"model" json:"model"`
+	CaptureAudio  bool   `yaml:"captureAudio" json:"captureAudio"` // Write each response's audio to a WAV next to the recording
+	// Forward the client's own API key (Authorization header or openai-insecure-api-key subprotocol)
+	// instead of OPENAI_API_KEY, which is only used as a fallback
+	APIKeyPassthrough bool `yaml:"apiKeyPassthrough" json:"apiKeyPassthrough"`
+	// Protocol version of the upstream ("beta" or "ga") and of clients ("beta", "ga" or "auto"),
+	// events are translated when they differ. Without clientApiVersion clients must match the upstream.
+	APIVersion       string `yaml:"apiVersion" json:"apiVersion"`
+	ClientAPIVersion string `yaml:"clientApiVersion" json:"clientApiVersion"`
+	// Clients may pick the model with ?model= (any model unless allowedModels is set) and the
+	// upstream with ?upstream_url=, which must be one of allowedUrls
+	AllowedModels []string `yaml:"allowedModels,omitempty" json:"allowedModels,omitempty"`
+	AllowedURLs   []string `yaml:"allowedUrls,omitempty" json:"allowedUrls,omitempty" redact:"url"`
+	// Named upstream targets and the rules routing connections to them, the settings above are the default target
+	Targets map[string]UpstreamTarget `yaml:"targets,omitempty" json:"targets,omitempty"`
+	Routes  []ProxyRoute              `yaml:"routes,omitempty" json:"routes,omitempty"`
+	// Extra headers and query parameters for the upstream dial, values may reference ${ENV_VARS}
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" redact:"secret"`
+	Query   map[string]string `yaml:"query,omitempty" json:"query,omitempty" redact:"secret"`
+	// "split" (default): inbound_/outbound_ files per logInbound/logOutbound, "combined": one session_ file
+	// with both directions tagged, "both": combined file plus the split files
+	RecordingMode string `yaml:"recordingMode" json:"recordingMode"`
+	// Keep each session's recordings in recorded/<upstream session id>/ with a manifest.json
+	SessionDirs bool `yaml:"sessionDirs" json:"sessionDirs"`
+	// Continue recordings in a new numbered file after maxSizeMB or maxMinutes
+	Rotation RecordingRotation `yaml:"rotation" json:"rotation"`
+	// Write recordings gzip-compressed as .ndjson.gz, replay reads both
+	CompressRecordings bool `yaml:"compressRecordings" json:"compressRecordings"`
+	// Store recordings in a database instead of NDJSON files
+	Storage RecordingStorage `yaml:"storage" json:"storage"`
+	// Prune the oldest recordings by age, total size or count
+	Retention RecordingRetention `yaml:"retention" json:"retention"`
+	// Write checksums of recordings so /recordings/{name}/verify can detect damaged ones
+	Integrity IntegrityConfig `yaml:"integrity" json:"integrity"`
+	// Encrypt recordings at rest with AES-GCM
+	Encryption RecordingEncryption `yaml:"encryption" json:"encryption"`
+	// Add a meta object (sequence number, direction, size, elapsed ms) to every recorded message
+	RecordMetadata bool `yaml:"recordMetadata" json:"recordMetadata"`
+	// Re-dial the upstream when it drops instead of closing the client connection
+	Reconnect ReconnectConfig `yaml:"reconnect" json:"reconnect"`
+	// Periodic pings to the upstream
+	Keepalive KeepaliveConfig `yaml:"keepalive" json:"keepalive"`
+	// Rewrites applied to client session.update events before forwarding
+	SessionOverrides SessionOverrideConfig `yaml:"sessionOverrides,omitempty" json:"sessionOverrides,omitempty"`
+	// Outbound HTTP(S)/SOCKS proxy and TLS settings for reaching the upstream
+	Outbound OutboundConfig `yaml:"outbound" json:"outbound"`
+	// Simulated request/token limits answered at the proxy
+	RateLimit RateLimitConfig `yaml:"rateLimit" json:"rateLimit"`
+	// Serve a mock scenario instead of dialing an upstream that keeps failing
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker" json:"circuitBreaker"`
+	// USD per 1M tokens by model name or glob, used to estimate the cost shown by /usage
+	Pricing map[string]ModelPricing `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+	// Field rewrites applied to events in either direction, after the session overrides
+	Transforms []TransformRule `yaml:"transforms,omitempty" json:"transforms,omitempty"`
 }
 
 type Event struct {
@@ -37,6 +160,36 @@ type Event struct {
 	DelayMs      int                     `yaml:"delay_ms" json:"delay_ms"`
 	Text         string                  `yaml:"text,omitempty" json:"text,omitempty"`                   // For "message" and "user_transcription"
 	FunctionCall *FunctionCallDefinition `yaml:"function_call,omitempty" json:"function_call,omitempty"` // For "function_call"
+	AudioFit     string                  `yaml:"audio_fit,omitempty" json:"audio_fit,omitempty"`         // For "message": "trim", "loop" or "pad" audio to the transcript length
+	MsPerWord    int                     `yaml:"ms_per_word,omitempty" json:"ms_per_word,omitempty"`     // Speech rate used by audio_fit (default 400)
+
+	// For "message": per-event loudness, applied after mock.audioNormalize
+	Normalize string  `yaml:"normalize,omitempty" json:"normalize,omitempty"` // "peak" or "rms"
+	GainDb    float64 `yaml:"gain_db,omitempty" json:"gain_db,omitempty"`
+
+	// For "user_transcription": overrides mock.transcription.confidence
+	Confidence *float64 `yaml:"confidence,omitempty" json:"confidence,omitempty"`
+
+	// For "message": content parts streamed in order within the item; text alone is one audio part
+	Parts []ContentPart `yaml:"parts,omitempty" json:"parts,omitempty"`
+
+	// Per-event overrides of mock.audioChunkSizeBytes / mock.chunkIntervalMs
+	ChunkSizeBytes  int `yaml:"chunk_size_bytes,omitempty" json:"chunk_size_bytes,omitempty"`
+	ChunkIntervalMs int `yaml:"chunk_interval_ms,omitempty" json:"chunk_interval_ms,omitempty"`
+}
+
+// ContentPart is one content part of a scripted assistant message.
+type ContentPart struct {
+	Type string `yaml:"type" json:"type"` // "audio" (audio + transcript) or "text"
+	Text string `yaml:"text" json:"text"`
+}
+
+// contentParts returns the content parts of a message event.
+func (e Event) contentParts() []ContentPart {
+	if len(e.Parts) > 0 {
+		return e.Parts
+	}
+	return []ContentPart{{Type: "audio", Text: e.Text}}
 }
 
 type FunctionCallDefinition struct {
@@ -56,12 +209,21 @@ type Config struct {
 	Mode        string       `yaml:"mode" json:"mode"`
 	LogInbound  bool         `yaml:"logInbound" json:"logInbound"`   // Log client -> server messages (both modes)
 	LogOutbound bool         `yaml:"logOutbound" json:"logOutbound"` // Log server -> client messages (proxy mode only)
+	Chaos       ChaosConfig  `yaml:"chaos" json:"chaos"`             // Fault injection on server -> client messages (both modes)
 	Scenarios   []Scenario   `yaml:"scenarios" json:"scenarios"`
+	// Named groups of scenarios with their own recordings, audio and session defaults
+	Tenants map[string]TenantConfig `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+	// Scenarios and recordings shared from a Git repository or an archive, added to the ones above
+	Library LibraryConfig `yaml:"library" json:"library"`
+
+	RecordingFilters RecordingFilters `yaml:"recordingFilters" json:"recordingFilters"` // What the inbound/outbound recorders write
 }
 
 // --- Global Variables ---
 
-var appConfig Config // Loaded config
+// Loaded config. It is never modified once in use: reloads and the admin API swap in a changed
+// copy under configMu, and sessions and requests read it through currentConfig or requestConfig.
+var appConfig = &Config{}
 
 const (
 	// Default config path if -config flag is not provided or for Docker's CMD
@@ -72,64 +234,329 @@ const (
 // loadConfiguration loads the application configuration.
 func loadConfiguration(cliConfigPath string) (string, error) {
 	log.Printf("Loading configuration from: %s", cliConfigPath)
-	data, err := os.ReadFile(cliConfigPath)
+	data, err := readConfigFile(cliConfigPath)
 	if err != nil {
 		return cliConfigPath, fmt.Errorf("failed to read config file %s: %w", cliConfigPath, err)
 	}
-	err = yaml.Unmarshal(data, &appConfig)
+	cfg, err := parseConfig(data, configFormat(cliConfigPath), filepath.Dir(cliConfigPath))
 	if err != nil {
-		return cliConfigPath, fmt.Errorf("failed to parse config file %s: %w", cliConfigPath, err)
+		return cliConfigPath, fmt.Errorf("%s: %w", cliConfigPath, err)
+	}
+	appConfig = cfg
+	return cliConfigPath, nil
+}
+
+// Formats of config files, told apart by their extension
+const (
+	configFormatYAML = "yaml"
+	configFormatJSON = "json"
+	configFormatTOML = "toml"
+)
+
+// configFormat returns the format of a config file: .json and .toml files, YAML otherwise.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return configFormatJSON
+	case ".toml":
+		return configFormatTOML
+	}
+	return configFormatYAML
+}
+
+// decodeConfig reads a config in any format. The keys are the same in all of them, the JSON ones
+// being the YAML ones; TOML is read through JSON.
+func decodeConfig(data []byte, format string, cfg *Config) error {
+	switch format {
+	case configFormatJSON:
+		return json.Unmarshal(data, cfg)
+	case configFormatTOML:
+		var doc map[string]any
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(converted, cfg)
+	}
+	return yaml.Unmarshal(data, cfg)
+}
+
+// parseConfig parses and validates a config, resolving relative paths against configDir and
+// filling in defaults. It is used at startup and for reloads.
+func parseConfig(data []byte, format, configDir string) (*Config, error) {
+	cfg := &Config{}
+	if err := decodeConfig(data, format, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s config: %w", strings.ToUpper(format), err)
+	}
+	if err := applyEnvOverrides(cfg); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
 	}
 
 	// Validate configuration
-	if err := validateConfig(&appConfig); err != nil {
-		return cliConfigPath, fmt.Errorf("configuration validation failed: %w", err)
+	if err := validateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
 	// Resolve audioWavPath
-	if appConfig.Mock.AudioWavPath != "" && !filepath.IsAbs(appConfig.Mock.AudioWavPath) {
-		configDir := filepath.Dir(cliConfigPath)
-		resolvedAudioPath := filepath.Join(configDir, appConfig.Mock.AudioWavPath)
-		log.Printf("Original audioWavPath: '%s'. Config file directory: '%s'. Resolved audioWavPath to: '%s'", appConfig.Mock.AudioWavPath, configDir, resolvedAudioPath)
-		appConfig.Mock.AudioWavPath = resolvedAudioPath
+	if cfg.Mock.AudioWavPath != "" && !filepath.IsAbs(cfg.Mock.AudioWavPath) {
+		resolvedAudioPath := filepath.Join(configDir, cfg.Mock.AudioWavPath)
+		log.Printf("Original audioWavPath: '%s'. Config file directory: '%s'. Resolved audioWavPath to: '%s'", cfg.Mock.AudioWavPath, configDir, resolvedAudioPath)
+		cfg.Mock.AudioWavPath = resolvedAudioPath
 	} else {
-		log.Printf("audioWavPath '%s' is absolute or empty, using as is.", appConfig.Mock.AudioWavPath)
+		log.Printf("audioWavPath '%s' is absolute or empty, using as is.", cfg.Mock.AudioWavPath)
 	}
-	return cliConfigPath, nil
+
+	// Resolve voiceAudioDirs the same way
+	for voice, dir := range cfg.Mock.VoiceAudioDirs {
+		if dir != "" && !filepath.IsAbs(dir) {
+			cfg.Mock.VoiceAudioDirs[voice] = filepath.Join(configDir, dir)
+		}
+	}
+	for name, tenant := range cfg.Tenants {
+		tenant.resolvePaths(configDir)
+		cfg.Tenants[name] = tenant
+	}
+	for _, path := range []*string{&cfg.Proxy.Outbound.CAFile, &cfg.Server.TLS.CertFile, &cfg.Server.TLS.KeyFile, &cfg.Server.TLS.Autocert.CacheDir, &cfg.Library.Dir} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(configDir, *path)
+		}
+	}
+
+	if cfg.Server.Port == 0 {
+		cfg.Server.Port = 8080
+	}
+	if cfg.Mock.AudioChunkSizeBytes == 0 {
+		cfg.Mock.AudioChunkSizeBytes = 4096
+	}
+	if cfg.Mock.ChunkIntervalMs == 0 {
+		cfg.Mock.ChunkIntervalMs = 100
+	}
+	if cfg.Mock.AudioCacheMaxMB == 0 {
+		cfg.Mock.AudioCacheMaxMB = defaultAudioCacheMaxMB
+	}
+	if cfg.Mock.ResumeTTLSeconds == 0 {
+		cfg.Mock.ResumeTTLSeconds = defaultResumeTTLSeconds
+	}
+	return cfg, nil
 }
 
 func validateConfig(cfg *Config) error {
 	// Only validate scenarios if we are in mock mode, or just warn?
 	// The original code validated scenarios always.
-	if len(cfg.Scenarios) == 0 && cfg.Mode == "mock" {
+	if len(cfg.Scenarios) == 0 && cfg.Mode == "mock" && !cfg.Library.enabled() {
 		return fmt.Errorf("no scenarios defined in configuration for mock mode")
 	}
 
-	scenarioNames := make(map[string]bool)
-	for _, scenario := range cfg.Scenarios {
-		if scenario.Name == "" {
-			return fmt.Errorf("scenario found with empty name")
+	if _, err := lookupAudioProfile(cfg.Mock.AudioProfile); err != nil {
+		return fmt.Errorf("mock.audioProfile: %w", err)
+	}
+
+	switch cfg.Mock.TranscriptSync {
+	case "", "none", "audio", "transcript":
+	default:
+		return fmt.Errorf("mock.transcriptSync has unknown value: %s", cfg.Mock.TranscriptSync)
+	}
+
+	if td := cfg.Mock.TurnDetection; td != nil && td.Type != "" {
+		if param, err := td.validate(); err != nil {
+			return fmt.Errorf("invalid mock.turnDetection (%s): %w", param, err)
 		}
+	}
+
+	if cfg.Mock.InputBufferMaxMs < 0 {
+		return fmt.Errorf("mock.inputBufferMaxMs must not be negative")
+	}
+
+	switch cfg.Mock.ReplayMode {
+	case "", replayModeStream, replayModeInteractive:
+	default:
+		return fmt.Errorf("mock.replayMode has unknown value: %s", cfg.Mock.ReplayMode)
+	}
+
+	if cfg.Mock.ReplayLoopPauseMs < 0 {
+		return fmt.Errorf("mock.replayLoopPauseMs must not be negative")
+	}
+	if cfg.Mock.ReplayMaxGapMs < 0 {
+		return fmt.Errorf("mock.replayMaxGapMs must not be negative")
+	}
+	if err := validateReplayTurns(cfg.Mock.ReplayTurns); err != nil {
+		return err
+	}
+
+	switch cfg.Mock.ReplayWelcome {
+	case "", replayWelcomeBoth, replayWelcomeMock, replayWelcomeRecorded:
+	default:
+		return fmt.Errorf("mock.replayWelcome has unknown value: %s", cfg.Mock.ReplayWelcome)
+	}
+
+	switch cfg.Mock.AudioNormalize {
+	case "", "none", "peak", "rms":
+	default:
+		return fmt.Errorf("mock.audioNormalize has unknown value: %s", cfg.Mock.AudioNormalize)
+	}
+
+	switch cfg.Mock.ResponseMarker {
+	case "", "none", "beep", "dtmf":
+	default:
+		return fmt.Errorf("mock.responseMarker has unknown value: %s", cfg.Mock.ResponseMarker)
+	}
+
+	if c := cfg.Mock.Transcription.Confidence; c != nil && (*c <= 0 || *c > 1) {
+		return fmt.Errorf("mock.transcription.confidence must be in (0, 1], got %v", *c)
+	}
+
+	switch cfg.Proxy.RecordingMode {
+	case "", "split", "combined", "both":
+	default:
+		return fmt.Errorf("proxy.recordingMode has unknown value: %s", cfg.Proxy.RecordingMode)
+	}
+
+	if cfg.Proxy.Keepalive.IntervalSeconds < 0 || cfg.Proxy.Keepalive.TimeoutSeconds < 0 {
+		return fmt.Errorf("proxy.keepalive values must not be negative")
+	}
+	if err := validateAPIVersion("proxy.apiVersion", cfg.Proxy.APIVersion, false); err != nil {
+		return err
+	}
+	if err := validateAPIVersion("proxy.clientApiVersion", cfg.Proxy.ClientAPIVersion, true); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.validateRouting(); err != nil {
+		return err
+	}
+	if cfg.Proxy.Rotation.MaxSizeMB < 0 || cfg.Proxy.Rotation.MaxMinutes < 0 {
+		return fmt.Errorf("proxy.rotation values must not be negative")
+	}
+	if err := cfg.Proxy.Outbound.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Server.TLS.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Server.Limits.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Server.AccessLog.validate(); err != nil {
+		return err
+	}
+	if err := validateCorrelationField(cfg.Server.CorrelationField); err != nil {
+		return err
+	}
+	if err := cfg.Library.validate(); err != nil {
+		return err
+	}
+	if err := validateAllowedOrigins(cfg.Server.AllowedOrigins); err != nil {
+		return err
+	}
+	if cfg.Server.AdminPort < 0 || cfg.Server.AdminPort > 65535 {
+		return fmt.Errorf("server.adminPort must be a port number")
+	}
+	if cfg.Server.AdminPort != 0 && cfg.Server.AdminPort == cmp.Or(cfg.Server.Port, 8080) {
+		return fmt.Errorf("server.adminPort must differ from server.port")
+	}
+	if err := cfg.Proxy.Storage.validate(cfg); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.Retention.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.Encryption.validate(cfg); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Proxy.CircuitBreaker.validate(cfg.Scenarios); err != nil {
+		return err
+	}
+	for i, rule := range cfg.Proxy.Transforms {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("proxy.transforms[%d]: %w", i, err)
+		}
+	}
+	for _, allowed := range cfg.Proxy.AllowedURLs {
+		if _, err := url.Parse(allowed); err != nil {
+			return fmt.Errorf("proxy.allowedUrls has invalid URL %s: %w", allowed, err)
+		}
+	}
+
+	if err := cfg.Chaos.validate(); err != nil {
+		return err
+	}
+	if err := cfg.RecordingFilters.Inbound.validate(); err != nil {
+		return fmt.Errorf("recordingFilters.inbound: %w", err)
+	}
+	if err := cfg.RecordingFilters.Outbound.validate(); err != nil {
+		return fmt.Errorf("recordingFilters.outbound: %w", err)
+	}
+	if err := cfg.RecordingFilters.Redact.compile(); err != nil {
+		return fmt.Errorf("recordingFilters.redact: %w", err)
+	}
+
+	if err := validateTenants(cfg.Tenants); err != nil {
+		return err
+	}
+	return validateScenarios(cfg.Scenarios)
+}
+
+// validateScenarios checks a list of scenarios, whose names must be unique.
+func validateScenarios(scenarios []Scenario) error {
+	scenarioNames := make(map[string]bool)
+	for _, scenario := range scenarios {
 		if scenarioNames[scenario.Name] {
 			return fmt.Errorf("duplicate scenario name: %s", scenario.Name)
 		}
 		scenarioNames[scenario.Name] = true
+		if err := scenario.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		for i, event := range scenario.Events {
-			if event.Type != "message" && event.Type != "function_call" && event.Type != "user_transcription" {
-				return fmt.Errorf("scenario '%s' event %d has unknown type: %s", scenario.Name, i, event.Type)
-			}
-			if event.Type == "function_call" && (event.FunctionCall == nil || event.FunctionCall.Name == "") {
-				return fmt.Errorf("scenario '%s' event %d (function_call) missing function name", scenario.Name, i)
+// validate checks a scenario of the config or of the admin API.
+func (scenario Scenario) validate() error {
+	if scenario.Name == "" {
+		return fmt.Errorf("scenario found with empty name")
+	}
+	for i, event := range scenario.Events {
+		if event.Type != "message" && event.Type != "function_call" && event.Type != "user_transcription" {
+			return fmt.Errorf("scenario '%s' event %d has unknown type: %s", scenario.Name, i, event.Type)
+		}
+		if event.Type == "function_call" && (event.FunctionCall == nil || event.FunctionCall.Name == "") {
+			return fmt.Errorf("scenario '%s' event %d (function_call) missing function name", scenario.Name, i)
+		}
+		if event.ChunkSizeBytes < 0 || event.ChunkIntervalMs < 0 {
+			return fmt.Errorf("scenario '%s' event %d has negative chunk settings", scenario.Name, i)
+		}
+		if c := event.Confidence; c != nil && (*c <= 0 || *c > 1) {
+			return fmt.Errorf("scenario '%s' event %d confidence must be in (0, 1], got %v", scenario.Name, i, *c)
+		}
+		switch event.Normalize {
+		case "", "none", "peak", "rms":
+		default:
+			return fmt.Errorf("scenario '%s' event %d has unknown normalize: %s", scenario.Name, i, event.Normalize)
+		}
+		for j, part := range event.Parts {
+			if part.Type != "audio" && part.Type != "text" {
+				return fmt.Errorf("scenario '%s' event %d part %d has unknown type: %s", scenario.Name, i, j, part.Type)
 			}
 		}
+		switch event.AudioFit {
+		case "", "none", "trim", "loop", "pad":
+		default:
+			return fmt.Errorf("scenario '%s' event %d has unknown audio_fit: %s", scenario.Name, i, event.AudioFit)
+		}
 	}
 	return nil
 }
 
-// validateWavFormat checks if the WAV file is 24kHz PCM16 Mono
+// validateWavFormat checks if the WAV file is 24kHz PCM16.
+// Stereo and multi-channel files are accepted, loadWavData downmixes them to mono.
 func validateWavFormat(path string) error {
-	f, err := os.Open(path)
+	f, err := openAudioFile(path)
 	if err != nil {
 		return err
 	}
@@ -152,10 +579,10 @@ func validateWavFormat(path string) error {
 		return fmt.Errorf("audio format is not PCM (expected 1, got %d)", audioFormat)
 	}
 
-	// Check NumChannels (Mono = 1) - bytes 22-23
+	// Check NumChannels (at least 1) - bytes 22-23
 	numChannels := binary.LittleEndian.Uint16(header[22:24])
-	if numChannels != 1 {
-		return fmt.Errorf("audio is not mono (expected 1 channel, got %d)", numChannels)
+	if numChannels == 0 {
+		return fmt.Errorf("audio has no channels")
 	}
 
 	// Check SampleRate (24000) - bytes 24-27
@@ -173,40 +600,73 @@ func validateWavFormat(path string) error {
 	return nil
 }
 
-func initConfig() {
-	cliConfigPath := flag.String("config", defaultConfigFlagValue, "Path to the configuration file")
-	flag.Parse()
-
-	loadedConfigFile, err := loadConfiguration(*cliConfigPath)
+// initConfig loads the config file and sets up what the server needs. Errors are fatal.
+func initConfig(configPath string) {
+	loadedConfigFile, err := loadConfiguration(configPath)
 	if err != nil {
 		log.Fatalf("Configuration error: %v", err)
 	}
 	log.Printf("Successfully loaded and processed configuration from %s", loadedConfigFile)
+	configFilePath = loadedConfigFile
+
+	audioCache = NewAudioCache(appConfig.Mock.AudioCacheMaxMB)
 
-	if appConfig.Server.Port == 0 {
-		appConfig.Server.Port = 8080
+	if err := configureOutbound(appConfig.Proxy.Outbound); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	if err := configureStorage(appConfig.Proxy.Storage); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	if err := configureEncryption(appConfig.Proxy.Encryption); err != nil {
+		log.Fatalf("Configuration error: %v", err)
 	}
-	if appConfig.Mock.AudioChunkSizeBytes == 0 {
-		appConfig.Mock.AudioChunkSizeBytes = 4096
+	startRetentionJanitor(appConfig.Proxy.Retention)
+	watchConfigReload()
+
+	if inputTranscriber, err = newTranscriber(appConfig.Mock.Transcription); err != nil {
+		log.Printf("WARNING: Input transcription disabled: %v", err)
+	} else if inputTranscriber != nil {
+		log.Printf("Input transcription enabled (backend: %s)", appConfig.Mock.Transcription.Backend)
 	}
-	if appConfig.Mock.ChunkIntervalMs == 0 {
-		appConfig.Mock.ChunkIntervalMs = 100
+
+	// Audio is preloaded while the server already answers; /readyz fails until it's done
+	go func() { markAssetsLoaded(loadAudioAssets()) }()
+}
+
+// loadAudioAssets checks and preloads the configured audio. A missing or unreadable file is
+// logged and returned, playback of it would fail.
+func loadAudioAssets() error {
+	// Chunking used by new sessions, so preloaded assets match what the first response needs
+	profile, _ := lookupAudioProfile(appConfig.Mock.AudioProfile)
+	out := audioOutput{Format: profile.OutputFormat, SampleRate: formatSampleRate(profile.OutputFormat, profile)}
+	chunkSize := appConfig.Mock.AudioChunkSizeBytes * out.bytesPerMs() / pcm16BytesPerMs
+	if profile.FrameMs > 0 {
+		chunkSize = profile.FrameMs * out.bytesPerMs()
 	}
 
 	// Check if audio file exists and validate format (after path resolution)
 	if appConfig.Mock.AudioWavPath != "" { // Only check if a path is configured
-		if _, err := os.Stat(appConfig.Mock.AudioWavPath); os.IsNotExist(err) {
+		f, err := openAudioFile(appConfig.Mock.AudioWavPath)
+		if errors.Is(err, fs.ErrNotExist) {
 			log.Printf("WARNING: Audio file specified in config does not exist: %s", appConfig.Mock.AudioWavPath)
 			log.Printf("WARNING: Audio playback will fail if this path is used.")
+			return fmt.Errorf("audio file does not exist: %s", appConfig.Mock.AudioWavPath)
+		} else if err == nil {
+			f.Close()
+		}
+		log.Printf("Audio file found: %s", appConfig.Mock.AudioWavPath)
+		if err := validateWavFormat(appConfig.Mock.AudioWavPath); err != nil {
+			log.Printf("WARNING: Audio file validation failed: %v", err)
 		} else {
-			log.Printf("Audio file found: %s", appConfig.Mock.AudioWavPath)
-			if err := validateWavFormat(appConfig.Mock.AudioWavPath); err != nil {
-				log.Printf("WARNING: Audio file validation failed: %v", err)
-			} else {
-				log.Printf("Audio file format validated: 24kHz PCM16")
-			}
+			log.Printf("Audio file format validated: 24kHz PCM16")
 		}
+		if err := audioCache.Preload(audioAsset{appConfig.Mock.AudioWavPath, appConfig.Mock.AudioNormalize}, chunkSize, out); err != nil {
+			log.Printf("WARNING: %v", err)
+			return err
+		}
+		checkVoiceAudioDirs(chunkSize, out)
 	} else {
 		log.Printf("WARNING: No audioWavPath configured. Audio playback will not occur.")
 	}
+	return nil
 }
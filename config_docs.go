@@ -0,0 +1,244 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- Default Config ---
+
+// The default config is generated from the config structs, commented with the comments of
+// their fields in the source, which is built in for that.
+
+//go:embed *.go
+var configSources embed.FS
+
+// defaultConfig returns the defaults the server runs with, spelled out, and the example scenario.
+func defaultConfig() Config {
+	return Config{
+		Server: ServerConfig{Port: 8080, AccessLog: AccessLogConfig{Level: accessLogErrors, Format: accessLogText}},
+		Mock: MockConfig{
+			AudioWavPath:        defaultAudioAsset,
+			ChunkIntervalMs:     100,
+			AudioChunkSizeBytes: 4096,
+			TranscriptSync:      "none",
+			ReplayMode:          replayModeStream,
+			ReplayTurns:         "auto",
+			ReplayWelcome:       replayWelcomeBoth,
+			AudioNormalize:      "none",
+			ResponseMarker:      "none",
+			AudioProfile:        "default",
+			AudioCacheMaxMB:     256,
+			ResumeTTLSeconds:    defaultResumeTTLSeconds,
+		},
+		Proxy: ProxyConfig{
+			URL:           "wss://api.openai.com/v1/realtime",
+			Model:         defaultProxyModel,
+			RecordingPath: "recordings",
+			RecordingMode: "split",
+			APIVersion:    apiVersionBeta,
+		},
+		Mode:      "mock",
+		Scenarios: []Scenario{exampleScenario()},
+	}
+}
+
+// exampleScenario shows the event types of scenarios.
+func exampleScenario() Scenario {
+	return Scenario{
+		Name: "example",
+		Events: []Event{
+			{Type: "user_transcription", DelayMs: 200, Text: "What's the weather in Paris?"},
+			{Type: "function_call", DelayMs: 500, FunctionCall: &FunctionCallDefinition{Name: "get_weather", Arguments: `{"city": "Paris"}`}},
+			{Type: "message", DelayMs: 1000, Text: "It is sunny in Paris today."},
+		},
+	}
+}
+
+// sourceDocs returns the comments of struct types ("Type") and their fields ("Type.Field")
+// in the built-in source.
+func sourceDocs() (map[string]string, error) {
+	docs := map[string]string{}
+	files, err := fs.Glob(configSources, "*.go")
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		data, err := configSources.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		file, err := parser.ParseFile(fset, name, data, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := typeSpec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				docs[typeSpec.Name.Name] = doc.Text()
+				for _, field := range structType.Fields.List {
+					for _, fieldName := range field.Names {
+						docs[typeSpec.Name.Name+"."+fieldName.Name] = field.Doc.Text() + field.Comment.Text()
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
+
+// commentLines turns source comment text into YAML comment lines.
+func commentLines(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "# " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// yamlKey returns the key of a struct field in YAML config files, "" for fields that have none.
+// Fields without a yaml tag have their lowercased name, like yaml.v3 decodes them.
+func yamlKey(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	if _, ok := field.Tag.Lookup("yaml"); !ok {
+		return strings.ToLower(field.Name)
+	}
+	return configKey(field)
+}
+
+// configNode builds the YAML of a config value. With all, settings left empty are written too
+// (lists and their items only get what is set).
+func configNode(v reflect.Value, docs map[string]string, all bool) (*yaml.Node, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := yamlKey(field)
+			if key == "" {
+				continue
+			}
+			value := v.Field(i)
+			if !all && value.IsZero() {
+				continue
+			}
+			valueNode, err := configNode(value, docs, all)
+			if err != nil {
+				return nil, err
+			}
+			doc := docs[v.Type().Name()+"."+field.Name]
+			if doc == "" {
+				doc = docs[indirectType(field.Type).Name()]
+			}
+			keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key, HeadComment: commentLines(doc)}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node, nil
+	case reflect.Pointer:
+		if !v.IsNil() {
+			return configNode(v.Elem(), docs, all)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Struct {
+			// Each setting is explained once per list, above the item that has it first
+			node := &yaml.Node{Kind: yaml.SequenceNode}
+			explained := map[string]bool{}
+			for i := 0; i < v.Len(); i++ {
+				item, err := configNode(v.Index(i), docs, false)
+				if err != nil {
+					return nil, err
+				}
+				for j := 0; j < len(item.Content); j += 2 {
+					key := item.Content[j]
+					if explained[key.Value] {
+						key.HeadComment = ""
+					}
+					explained[key.Value] = true
+				}
+				if len(item.Content) > 0 {
+					item.HeadComment, item.Content[0].HeadComment = item.Content[0].HeadComment, ""
+				}
+				node.Content = append(node.Content, item)
+			}
+			if v.Len() == 0 {
+				node.Style = yaml.FlowStyle
+			}
+			return node, nil
+		}
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(v.Interface()); err != nil {
+		return nil, err
+	}
+	if (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && len(node.Content) == 0 {
+		node.Style = yaml.FlowStyle
+	}
+	return node, nil
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	return t
+}
+
+// writeCommentedYAML writes v as YAML commented from the source, under a header comment.
+func writeCommentedYAML(w io.Writer, header string, v any, all bool) error {
+	docs, err := sourceDocs()
+	if err != nil {
+		return fmt.Errorf("failed to read the config docs: %w", err)
+	}
+	node, err := configNode(reflect.ValueOf(v), docs, all)
+	if err != nil {
+		return err
+	}
+	node.HeadComment = commentLines(header)
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// printDefaultConfig writes the default config with every setting, for -print-default-config.
+func printDefaultConfig(w io.Writer) error {
+	header := "Default configuration of openai-realtime-mock with every setting.\n" +
+		"Empty values are unset; settings can be left out to keep their defaults."
+	return writeCommentedYAML(w, header, defaultConfig(), true)
+}
+
+// printExampleScenario writes the example scenario as an item of scenarios, for -print-example-scenario.
+func printExampleScenario(w io.Writer) error {
+	header := "Example scenario, add it under scenarios: in the config."
+	return writeCommentedYAML(w, header, []Scenario{exampleScenario()}, false)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// --- Environment Overrides ---
+
+// Every setting of the config file can be overridden with an environment variable named after its
+// path: MOCK_ followed by the keys in upper snake case, e.g. server.port is MOCK_SERVER_PORT and
+// proxy.rotation.maxSizeMB is MOCK_PROXY_ROTATION_MAX_SIZE_MB. Settings of the mock section leave
+// out its name: mock.audioWavPath is MOCK_AUDIO_WAV_PATH. Lists of strings are comma-separated,
+// maps of strings are key=value pairs separated by commas. Lists and maps of objects (scenarios,
+// proxy.targets, ...) can only be set in the file.
+const envOverridePrefix = "MOCK"
+
+// envKey turns a config key into its part of a variable name: audioWavPath -> AUDIO_WAV_PATH, maxSizeMB -> MAX_SIZE_MB.
+func envKey(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// configKey returns the key of a struct field in the config file, "" for fields that have none.
+func configKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "-" || !field.IsExported() {
+		return ""
+	}
+	return key
+}
+
+// applyEnvOverrides sets the config values given in the environment. It runs after the file was
+// parsed and before validation, so overridden values are validated like the rest.
+func applyEnvOverrides(cfg *Config) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := configKey(v.Type().Field(i))
+		if key == "" {
+			continue
+		}
+		env := envOverridePrefix + "_" + envKey(key)
+		if key == "mock" {
+			env = envOverridePrefix
+		}
+		if err := overrideFromEnv(v.Field(i), key, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overrideFromEnv sets v, found at path in the config, from the variable env or, for objects,
+// from variables starting with env.
+func overrideFromEnv(v reflect.Value, path, env string) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			key := configKey(v.Type().Field(i))
+			if key == "" {
+				continue
+			}
+			if err := overrideFromEnv(v.Field(i), path+"."+key, env+"_"+envKey(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Pointer:
+		if v.Type().Elem().Kind() == reflect.Struct && !hasEnvPrefix(env+"_") {
+			return nil // Left unset unless one of its fields is given
+		}
+		if v.Type().Elem().Kind() != reflect.Struct {
+			if _, ok := os.LookupEnv(env); !ok {
+				return nil
+			}
+		}
+		target := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			target.Elem().Set(v.Elem())
+		}
+		if err := overrideFromEnv(target.Elem(), path, env); err != nil {
+			return err
+		}
+		v.Set(target)
+		return nil
+	}
+
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return nil
+	}
+	if err := setFromString(v, value); err != nil {
+		return fmt.Errorf("%s (%s): %w", env, path, err)
+	}
+	log.Printf("Config: %s set from %s", path, env)
+	return nil
+}
+
+func hasEnvPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFromString parses value into a setting of a basic type, a list of strings or a map of strings.
+func setFromString(v reflect.Value, value string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("lists of objects can only be set in the config file")
+		}
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = reflect.Append(list, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(list)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("maps of objects can only be set in the config file")
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid key=value pair %q", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)).Convert(v.Type().Key()), reflect.ValueOf(strings.TrimSpace(val)).Convert(v.Type().Elem()))
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("can only be set in the config file")
+	}
+	return nil
+}
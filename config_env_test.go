@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"port", "PORT"},
+		{"audioWavPath", "AUDIO_WAV_PATH"},
+		{"maxSizeMB", "MAX_SIZE_MB"},
+		{"allowedUrls", "ALLOWED_URLS"},
+		{"caFile", "CA_FILE"},
+		{"baseURLPath", "BASE_URL_PATH"},
+		{"gzip2Level", "GZIP2_LEVEL"},
+		{"silence_duration_ms", "SILENCE_DURATION_MS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := envKey(tt.key); got != tt.want {
+				t.Errorf("envKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	intPtr := func(n int) *int { return &n }
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		get  func(cfg *Config) interface{}
+		want interface{}
+	}{
+		{"environment over file", "server:\n  port: 9000\n", map[string]string{"MOCK_SERVER_PORT": "9100"}, func(c *Config) interface{} { return c.Server.Port }, 9100},
+		{"file without variable", "server:\n  port: 9000\n", nil, func(c *Config) interface{} { return c.Server.Port }, 9000},
+		{"default without either", "", nil, func(c *Config) interface{} { return c.Server.Port }, 8080},
+		{"top-level key", "", map[string]string{"MOCK_MODE": "proxy"}, func(c *Config) interface{} { return c.Mode }, "proxy"},
+		{"boolean", "", map[string]string{"MOCK_LOG_INBOUND": "true"}, func(c *Config) interface{} { return c.LogInbound }, true},
+		{"mock section without its name", "", map[string]string{"MOCK_RESPONSE_DELAY_SECONDS": "3"}, func(c *Config) interface{} { return c.Mock.ResponseDelaySeconds }, 3},
+		{"nested section", "", map[string]string{"MOCK_PROXY_ROTATION_MAX_SIZE_MB": "5"}, func(c *Config) interface{} { return c.Proxy.Rotation.MaxSizeMB }, 5},
+		{"list of strings", "proxy:\n  allowedModels: [gpt-4o]\n", map[string]string{"MOCK_PROXY_ALLOWED_MODELS": "gpt-realtime, gpt-realtime-mini,"}, func(c *Config) interface{} { return c.Proxy.AllowedModels }, []string{"gpt-realtime", "gpt-realtime-mini"}},
+		{"map of strings", "", map[string]string{"MOCK_PROXY_HEADERS": "X-Team=voice, X-Env=ci"}, func(c *Config) interface{} { return c.Proxy.Headers }, map[string]string{"X-Team": "voice", "X-Env": "ci"}},
+		{"object created for its field", "", map[string]string{"MOCK_TURN_DETECTION_SILENCE_DURATION_MS": "300"}, func(c *Config) interface{} { return c.Mock.TurnDetection }, &TurnDetection{SilenceDurationMs: intPtr(300)}},
+		{
+			"object of the file kept",
+			"mock:\n  turnDetection:\n    type: server_vad\n    silence_duration_ms: 500\n",
+			map[string]string{"MOCK_TURN_DETECTION_SILENCE_DURATION_MS": "300"},
+			func(c *Config) interface{} { return c.Mock.TurnDetection },
+			&TurnDetection{Type: "server_vad", SilenceDurationMs: intPtr(300)},
+		},
+		{"object left unset", "", nil, func(c *Config) interface{} { return c.Mock.TurnDetection }, (*TurnDetection)(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := parseConfig([]byte(tt.file), "yaml", t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.get(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvOverrideErrors(t *testing.T) {
+	tests := []struct {
+		name, env, value, err string
+	}{
+		{"invalid integer", "MOCK_SERVER_PORT", "eighty", "MOCK_SERVER_PORT (server.port): invalid integer"},
+		{"invalid boolean", "MOCK_LOG_INBOUND", "maybe", "MOCK_LOG_INBOUND (logInbound): invalid boolean"},
+		{"invalid pair", "MOCK_PROXY_HEADERS", "X-Team", `invalid key=value pair "X-Team"`},
+		{"list of objects", "MOCK_SCENARIOS", "greeting", "lists of objects can only be set in the config file"},
+		{"validated like the file", "MOCK_SERVER_ADMIN_PORT", "70000", "server.adminPort must be a port number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.env, tt.value)
+			if _, err := parseConfig(nil, "yaml", t.TempDir()); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
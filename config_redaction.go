@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// --- Config Redaction ---
+
+// GET /config returns a sanitized copy of the config unless the admin asks for ?full=true.
+// Settings tagged redact:"..." are masked, by kind:
+//   - "secret": replaced by [REDACTED]; in maps the values are
+//   - "url": the password and query parameter values are masked, scheme, host and path stay
+//   - "path": only the file name stays, the directories are left out
+const (
+	redactSecret = "secret"
+	redactURL    = "url"
+	redactPath   = "path"
+)
+
+// redactedConfig returns a copy of cfg with its tagged settings masked.
+func redactedConfig(cfg Config) (Config, error) {
+	// A round trip through JSON copies the maps and slices, so the running config is untouched
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return Config{}, err
+	}
+	var copied Config
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return Config{}, err
+	}
+	redactSettings(reflect.ValueOf(&copied).Elem(), "")
+	return copied, nil
+}
+
+// redactSettings masks v, and the settings in it, as kind says.
+func redactSettings(v reflect.Value, kind string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.IsExported() {
+				redactSettings(v.Field(i), field.Tag.Get("redact"))
+			}
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			redactSettings(v.Elem(), kind)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			redactSettings(v.Index(i), kind)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			redactSettings(elem, kind)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.String:
+		if kind != "" && v.String() != "" {
+			v.SetString(redactSetting(kind, v.String()))
+		}
+	}
+}
+
+func redactSetting(kind, value string) string {
+	switch kind {
+	case redactURL:
+		u, err := url.Parse(value)
+		if err != nil {
+			return redactedValue
+		}
+		var params []string
+		for name := range u.Query() {
+			params = append(params, url.QueryEscape(name)+"="+redactedValue)
+		}
+		sort.Strings(params)
+		u.RawQuery = strings.Join(params, "&")
+		return u.Redacted()
+	case redactPath:
+		return filepath.Base(value)
+	}
+	return redactedValue
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// --- Config Reload ---
+
+// configMu guards appConfig while the server runs: reloads and the admin API replace it.
+var configMu sync.RWMutex
+
+// currentConfig returns the running config. It must not be modified.
+func currentConfig() *Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return appConfig
+}
+
+type configSnapshotKey struct{}
+
+// withConfigSnapshot pins the running config to a request, so everything serving it sees one
+// config for as long as the session lasts, even when the config is reloaded meanwhile.
+func withConfigSnapshot(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), configSnapshotKey{}, currentConfig()))
+}
+
+// requestConfig returns the config pinned to a request, or the running config.
+func requestConfig(r *http.Request) *Config {
+	if cfg, ok := r.Context().Value(configSnapshotKey{}).(*Config); ok {
+		return cfg
+	}
+	return currentConfig()
+}
+
+// configFilePath is the config file the server was started with, read again on SIGHUP.
+var configFilePath string
+
+// restartSetting is a part of the config that is only applied at startup.
+type restartSetting struct {
+	name   string
+	field  func(*Config) any
+	reject bool // Changing it fails the reload, otherwise the running value is kept until a restart
+}
+
+var restartSettings = []restartSetting{
+	{"server.port", func(c *Config) any { return &c.Server.Port }, true},
+	{"server.adminPort", func(c *Config) any { return &c.Server.AdminPort }, true},
+	{"server.tls", func(c *Config) any { return &c.Server.TLS }, false},
+	{"mock.audioCacheMaxMB", func(c *Config) any { return &c.Mock.AudioCacheMaxMB }, false},
+	{"mock.transcription", func(c *Config) any { return &c.Mock.Transcription }, false},
+	{"proxy.outbound", func(c *Config) any { return &c.Proxy.Outbound }, false},
+	{"proxy.storage", func(c *Config) any { return &c.Proxy.Storage }, false},
+	{"proxy.encryption", func(c *Config) any { return &c.Proxy.Encryption }, false},
+	{"proxy.retention", func(c *Config) any { return &c.Proxy.Retention }, false},
+}
+
+// ConfigReload is the answer to PUT /config.
+type ConfigReload struct {
+	Scenarios int      `json:"scenarios"`
+	Deferred  []string `json:"deferred,omitempty"` // Changed settings that take effect after a restart
+}
+
+// reloadConfig swaps in a validated config. Sessions already running keep the config they started
+// with; new sessions and requests get the new one. A different port fails
+// the reload, other startup settings keep their running values.
+func reloadConfig(next *Config) (*ConfigReload, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	reload := &ConfigReload{Scenarios: len(next.Scenarios)}
+	for _, setting := range restartSettings {
+		current, changed := setting.field(appConfig), setting.field(next)
+		if reflect.DeepEqual(current, changed) {
+			continue
+		}
+		if setting.reject {
+			return nil, fmt.Errorf("%s can't change without a restart", setting.name)
+		}
+		reflect.ValueOf(changed).Elem().Set(reflect.ValueOf(current).Elem())
+		reload.Deferred = append(reload.Deferred, setting.name)
+	}
+	appConfig = next
+	resetUpstreamCheck() // The targets may have changed
+	return reload, nil
+}
+
+// reloadConfigFile reads the config file again.
+func reloadConfigFile() (*ConfigReload, error) {
+	data, err := readConfigFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	next, err := parseConfig(data, configFormat(configFilePath), filepath.Dir(configFilePath))
+	if err != nil {
+		return nil, err
+	}
+	return reloadConfig(next)
+}
+
+// watchConfigReload reloads the config file on SIGHUP. A config that fails to load is logged and ignored.
+func watchConfigReload() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Printf("SIGHUP received, reloading %s", configFilePath)
+			reload, err := reloadConfigFile()
+			if err != nil {
+				log.Printf("Config reload failed, keeping the running config: %v", err)
+				continue
+			}
+			reload.log()
+		}
+	}()
+}
+
+func (r *ConfigReload) log() {
+	log.Printf("Config reloaded: %d scenarios", r.Scenarios)
+	for _, name := range r.Deferred {
+		log.Printf("WARNING: %s changed, the new value takes effect after a restart", name)
+	}
+}
+
+// handlePutConfig replaces the running config with the config in the body, like a reload of the file.
+// It is YAML unless the Content-Type says JSON or TOML. Relative paths are resolved against the
+// directory of the config file.
+func handlePutConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	format := configFormatYAML
+	switch contentType := r.Header.Get("Content-Type"); {
+	case strings.Contains(contentType, "json"):
+		format = configFormatJSON
+	case strings.Contains(contentType, "toml"):
+		format = configFormatTOML
+	}
+	next, err := parseConfig(data, format, filepath.Dir(configFilePath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reload, err := reloadConfig(next)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	reload.log()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reload)
+}
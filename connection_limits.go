@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Connection Limits ---
+
+// Limits keep one runaway client, like a load test, from taking a shared mock down for everyone.
+// Refused handshakes get 429 Too Many Requests with Retry-After, or with closeOnLimit a WebSocket
+// that is closed right away with 1013 (try again later), which browsers can see.
+
+const defaultConnectionWindowSeconds = 60
+
+// ConnectionLimits caps the WebSockets of the server and of each client IP.
+type ConnectionLimits struct {
+	MaxConnections      int `yaml:"maxConnections" json:"maxConnections"`           // Concurrent WebSockets (0: unlimited)
+	MaxConnectionsPerIP int `yaml:"maxConnectionsPerIp" json:"maxConnectionsPerIp"` // Concurrent WebSockets of one client IP (0: unlimited)
+	HandshakesPerIP     int `yaml:"handshakesPerIp" json:"handshakesPerIp"`         // Handshakes of one client IP per window (0: unlimited)
+	WindowSeconds       int `yaml:"windowSeconds" json:"windowSeconds"`             // Sliding window of handshakesPerIp (default 60)
+	// Accept refused handshakes and close them with 1013 instead of answering 429
+	CloseOnLimit bool `yaml:"closeOnLimit" json:"closeOnLimit"`
+}
+
+func (c ConnectionLimits) validate() error {
+	if c.MaxConnections < 0 || c.MaxConnectionsPerIP < 0 || c.HandshakesPerIP < 0 || c.WindowSeconds < 0 {
+		return fmt.Errorf("server.limits values must not be negative")
+	}
+	return nil
+}
+
+func (c ConnectionLimits) window() time.Duration {
+	if c.WindowSeconds <= 0 {
+		return defaultConnectionWindowSeconds * time.Second
+	}
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// connectionCounter tracks the open WebSockets and recent handshakes.
+type connectionCounter struct {
+	mu         sync.Mutex
+	open       int
+	openByIP   map[string]int
+	handshakes map[string][]time.Time
+}
+
+var connections = &connectionCounter{openByIP: map[string]int{}, handshakes: map[string][]time.Time{}}
+
+// admit counts a connection of ip, or returns why it is refused and when to retry.
+func (c *connectionCounter) admit(ip string, limits ConnectionLimits) (reason string, retryAfter time.Duration) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	window := limits.window()
+	recent := c.handshakes[ip]
+	for len(recent) > 0 && !recent[0].After(now.Add(-window)) {
+		recent = recent[1:]
+	}
+	if len(recent) == 0 {
+		delete(c.handshakes, ip)
+	} else {
+		c.handshakes[ip] = recent
+	}
+
+	switch {
+	case limits.HandshakesPerIP > 0 && len(recent) >= limits.HandshakesPerIP:
+		return "too many handshakes", recent[0].Add(window).Sub(now)
+	case limits.MaxConnections > 0 && c.open >= limits.MaxConnections:
+		return "too many connections", time.Second
+	case limits.MaxConnectionsPerIP > 0 && c.openByIP[ip] >= limits.MaxConnectionsPerIP:
+		return "too many connections from " + ip, time.Second
+	}
+	if limits.HandshakesPerIP > 0 {
+		c.handshakes[ip] = append(recent, now)
+	}
+	c.open++
+	c.openByIP[ip]++
+	return "", 0
+}
+
+// release counts a connection of ip as closed.
+func (c *connectionCounter) release(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open--
+	if c.openByIP[ip]--; c.openByIP[ip] <= 0 {
+		delete(c.openByIP, ip)
+	}
+}
+
+// remoteIP returns the IP of the client, without its port.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// admitConnection applies server.limits to a WebSocket handshake. A refused handshake is answered,
+// and the caller returns; an admitted one is released when the caller is done with it.
+func admitConnection(w http.ResponseWriter, r *http.Request) (release func(), ok bool) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		return func() {}, true
+	}
+	limits := requestConfig(r).Server.Limits
+
+	ip := remoteIP(r)
+	reason, retryAfter := connections.admit(ip, limits)
+	if reason == "" {
+		return func() { connections.release(ip) }, true
+	}
+	log.Printf("Limits: Refused WebSocket from %s: %s", r.RemoteAddr, reason)
+	seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	if !limits.CloseOnLimit {
+		w.Header().Set("Retry-After", seconds)
+		http.Error(w, "Rate limit exceeded: "+reason, http.StatusTooManyRequests)
+		return nil, false
+	}
+	conn, err := upgrader.Upgrade(w, r, http.Header{"Retry-After": {seconds}})
+	if err != nil {
+		return nil, false
+	}
+	writeCloseFrame(conn, closeInfo{Code: websocket.CloseTryAgainLater, Reason: reason})
+	conn.Close()
+	return nil, false
+}
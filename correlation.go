@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Correlation IDs ---
+
+// Every WebSocket connection gets a correlation ID that prefixes its log lines, so the lines of
+// concurrent sessions can be told apart. Clients can bring their own in X-Correlation-ID; the ID
+// is returned in the handshake response and listed by GET /sessions.
+
+const correlationHeader = "X-Correlation-ID"
+
+// Client IDs are taken as they are if they are short and safe to print
+var validCorrelationID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+var validCorrelationField = regexp.MustCompile(`^[A-Za-z0-9_.-]*$`)
+
+func validateCorrelationField(field string) error {
+	if !validCorrelationField.MatchString(field) {
+		return fmt.Errorf("server.correlationField must be letters, digits, '_', '.' or '-': %q", field)
+	}
+	return nil
+}
+
+type correlationKey struct{}
+
+type correlation struct {
+	id     string
+	logger *log.Logger
+}
+
+// withCorrelationID gives the request of a connection its correlation ID and logger.
+func withCorrelationID(r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(correlationKey{}).(*correlation); ok {
+		return r
+	}
+	id := r.Header.Get(correlationHeader)
+	if !validCorrelationID.MatchString(id) {
+		b := make([]byte, 4)
+		rand.Read(b)
+		id = "c-" + hex.EncodeToString(b)
+	}
+	c := &correlation{id: id, logger: log.New(log.Writer(), "["+id+"] ", log.Flags()|log.Lmsgprefix)}
+	return r.WithContext(context.WithValue(r.Context(), correlationKey{}, c))
+}
+
+// correlationID returns the correlation ID of a request, "" outside connections.
+func correlationID(r *http.Request) string {
+	if c, ok := r.Context().Value(correlationKey{}).(*correlation); ok {
+		return c.id
+	}
+	return ""
+}
+
+// requestLog returns the logger of a connection's request, the standard logger outside connections.
+func requestLog(r *http.Request) *log.Logger {
+	if c, ok := r.Context().Value(correlationKey{}).(*correlation); ok {
+		return c.logger
+	}
+	return log.Default()
+}
+
+// withCorrelationHeader adds the correlation ID to the headers of a handshake response.
+func withCorrelationHeader(r *http.Request, header http.Header) http.Header {
+	id := correlationID(r)
+	if id == "" {
+		return header
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(correlationHeader, id)
+	return header
+}
+
+// newClientConn wraps the WebSocket of a client, logging and tagging events with its correlation ID.
+func newClientConn(conn *websocket.Conn, r *http.Request) *SafeWebSocket {
+	s := &SafeWebSocket{Conn: conn, logger: requestLog(r), correlationID: correlationID(r)}
+	if field := requestConfig(r).Server.CorrelationField; field != "" && s.correlationID != "" {
+		s.correlationPrefix = []byte(`{"` + field + `":"` + s.correlationID + `"`)
+	}
+	return s
+}
+
+// logf logs a line of the connection.
+func (s *SafeWebSocket) logf(format string, args ...any) {
+	if s.logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	s.logger.Printf(format, args...)
+}
+
+// logf logs a line of the session's connection.
+func (s *MockSession) logf(format string, args ...any) {
+	s.Conn.logf(format, args...)
+}
+
+// tagEvent adds the correlation field to an event sent to the client.
+func (s *SafeWebSocket) tagEvent(data []byte) []byte {
+	if s.correlationPrefix == nil || len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	tagged := append([]byte(nil), s.correlationPrefix...)
+	if data[1] != '}' {
+		tagged = append(tagged, ',')
+	}
+	return append(tagged, data[1:]...)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// --- Origins & CORS ---
+
+// allowedOrigin reports whether a browser page from the request's Origin may use the server.
+// Requests without an Origin (not from a browser) and from the server's own pages always may.
+// Without server.allowedOrigins every origin may connect, and REST responses get no CORS headers.
+func allowedOrigin(r *http.Request) bool {
+	origin, allowed := r.Header.Get("Origin"), requestConfig(r).Server.AllowedOrigins
+	if origin == "" || len(allowed) == 0 || sameOrigin(r, origin) {
+		return true
+	}
+	return matchOrigin(allowed, origin)
+}
+
+func sameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// matchOrigin matches an origin against patterns like https://app.example.com, https://*.example.com or *.
+func matchOrigin(patterns []string, origin string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(origin)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateAllowedOrigins(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("server.allowedOrigins has invalid pattern %s", pattern)
+		}
+	}
+	return nil
+}
+
+// withCORS refuses requests from origins that aren't allowed with 403 and adds CORS headers
+// for allowed cross-origin ones, answering their preflight requests.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin, allowed := r.Header.Get("Origin"), requestConfig(r).Server.AllowedOrigins
+		if origin == "" || len(allowed) == 0 || sameOrigin(r, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !matchOrigin(allowed, origin) {
+			http.Error(w, fmt.Sprintf("Origin %s is not allowed", origin), http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, OpenAI-Beta")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
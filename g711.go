@@ -0,0 +1,118 @@
+package main
+
+import "encoding/binary"
+
+// --- G.711 (mu-law / A-law) Codecs ---
+
+const (
+	ulawBias = 0x84
+	ulawClip = 32635
+)
+
+// linearToULaw encodes one 16-bit PCM sample as G.711 mu-law.
+func linearToULaw(sample int16) byte {
+	s := int(sample)
+	sign := 0
+	if s < 0 {
+		s = -s
+		sign = 0x80
+	}
+	if s > ulawClip {
+		s = ulawClip
+	}
+	s += ulawBias
+
+	exponent := 7
+	for mask := 0x4000; s&mask == 0 && exponent > 0; mask >>= 1 {
+		exponent--
+	}
+	mantissa := (s >> (exponent + 3)) & 0x0F
+	return ^byte(sign | exponent<<4 | mantissa)
+}
+
+// ulawToLinear decodes one G.711 mu-law byte to a 16-bit PCM sample.
+func ulawToLinear(u byte) int16 {
+	u = ^u
+	sign := u & 0x80
+	exponent := int(u>>4) & 0x07
+	mantissa := int(u & 0x0F)
+	s := ((mantissa << 3) + ulawBias) << exponent
+	s -= ulawBias
+	if sign != 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+// linearToALaw encodes one 16-bit PCM sample as G.711 A-law.
+func linearToALaw(sample int16) byte {
+	s := int(sample) >> 3 // A-law works on 13-bit samples
+	sign := 0x80
+	if s < 0 {
+		s = -s - 1
+		sign = 0
+	}
+	if s > 0xFFF {
+		s = 0xFFF
+	}
+
+	var out int
+	if s < 32 {
+		out = s >> 1
+	} else {
+		exponent := 1
+		for v := s >> 5; v > 1; v >>= 1 {
+			exponent++
+		}
+		out = exponent<<4 | (s>>exponent)&0x0F
+	}
+	return byte((out | sign) ^ 0x55)
+}
+
+// alawToLinear decodes one G.711 A-law byte to a 16-bit PCM sample.
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	sign := a & 0x80
+	exponent := int(a>>4) & 0x07
+	mantissa := int(a & 0x0F)
+
+	var s int
+	if exponent == 0 {
+		s = mantissa<<4 + 8
+	} else {
+		s = (mantissa<<4 + 0x108) << (exponent - 1)
+	}
+	if sign == 0 {
+		return int16(-s)
+	}
+	return int16(s)
+}
+
+// encodeG711 converts little-endian PCM16 audio to mu-law or A-law bytes.
+func encodeG711(pcm []byte, alaw bool) []byte {
+	out := make([]byte, len(pcm)/2)
+	for i := range out {
+		sample := int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+		if alaw {
+			out[i] = linearToALaw(sample)
+		} else {
+			out[i] = linearToULaw(sample)
+		}
+	}
+	return out
+}
+
+// decodeG711 converts mu-law or A-law bytes to little-endian PCM16 audio.
+func decodeG711(data []byte, alaw bool) []byte {
+	out := make([]byte, len(data)*2)
+	for i, b := range data {
+		var sample int16
+		if alaw {
+			sample = alawToLinear(b)
+		} else {
+			sample = ulawToLinear(b)
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(sample))
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+var g711Codecs = []struct {
+	name   string
+	alaw   bool
+	encode func(int16) byte
+	decode func(byte) int16
+	zero   byte
+}{
+	{"mu-law", false, linearToULaw, ulawToLinear, 0xFF},
+	{"A-law", true, linearToALaw, alawToLinear, 0xD5},
+}
+
+func TestG711CodeRoundTrip(t *testing.T) {
+	for _, codec := range g711Codecs {
+		t.Run(codec.name, func(t *testing.T) {
+			for b := range 256 {
+				// mu-law has a negative zero, which encodes as positive zero
+				if !codec.alaw && b == 0x7F {
+					continue
+				}
+				if got := codec.encode(codec.decode(byte(b))); got != byte(b) {
+					t.Errorf("encode(decode(%#02x)) = %#02x", b, got)
+				}
+			}
+			if got := codec.encode(0); got != codec.zero {
+				t.Errorf("encode(0) = %#02x, want %#02x", got, codec.zero)
+			}
+		})
+	}
+}
+
+func TestG711SampleRoundTrip(t *testing.T) {
+	for _, codec := range g711Codecs {
+		t.Run(codec.name, func(t *testing.T) {
+			prev := int16(-32768)
+			for s := -32768; s <= 32767; s++ {
+				got := codec.decode(codec.encode(int16(s)))
+				// Companding keeps about 4 bits of mantissa, quiet samples are exact to a few steps
+				diff, limit := int(got)-s, max(s/16, -s/16, 8)
+				if diff > limit || -diff > limit {
+					t.Fatalf("sample %d decodes as %d, off by more than %d", s, got, limit)
+				}
+				if got < prev {
+					t.Fatalf("sample %d decodes as %d, below %d of the previous sample", s, got, prev)
+				}
+				prev = got
+			}
+		})
+	}
+}
+
+func TestG711Buffers(t *testing.T) {
+	samples := []int16{0, 1000, -1000, 32767, -32768, 8}
+	pcm := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(s))
+	}
+	for _, codec := range g711Codecs {
+		t.Run(codec.name, func(t *testing.T) {
+			encoded := encodeG711(pcm, codec.alaw)
+			if len(encoded) != len(samples) {
+				t.Fatalf("encoded %d samples to %d bytes", len(samples), len(encoded))
+			}
+			for i, s := range samples {
+				if want := codec.encode(s); encoded[i] != want {
+					t.Errorf("byte %d = %#02x, want %#02x", i, encoded[i], want)
+				}
+			}
+
+			decoded := decodeG711(encoded, codec.alaw)
+			if len(decoded) != len(pcm) {
+				t.Fatalf("decoded %d bytes to %d bytes of PCM16", len(encoded), len(decoded))
+			}
+			for i, b := range encoded {
+				if got := int16(binary.LittleEndian.Uint16(decoded[i*2:])); got != codec.decode(b) {
+					t.Errorf("sample %d = %d, want %d", i, got, codec.decode(b))
+				}
+			}
+
+			// A trailing odd byte is not a whole sample and is dropped
+			if odd := encodeG711(append(bytes.Clone(pcm), 0x7F), codec.alaw); !bytes.Equal(odd, encoded) {
+				t.Errorf("odd-length input encoded to %x, want %x", odd, encoded)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// --- Gain & Normalization ---
+
+const (
+	normalizePeakDBFS = -1.0  // Target peak level for "peak" normalization
+	normalizeRMSDBFS  = -20.0 // Target RMS level for "rms" normalization
+)
+
+// dbfsToLinear converts a level in dBFS to a PCM16 sample magnitude.
+func dbfsToLinear(db float64) float64 {
+	return math.Pow(10, db/20) * math.MaxInt16
+}
+
+// normalizeGain returns the linear gain that brings PCM16 audio to the target level of the
+// normalization mode ("peak" or "rms"), or 1 for other modes and silent audio.
+func normalizeGain(pcm []byte, mode string) float64 {
+	var level, target float64
+	switch mode {
+	case "peak":
+		for i := 0; i+1 < len(pcm); i += 2 {
+			level = math.Max(level, math.Abs(float64(int16(binary.LittleEndian.Uint16(pcm[i:])))))
+		}
+		target = dbfsToLinear(normalizePeakDBFS)
+	case "rms":
+		level = pcm16RMS(pcm) * 32768
+		target = dbfsToLinear(normalizeRMSDBFS)
+	default:
+		return 1
+	}
+	if level == 0 {
+		return 1
+	}
+	return target / level
+}
+
+// applyGain normalizes PCM16 audio and then applies gainDb on top, returning a copy.
+// Samples that would clip are limited to the PCM16 range.
+func applyGain(pcm []byte, normalize string, gainDb float64) []byte {
+	gain := normalizeGain(pcm, normalize) * math.Pow(10, gainDb/20)
+	out := make([]byte, len(pcm))
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) * gain
+		v = math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(v)))
+		binary.LittleEndian.PutUint16(out[i:], uint16(int16(v)))
+	}
+	return out
+}
+
+// needsGain reports whether a normalization mode or gain changes the audio.
+func needsGain(normalize string, gainDb float64) bool {
+	return (normalize != "" && normalize != "none") || gainDb != 0
+}
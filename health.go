@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// --- Liveness & Readiness ---
+
+// readiness tracks what an instance needs before it should get traffic: the audio assets preloaded
+// and, in proxy and vcr mode, an upstream dial check that passed. The config is loaded before the
+// server listens, so it is always there.
+var readiness struct {
+	mu          sync.Mutex
+	assetsReady bool
+	assetsErr   string // Why the audio assets failed to load, fails readiness in mock mode
+	upstream    upstreamCheck
+}
+
+// upstreamCheckTTL is how long the result of an upstream dial check is served before the next
+// probe starts another one.
+const upstreamCheckTTL = 30 * time.Second
+
+// upstreamCheck is the result of the last upstream dial check.
+type upstreamCheck struct {
+	err        error
+	at         time.Time // Zero until a check finished
+	running    bool
+	generation int // Counts resets, results of checks started before one are dropped
+}
+
+var errUpstreamChecking = errors.New("checking")
+
+// markAssetsLoaded records the end of the audio preload, with the error that made it fail.
+func markAssetsLoaded(err error) {
+	readiness.mu.Lock()
+	defer readiness.mu.Unlock()
+	readiness.assetsReady = true
+	if err != nil {
+		readiness.assetsErr = err.Error()
+	}
+}
+
+// upstreamReady returns the result of the last upstream dial check, errUpstreamChecking before
+// the first one finished. Once the result is older than upstreamCheckTTL it starts a new check in
+// the background, so probes never wait for a dial and at most one check runs at a time.
+func upstreamReady() error {
+	readiness.mu.Lock()
+	defer readiness.mu.Unlock()
+	check := &readiness.upstream
+	if !check.running && time.Since(check.at) >= upstreamCheckTTL {
+		check.running = true
+		go refreshUpstreamCheck(check.generation)
+	}
+	if check.at.IsZero() {
+		return errUpstreamChecking
+	}
+	return check.err
+}
+
+// refreshUpstreamCheck dials the upstream targets and stores the result, unless the check was
+// reset meanwhile.
+func refreshUpstreamCheck(generation int) error {
+	err := checkUpstream()
+	readiness.mu.Lock()
+	defer readiness.mu.Unlock()
+	if readiness.upstream.generation == generation {
+		readiness.upstream = upstreamCheck{err: err, at: time.Now(), generation: generation}
+	}
+	return err
+}
+
+// resetUpstreamCheck drops the last result after the targets or the mode may have changed.
+func resetUpstreamCheck() {
+	readiness.mu.Lock()
+	defer readiness.mu.Unlock()
+	readiness.upstream = upstreamCheck{generation: readiness.upstream.generation + 1}
+}
+
+// handleHealthz is the liveness probe: it answers 200 as long as the server handles requests.
+// Missing assets or an unreachable upstream only fail /readyz, so they don't get the instance restarted.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"mode":   currentMode(),
+	})
+}
+
+// handleReadyz is the readiness probe: it answers 503 with the reason until the audio assets are
+// loaded and, in proxy and vcr mode, the upstream can be reached and authenticated against.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready := map[string]interface{}{
+		"status": "ok",
+		"mode":   currentMode(),
+	}
+	status := http.StatusOK
+	fail := func(check, reason string) {
+		ready["status"] = "error"
+		ready[check] = reason
+		status = http.StatusServiceUnavailable
+	}
+
+	readiness.mu.Lock()
+	assetsReady, assetsErr := readiness.assetsReady, readiness.assetsErr
+	readiness.mu.Unlock()
+	switch {
+	case !assetsReady:
+		fail("audio", "loading")
+	case assetsErr != "" && currentMode() == "mock":
+		fail("audio", assetsErr)
+	default:
+		ready["audio"] = "ok"
+	}
+
+	if mode := currentMode(); mode == "proxy" || mode == "vcr" {
+		if err := upstreamReady(); err != nil {
+			fail("upstream", err.Error())
+		} else {
+			ready["upstream"] = "ok"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ready)
+}
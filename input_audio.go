@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// --- Input Audio Handling ---
+
+// InputAudioAppendEvent is the client event that appends base64 audio to the input buffer.
+type InputAudioAppendEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id,omitempty"`
+	Audio   string `json:"audio"`
+}
+
+// isSupportedAudioFormat reports whether the mock understands an audio format name.
+func isSupportedAudioFormat(format string) bool {
+	switch format {
+	case "pcm16", "audio/pcm", "g711_ulaw", "g711_alaw", "audio/pcmu", "audio/pcma":
+		return true
+	}
+	return false
+}
+
+// decodeInputAudio decodes a base64 audio payload and checks that it is plausible
+// for the declared input audio format.
+func decodeInputAudio(audio string, format string) ([]byte, error) {
+	if audio == "" {
+		return nil, fmt.Errorf("audio payload is empty")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(audio)
+	if err != nil {
+		return nil, fmt.Errorf("audio is not valid base64: %w", err)
+	}
+
+	switch format {
+	case "", "pcm16", "audio/pcm":
+		// PCM16 samples are 2 bytes each, so an odd length means a truncated or garbage payload
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("pcm16 audio must have an even number of bytes, got %d", len(data))
+		}
+	case "g711_ulaw", "g711_alaw", "audio/pcmu", "audio/pcma":
+		// One byte per sample, any length is valid
+	default:
+		return nil, fmt.Errorf("unsupported input audio format: %s", format)
+	}
+
+	return data, nil
+}
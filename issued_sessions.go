@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// --- Issued Sessions ---
+
+// Settings posted to /v1/realtime/sessions are kept under the client secret issued for them
+// and become the initial state of the session that connects with that secret.
+
+// issuedSessionTTL is how long a client secret can be used to connect.
+const issuedSessionTTL = time.Minute
+
+// SessionRequest is the body of POST /v1/realtime/sessions. Unset fields keep the mock defaults.
+type SessionRequest struct {
+	Model             string   `json:"model,omitempty"`
+	Voice             string   `json:"voice,omitempty"`
+	Modalities        []string `json:"modalities,omitempty"`
+	Instructions      string   `json:"instructions,omitempty"`
+	InputAudioFormat  string   `json:"input_audio_format,omitempty"`
+	OutputAudioFormat string   `json:"output_audio_format,omitempty"`
+}
+
+func (req SessionRequest) validate() error {
+	for param, format := range map[string]string{
+		"input_audio_format":  req.InputAudioFormat,
+		"output_audio_format": req.OutputAudioFormat,
+	} {
+		if format != "" && !isSupportedAudioFormat(format) {
+			return fmt.Errorf("Invalid '%s': unsupported value '%s'.", param, format)
+		}
+	}
+	for _, modality := range req.Modalities {
+		if modality != "audio" && modality != "text" {
+			return fmt.Errorf("Invalid 'modalities': unsupported value '%s'.", modality)
+		}
+	}
+	return nil
+}
+
+type issuedSession struct {
+	settings  SessionRequest
+	expiresAt time.Time
+}
+
+var (
+	issuedSessionsMu sync.Mutex
+	issuedSessions   = map[string]issuedSession{}
+)
+
+// issueSession stores the settings for a client secret, dropping secrets that have expired.
+func issueSession(secret string, settings SessionRequest, expiresAt time.Time) {
+	issuedSessionsMu.Lock()
+	defer issuedSessionsMu.Unlock()
+	now := time.Now()
+	for key, issued := range issuedSessions {
+		if now.After(issued.expiresAt) {
+			delete(issuedSessions, key)
+		}
+	}
+	issuedSessions[secret] = issuedSession{settings: settings, expiresAt: expiresAt}
+}
+
+// lookupIssuedSession returns the settings stored for a client secret that has not expired.
+func lookupIssuedSession(secret string) (SessionRequest, bool) {
+	if secret == "" {
+		return SessionRequest{}, false
+	}
+	issuedSessionsMu.Lock()
+	defer issuedSessionsMu.Unlock()
+	issued, ok := issuedSessions[secret]
+	if !ok || time.Now().After(issued.expiresAt) {
+		return SessionRequest{}, false
+	}
+	return issued.settings, true
+}
+
+// applySessionRequest sets the settings of a session request on a freshly created session.
+func (s *MockSession) applySessionRequest(req SessionRequest) {
+	if req.Model != "" {
+		s.Model = req.Model
+	}
+	if req.Voice != "" {
+		s.Voice = req.Voice
+	}
+	if len(req.Modalities) > 0 {
+		s.Modalities = slices.Clone(req.Modalities)
+	}
+	if req.Instructions != "" {
+		s.Instructions = req.Instructions
+	}
+	if req.InputAudioFormat != "" {
+		s.InputAudioFormat = req.InputAudioFormat
+	}
+	if req.OutputAudioFormat != "" {
+		s.OutputAudioFormat = req.OutputAudioFormat
+	}
+	s.logf("Client %s: Applied the settings of its client secret (model: %s, voice: %s)", s.Conn.RemoteAddr(), s.Model, s.Voice)
+}
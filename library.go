@@ -0,0 +1,448 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- Scenario Library ---
+
+// A library is a Git repository or an HTTP(S) archive with scenarios and recordings, shared by
+// several instances of the mock. It is fetched at startup and on POST /sync into a fresh
+// directory, checked, and only then swapped in, so a broken push never replaces a working library.
+
+const (
+	defaultLibraryDir        = "library"
+	defaultLibraryScenarios  = "scenarios"
+	defaultLibraryRecordings = "recordings"
+	librarySyncTimeout       = 5 * time.Minute
+)
+
+// LibraryConfig points at the scenario library, either git or archiveUrl.
+type LibraryConfig struct {
+	Git        string `yaml:"git" json:"git" redact:"url"`               // Repository cloned with the git binary
+	Ref        string `yaml:"ref" json:"ref"`                            // Branch or tag of git, default the default branch
+	ArchiveURL string `yaml:"archiveUrl" json:"archiveUrl" redact:"url"` // .tar.gz or .zip archive
+	// Headers of the archive download, e.g. Authorization. Values may reference env vars as ${VAR}.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" redact:"secret"`
+	Dir     string            `yaml:"dir" json:"dir" redact:"path"` // Where the library is kept (default library)
+	// Directories in the library with the scenario files (YAML or JSON, a scenario or a list of them)
+	// and the recordings (default scenarios and recordings)
+	ScenariosPath  string `yaml:"scenariosPath" json:"scenariosPath"`
+	RecordingsPath string `yaml:"recordingsPath" json:"recordingsPath"`
+}
+
+func (c LibraryConfig) enabled() bool {
+	return c.Git != "" || c.ArchiveURL != ""
+}
+
+func (c LibraryConfig) validate() error {
+	if c.Git != "" && c.ArchiveURL != "" {
+		return fmt.Errorf("library takes git or archiveUrl, not both")
+	}
+	for _, path := range []string{c.ScenariosPath, c.RecordingsPath} {
+		if path != "" && !filepath.IsLocal(path) {
+			return fmt.Errorf("library paths must stay inside the library: %s", path)
+		}
+	}
+	return nil
+}
+
+func (c LibraryConfig) dir() string {
+	return cmp.Or(c.Dir, defaultLibraryDir)
+}
+
+func (c LibraryConfig) recordingsDir() string {
+	return filepath.Join(c.dir(), cmp.Or(c.RecordingsPath, defaultLibraryRecordings))
+}
+
+func (c LibraryConfig) source() string {
+	return redactSetting(redactURL, cmp.Or(c.Git, c.ArchiveURL))
+}
+
+// LibrarySync describes the library in use, the answer of /sync.
+type LibrarySync struct {
+	Source     string    `json:"source"`
+	Revision   string    `json:"revision,omitempty"` // Commit of a Git library
+	Scenarios  int       `json:"scenarios"`
+	Recordings int       `json:"recordings"`
+	SyncedAt   time.Time `json:"synced_at"`
+	Error      string    `json:"error,omitempty"` // Why the last sync failed, the library before it is kept
+}
+
+// library holds the scenarios of the library in use.
+var library struct {
+	mu        sync.RWMutex
+	scenarios []Scenario
+	status    LibrarySync
+	syncing   sync.Mutex // One sync at a time
+}
+
+// libraryScenarios returns the scenarios of the library.
+func libraryScenarios() []Scenario {
+	library.mu.RLock()
+	defer library.mu.RUnlock()
+	return library.scenarios
+}
+
+// syncLibrary fetches the library, checks it and swaps it in.
+func syncLibrary(cfg LibraryConfig) (LibrarySync, error) {
+	library.syncing.Lock()
+	defer library.syncing.Unlock()
+
+	status, err := fetchLibrary(cfg)
+	library.mu.Lock()
+	defer library.mu.Unlock()
+	if err != nil {
+		library.status.Source = cfg.source()
+		library.status.Error = err.Error()
+		return library.status, err
+	}
+	library.status = status
+	return status, nil
+}
+
+func fetchLibrary(cfg LibraryConfig) (LibrarySync, error) {
+	dir := cfg.dir()
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return LibrarySync{}, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-")
+	if err != nil {
+		return LibrarySync{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), librarySyncTimeout)
+	defer cancel()
+	status := LibrarySync{Source: cfg.source(), SyncedAt: time.Now().UTC()}
+	root := filepath.Join(tmp, "library")
+	if cfg.Git != "" {
+		status.Revision, err = cloneLibrary(ctx, cfg, root)
+	} else {
+		err = downloadLibrary(ctx, cfg, root)
+	}
+	if err != nil {
+		return LibrarySync{}, err
+	}
+
+	scenarios, err := loadLibraryScenarios(filepath.Join(root, cmp.Or(cfg.ScenariosPath, defaultLibraryScenarios)))
+	if err != nil {
+		return LibrarySync{}, err
+	}
+	status.Scenarios = len(scenarios)
+	status.Recordings = countLibraryRecordings(filepath.Join(root, cmp.Or(cfg.RecordingsPath, defaultLibraryRecordings)))
+
+	// Swap the checked library in; open recordings of the old one stay readable until closed
+	old := filepath.Join(tmp, "old")
+	if err := os.Rename(dir, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return LibrarySync{}, err
+	}
+	if err := os.Rename(root, dir); err != nil {
+		os.Rename(old, dir)
+		return LibrarySync{}, err
+	}
+	library.mu.Lock()
+	library.scenarios = scenarios
+	library.mu.Unlock()
+	return status, nil
+}
+
+// cloneLibrary makes a shallow clone of the Git library and returns its commit.
+func cloneLibrary(ctx context.Context, cfg LibraryConfig, dir string) (string, error) {
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if cfg.Ref != "" {
+		args = append(args, "--branch", cfg.Ref)
+	}
+	args = append(args, "--", cfg.Git, dir)
+	if out, err := exec.CommandContext(ctx, "git", args...).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git clone %s failed: %v: %s", cfg.source(), err, strings.TrimSpace(string(out)))
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	os.RemoveAll(filepath.Join(dir, ".git"))
+	return strings.TrimSpace(string(out)), nil
+}
+
+// downloadLibrary unpacks the library archive into dir. An archive with one top-level directory,
+// like the archives of GitHub and GitLab, is unpacked from inside it.
+func downloadLibrary(ctx context.Context, cfg LibraryConfig, dir string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.ArchiveURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	client := &http.Client{Transport: sessionHTTPClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", cfg.source(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", cfg.source(), resp.Status)
+	}
+
+	archive, err := os.CreateTemp(filepath.Dir(dir), "archive-")
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	size, err := io.Copy(archive, resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", cfg.source(), err)
+	}
+
+	unpacked := dir + "-unpacked"
+	if isZipArchive(archive) {
+		err = unpackZip(archive, size, unpacked)
+	} else {
+		err = unpackTarGz(archive, unpacked)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", cfg.source(), err)
+	}
+	entries, err := os.ReadDir(unpacked)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return os.Rename(filepath.Join(unpacked, entries[0].Name()), dir)
+	}
+	return os.Rename(unpacked, dir)
+}
+
+func isZipArchive(f *os.File) bool {
+	magic := make([]byte, 4)
+	_, err := f.ReadAt(magic, 0)
+	return err == nil && string(magic) == "PK\x03\x04"
+}
+
+// archivePath returns where an archive entry is unpacked, refusing entries that leave dir.
+func archivePath(dir, name string) (string, error) {
+	name = strings.TrimPrefix(filepath.FromSlash(name), string(filepath.Separator))
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("archive entry %s is outside the archive", name)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func unpackFile(path string, mode os.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func unpackTarGz(f *os.File, dir string) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue // Directories are made as needed, links are left out
+		}
+		path, err := archivePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := unpackFile(path, header.FileInfo().Mode(), tr); err != nil {
+			return err
+		}
+	}
+}
+
+func unpackZip(f *os.File, size int64, dir string) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range zr.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		path, err := archivePath(dir, file.Name)
+		if err != nil {
+			return err
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = unpackFile(path, file.Mode(), rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadLibraryScenarios reads the scenario files of a library, a missing directory has none.
+// A file holds a scenario, a list of them, or a config-like document with scenarios:.
+func loadLibraryScenarios(dir string) ([]Scenario, error) {
+	var scenarios []Scenario
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found, err := parseScenarioFile(data)
+		if err != nil {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("library scenario file %s: %w", rel, err)
+		}
+		scenarios = append(scenarios, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := validateScenarios(scenarios); err != nil {
+		return nil, fmt.Errorf("library: %w", err)
+	}
+	return scenarios, nil
+}
+
+// parseScenarioFile decodes a scenario file. JSON is read as the YAML it is.
+func parseScenarioFile(data []byte) ([]Scenario, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 {
+		return nil, nil
+	}
+	doc := node.Content[0]
+	switch {
+	case doc.Kind == yaml.SequenceNode:
+		var scenarios []Scenario
+		err := doc.Decode(&scenarios)
+		return scenarios, err
+	case doc.Kind == yaml.MappingNode && slices.ContainsFunc(doc.Content, func(n *yaml.Node) bool { return n.Value == "scenarios" }):
+		var file struct {
+			Scenarios []Scenario `yaml:"scenarios"`
+		}
+		err := doc.Decode(&file)
+		return file.Scenarios, err
+	default:
+		var scenario Scenario
+		err := doc.Decode(&scenario)
+		return []Scenario{scenario}, err
+	}
+}
+
+func countLibraryRecordings(dir string) int {
+	count := 0
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && (strings.HasSuffix(path, ".ndjson") || strings.HasSuffix(path, ".ndjson.gz")) {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// syncLibraryAtStartup fetches the library before the server starts. Without the source, the
+// scenarios of the last sync on disk are used.
+func syncLibraryAtStartup() {
+	cfg := appConfig.Library
+	if !cfg.enabled() {
+		return
+	}
+	status, err := syncLibrary(cfg)
+	if err == nil {
+		log.Printf("Library: Synced %s (%s): %d scenarios, %d recordings", status.Source, cmp.Or(status.Revision, "archive"), status.Scenarios, status.Recordings)
+		return
+	}
+	log.Printf("WARNING: Library sync failed: %v", err)
+	scenarios, err := loadLibraryScenarios(filepath.Join(cfg.dir(), cmp.Or(cfg.ScenariosPath, defaultLibraryScenarios)))
+	if err != nil {
+		log.Printf("WARNING: Library in %s unusable: %v", cfg.dir(), err)
+		return
+	}
+	library.mu.Lock()
+	library.scenarios = scenarios
+	library.mu.Unlock()
+	log.Printf("Library: Using the %d scenarios of the last sync in %s", len(scenarios), cfg.dir())
+}
+
+// handleSync fetches the library again (POST) or tells how the last sync went (GET).
+func handleSync(w http.ResponseWriter, r *http.Request) {
+	cfg := currentConfig().Library
+	if !cfg.enabled() {
+		http.Error(w, "No library configured", http.StatusNotFound)
+		return
+	}
+	status := func() LibrarySync {
+		library.mu.RLock()
+		defer library.mu.RUnlock()
+		return library.status
+	}()
+	code := http.StatusOK
+	if r.Method == http.MethodPost {
+		var err error
+		if status, err = syncLibrary(cfg); err != nil {
+			log.Printf("Admin: Library sync failed: %v", err)
+			code = http.StatusBadGateway
+		} else {
+			log.Printf("Admin: Synced library %s: %d scenarios, %d recordings", status.Source, status.Scenarios, status.Recordings)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// --- Session Listing ---
+
+// LiveSessionInfo describes a connected session in GET /sessions.
+type LiveSessionInfo struct {
+	ID          string             `json:"id"`
+	RemoteAddr  string             `json:"remote_addr"`
+	Correlation string             `json:"correlation_id,omitempty"` // Prefix of the session's log lines
+	Mode        string             `json:"mode"`
+	Scenario    string             `json:"scenario,omitempty"`
+	Replay      string             `json:"replay,omitempty"`
+	Target      string             `json:"target,omitempty"`
+	Model       string             `json:"model,omitempty"`
+	SessionID   string             `json:"session_id,omitempty"` // Upstream session of a proxied session
+	ConnectedAt time.Time          `json:"connected_at"`
+	Recording   bool               `json:"recording"`
+	Observers   int                `json:"observers"`
+	Events      SessionEventCounts `json:"events"`
+}
+
+// SessionEventCounts counts the messages of a session by who sent them.
+type SessionEventCounts struct {
+	Client int64 `json:"client"`
+	Server int64 `json:"server"`
+}
+
+func (s *liveSession) info() LiveSessionInfo {
+	info := LiveSessionInfo{
+		ID:          s.ID,
+		RemoteAddr:  s.conn.RemoteAddr(),
+		Correlation: s.conn.correlationID,
+		Mode:        s.mode,
+		Scenario:    s.scenario,
+		Replay:      s.replay,
+		Target:      s.target,
+		Model:       s.model,
+		ConnectedAt: s.connectedAt,
+		Recording:   s.recording.isEnabled(),
+		Events:      SessionEventCounts{Client: s.conn.received.Load(), Server: s.conn.sent.Load()},
+	}
+	if s.usage != nil {
+		info.SessionID = proxyUsage.sessionID(s.usage)
+	}
+	s.mu.Lock()
+	info.Observers = len(s.observers)
+	s.mu.Unlock()
+	return info
+}
+
+// handleListSessions returns the connected sessions, oldest first.
+func handleListSessions(w http.ResponseWriter, r *http.Request) {
+	liveSessions.Lock()
+	sessions := make([]*liveSession, 0, len(liveSessions.byID))
+	for _, session := range liveSessions.byID {
+		sessions = append(sessions, session)
+	}
+	liveSessions.Unlock()
+
+	infos := make([]LiveSessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, session.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ConnectedAt.Before(infos[j].ConnectedAt) })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// --- Session Control ---
+
+// Time a client gets to answer a close frame from POST /sessions/{id}/close before the connection is dropped
+const adminCloseGrace = time.Second
+
+// handleCloseSession disconnects a live session: POST /sessions/{id}/close, optionally with
+// {"code": 1011, "reason": "..."}. Without a body the session is closed normally; code 1006
+// drops the connection without a close frame.
+func handleCloseSession(w http.ResponseWriter, r *http.Request) {
+	session := findLiveSession(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	info := closeInfo{Code: websocket.CloseNormalClosure, Reason: "session closed by the server"}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&info); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if info.Code < 1000 || info.Code > 4999 {
+		http.Error(w, fmt.Sprintf("invalid close code: %d", info.Code), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Admin: Closing session %s with code %d", session.ID, info.Code)
+	session.conn.WriteClose(info)
+	time.AfterFunc(adminCloseGrace, func() { session.conn.Close() })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":    session.ID,
+		"close": info,
+	})
+}
+
+// handleInjectEvent sends the server event in the body to the client of a live session:
+// POST /sessions/{id}/events with {"type": "error", ...}. An event_id is added if missing.
+// Observers see the event, recordings don't.
+func handleInjectEvent(w http.ResponseWriter, r *http.Request) {
+	session := findLiveSession(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	var event map[string]interface{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 10<<20)).Decode(&event); err != nil {
+		http.Error(w, fmt.Sprintf("invalid event: %v", err), http.StatusBadRequest)
+		return
+	}
+	eventType, _ := event["type"].(string)
+	if eventType == "" {
+		http.Error(w, "event needs a type", http.StatusBadRequest)
+		return
+	}
+	if _, ok := event["event_id"]; !ok {
+		event["event_id"] = uuid.NewString()
+	}
+
+	if err := sendJSONEvent(session.conn, event); err != nil {
+		http.Error(w, fmt.Sprintf("failed to send event: %v", err), http.StatusConflict)
+		return
+	}
+	log.Printf("Admin: Injected %s into session %s", eventType, session.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,12 +28,16 @@ type BaseEvent struct {
 }
 
 type SessionObject struct {
-	ID               string        `json:"id"`
-	Object           string        `json:"object"` // "realtime.session"
-	ClientSecret     *ClientSecret `json:"client_secret,omitempty"`
-	Model            string        `json:"model,omitempty"`
-	InputAudioFormat string        `json:"input_audio_format,omitempty"`
-	Modalities       []string      `json:"modalities,omitempty"`
+	ID                string         `json:"id"`
+	Object            string         `json:"object"` // "realtime.session"
+	ClientSecret      *ClientSecret  `json:"client_secret,omitempty"`
+	Model             string         `json:"model,omitempty"`
+	InputAudioFormat  string         `json:"input_audio_format,omitempty"`
+	OutputAudioFormat string         `json:"output_audio_format,omitempty"`
+	Modalities        []string       `json:"modalities,omitempty"`
+	Voice             string         `json:"voice,omitempty"`
+	Instructions      string         `json:"instructions,omitempty"`
+	TurnDetection     *TurnDetection `json:"turn_detection,omitempty"`
 }
 
 type ClientSecret struct {
@@ -42,15 +52,35 @@ type ConversationObject struct {
 
 type RecordedEvent struct {
 	Timestamp int64           `json:"timestamp"`
+	Direction string          `json:"direction,omitempty"` // "client" or "server" in combined recordings
+	Frame     string          `json:"frame,omitempty"`     // "binary" for binary frames (data is base64), "close" for close frames, empty for JSON
+	Bytes     int             `json:"bytes,omitempty"`     // Size of a binary frame whose data was stripped
 	Data      json.RawMessage `json:"data"`
+	Meta      *RecordMeta     `json:"meta,omitempty"`    // Written by the proxy with recordMetadata
+	CRC32     string          `json:"crc32,omitempty"`   // Of data, written with proxy.integrity.lineChecksums
+	Trigger   string          `json:"trigger,omitempty"` // On response.created: what an interactive replay waits for (turnTrigger*)
 }
 
+// RecordMeta describes a proxied message for latency and throughput analysis.
+type RecordMeta struct {
+	Seq       int64  `json:"seq"`        // Position in the session across both directions, starting at 1
+	Direction string `json:"direction"`  // "client" or "server", also in split recordings
+	Size      int    `json:"size"`       // Bytes as forwarded
+	ElapsedMs int64  `json:"elapsed_ms"` // Since the client connected
+}
+
+// Directions of messages in combined recordings
+const (
+	directionClient = "client"
+	directionServer = "server"
+)
+
 // --- Global Variables ---
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin:     func(r *http.Request) bool { return true }, // Allow all origins
+	CheckOrigin:     allowedOrigin, // server.allowedOrigins, all origins without it
 }
 
 // --- Safe WebSocket ---
@@ -58,11 +88,31 @@ var upgrader = websocket.Upgrader{
 type SafeWebSocket struct {
 	Conn *websocket.Conn
 	Mu   sync.Mutex
+
+	chaos *chaosInjector // Optional fault injection on outgoing text messages
+	tap   *liveSession   // Observers get a copy of every message read and written
+
+	sent, received atomic.Int64 // Messages written and read, for GET /sessions
+
+	// Correlation ID of the client connection, prefixed to its log lines
+	correlationID     string
+	logger            *log.Logger
+	correlationPrefix []byte // Start of the events with server.correlationField, nil without
 }
 
 func (s *SafeWebSocket) WriteMessage(messageType int, data []byte) error {
 	s.Mu.Lock()
 	defer s.Mu.Unlock()
+	s.sent.Add(1)
+	if messageType == websocket.TextMessage {
+		data = s.tagEvent(data)
+	}
+	if s.tap != nil {
+		s.tap.broadcast(directionServer, messageType, data)
+	}
+	if s.chaos != nil && messageType == websocket.TextMessage {
+		return s.chaos.deliver(data)
+	}
 	return s.Conn.WriteMessage(messageType, data)
 }
 
@@ -70,7 +120,19 @@ func (s *SafeWebSocket) ReadMessage() (messageType int, p []byte, err error) {
 	// ReadMessage is not concurrent-safe either, but usually we have one reader.
 	// If we needed concurrent reads, we'd lock here too.
 	// For now, we assume single reader loop.
-	return s.Conn.ReadMessage()
+	messageType, p, err = s.Conn.ReadMessage()
+	if err == nil {
+		s.received.Add(1)
+	}
+	if err == nil && s.tap != nil {
+		s.tap.broadcast(directionClient, messageType, p)
+	}
+	return messageType, p, err
+}
+
+// WriteClose sends a close frame, bypassing fault injection.
+func (s *SafeWebSocket) WriteClose(info closeInfo) error {
+	return writeCloseFrame(s.Conn, info)
 }
 
 func (s *SafeWebSocket) Close() error {
@@ -84,47 +146,126 @@ func (s *SafeWebSocket) RemoteAddr() string {
 // --- Main Function ---
 
 func main() {
-	initConfig()
+	if err := runCLI(os.Args[1:]); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
+
+// serve runs the server with the loaded config until it fails.
+func serve() {
+	// Shared scenarios and recordings
+	syncLibraryAtStartup()
 
 	// Setup HTTP Routes
 	router := setupRouter()
 
 	// Start Server
 	addr := fmt.Sprintf(":%d", appConfig.Server.Port)
-	log.Printf("Starting Simplified OpenAI Realtime Mock server on %s", addr)
+	scheme := "ws"
+	if appConfig.Server.TLS.enabled() {
+		scheme = "wss"
+	}
+	log.Printf("Starting Simplified OpenAI Realtime Mock server on %s (%s://)", addr, scheme)
 	log.Printf("Active Mode: %s", appConfig.Mode)
-	if appConfig.Mode == "proxy" {
-		log.Printf("Proxy Target: %s", appConfig.Proxy.URL)
+	if appConfig.Mode == "proxy" || appConfig.Mode == "vcr" {
+		log.Printf("Proxy Target: %s", redactSetting(redactURL, appConfig.Proxy.URL))
 		log.Printf("Proxy Model: %s", appConfig.Proxy.Model)
+		if err := refreshUpstreamCheck(0); err != nil {
+			log.Printf("WARNING: Upstream check failed: %v", err)
+		} else {
+			log.Printf("Upstream check passed")
+		}
 	} else {
-		log.Printf("Loaded %d scenarios", len(appConfig.Scenarios))
-		for _, s := range appConfig.Scenarios {
+		scenarios := currentScenarios()
+		log.Printf("Loaded %d scenarios", len(scenarios))
+		for _, s := range scenarios {
 			log.Printf("- Scenario: %s (%d events)", s.Name, len(s.Events))
 		}
 	}
 
-	err := http.ListenAndServe(addr, router)
+	if appConfig.Server.AdminPort != 0 {
+		adminAddr := fmt.Sprintf(":%d", appConfig.Server.AdminPort)
+		log.Printf("Admin endpoints and UI on %s", adminAddr)
+		go func() {
+			if err := listenAndServe(adminAddr, withAccessLog(withCORS(setupAdminRouter()))); err != nil {
+				log.Fatalf("Failed to start admin server: %v", err)
+			}
+		}()
+	}
+
+	err := listenAndServe(addr, withAccessLog(withCORS(router)))
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
 
-// setupRouter initializes the HTTP routes.
+// setupRouter initializes the HTTP routes. With server.adminPort the management endpoints
+// are left out, setupAdminRouter serves them on their own port.
 func setupRouter() *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// API Endpoints
 	mux.HandleFunc("/v1/realtime/sessions", handleCreateSession)
+	mux.HandleFunc("/v1/realtime/client_secrets", handleCreateClientSecret)
+	mux.HandleFunc("/v1/realtime/transcription_sessions", handleCreateTranscriptionSession)
 	mux.HandleFunc("/v1/realtime", handleWebSocket)
+	mux.HandleFunc("/v1/realtime/scenarios/{name}", handleScenarioWebSocket)
+	mux.HandleFunc("GET /v1/models", handleListModels)
+	mux.HandleFunc("GET /v1/models/{id}", handleGetModel)
+	mux.HandleFunc("/tenants/{tenant}/v1/", withTenantPrefix(mux))
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	if appConfig.Server.AdminPort == 0 {
+		addAdminRoutes(mux)
+	}
+	return mux
+}
+
+// setupAdminRouter initializes the routes of the admin port.
+func setupAdminRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	addAdminRoutes(mux)
+	return mux
+}
+
+// addAdminRoutes adds the management and observability endpoints and the web UI.
+func addAdminRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/config", handleGetConfig)
+	mux.HandleFunc("PUT /config", requireAdmin(handlePutConfig))
+	mux.HandleFunc("GET /sync", handleSync)
+	mux.HandleFunc("POST /sync", requireAdmin(handleSync))
+	mux.HandleFunc("GET /mode", handleGetMode)
+	mux.HandleFunc("PUT /mode", requireAdmin(handlePutMode))
+	mux.HandleFunc("/usage", handleGetUsage)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("GET /sessions", handleListSessions)
+	mux.HandleFunc("POST /sessions/{id}/recording", requireAdmin(handleSessionRecording))
+	mux.HandleFunc("POST /sessions/{id}/close", requireAdmin(handleCloseSession))
+	mux.HandleFunc("POST /sessions/{id}/events", requireAdmin(handleInjectEvent))
+	mux.HandleFunc("GET /observe/{id}", requireAdmin(handleObserve))
+	mux.HandleFunc("GET /feed", handleFeed)
+	mux.HandleFunc("GET /scenarios", handleListScenarios)
+	mux.HandleFunc("GET /scenarios/{name}", handleGetScenario)
+	mux.HandleFunc("POST /scenarios/{name}", requireAdmin(handlePutScenario))
+	mux.HandleFunc("PUT /scenarios/{name}", requireAdmin(handlePutScenario))
+	mux.HandleFunc("DELETE /scenarios/{name}", requireAdmin(handleDeleteScenario))
 	mux.HandleFunc("/recordings", handleListRecordings)
-	mux.HandleFunc("/recordings/", handleGetRecording) // Note trailing slash for path parameter handling
+	mux.HandleFunc("POST /recordings", requireAdmin(handleUploadRecording))
+	mux.HandleFunc("/recordings/", requireRecordingAdmin(handleGetRecording)) // Note trailing slash for path parameter handling
+	mux.HandleFunc("GET /recordings/{name}/audio", requireRecordingAdmin(handleRecordingAudio))
+	mux.HandleFunc("GET /recordings/{name}/stats", handleRecordingStats)
+	mux.HandleFunc("GET /recordings/{name}/transcript", requireRecordingAdmin(handleRecordingTranscript))
+	mux.HandleFunc("GET /recordings/{name}/messages", requireRecordingAdmin(handleRecordingMessages))
+	mux.HandleFunc("GET /recordings/{name}/captions", requireRecordingAdmin(handleRecordingCaptions))
+	mux.HandleFunc("GET /recordings/{name}/follow", requireRecordingAdmin(handleFollowRecording))
+	mux.HandleFunc("GET /recordings/{name}/verify", handleVerifyRecording)
+	mux.HandleFunc("GET /recordings/{name}/files/{file}", requireRecordingAdmin(handleGetSessionFile))
+	mux.HandleFunc("DELETE /recordings/{name}", requireAdmin(handleDeleteRecording))
 
 	// Static Files
-	fs := http.FileServer(http.Dir("./static"))
-	mux.Handle("/", fs)
-
-	return mux
+	mux.Handle("/", staticHandler())
 }
 
 // --- HTTP Handlers ---
@@ -134,56 +275,164 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	// Ignore request body, just send back a success with a fake token
+	if connectionMode(r) == "proxy" {
+		proxySessionRequest(w, r)
+		return
+	}
+	// The settings of the body apply to the session that connects with the issued token
+	var settings SessionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&settings); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := settings.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	sessionID := "mock-sess-" + uuid.NewString()
 	ephemeralKey := "ek_mock_" + uuid.NewString()
-	// ExpiresAt should be in milliseconds for consistency with typical client expectations
-	expiresAt := time.Now().Add(1 * time.Minute).UnixMilli()
+	expires := time.Now().Add(issuedSessionTTL)
+	issueSession(ephemeralKey, settings, expires)
 
 	response := SessionObject{
-		ID:               sessionID,
-		Object:           "realtime.session",
-		Model:            "mock-model", // Add minimal fields client might need
-		InputAudioFormat: "pcm16",
-		Modalities:       []string{"audio", "text"},
+		ID:                sessionID,
+		Object:            "realtime.session",
+		Model:             cmp.Or(settings.Model, mockModel),
+		InputAudioFormat:  cmp.Or(settings.InputAudioFormat, "pcm16"),
+		OutputAudioFormat: settings.OutputAudioFormat,
+		Modalities:        settings.Modalities,
+		Voice:             settings.Voice,
+		Instructions:      settings.Instructions,
 		ClientSecret: &ClientSecret{
-			Value:     ephemeralKey,
-			ExpiresAt: expiresAt,
+			Value: ephemeralKey,
+			// ExpiresAt should be in milliseconds for consistency with typical client expectations
+			ExpiresAt: expires.UnixMilli(),
 		},
 	}
+	if len(response.Modalities) == 0 {
+		response.Modalities = []string{"audio", "text"}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 	log.Printf("Issued mock session token for session: %s", sessionID)
 }
 
+// handleCreateClientSecret is the GA counterpart of handleCreateSession.
+func handleCreateClientSecret(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if connectionMode(r) == "proxy" {
+		proxySessionRequest(w, r)
+		return
+	}
+	// Ignore request body, just send back a fake token
+	ephemeralKey := "ek_mock_" + uuid.NewString()
+	response := map[string]interface{}{
+		"value":      ephemeralKey,
+		"expires_at": time.Now().Add(1 * time.Minute).Unix(),
+		"session": map[string]interface{}{
+			"id":     "mock-sess-" + uuid.NewString(),
+			"object": "realtime.session",
+			"type":   "realtime",
+			"model":  mockModel,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+	log.Printf("Issued mock client secret: %s", ephemeralKey)
+}
+
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	// Check Mode
-	if appConfig.Mode == "proxy" {
+	r = withConfigSnapshot(withCorrelationID(r))
+	release, ok := admitConnection(w, r)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Check Mode, the server's or the connection's ?mode=
+	mode := connectionMode(r)
+	if err := checkConnectionMode(r, mode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch mode {
+	case "proxy":
 		handleProxyWebSocket(w, r)
 		return
+	case "vcr":
+		handleVCRWebSocket(w, r)
+		return
 	}
 
 	// Mock Mode
 	handleMockWebSocket(w, r)
 }
 
+// handleScenarioWebSocket serves /v1/realtime/scenarios/{name} for clients that can change the
+// base URL but not add a query. The name in the path wins over a ?scenario=.
+func handleScenarioWebSocket(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Set("scenario", r.PathValue("name"))
+	r.URL.RawQuery = query.Encode()
+	handleWebSocket(w, r)
+}
+
+// handleGetConfig returns the running config with URLs, headers and paths redacted, or
+// in full with ?full=true and the admin token.
 func handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	full := r.URL.Query().Get("full") == "true"
+	if full && !authorizeAdmin(w, r) {
+		return
+	}
+	cfg := *currentConfig()
+	if !full {
+		var err error
+		if cfg, err = redactedConfig(cfg); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to redact config: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(appConfig)
+	json.NewEncoder(w).Encode(cfg)
 }
 
 type RecordingFile struct {
-	Name string `json:"name"`
-	Size int64  `json:"size"`
+	Name    string           `json:"name"`
+	Size    int64            `json:"size"`
+	ModTime time.Time        `json:"mod_time"`
+	Meta    *RecordingMeta   `json:"meta,omitempty"`    // From the .meta.json sidecar
+	Store   string           `json:"store,omitempty"`   // Storage backend, empty for files
+	Live    bool             `json:"live,omitempty"`    // Still being written, see /recordings/{name}/follow
+	Session *SessionManifest `json:"session,omitempty"` // A session directory, once the session has ended
+	Files   []RecordingFile  `json:"files,omitempty"`   // Of a session directory, see /recordings/{name}/files/{file}
+	path    string           // For searching
 }
 
 func handleListRecordings(w http.ResponseWriter, r *http.Request) {
-	recordingDir := appConfig.Proxy.RecordingPath
+	recordingDir := currentConfig().Proxy.RecordingPath
 	if recordingDir == "" {
 		recordingDir = "recordings"
 	}
 
+	query, search, err := parseRecordingQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.contains != "" && !authorizeRecordingRead(w, r) {
+		return // Searching for text would reveal it
+	}
+	page, err := parseRecordingPage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	entries, err := os.ReadDir(recordingDir)
 	if err != nil {
 		// If directory doesn't exist, return empty list instead of error
@@ -195,20 +444,68 @@ func handleListRecordings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var recordings []RecordingFile
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	// New recordings are written to the recorded/ subdirectory
+	recordedDir := filepath.Join(recordingDir, "recorded")
+	recorded, _ := os.ReadDir(recordedDir)
+
+	recordings := []RecordingFile{}
+	live := liveRecordingPaths()
+	for _, listing := range []struct {
+		dir     string
+		entries []os.DirEntry
+	}{{recordingDir, entries}, {recordedDir, recorded}} {
+		for _, entry := range listing.entries {
+			// Session directories are listed as one entry with their files
+			if entry.IsDir() && listing.dir == recordedDir {
+				if session, ok := sessionEntry(filepath.Join(recordedDir, entry.Name()), live); ok {
+					recordings = append(recordings, session)
+				}
+				continue
+			}
+			if entry.IsDir() || strings.HasSuffix(entry.Name(), ".meta.json") {
+				continue
+			}
+			if storePath != "" && strings.HasPrefix(filepath.Join(listing.dir, entry.Name()), filepath.Clean(storePath)) {
+				continue
+			}
 			info, err := entry.Info()
 			if err == nil {
 				recordings = append(recordings, RecordingFile{
-					Name: entry.Name(),
-					Size: info.Size(),
+					Name:    entry.Name(),
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+					path:    filepath.Join(listing.dir, entry.Name()),
 				})
 			}
 		}
 	}
+	if eventStore != nil {
+		stored, err := eventStore.list()
+		if err != nil {
+			log.Printf("Failed to list stored recordings: %v", err)
+		}
+		recordings = append(recordings, stored...)
+	}
+	if objects != nil {
+		recordings = append(recordings, remoteRecordings(recordings)...)
+	}
+	if search {
+		recordings = searchRecordings(recordings, query)
+	}
+
+	// Sidecars are only read for the page that is returned
+	total := len(recordings)
+	recordings = page.apply(recordings)
+	for i := range recordings {
+		if recordings[i].Store == "s3" {
+			objects.fetch(filepath.Base(metaPath(recordings[i].Name)))
+		}
+		recordings[i].Meta = readRecordingMeta(recordings[i].path)
+		recordings[i].Live = recordings[i].Live || isLiveRecording(recordings[i].Name)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(recordings)
 }
 
@@ -226,13 +523,108 @@ func handleGetRecording(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recordingDir := appConfig.Proxy.RecordingPath
+	recordingDir := currentConfig().Proxy.RecordingPath
 	if recordingDir == "" {
 		recordingDir = "recordings"
 	}
 
-	path := filepath.Join(recordingDir, filename)
-	http.ServeFile(w, r, path)
+	if path, ok := storedRecording(filename); ok {
+		file, err := openRecording(path)
+		if err != nil {
+			http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.Copy(w, file)
+		return
+	}
+
+	// A session directory is described by its listing entry
+	if sessionDir, ok := sessionDirectory(filename); ok {
+		if session, ok := sessionEntry(sessionDir, liveRecordingPaths()); ok {
+			session.Meta = readRecordingMeta(session.path)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(session)
+			return
+		}
+	}
+
+	// Recordings live in the recordings directory or its recorded/ subdirectory, or are fetched from the bucket
+	dir := recordingDir
+	if _, ok := recordingFile(filepath.Join(dir, filename)); !ok {
+		dir = filepath.Join(recordingDir, "recorded")
+		if _, ok := recordingFile(filepath.Join(dir, filename)); !ok {
+			if path, ok := fetchRemoteRecording(filename); ok {
+				dir = filepath.Dir(path)
+			}
+		}
+	}
+	serveRecordingFile(w, r, dir, filename)
+}
+
+// serveRecordingFile sends a recording or other file of dir.
+func serveRecordingFile(w http.ResponseWriter, r *http.Request, dir, filename string) {
+	// Compressed recordings are served for their plain name too, decompressed unless the client takes gzip
+	path, ok := recordingFile(filepath.Join(dir, filename))
+	if ok && isEncryptedRecording(path) {
+		serveEncryptedRecording(w, filename, path)
+		return
+	}
+	if !ok || strings.HasSuffix(filename, ".gz") || !strings.HasSuffix(path, ".gz") {
+		http.ServeFile(w, r, filepath.Join(dir, filename))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		http.ServeFile(w, r, path)
+		return
+	}
+	file, err := openRecording(path)
+	if err != nil {
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	io.Copy(w, file)
+}
+
+// handleDeleteRecording removes a recording with its metadata, also from the bucket. Stored recordings are
+// removed in one transaction.
+func handleDeleteRecording(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	var err error
+	if dir, isSession := sessionDirectory(name); isSession {
+		if isLiveRecording(name) {
+			http.Error(w, "Session is still being recorded", http.StatusConflict)
+			return
+		}
+		path = dir
+		err = os.RemoveAll(dir)
+	} else if stored, isStored := strings.CutPrefix(path, storedPrefix); isStored {
+		err = eventStore.remove(stored)
+	} else if err = os.Remove(path); err == nil {
+		os.Remove(metaPath(path))
+		err = deleteRemoteRecording(filepath.Base(path))
+	}
+	if err != nil {
+		log.Printf("Failed to delete recording %s: %v", path, err)
+		http.Error(w, "Failed to delete recording", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Deleted recording %s", path)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // --- Shared Helpers ---
@@ -245,3 +637,27 @@ func sendJSONEvent(conn *SafeWebSocket, payload interface{}) error {
 	}
 	return conn.WriteMessage(websocket.TextMessage, data)
 }
+
+// sendErrorEvent sends a Realtime API style error event to the client.
+// clientEventID is the event_id of the client event that caused the error, if any.
+func sendErrorEvent(conn *SafeWebSocket, errType, code, message, param, clientEventID string) error {
+	errorBody := map[string]interface{}{
+		"type":     errType,
+		"code":     code,
+		"message":  message,
+		"param":    nil,
+		"event_id": nil,
+	}
+	if param != "" {
+		errorBody["param"] = param
+	}
+	if clientEventID != "" {
+		errorBody["event_id"] = clientEventID
+	}
+
+	return sendJSONEvent(conn, map[string]interface{}{
+		"type":     "error",
+		"event_id": uuid.NewString(),
+		"error":    errorBody,
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// --- Response Audio Markers ---
+
+const (
+	responseMarkerMs        = 150
+	responseMarkerAmplitude = 8000.0
+	responseBeepHz          = 1000.0
+)
+
+// dtmfTones holds the low/high frequency pair of the DTMF digits 0-9.
+var dtmfTones = [10][2]float64{
+	{941, 1336},
+	{697, 1209}, {697, 1336}, {697, 1477},
+	{770, 1209}, {770, 1336}, {770, 1477},
+	{852, 1209}, {852, 1336}, {852, 1477},
+}
+
+// overlayResponseMarker mixes a short marker into the start of 24kHz PCM16 audio and returns a copy.
+// "beep" is the same 1kHz tone for every response; "dtmf" plays the last digit of the response
+// number within the session, so a response heard twice in client playback stands out.
+// The original audio is ducked by half underneath the marker.
+func overlayResponseMarker(audio []byte, mode string, seq int) []byte {
+	markerLen := responseMarkerMs * pcm16BytesPerMs
+	out := make([]byte, max(len(audio), markerLen))
+	copy(out, audio)
+
+	freqs := []float64{responseBeepHz}
+	if mode == "dtmf" {
+		digit := dtmfTones[seq%10]
+		freqs = digit[:]
+	}
+
+	for i := 0; i < markerLen/2; i++ {
+		t := float64(i) / sourceSampleRate
+		var tone float64
+		for _, f := range freqs {
+			tone += math.Sin(2 * math.Pi * f * t)
+		}
+		tone = tone / float64(len(freqs)) * responseMarkerAmplitude
+
+		v := float64(int16(binary.LittleEndian.Uint16(out[2*i:])))/2 + tone
+		v = math.Max(math.MinInt16, math.Min(math.MaxInt16, v))
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16(v)))
+	}
+	return out
+}
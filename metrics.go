@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Proxy Metrics ---
+
+// Histogram buckets in seconds, shared by the latency metrics
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative uint64
+	for i, bound := range latencyBuckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+}
+
+type eventKey struct {
+	direction string
+	eventType string
+}
+
+type proxyMetrics struct {
+	mu             sync.Mutex
+	events         map[eventKey]uint64
+	dial           histogram
+	firstResponse  histogram
+	dialErrors     uint64
+	sessionsTotal  uint64
+	sessionsActive int64
+}
+
+var metrics = &proxyMetrics{events: make(map[eventKey]uint64)}
+
+// countEvent counts a forwarded message by direction and event type and returns the type.
+// Binary frames count as type "binary".
+func (m *proxyMetrics) countEvent(direction string, messageType int, msg []byte) string {
+	eventType := binaryFrameType
+	if messageType == websocket.TextMessage {
+		var event BaseEvent
+		json.Unmarshal(msg, &event)
+		eventType = event.Type
+	}
+	m.mu.Lock()
+	m.events[eventKey{direction, eventType}]++
+	m.mu.Unlock()
+	return eventType
+}
+
+func (m *proxyMetrics) sessionStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionsTotal++
+	m.sessionsActive++
+}
+
+func (m *proxyMetrics) sessionEnded() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionsActive--
+}
+
+func (m *proxyMetrics) observeDial(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.dialErrors++
+		return
+	}
+	m.dial.observe(d.Seconds())
+}
+
+func (m *proxyMetrics) observeFirstResponse(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.firstResponse.observe(d.Seconds())
+}
+
+// responseLatency measures one session's time from a response being requested (the client's
+// response.create, or the end of speech with server VAD) to the response's first delta.
+type responseLatency struct {
+	mu    sync.Mutex
+	since time.Time
+}
+
+func (l *responseLatency) start() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.since.IsZero() {
+		l.since = time.Now()
+	}
+}
+
+// firstDelta returns the latency if a response was pending.
+func (l *responseLatency) firstDelta() (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.since.IsZero() {
+		return 0, false
+	}
+	latency := time.Since(l.since)
+	l.since = time.Time{}
+	return latency, true
+}
+
+// track updates the latency from an event type and reports a completed measurement.
+func (l *responseLatency) track(eventType string) (time.Duration, bool) {
+	switch {
+	case eventType == "response.create" || eventType == "input_audio_buffer.speech_stopped":
+		l.start()
+	case strings.HasPrefix(eventType, "response.") && strings.HasSuffix(eventType, ".delta"):
+		return l.firstDelta()
+	}
+	return 0, false
+}
+
+// handleMetrics serves the proxy metrics in the Prometheus text format.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP realtime_proxy_sessions_total Proxied sessions since startup.\n# TYPE realtime_proxy_sessions_total counter\nrealtime_proxy_sessions_total %d\n", metrics.sessionsTotal)
+	fmt.Fprintf(w, "# HELP realtime_proxy_sessions_active Proxied sessions currently open.\n# TYPE realtime_proxy_sessions_active gauge\nrealtime_proxy_sessions_active %d\n", metrics.sessionsActive)
+	fmt.Fprintf(w, "# HELP realtime_proxy_dial_errors_total Failed upstream dials.\n# TYPE realtime_proxy_dial_errors_total counter\nrealtime_proxy_dial_errors_total %d\n", metrics.dialErrors)
+	metrics.dial.write(w, "realtime_proxy_dial_seconds", "Time to open the upstream WebSocket.")
+	metrics.firstResponse.write(w, "realtime_proxy_first_response_seconds", "Time from response.create or end of speech to the response's first delta.")
+
+	keys := make([]eventKey, 0, len(metrics.events))
+	for key := range metrics.events {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].direction != keys[j].direction {
+			return keys[i].direction < keys[j].direction
+		}
+		return keys[i].eventType < keys[j].eventType
+	})
+	fmt.Fprintf(w, "# HELP realtime_proxy_events_total Forwarded messages by direction and event type.\n# TYPE realtime_proxy_events_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(w, "realtime_proxy_events_total{direction=%q,type=%q} %d\n", key.direction, key.eventType, metrics.events[key])
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"bufio"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,7 +20,12 @@ import (
 
 // --- Mock Mode Logic ---
 
+// defaultReplayPath is the recording served by the replay command.
+var defaultReplayPath string
+
 func handleMockWebSocket(w http.ResponseWriter, r *http.Request) {
+	logger := requestLog(r)
+	cfg := requestConfig(r)
 	// 1. Determine Scenario or Replay
 	scenarioName := r.URL.Query().Get("scenario")
 	replaySessionName := r.URL.Query().Get("replaySession")
@@ -30,9 +36,39 @@ func handleMockWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	found := false
 
+	tenant, err := requestTenant(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	// Names only, so replays stay in the directories below
+	if replaySessionName != "" && (filepath.Base(replaySessionName) != replaySessionName || strings.HasPrefix(replaySessionName, ".")) {
+		http.Error(w, "Invalid replaySession "+replaySessionName, http.StatusBadRequest)
+		return
+	}
+
+	// A resumed session continues its scenario, in its tenant
+	var resumed *suspendedSession
+	if resumeID := r.URL.Query().Get("resume"); resumeID != "" {
+		var ok bool
+		if resumed, ok = takeSuspendedSession(resumeID); !ok {
+			http.Error(w, "Session "+resumeID+" not found or expired", http.StatusNotFound)
+			return
+		}
+		selectedScenario, tenant = resumed.scenario, resumed.session.Tenant
+		found = true
+	}
+
+	// 0. The recording of the replay command, unless the client asked for something else
+	if !found && defaultReplayPath != "" && scenarioName == "" && replaySessionName == "" {
+		replayFilePath = defaultReplayPath
+		isReplay = true
+		found = true
+	}
+
 	// 1. Check for Replay
-	if replaySessionName != "" {
-		recordingDir := appConfig.Proxy.RecordingPath
+	if !found && replaySessionName != "" {
+		recordingDir := tenant.recordingPath(cfg)
 		if recordingDir == "" {
 			recordingDir = "recordings"
 		}
@@ -50,65 +86,113 @@ func handleMockWebSocket(w http.ResponseWriter, r *http.Request) {
 			filepath.Join(recordingDir, "recorded", baseName+".ndjson"),
 			filepath.Join(recordingDir, "recorded", baseName),
 
-			// 3. Fallback to root (legacy)
+			// 3. Session directories (e.g., recordings/recorded/sess_123/session.ndjson)
+			filepath.Join(recordingDir, "recorded", baseName, "session.ndjson"),
+			filepath.Join(recordingDir, "recorded", baseName, "outbound.ndjson"),
+
+			// 4. Fallback to root (legacy)
 			filepath.Join(recordingDir, baseName+".ndjson"),
 			filepath.Join(recordingDir, baseName),
 		}
 
-		for _, path := range possiblePaths {
-			if _, err := os.Stat(path); err == nil {
+		for _, candidate := range possiblePaths {
+			if path, ok := recordingFile(candidate); ok {
 				replayFilePath = path
 				isReplay = true
 				found = true
-				log.Printf("Found recording for replay: %s", path)
+				logger.Printf("Found recording for replay: %s", path)
 				break
 			}
 		}
+
+		// 5. Recordings of the library. Tenants with their own directory replay only from it.
+		if !found && cfg.Library.enabled() && !tenant.ownRecordings() {
+			libraryDir := cfg.Library.recordingsDir()
+			for _, candidate := range []string{
+				filepath.Join(libraryDir, baseName+".ndjson"),
+				filepath.Join(libraryDir, baseName),
+			} {
+				if path, ok := recordingFile(candidate); ok {
+					replayFilePath = path
+					isReplay = true
+					found = true
+					logger.Printf("Found recording for replay in the library: %s", path)
+					break
+				}
+			}
+		}
+
+		// 6. Recordings in the storage backend, or in the bucket
+		if !found && !tenant.ownRecordings() {
+			path, ok := storedRecording(baseName)
+			if !ok {
+				path, ok = fetchRemoteRecording(baseName + ".ndjson")
+			}
+			if ok {
+				replayFilePath = path
+				isReplay = true
+				found = true
+				logger.Printf("Found recording for replay: %s", path)
+			}
+		}
 		if !found {
-			log.Printf("Replay session '%s' not found in %s (checked examples and recorded subdirs)", replaySessionName, recordingDir)
+			logger.Printf("Replay session '%s' not found in %s (checked examples and recorded subdirs)", replaySessionName, recordingDir)
+		}
+		// Encrypted recordings are replayed only to clients with the admin token, as over HTTP
+		if found && isEncryptedRecording(replayFilePath) && !authorizeRecordingRead(w, r) {
+			logger.Printf("Refused replay of encrypted recording %s without the admin token", replaySessionName)
+			return
 		}
 	}
 
 	// 2. Check Config Scenarios (if not a replay)
+	scenarios := tenant.scenarios(cfg)
 	if !found && scenarioName != "" {
-		for _, s := range appConfig.Scenarios {
-			if s.Name == scenarioName {
-				selectedScenario = s
-				found = true
-				break
-			}
-		}
+		selectedScenario, found = tenant.findScenario(cfg, scenarioName)
 	}
 
-	if !found && len(appConfig.Scenarios) > 0 {
+	if !found && len(scenarios) > 0 {
 		// If neither found, default to first scenario (unless replay was explicitly requested but failed?)
 		// If replay was requested but not found, we probably shouldn't fallback to default scenario silently?
 		// But for now let's keep the fallback behavior but maybe log it.
 		if replaySessionName != "" {
-			log.Printf("Replay session not found. Falling back to default scenario.")
+			logger.Printf("Replay session not found. Falling back to default scenario.")
 		} else if scenarioName != "" {
-			log.Printf("Scenario '%s' not found. Falling back to default scenario.", scenarioName)
+			logger.Printf("Scenario '%s' not found. Falling back to default scenario.", scenarioName)
 		}
 
-		selectedScenario = appConfig.Scenarios[0]
-		log.Printf("Using default scenario: %s", selectedScenario.Name)
+		selectedScenario = scenarios[0]
+		logger.Printf("Using default scenario: %s", selectedScenario.Name)
 	} else if !found {
-		log.Printf("No scenarios available to run.")
+		logger.Printf("No scenarios available to run.")
 		return
 	}
+	var filter eventFilter
+	if isReplay {
+		if filter, err = replayEventFilter(r.URL.Query()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := upgrader.Upgrade(w, r, withCorrelationHeader(r, nil))
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		logger.Printf("WebSocket upgrade error: %v", err)
+		if resumed != nil {
+			suspendSession(resumed.session, resumed.scenario, resumed.convID)
+		}
 		return
 	}
-	safeConn := &SafeWebSocket{Conn: conn}
+	safeConn := newClientConn(conn, r)
+	if cfg.Chaos.enabled() {
+		safeConn.enableChaos(cfg.Chaos)
+	}
 	defer safeConn.Close()
 
 	if isReplay {
-		log.Printf("WebSocket client connected: %s. Replaying: %s", safeConn.RemoteAddr(), replayFilePath)
+		logger.Printf("WebSocket client connected: %s. Replaying: %s", safeConn.RemoteAddr(), replayFilePath)
 	} else {
-		log.Printf("WebSocket client connected: %s. Scenario: %s", safeConn.RemoteAddr(), selectedScenario.Name)
+		logger.Printf("WebSocket client connected: %s. Scenario: %s", safeConn.RemoteAddr(), selectedScenario.Name)
 	}
 
 	// --- Send Welcome Messages (SessionCreated, ConversationCreated) ---
@@ -122,134 +206,475 @@ func handleMockWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	sessionID := "mock-ws-sess-" + uuid.NewString()
 	convID := "mock-conv-" + uuid.NewString()
+	if resumed != nil {
+		sessionID, convID = resumed.session.ID, resumed.convID
+	}
+	session := NewMockSession(safeConn, sessionID, cfg)
+	session.applyTenant(tenant)
+	if profile := r.URL.Query().Get("audio_profile"); profile != "" {
+		if err := session.setAudioProfile(profile); err != nil {
+			logger.Printf("Client %s: %v. Keeping default audio profile.", safeConn.RemoteAddr(), err)
+		}
+	}
+	if binaryAudio := r.URL.Query().Get("binary_audio"); binaryAudio != "" {
+		session.BinaryAudio = binaryAudio == "true" || binaryAudio == "1"
+	}
+	if resumed != nil {
+		session.resumeFrom(resumed.session)
+	} else if settings, ok := lookupIssuedSession(clientAPIKey(r)); ok {
+		session.applySessionRequest(settings)
+	}
 
-	// Send session.created
-	sessionCreated := map[string]interface{}{
-		"type":     "session.created",
-		"event_id": uuid.NewString(),
-		"session": SessionObject{
-			ID:               sessionID,
-			Object:           "realtime.session",
-			Model:            "mock-model",
-			InputAudioFormat: "pcm16",
-			Modalities:       []string{"audio", "text"},
-		},
+	// Transcription sessions answer committed audio with transcripts only; replays play as recorded
+	transcription := isTranscriptionSession(r) && !isReplay
+	if transcription {
+		session.startTranscription(selectedScenario)
+		defer session.stopTranscription()
+		logger.Printf("Client %s: Transcription session", safeConn.RemoteAddr())
 	}
-	if err := sendJSONEvent(safeConn, sessionCreated); err != nil {
-		return
+
+	// A replay may bring its own welcome events, mock.replayWelcome decides which are sent
+	var replay replayOptions
+	var recordedWelcome map[string][]byte
+	if isReplay {
+		replay.ids = replayIDMapper(r, sessionID, convID)
+		welcome := replayWelcome(r)
+		replay.skipWelcome = welcome != replayWelcomeBoth
+		replayLoopOptions(r, &replay, sessionID, convID)
+		replay.filter = filter
+		replay.maxGap = replayMaxGap(r)
+		if welcome == replayWelcomeRecorded {
+			recordedWelcome = readRecordedWelcome(replayFilePath)
+		}
+	}
+
+	// Send session.created, transcription sessions get transcription_session.created instead
+	if recorded, ok := recordedWelcome["session.created"]; ok {
+		if err := safeConn.WriteMessage(websocket.TextMessage, replay.ids.rewrite(recorded)); err != nil {
+			return
+		}
+	} else if transcription {
+		sessionCreated := map[string]interface{}{
+			"type":     "transcription_session.created",
+			"event_id": uuid.NewString(),
+			"session":  session.transcriptionSessionObject(),
+		}
+		if err := sendJSONEvent(safeConn, sessionCreated); err != nil {
+			return
+		}
+	} else {
+		sessionCreated := map[string]interface{}{
+			"type":     "session.created",
+			"event_id": uuid.NewString(),
+			"session":  session.sessionObject(),
+		}
+		if err := sendJSONEvent(safeConn, sessionCreated); err != nil {
+			return
+		}
 	}
 
 	// Send conversation.created
-	convCreated := map[string]interface{}{
-		"type":     "conversation.created",
-		"event_id": uuid.NewString(),
-		"conversation": ConversationObject{
-			ID:     convID,
-			Object: "realtime.conversation",
-		},
+	if recorded, ok := recordedWelcome["conversation.created"]; ok {
+		if err := safeConn.WriteMessage(websocket.TextMessage, replay.ids.rewrite(recorded)); err != nil {
+			return
+		}
+	} else if !transcription {
+		convCreated := map[string]interface{}{
+			"type":     "conversation.created",
+			"event_id": uuid.NewString(),
+			"conversation": ConversationObject{
+				ID:     convID,
+				Object: "realtime.conversation",
+			},
+		}
+		if err := sendJSONEvent(safeConn, convCreated); err != nil {
+			return
+		}
 	}
-	if err := sendJSONEvent(safeConn, convCreated); err != nil {
-		return
+	if resumed != nil {
+		if err := session.sendHistory(); err != nil {
+			return
+		}
 	}
 
-	// --- Simple Client State ---
+	// --- Response Trigger ---
+	// Interactive replays start right away and hold every recorded response until its trigger
+	interactive := isReplay && replayMode(r) == replayModeInteractive
+	var turnTriggers chan replayTrigger
+	if interactive {
+		turnTriggers = make(chan replayTrigger, 16)
+		defer close(turnTriggers)
+		logger.Printf("Client %s: Interactive replay, responses wait for their triggers", safeConn.RemoteAddr())
+		interactiveReplay := replay
+		interactiveReplay.turnTriggers = turnTriggers
+		interactiveReplay.turns = replayTurns(r)
+		go playReplay(safeConn, replayFilePath, interactiveReplay)
+	}
+	releaseTurn := func(trigger replayTrigger) {
+		select {
+		case turnTriggers <- trigger:
+		default: // Plenty of turns already queued
+		}
+	}
 	var scenarioOnce sync.Once
+	session.StartResponse = func(trigger string) {
+		if transcription {
+			return // Transcription sessions never respond
+		}
+		if interactive {
+			releaseTurn(replayTrigger{event: trigger, starts: true})
+			return
+		}
+		scenarioOnce.Do(func() {
+			session.scenarioStarted.Store(true)
+			go func() {
+				// Delay before starting response (only for scenarios, not replays)
+				if delay := tenant.responseDelay(cfg); !isReplay && delay > 0 {
+					time.Sleep(delay)
+				}
+
+				if isReplay {
+					playReplay(safeConn, replayFilePath, replay)
+				} else {
+					runScenario(session, selectedScenario)
+				}
+			}()
+		})
+	}
 	audioReceived := false
+	// A scenario the client was in the middle of goes on right away
+	if resumed != nil && session.scenarioStarted.Load() && int(session.scenarioPos.Load()) < len(selectedScenario.Events) {
+		logger.Printf("Client %s: Resuming scenario %s at event %d/%d", safeConn.RemoteAddr(), selectedScenario.Name, session.scenarioPos.Load()+1, len(selectedScenario.Events))
+		session.StartResponse(turnTriggerNone)
+	}
 
 	// --- Inbound Recording ---
 	var inboundRecorder *Recorder
-	if appConfig.LogInbound {
+	if cfg.LogInbound {
 		var err error
 		recordingName := r.URL.Query().Get("recording_name")
 		inboundName := ""
 		if recordingName != "" {
 			inboundName = "inbound_" + recordingName
 		}
-		inboundRecorder, err = NewRecorder(appConfig.Proxy.RecordingPath, "inbound", inboundName)
+		inboundRecorder, err = tenant.newRecorder(cfg, "inbound", inboundName)
 		if err != nil {
-			log.Printf("Failed to initialize inbound recorder: %v", err)
+			logger.Printf("Failed to initialize inbound recorder: %v", err)
 		} else {
+			inboundRecorder.SetFilter(cfg.RecordingFilters.Inbound)
+			inboundRecorder.SetRedaction(&cfg.RecordingFilters.Redact)
+			meta := newRecordingMeta("inbound", connectionMode(r))
+			meta.SessionID = sessionID
+			if isReplay {
+				meta.Replay = filepath.Base(replayFilePath)
+			} else {
+				meta.Scenario = selectedScenario.Name
+			}
+			inboundRecorder.SetMeta(meta)
 			defer inboundRecorder.Close()
 		}
 	}
+	recording := newRecordingToggle(r)
+	recording.add(inboundRecorder)
+	live := &liveSession{ID: sessionID, recording: recording, conn: safeConn, mode: connectionMode(r), model: session.Model, connectedAt: time.Now()}
+	if isReplay {
+		live.replay = filepath.Base(replayFilePath)
+	} else {
+		live.scenario = selectedScenario.Name
+	}
+	registerLiveSession(live)
+	defer unregisterLiveSession(live)
+	safeConn.tap = live
 
 	// --- Read Loop ---
 	for {
 		messageType, message, err := safeConn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("Client %s read error: %v", safeConn.RemoteAddr(), err)
+				logger.Printf("Client %s read error: %v", safeConn.RemoteAddr(), err)
 			} else {
-				log.Printf("Client %s disconnected: %v", safeConn.RemoteAddr(), err)
+				logger.Printf("Client %s disconnected: %v", safeConn.RemoteAddr(), err)
+			}
+			if inboundRecorder != nil {
+				inboundRecorder.RecordClose("", closeFromError(err))
 			}
 			break // Exit loop on error or close
 		}
 
+		if messageType == websocket.TextMessage && handleRecordingControl(safeConn, recording, message) {
+			continue
+		}
+
 		// Record inbound message
-		if inboundRecorder != nil && messageType == websocket.TextMessage {
-			inboundRecorder.RecordMessage(message)
+		if inboundRecorder != nil {
+			inboundRecorder.RecordFrame("", messageType, message)
 		}
 
 		if messageType == websocket.TextMessage {
 			var base BaseEvent
 			if err := json.Unmarshal(message, &base); err == nil {
-				// log.Printf("Client %s received event: %s", safeConn.RemoteAddr(), base.Type)
+				// logger.Printf("Client %s received event: %s", safeConn.RemoteAddr(), base.Type)
+
+				switch base.Type {
+				case "input_audio_buffer.append":
+					var appendEvent InputAudioAppendEvent
+					json.Unmarshal(message, &appendEvent)
+					audio, err := decodeInputAudio(appendEvent.Audio, session.InputAudioFormat)
+					if err != nil {
+						logger.Printf("Client %s: Rejected input audio: %v", safeConn.RemoteAddr(), err)
+						sendErrorEvent(safeConn, "invalid_request_error", "invalid_value",
+							fmt.Sprintf("Invalid 'audio'. Expected base64-encoded %s audio bytes.", session.InputAudioFormat),
+							"audio", base.EventID)
+						continue
+					}
+					if err := session.appendInputAudio(audio); err != nil {
+						logger.Printf("Client %s: Rejected input audio: %v", safeConn.RemoteAddr(), err)
+						sendErrorEvent(safeConn, "invalid_request_error", "input_audio_buffer_size_exceeded", "Error appending input audio: "+err.Error(), "audio", base.EventID)
+						continue
+					}
 
-				if base.Type == "input_audio_buffer.append" {
-					if !audioReceived {
+					// Without turn detection, the first audio chunk triggers the response
+					if !session.vadEnabled() && !audioReceived && !interactive {
 						audioReceived = true
-						log.Printf("Client %s: Trigger event received (%s). Starting response.", safeConn.RemoteAddr(), base.Type)
-						scenarioOnce.Do(func() {
-							go func() {
-								// Delay before starting response (only for scenarios, not replays)
-								if !isReplay && appConfig.Mock.ResponseDelaySeconds > 0 {
-									time.Sleep(time.Duration(appConfig.Mock.ResponseDelaySeconds) * time.Second)
-								}
-
-								if isReplay {
-									runReplay(safeConn, replayFilePath)
-								} else {
-									runScenario(safeConn, selectedScenario, sessionID)
-								}
-							}()
-						})
+						logger.Printf("Client %s: Trigger event received (%s). Starting response.", safeConn.RemoteAddr(), base.Type)
+						session.StartResponse(turnTriggerCommit)
 					}
+				case "input_audio_buffer.commit":
+					// Scenarios with user_transcription events commit the user's turns themselves. Without turn
+					// detection the manual commit is left to them, as a second committed event for the same turn
+					// would confuse clients; the buffered audio stays for the scenario's transcription.
+					if !isReplay && !session.Transcription && !session.vadEnabled() && selectedScenario.scriptsCommits() {
+						logger.Printf("Client %s: Manual commit left to the scenario's user_transcription events", safeConn.RemoteAddr())
+						continue
+					}
+					session.commitInputBuffer(base.EventID, "")
+					// With turn detection the API answers a manual commit, so it is a turn of its own.
+					// Without, it only releases recorded responses that waited for committed audio.
+					if interactive && session.vadEnabled() {
+						session.StartResponse(turnTriggerCommit)
+					} else if interactive {
+						releaseTurn(replayTrigger{event: turnTriggerCommit})
+					}
+				case "input_audio_buffer.clear":
+					session.clearInputBuffer()
+				case "session.update", "transcription_session.update":
+					session.handleSessionUpdate(message)
+				case "response.cancel":
+					session.cancelResponse(base.EventID)
+				case "conversation.item.truncate":
+					session.handleTruncate(message)
+				case "response.create":
+					if transcription {
+						sendErrorEvent(safeConn, "invalid_request_error", "invalid_event",
+							"Responses are not supported in transcription sessions.", "", base.EventID)
+						continue
+					}
+					logger.Printf("Client %s: Trigger event received (%s). Starting response.", safeConn.RemoteAddr(), base.Type)
+					session.StartResponse(turnTriggerResponse)
 				}
 			} else {
-				log.Printf("Client %s received non-JSON text message or parse error: %v", safeConn.RemoteAddr(), err)
+				logger.Printf("Client %s received non-JSON text message or parse error: %v", safeConn.RemoteAddr(), err)
 			}
 		} else if messageType == websocket.BinaryMessage {
-			log.Printf("Client %s received binary message (%d bytes) - treating as audio", safeConn.RemoteAddr(), len(message))
-			if !audioReceived {
+			logger.Printf("Client %s received binary message (%d bytes) - treating as audio", safeConn.RemoteAddr(), len(message))
+			if err := session.appendInputAudio(message); err != nil {
+				logger.Printf("Client %s: Rejected input audio: %v", safeConn.RemoteAddr(), err)
+				sendErrorEvent(safeConn, "invalid_request_error", "input_audio_buffer_size_exceeded", "Error appending input audio: "+err.Error(), "", "")
+				continue
+			}
+			if !session.vadEnabled() && !audioReceived && !interactive {
 				audioReceived = true
-				log.Printf("Client %s: First binary audio received. Starting response.", safeConn.RemoteAddr())
-				scenarioOnce.Do(func() {
-					go func() {
-						if appConfig.Mock.ResponseDelaySeconds > 0 {
-							time.Sleep(time.Duration(appConfig.Mock.ResponseDelaySeconds) * time.Second)
-						}
-
-						if isReplay {
-							runReplay(safeConn, replayFilePath)
-						} else {
-							runScenario(safeConn, selectedScenario, sessionID)
-						}
-					}()
-				})
+				logger.Printf("Client %s: First binary audio received. Starting response.", safeConn.RemoteAddr())
+				session.StartResponse(turnTriggerCommit)
 			}
 		}
 	}
+
+	// Keep the session for a reconnect with ?resume=, replays and transcription sessions start over
+	session.ended.Store(true)
+	if !isReplay && !transcription {
+		suspendSession(session, selectedScenario, convID)
+	}
 }
 
 // --- Replay Logic ---
 
-func runReplay(conn *SafeWebSocket, filePath string) {
-	log.Printf("Starting replay from: %s", filePath)
+const (
+	replayModeStream      = "stream"
+	replayModeInteractive = "interactive"
+)
+
+// replayMode returns the replay pacing requested with ?replay_mode=, or the configured default.
+func replayMode(r *http.Request) string {
+	if mode := r.URL.Query().Get("replay_mode"); mode != "" {
+		return mode
+	}
+	return requestConfig(r).Mock.ReplayMode
+}
+
+// replayIDMapper returns the ID remapping for a replay, nil unless fresh IDs were asked for
+// with mock.replayFreshIds or ?replay_fresh_ids=true.
+func replayIDMapper(r *http.Request, sessionID, convID string) *replayIDs {
+	fresh := requestConfig(r).Mock.ReplayFreshIDs
+	if value := r.URL.Query().Get("replay_fresh_ids"); value != "" {
+		fresh = value == "true" || value == "1"
+	}
+	if !fresh {
+		return nil
+	}
+	return newReplayIDs(sessionID, convID)
+}
 
-	file, err := os.Open(filePath)
+const (
+	replayWelcomeBoth     = "both"     // The mock's welcome, then the recorded one (default)
+	replayWelcomeMock     = "mock"     // Only the mock's welcome
+	replayWelcomeRecorded = "recorded" // The recorded welcome instead of the mock's, sent on connect
+)
+
+// replayWelcome returns how welcome events are handled, from ?replay_welcome= or the config.
+func replayWelcome(r *http.Request) string {
+	welcome := r.URL.Query().Get("replay_welcome")
+	if welcome == "" {
+		welcome = requestConfig(r).Mock.ReplayWelcome
+	}
+	switch welcome {
+	case replayWelcomeMock, replayWelcomeRecorded:
+		return welcome
+	}
+	return replayWelcomeBoth
+}
+
+func isWelcomeEvent(eventType string) bool {
+	return eventType == "session.created" || eventType == "conversation.created"
+}
+
+// readRecordedWelcome returns the session.created / conversation.created events a recording
+// starts with, by type.
+func readRecordedWelcome(filePath string) map[string][]byte {
+	welcome := make(map[string][]byte)
+	file, err := openRecording(filePath)
 	if err != nil {
-		log.Printf("Failed to open replay file: %v", err)
-		return
+		return welcome
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	for scanner.Scan() {
+		var event RecordedEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Direction == directionClient {
+			continue
+		}
+		var base BaseEvent
+		if event.Frame != "" || json.Unmarshal(event.Data, &base) != nil || !isWelcomeEvent(base.Type) {
+			break // The welcome is over
+		}
+		welcome[base.Type] = event.Data
+	}
+	return welcome
+}
+
+// replayOptions adjusts how runReplay plays a recording.
+type replayOptions struct {
+	// Each response.created first waits for its trigger, the replay stops once the channel is closed
+	turnTriggers <-chan replayTrigger
+	// How responses are mapped to triggers, replayTurnsAuto or replayTurnsAny
+	turns string
+	// Rewrites the recorded IDs, nil keeps them
+	ids *replayIDs
+	// Leave out recorded session.created / conversation.created, the client already got a welcome
+	skipWelcome bool
+	// Start over when the recording ends, after loopPause, with fresh IDs for the session below
+	loop                      bool
+	loopPause                 time.Duration
+	sessionID, conversationID string
+	// Selects the events of a stored recording
+	filter eventFilter
+	// Longest pause between two events, 0 keeps the recorded ones
+	maxGap time.Duration
+}
+
+// replayLoopOptions applies mock.replayLoop / ?replay_loop= to the options.
+func replayLoopOptions(r *http.Request, opts *replayOptions, sessionID, convID string) {
+	cfg := requestConfig(r)
+	opts.loop = cfg.Mock.ReplayLoop
+	if value := r.URL.Query().Get("replay_loop"); value != "" {
+		opts.loop = value == "true" || value == "1"
+	}
+	opts.loopPause = time.Duration(cfg.Mock.ReplayLoopPauseMs) * time.Millisecond
+	opts.sessionID, opts.conversationID = sessionID, convID
+}
+
+// replayMaxGap returns mock.replayMaxGapMs, or ?replay_max_gap_ms= if given.
+func replayMaxGap(r *http.Request) time.Duration {
+	maxGapMs := requestConfig(r).Mock.ReplayMaxGapMs
+	if value, err := strconv.Atoi(r.URL.Query().Get("replay_max_gap_ms")); err == nil && value >= 0 {
+		maxGapMs = value
+	}
+	return time.Duration(maxGapMs) * time.Millisecond
+}
+
+// replayClock turns recorded timestamps into the pauses between replayed events. A timestamp that goes
+// back (e.g. after a clock adjustment) doesn't pause, and timing continues from it; pauses longer than
+// maxGap are shortened, so a single bad timestamp can't stall a replay.
+type replayClock struct {
+	maxGap  time.Duration // 0: no limit
+	last    int64
+	started bool
+	logf    func(format string, args ...any)
+}
+
+// wait returns the pause before the event recorded at timestamp (Unix ms).
+func (c *replayClock) wait(timestamp int64) time.Duration {
+	if !c.started {
+		c.reset(timestamp)
+		return 0
+	}
+	gap := time.Duration(timestamp-c.last) * time.Millisecond
+	c.last = timestamp
+	switch {
+	case gap < 0:
+		c.logf("Replay timestamp goes back %s, continuing without a pause", -gap)
+		return 0
+	case c.maxGap > 0 && gap > c.maxGap:
+		c.logf("Replay pause of %s shortened to %s", gap, c.maxGap)
+		return c.maxGap
+	}
+	return gap
+}
+
+// reset measures the next pause from timestamp.
+func (c *replayClock) reset(timestamp int64) {
+	c.last, c.started = timestamp, true
+}
+
+// playReplay runs the replay, over and over in loop mode until the connection goes away.
+// Every further loop leaves out the recorded welcome and gets its own fresh IDs.
+func playReplay(conn *SafeWebSocket, filePath string, opts replayOptions) {
+	for loop := 1; runReplay(conn, filePath, opts) && opts.loop; loop++ {
+		conn.logf("Replay loop %d finished, starting over: %s", loop, filePath)
+		time.Sleep(opts.loopPause)
+		opts.ids = newReplayIDs(opts.sessionID, opts.conversationID)
+		opts.skipWelcome = true
+	}
+}
+
+// runReplay sends the server events of a recording with their recorded timing. It reports whether
+// the recording played to its end and sent anything, i.e. whether another loop makes sense.
+func runReplay(conn *SafeWebSocket, filePath string, opts replayOptions) bool {
+	conn.logf("Starting replay from: %s", filePath)
+
+	var file io.ReadCloser
+	var err error
+	if !opts.filter.empty() && strings.HasPrefix(filePath, storedPrefix) {
+		file, err = openStoredRecording(filePath, opts.filter)
+	} else {
+		file, err = openRecording(filePath)
+	}
+	if err != nil {
+		conn.logf("Failed to open replay file: %v", err)
+		return false
 	}
 	defer file.Close()
 
@@ -259,8 +684,13 @@ func runReplay(conn *SafeWebSocket, filePath string) {
 	buf := make([]byte, maxCapacity)
 	scanner.Buffer(buf, maxCapacity)
 
-	var lastTimestamp int64
-	firstEvent := true
+	clock := replayClock{maxGap: opts.maxGap, logf: conn.logf}
+	var turns *turnMapper
+	if opts.turnTriggers != nil {
+		turns = newTurnMapper(opts.turns, filePath)
+	}
+	turn := 0
+	sent := 0
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -270,67 +700,138 @@ func runReplay(conn *SafeWebSocket, filePath string) {
 
 		var event RecordedEvent
 		if err := json.Unmarshal(line, &event); err != nil {
-			log.Printf("Error parsing replay line: %v. Skipping.", err)
+			conn.logf("Error parsing replay line: %v. Skipping.", err)
+			continue
+		}
+
+		var base BaseEvent
+		if event.Frame == "" {
+			json.Unmarshal(event.Data, &base)
+		}
+		if turns != nil {
+			turns.observe(event, base.Type)
+		}
+		// Combined recordings also hold what the client sent, only server events are replayed
+		if event.Direction == directionClient {
+			continue
+		}
+		if opts.skipWelcome && isWelcomeEvent(base.Type) {
 			continue
 		}
 
-		// Calculate delay
-		if firstEvent {
-			lastTimestamp = event.Timestamp
-			firstEvent = false
+		// In interactive replays the wait for the trigger replaces the recorded gap
+		if turns != nil && base.Type == "response.created" {
+			turn++
+			if trigger := turns.trigger(event); trigger != turnTriggerNone {
+				conn.logf("Replay waiting for the trigger of turn %d (%s)", turn, trigger)
+				if !awaitTurn(opts.turnTriggers, trigger) {
+					conn.logf("Replay stopped, client disconnected: %s", filePath)
+					return false
+				}
+				clock.reset(event.Timestamp)
+			} else {
+				conn.logf("Replay turn %d was not triggered by the client, keeping its timing", turn)
+			}
+		}
+
+		if delay := clock.wait(event.Timestamp); delay > 0 {
+			time.Sleep(delay)
 		}
 
-		delay := event.Timestamp - lastTimestamp
-		if delay > 0 {
-			time.Sleep(time.Duration(delay) * time.Millisecond)
+		// A recorded close ends the replay the same way the session ended
+		if event.Frame == closeFrameType {
+			var info closeInfo
+			json.Unmarshal(event.Data, &info)
+			conn.logf("Replay closing connection with code %d", info.Code)
+			if err := conn.WriteClose(info); err != nil {
+				conn.logf("Error sending replay close: %v", err)
+			}
+			return false
+		}
+
+		// Binary frames are stored base64-encoded
+		messageType, data := websocket.TextMessage, []byte(event.Data)
+		if event.Frame == "binary" {
+			var payload string
+			json.Unmarshal(event.Data, &payload)
+			if data, err = base64.StdEncoding.DecodeString(payload); err != nil {
+				conn.logf("Error decoding binary replay frame: %v. Skipping.", err)
+				continue
+			}
+			messageType = websocket.BinaryMessage
+		} else {
+			data = opts.ids.rewrite(data)
 		}
-		lastTimestamp = event.Timestamp
 
 		// Send raw data
-		if err := conn.WriteMessage(websocket.TextMessage, event.Data); err != nil {
-			log.Printf("Error sending replay message: %v", err)
-			return
+		if err := conn.WriteMessage(messageType, data); err != nil {
+			conn.logf("Error sending replay message: %v", err)
+			return false
 		}
+		sent++
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Printf("Error reading replay file: %v", err)
+		conn.logf("Error reading replay file: %v", err)
+		return false
 	}
 
-	log.Printf("Replay completed: %s", filePath)
+	conn.logf("Replay completed: %s", filePath)
+	return sent > 0
 }
 
 // --- Scenario Execution Logic ---
 
-func runScenario(conn *SafeWebSocket, scenario Scenario, sessionID string) {
-	log.Printf("Starting scenario execution: %s", scenario.Name)
+// scriptsCommits reports whether the scenario commits user turns itself, with user_transcription events.
+func (scenario Scenario) scriptsCommits() bool {
+	return slices.ContainsFunc(scenario.Events, func(e Event) bool { return e.Type == "user_transcription" })
+}
 
-	for i, event := range scenario.Events {
+func runScenario(session *MockSession, scenario Scenario) {
+	session.logf("Starting scenario execution: %s", scenario.Name)
+
+	// Resumed sessions continue after the events they already played
+	for i := int(session.scenarioPos.Load()); i < len(scenario.Events) && !session.ended.Load(); i++ {
+		event := scenario.Events[i]
 		// 1. Wait for delay
 		if event.DelayMs > 0 {
 			time.Sleep(time.Duration(event.DelayMs) * time.Millisecond)
 		}
 
-		log.Printf("Executing event %d/%d (Type: %s)", i+1, len(scenario.Events), event.Type)
+		session.logf("Executing event %d/%d (Type: %s)", i+1, len(scenario.Events), event.Type)
+		feedScenarioProgress(session, scenario, i+1, scenarioRunning)
 
 		// 2. Execute Event
 		switch event.Type {
 		case "message":
-			streamMessageResponse(conn, event, sessionID)
+			streamMessageResponse(session, event)
 		case "function_call":
-			sendFunctionCall(conn, event, sessionID)
+			sendFunctionCall(session, event)
 		case "user_transcription":
-			sendUserTranscription(conn, event, sessionID)
+			sendUserTranscription(session, event)
 		default:
-			log.Printf("Unknown event type: %s", event.Type)
+			session.logf("Unknown event type: %s", event.Type)
 		}
+		// An event cut off by a disconnect plays again when the session is resumed
+		if session.ended.Load() {
+			session.logf("Scenario %s stopped at event %d/%d, the client disconnected", scenario.Name, i+1, len(scenario.Events))
+			feedScenarioProgress(session, scenario, i+1, scenarioStopped)
+			return
+		}
+		session.scenarioPos.Store(int32(i + 1))
+	}
+	session.logf("Scenario execution completed: %s", scenario.Name)
+	if !session.ended.Load() {
+		feedScenarioProgress(session, scenario, len(scenario.Events), scenarioCompleted)
 	}
-	log.Printf("Scenario execution completed: %s", scenario.Name)
 }
 
-func streamMessageResponse(conn *SafeWebSocket, event Event, sessionID string) {
+func streamMessageResponse(session *MockSession, event Event) {
+	conn := session.Conn
 	responseID := "mock-resp-" + uuid.NewString()
 	itemID := "mock-item-" + uuid.NewString()
+	resp := session.beginResponse(responseID)
+	defer session.endResponse(resp)
 
 	// 1. response.created
 	respCreated := map[string]interface{}{
@@ -384,75 +885,29 @@ func streamMessageResponse(conn *SafeWebSocket, event Event, sessionID string) {
 		return
 	}
 
-	// Start streaming content
-	var wg sync.WaitGroup
-
-	// We will use a single content part for Audio + Transcript
-	// In the Realtime API, audio response usually comes as a single content part with type="audio"
-	// which contains both "audio" (base64) and "transcript" (text) fields updates.
-
-	// response.content_part.added
-	partAdded := map[string]interface{}{
-		"type":          "response.content_part.added",
-		"event_id":      uuid.NewString(),
-		"response_id":   responseID,
-		"item_id":       itemID,
-		"output_index":  0,
-		"content_index": 0,
-		"part": map[string]interface{}{
-			"type":       "audio",
-			"transcript": "",
-		},
-	}
-	if err := sendJSONEvent(conn, partAdded); err != nil {
-		return
-	}
-
-	// Stream Audio and Transcript concurrently
-	if appConfig.Mock.AudioWavPath != "" {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			streamAudio(conn, responseID, itemID, 0)
-		}()
-	}
-
-	if event.Text != "" {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			streamTranscript(conn, responseID, itemID, 0, event.Text)
-		}()
+	// Stream the content parts one after another, each with its own content_index
+	content := []interface{}{}
+	for contentIndex, part := range event.contentParts() {
+		if resp.isCancelled() {
+			break
+		}
+		finished, err := streamContentPart(session, resp, itemID, contentIndex, part, event)
+		if err != nil {
+			return
+		}
+		content = append(content, finished)
 	}
 
-	wg.Wait()
-
-	// response.content_part.done
-	partDone := map[string]interface{}{
-		"type":          "response.content_part.done",
-		"event_id":      uuid.NewString(),
-		"response_id":   responseID,
-		"item_id":       itemID,
-		"output_index":  0,
-		"content_index": 0,
-		"part": map[string]interface{}{
-			"type":       "audio",
-			"transcript": event.Text,
-		},
-	}
-	if err := sendJSONEvent(conn, partDone); err != nil {
-		return
+	// A cancelled response ends with whatever was streamed so far
+	status, itemStatus := "completed", "completed"
+	var statusDetails interface{}
+	if resp.isCancelled() {
+		status, itemStatus = "cancelled", "incomplete"
+		statusDetails = map[string]interface{}{"type": "cancelled", "reason": "client_cancelled"}
+		session.logf("Client %s: Response %s cancelled after %dms of audio", conn.RemoteAddr(), responseID, session.itemAudioMs(itemID))
 	}
 
 	// response.output_item.done
-	itemDoneContent := []interface{}{
-		map[string]interface{}{
-			"type":       "audio",
-			"transcript": event.Text,
-			// "audio": "..." // We don't include full audio in done event usually to save bandwidth in logs, but API might
-		},
-	}
-
 	itemDone := map[string]interface{}{
 		"type":         "response.output_item.done",
 		"event_id":     uuid.NewString(),
@@ -462,36 +917,33 @@ func streamMessageResponse(conn *SafeWebSocket, event Event, sessionID string) {
 			"id":      itemID,
 			"object":  "realtime.item",
 			"type":    "message",
-			"status":  "completed",
+			"status":  itemStatus,
 			"role":    "assistant",
-			"content": itemDoneContent,
+			"content": content,
 		},
 	}
 	if err := sendJSONEvent(conn, itemDone); err != nil {
 		return
 	}
+	session.rememberItem(itemDone["item"].(map[string]interface{}))
 
 	// response.done
 	respDone := map[string]interface{}{
 		"type":     "response.done",
 		"event_id": uuid.NewString(),
 		"response": map[string]interface{}{
-			"id":     responseID,
-			"object": "realtime.response",
-			"status": "completed",
+			"id":             responseID,
+			"object":         "realtime.response",
+			"status":         status,
+			"status_details": statusDetails,
 			"output": []interface{}{
 				map[string]interface{}{
-					"id":     itemID,
-					"object": "realtime.item",
-					"type":   "message",
-					"status": "completed",
-					"role":   "assistant",
-					"content": []interface{}{
-						map[string]interface{}{
-							"type":       "audio",
-							"transcript": event.Text,
-						},
-					},
+					"id":      itemID,
+					"object":  "realtime.item",
+					"type":    "message",
+					"status":  itemStatus,
+					"role":    "assistant",
+					"content": content,
 				},
 			},
 		},
@@ -499,9 +951,96 @@ func streamMessageResponse(conn *SafeWebSocket, event Event, sessionID string) {
 	sendJSONEvent(conn, respDone)
 }
 
-func sendFunctionCall(conn *SafeWebSocket, event Event, sessionID string) {
+// streamContentPart streams a single content part of a message item, from
+// response.content_part.added to response.content_part.done, and returns the finished part.
+// Audio parts stream audio and transcript concurrently, text parts only stream text deltas.
+func streamContentPart(session *MockSession, resp *activeResponse, itemID string, contentIndex int, part ContentPart, event Event) (map[string]interface{}, error) {
+	conn := session.Conn
+
+	// Audio-related settings (audio_fit, transcriptSync) are computed against this part's text
+	partEvent := event
+	partEvent.Text = part.Text
+
+	added := map[string]interface{}{"type": "audio", "transcript": ""}
+	if part.Type == "text" {
+		added = map[string]interface{}{"type": "text", "text": ""}
+	}
+	partAdded := map[string]interface{}{
+		"type":          "response.content_part.added",
+		"event_id":      uuid.NewString(),
+		"response_id":   resp.id,
+		"item_id":       itemID,
+		"output_index":  0,
+		"content_index": contentIndex,
+		"part":          added,
+	}
+	if err := sendJSONEvent(conn, partAdded); err != nil {
+		return nil, err
+	}
+
+	var finished map[string]interface{}
+	if part.Type == "text" {
+		text := streamText(conn, resp, itemID, contentIndex, part.Text, session.chunkInterval(event))
+		finished = map[string]interface{}{"type": "text", "text": text}
+	} else {
+		// Stream Audio and Transcript concurrently
+		var wg sync.WaitGroup
+		var audioChunks []audioChunk
+		if audioPath := session.Tenant.audioPath(session.config, session.Voice); audioPath != "" {
+			var err error
+			// Only the first audio part of a response gets the marker
+			marker, markerSeq := session.config.Mock.ResponseMarker, 0
+			if marker != "" && marker != "none" && !resp.marked {
+				markerSeq = resp.seq
+				resp.marked = true
+			}
+			audioChunks, err = responseAudioChunks(audioAsset{audioPath, session.config.Mock.AudioNormalize}, partEvent, session.chunkSize(event), session.output(), marker, markerSeq)
+			if err != nil {
+				session.logf("Client %s: ERROR loading audio file %s: %v", conn.RemoteAddr(), audioPath, err)
+			}
+		}
+		audioInterval, transcriptInterval := streamIntervals(session.config.Mock.TranscriptSync, partEvent, session.chunkInterval(event), len(audioChunks))
+
+		if audioChunks != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				streamAudio(session, resp, itemID, contentIndex, audioChunks, audioInterval)
+			}()
+		}
+
+		transcript := part.Text
+		if part.Text != "" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				transcript = streamTranscript(conn, resp, itemID, contentIndex, part.Text, transcriptInterval)
+			}()
+		}
+
+		wg.Wait()
+		finished = map[string]interface{}{"type": "audio", "transcript": transcript}
+	}
+
+	partDone := map[string]interface{}{
+		"type":          "response.content_part.done",
+		"event_id":      uuid.NewString(),
+		"response_id":   resp.id,
+		"item_id":       itemID,
+		"output_index":  0,
+		"content_index": contentIndex,
+		"part":          finished,
+	}
+	if err := sendJSONEvent(conn, partDone); err != nil {
+		return nil, err
+	}
+	return finished, nil
+}
+
+func sendFunctionCall(session *MockSession, event Event) {
+	conn := session.Conn
 	if event.FunctionCall == nil {
-		log.Printf("Error: FunctionCall definition missing for event")
+		session.logf("Error: FunctionCall definition missing for event")
 		return
 	}
 
@@ -603,6 +1142,15 @@ func sendFunctionCall(conn *SafeWebSocket, event Event, sessionID string) {
 	}
 
 	// response.done
+	item := map[string]interface{}{
+		"id":        itemID,
+		"object":    "realtime.item",
+		"type":      "function_call",
+		"status":    "completed",
+		"name":      event.FunctionCall.Name,
+		"call_id":   callID,
+		"arguments": args,
+	}
 	respDone := map[string]interface{}{
 		"type":     "response.done",
 		"event_id": uuid.NewString(),
@@ -610,23 +1158,17 @@ func sendFunctionCall(conn *SafeWebSocket, event Event, sessionID string) {
 			"id":     responseID,
 			"object": "realtime.response",
 			"status": "completed",
-			"output": []interface{}{
-				map[string]interface{}{
-					"id":        itemID,
-					"object":    "realtime.item",
-					"type":      "function_call",
-					"status":    "completed",
-					"name":      event.FunctionCall.Name,
-					"call_id":   callID,
-					"arguments": args,
-				},
-			},
+			"output": []interface{}{item},
 		},
 	}
-	sendJSONEvent(conn, respDone)
+	if err := sendJSONEvent(conn, respDone); err != nil {
+		return
+	}
+	session.rememberItem(item)
 }
 
-func sendUserTranscription(conn *SafeWebSocket, event Event, sessionID string) {
+func sendUserTranscription(session *MockSession, event Event) {
+	conn := session.Conn
 	itemID := "mock-item-trans-" + uuid.NewString()
 
 	// 1. input_audio_buffer.committed
@@ -637,7 +1179,7 @@ func sendUserTranscription(conn *SafeWebSocket, event Event, sessionID string) {
 		"item_id":          itemID,
 	}
 	if err := sendJSONEvent(conn, committed); err != nil {
-		log.Printf("Failed to send input_audio_buffer.committed: %v", err)
+		session.logf("Failed to send input_audio_buffer.committed: %v", err)
 		return
 	}
 
@@ -661,71 +1203,94 @@ func sendUserTranscription(conn *SafeWebSocket, event Event, sessionID string) {
 		},
 	}
 	if err := sendJSONEvent(conn, itemCreated); err != nil {
-		log.Printf("Failed to send conversation.item.created: %v", err)
+		session.logf("Failed to send conversation.item.created: %v", err)
 		return
 	}
+	session.rememberItem(itemCreated["item"].(map[string]interface{}))
 
 	// 3. conversation.item.input_audio_transcription.completed
-	transcriptionCompleted := map[string]interface{}{
-		"type":          "conversation.item.input_audio_transcription.completed",
-		"event_id":      uuid.NewString(),
-		"item_id":       itemID,
-		"content_index": 0,
-		"transcript":    event.Text,
+	// With an STT backend configured, the transcript reflects the audio the client actually sent
+	var audio []byte
+	if inputTranscriber != nil {
+		audio, _ = session.takeInputAudio()
 	}
-	if err := sendJSONEvent(conn, transcriptionCompleted); err != nil {
-		log.Printf("Failed to send user transcription: %v", err)
+	confidence := session.config.Mock.Transcription.Confidence
+	if event.Confidence != nil {
+		confidence = event.Confidence
 	}
+	session.sendInputTranscription(itemID, audio, event.Text, confidence)
 }
 
-func streamAudio(conn *SafeWebSocket, responseID, itemID string, contentIndex int) {
-	file, err := os.Open(appConfig.Mock.AudioWavPath)
-	if err != nil {
-		log.Printf("Client %s: ERROR opening audio file %s: %v", conn.RemoteAddr(), appConfig.Mock.AudioWavPath, err)
+// streamIntervals returns the tick intervals for the audio and transcript streams of a message.
+// With a transcriptSync mode, one stream is paced so both finish at roughly the same time.
+func streamIntervals(mode string, event Event, base time.Duration, chunks int) (audio time.Duration, transcript time.Duration) {
+	audio, transcript = base, base
+
+	if mode == "" || mode == "none" {
 		return
 	}
-	defer file.Close()
 
-	// Skip WAV header (assume 44 bytes)
-	header := make([]byte, 44)
-	if _, err = io.ReadFull(file, header); err != nil {
-		log.Printf("Client %s: ERROR reading WAV header: %v", conn.RemoteAddr(), err)
+	words := len(strings.Fields(event.Text))
+	if words == 0 || chunks == 0 {
 		return
 	}
 
-	buffer := make([]byte, appConfig.Mock.AudioChunkSizeBytes)
-	ticker := time.NewTicker(time.Duration(appConfig.Mock.ChunkIntervalMs) * time.Millisecond)
+	switch mode {
+	case "audio":
+		// Audio keeps its pace, the transcript is stretched or squeezed over the audio duration
+		transcript = base * time.Duration(chunks) / time.Duration(words)
+	case "transcript":
+		// Transcript keeps its pace, the audio chunks are spread over the transcript duration
+		audio = base * time.Duration(words) / time.Duration(chunks)
+	}
+	if audio <= 0 {
+		audio = time.Millisecond
+	}
+	if transcript <= 0 {
+		transcript = time.Millisecond
+	}
+	return
+}
+
+func streamAudio(session *MockSession, resp *activeResponse, itemID string, contentIndex int, chunks []audioChunk, interval time.Duration) {
+	conn := session.Conn
+	responseID := resp.id
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	// Note: response.content_part.added is now sent in streamMessageResponse
+	// Note: response.content_part.added is sent in streamContentPart
+	session.addItemAudio(itemID, contentIndex, 0) // The part can be truncated before its first chunk
 
-	for range ticker.C {
-		n, err := file.Read(buffer)
-		if n > 0 {
-			audioData := buffer[:n]
-			encodedData := base64.StdEncoding.EncodeToString(audioData)
-
-			audioDelta := map[string]interface{}{
-				"type":          "response.audio.delta",
-				"event_id":      uuid.NewString(),
-				"response_id":   responseID,
-				"item_id":       itemID,
-				"output_index":  0,
-				"content_index": contentIndex,
-				"delta":         encodedData,
-			}
-			if err := sendJSONEvent(conn, audioDelta); err != nil {
+chunkLoop:
+	for _, chunk := range chunks {
+		select {
+		case <-ticker.C:
+		case <-resp.cancelled:
+			break chunkLoop
+		}
+
+		// Binary transport sends raw PCM frames, control events stay JSON
+		if session.BinaryAudio {
+			if err := conn.WriteMessage(websocket.BinaryMessage, chunk.data); err != nil {
 				return
 			}
+			session.addItemAudio(itemID, contentIndex, chunk.decodedSize)
+			continue
 		}
 
-		if err == io.EOF {
-			break
+		audioDelta := map[string]interface{}{
+			"type":          "response.audio.delta",
+			"event_id":      uuid.NewString(),
+			"response_id":   responseID,
+			"item_id":       itemID,
+			"output_index":  0,
+			"content_index": contentIndex,
+			"delta":         chunk.encoded,
 		}
-		if err != nil {
-			log.Printf("Error reading audio: %v", err)
-			break
+		if err := sendJSONEvent(conn, audioDelta); err != nil {
+			return
 		}
+		session.addItemAudio(itemID, contentIndex, chunk.decodedSize)
 	}
 
 	// response.output_audio.done
@@ -740,20 +1305,23 @@ func streamAudio(conn *SafeWebSocket, responseID, itemID string, contentIndex in
 	sendJSONEvent(conn, audioDone)
 }
 
-func streamTranscript(conn *SafeWebSocket, responseID, itemID string, contentIndex int, text string) {
+// streamTranscript streams the transcript word by word and returns the part that was actually sent,
+// which is shorter than text when the response gets cancelled.
+func streamTranscript(conn *SafeWebSocket, resp *activeResponse, itemID string, contentIndex int, text string, interval time.Duration) string {
+	responseID := resp.id
 	words := strings.Fields(text)
 	if len(words) == 0 {
-		return
+		return text
 	}
 
-	ticker := time.NewTicker(time.Duration(appConfig.Mock.ChunkIntervalMs) * time.Millisecond)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	// Note: response.content_part.added is now sent in streamMessageResponse
+	// Note: response.content_part.added is sent in streamContentPart
 
 	wordIndex := 0
 	for range ticker.C {
-		if wordIndex >= len(words) {
+		if wordIndex >= len(words) || resp.isCancelled() {
 			break
 		}
 
@@ -768,11 +1336,15 @@ func streamTranscript(conn *SafeWebSocket, responseID, itemID string, contentInd
 			"delta":         delta,
 		}
 		if err := sendJSONEvent(conn, transcriptDelta); err != nil {
-			return
+			return strings.Join(words[:wordIndex], " ")
 		}
 		wordIndex++
 	}
 
+	if wordIndex < len(words) {
+		text = strings.Join(words[:wordIndex], " ")
+	}
+
 	// response.output_audio_transcript.done
 	transcriptDone := map[string]interface{}{
 		"type":          "response.output_audio_transcript.done",
@@ -784,4 +1356,48 @@ func streamTranscript(conn *SafeWebSocket, responseID, itemID string, contentInd
 		"transcript":    text,
 	}
 	sendJSONEvent(conn, transcriptDone)
+	return text
+}
+
+// streamText streams a text content part word by word and returns the part that was actually sent.
+func streamText(conn *SafeWebSocket, resp *activeResponse, itemID string, contentIndex int, text string, interval time.Duration) string {
+	words := strings.Fields(text)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	wordIndex := 0
+	for wordIndex < len(words) && !resp.isCancelled() {
+		<-ticker.C
+		textDelta := map[string]interface{}{
+			"type":          "response.text.delta",
+			"event_id":      uuid.NewString(),
+			"response_id":   resp.id,
+			"item_id":       itemID,
+			"output_index":  0,
+			"content_index": contentIndex,
+			"delta":         words[wordIndex] + " ",
+		}
+		if err := sendJSONEvent(conn, textDelta); err != nil {
+			return strings.Join(words[:wordIndex], " ")
+		}
+		wordIndex++
+	}
+
+	if wordIndex < len(words) {
+		text = strings.Join(words[:wordIndex], " ")
+	}
+
+	// response.output_text.done
+	textDone := map[string]interface{}{
+		"type":          "response.output_text.done",
+		"event_id":      uuid.NewString(),
+		"response_id":   resp.id,
+		"item_id":       itemID,
+		"output_index":  0,
+		"content_index": contentIndex,
+		"text":          text,
+	}
+	sendJSONEvent(conn, textDone)
+	return text
 }
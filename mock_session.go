@@ -0,0 +1,482 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Mock Session State ---
+
+// TurnDetection mirrors the turn_detection object of a Realtime session.
+type TurnDetection struct {
+	Type              string   `yaml:"type" json:"type"` // "server_vad", "semantic_vad" or "none"
+	Threshold         *float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	PrefixPaddingMs   *int     `yaml:"prefix_padding_ms,omitempty" json:"prefix_padding_ms,omitempty"`
+	SilenceDurationMs *int     `yaml:"silence_duration_ms,omitempty" json:"silence_duration_ms,omitempty"`
+	Eagerness         string   `yaml:"eagerness,omitempty" json:"eagerness,omitempty"` // semantic_vad only: "low", "medium", "high", "auto"
+	CreateResponse    *bool    `yaml:"create_response,omitempty" json:"create_response,omitempty"`
+}
+
+// createResponse reports whether a response should be started after an automatic commit.
+func (t *TurnDetection) createResponse() bool {
+	return t.CreateResponse == nil || *t.CreateResponse
+}
+
+// validate checks the turn detection settings the same way the real API rejects bad values.
+// It returns the offending param name along with the error.
+func (t *TurnDetection) validate() (string, error) {
+	switch t.Type {
+	case "server_vad", "none":
+		if t.Eagerness != "" {
+			return "turn_detection.eagerness", fmt.Errorf("eagerness is only supported for semantic_vad")
+		}
+	case "semantic_vad":
+		switch t.Eagerness {
+		case "", "low", "medium", "high", "auto":
+		default:
+			return "turn_detection.eagerness", fmt.Errorf("unsupported eagerness '%s'", t.Eagerness)
+		}
+	default:
+		return "turn_detection.type", fmt.Errorf("unsupported value '%s'", t.Type)
+	}
+	if t.Threshold != nil && (*t.Threshold < 0 || *t.Threshold > 1) {
+		return "turn_detection.threshold", fmt.Errorf("threshold must be between 0.0 and 1.0, got %g", *t.Threshold)
+	}
+	if t.PrefixPaddingMs != nil && *t.PrefixPaddingMs < 0 {
+		return "turn_detection.prefix_padding_ms", fmt.Errorf("prefix_padding_ms must not be negative")
+	}
+	if t.SilenceDurationMs != nil && *t.SilenceDurationMs < 0 {
+		return "turn_detection.silence_duration_ms", fmt.Errorf("silence_duration_ms must not be negative")
+	}
+	return "", nil
+}
+
+// withDefaults returns a copy with every unset tunable filled in, as echoed back by the API.
+func (t *TurnDetection) withDefaults() *TurnDetection {
+	out := *t
+	if out.Type == "semantic_vad" {
+		if out.Eagerness == "" {
+			out.Eagerness = "auto"
+		}
+		return &out
+	}
+	if out.Threshold == nil {
+		v := defaultVADThreshold
+		out.Threshold = &v
+	}
+	if out.PrefixPaddingMs == nil {
+		v := defaultVADPrefixPaddingMs
+		out.PrefixPaddingMs = &v
+	}
+	if out.SilenceDurationMs == nil {
+		v := defaultVADSilenceDurationMs
+		out.SilenceDurationMs = &v
+	}
+	return &out
+}
+
+// SessionUpdateEvent is the client event used to change the session configuration.
+type SessionUpdateEvent struct {
+	Type    string `json:"type"`
+	EventID string `json:"event_id,omitempty"`
+	Session struct {
+		Voice             string          `json:"voice,omitempty"`
+		InputAudioFormat  string          `json:"input_audio_format,omitempty"`
+		OutputAudioFormat string          `json:"output_audio_format,omitempty"`
+		TurnDetection     json.RawMessage `json:"turn_detection,omitempty"`
+
+		InputAudioTranscription *InputAudioTranscription `json:"input_audio_transcription,omitempty"`
+	} `json:"session"`
+}
+
+// MockSession holds the per-connection state of a mock WebSocket session.
+// It is only mutated from the connection's read loop.
+type MockSession struct {
+	Conn              *SafeWebSocket
+	ID                string
+	InputAudioFormat  string
+	OutputAudioFormat string
+	Profile           AudioProfile
+	Voice             string // Selects the audio variant from mock.voiceAudioDirs
+	Model             string
+	Modalities        []string
+	Instructions      string
+	TurnDetection     *TurnDetection // nil means turn detection is disabled
+	BinaryAudio       bool           // Deliver output audio as binary WebSocket frames instead of JSON deltas
+	Tenant            *TenantConfig  // nil outside tenants
+	config            *Config        // The config the client connected with, kept through reloads
+
+	// Transcription sessions (?intent=transcription) only transcribe committed audio
+	Transcription      bool
+	InputTranscription *InputAudioTranscription
+	transcripts        []string // Scenario texts handed out in turn without an STT backend
+	transcriptIndex    int
+	transcriptionTurns chan transcriptionTurn
+
+	lastItemID string // ID of the most recent user item, used as previous_item_id
+	vad        vadState
+
+	// What a reconnect with ?resume= continues from
+	historyMu       sync.Mutex
+	history         []map[string]interface{} // Conversation items in the order they were created
+	scenarioPos     atomic.Int32             // Scenario events played to the end
+	scenarioStarted atomic.Bool
+	ended           atomic.Bool // The client disconnected
+
+	// Response state is shared with the scenario goroutine streaming responses
+	responseMu     sync.Mutex
+	activeResponse *activeResponse
+	responseCount  int
+	itemAudioBytes map[string]map[int]int // Output audio delivered per assistant item and content index
+
+	// The input buffer is shared with the scenario goroutine (user_transcription events)
+	audioMu          sync.Mutex
+	inputBufferBytes int    // Bytes appended since the last commit or clear
+	inputAudio       []byte // The appended audio itself, only retained when an STT backend needs it
+
+	// StartResponse kicks off the selected scenario or replay.
+	// The trigger (turnTriggerResponse or turnTriggerCommit) matters to interactive replays only.
+	StartResponse func(trigger string)
+}
+
+// NewMockSession creates the state for a freshly connected client using the mock defaults of cfg.
+func NewMockSession(conn *SafeWebSocket, sessionID string, cfg *Config) *MockSession {
+	s := &MockSession{
+		Conn:        conn,
+		ID:          sessionID,
+		Voice:       defaultVoice,
+		Model:       mockModel,
+		Modalities:  []string{"audio", "text"},
+		BinaryAudio: cfg.Mock.BinaryAudio,
+		config:      cfg,
+	}
+	if err := s.setAudioProfile(cfg.Mock.AudioProfile); err != nil {
+		s.setAudioProfile("default")
+	}
+	if td := cfg.Mock.TurnDetection; td != nil && td.Type != "" && td.Type != "none" {
+		s.TurnDetection = td.withDefaults()
+	}
+	return s
+}
+
+// setAudioProfile switches the session to a named audio profile and its default formats.
+func (s *MockSession) setAudioProfile(name string) error {
+	profile, err := lookupAudioProfile(name)
+	if err != nil {
+		return err
+	}
+	s.Profile = profile
+	s.InputAudioFormat = profile.InputFormat
+	s.OutputAudioFormat = profile.OutputFormat
+	return nil
+}
+
+// output returns the encoding used for response audio.
+func (s *MockSession) output() audioOutput {
+	return audioOutput{Format: s.OutputAudioFormat, SampleRate: formatSampleRate(s.OutputAudioFormat, s.Profile)}
+}
+
+// inputSampleRate returns the sample rate of the client's input audio.
+func (s *MockSession) inputSampleRate() int {
+	return formatSampleRate(s.InputAudioFormat, s.Profile)
+}
+
+// chunkSize returns the output audio chunk size in encoded bytes for a message event.
+// Event overrides win, then the profile's frame size, then the mock config (scaled to the output format).
+// The format is only known per session, so an odd override is rounded down to whole PCM16 samples here;
+// G.711 has one byte per sample and takes any size.
+func (s *MockSession) chunkSize(event Event) int {
+	out := s.output()
+	if event.ChunkSizeBytes > 0 {
+		if !isG711(out.Format) {
+			return max(event.ChunkSizeBytes&^1, 2)
+		}
+		return event.ChunkSizeBytes
+	}
+	if s.Profile.FrameMs > 0 {
+		return s.Profile.FrameMs * out.bytesPerMs()
+	}
+	return s.config.Mock.AudioChunkSizeBytes * out.bytesPerMs() / pcm16BytesPerMs
+}
+
+// chunkInterval returns the delta interval for a message event.
+func (s *MockSession) chunkInterval(event Event) time.Duration {
+	if event.ChunkIntervalMs > 0 {
+		return time.Duration(event.ChunkIntervalMs) * time.Millisecond
+	}
+	if s.Profile.FrameMs > 0 {
+		return time.Duration(s.Profile.FrameMs) * time.Millisecond
+	}
+	return time.Duration(s.config.Mock.ChunkIntervalMs) * time.Millisecond
+}
+
+// sessionObject returns the session as announced in session.created/session.updated.
+func (s *MockSession) sessionObject() SessionObject {
+	return SessionObject{
+		ID:                s.ID,
+		Object:            "realtime.session",
+		Model:             s.Model,
+		InputAudioFormat:  s.InputAudioFormat,
+		OutputAudioFormat: s.OutputAudioFormat,
+		Modalities:        s.Modalities,
+		Voice:             s.Voice,
+		Instructions:      s.Instructions,
+		TurnDetection:     s.TurnDetection,
+	}
+}
+
+// vadEnabled reports whether server-side voice activity detection is active.
+func (s *MockSession) vadEnabled() bool {
+	return s.TurnDetection != nil && (s.TurnDetection.Type == "server_vad" || s.TurnDetection.Type == "semantic_vad")
+}
+
+// handleSessionUpdate applies a session.update from the client and replies with session.updated.
+// Transcription sessions get their transcription_session.update here too.
+func (s *MockSession) handleSessionUpdate(message []byte) {
+	var update SessionUpdateEvent
+	if err := json.Unmarshal(message, &update); err != nil {
+		sendErrorEvent(s.Conn, "invalid_request_error", "invalid_event", "Invalid session.update payload.", "", "")
+		return
+	}
+
+	for param, format := range map[string]string{
+		"input_audio_format":  update.Session.InputAudioFormat,
+		"output_audio_format": update.Session.OutputAudioFormat,
+	} {
+		if format != "" && !isSupportedAudioFormat(format) {
+			sendErrorEvent(s.Conn, "invalid_request_error", "invalid_value",
+				fmt.Sprintf("Invalid 'session.%s': unsupported value '%s'.", param, format),
+				"session."+param, update.EventID)
+			return
+		}
+	}
+	if update.Session.Voice != "" {
+		s.Voice = update.Session.Voice
+	}
+	if update.Session.InputAudioFormat != "" {
+		s.InputAudioFormat = update.Session.InputAudioFormat
+	}
+	if update.Session.OutputAudioFormat != "" {
+		s.OutputAudioFormat = update.Session.OutputAudioFormat
+	}
+
+	if len(update.Session.TurnDetection) > 0 {
+		if string(update.Session.TurnDetection) == "null" {
+			s.TurnDetection = nil
+		} else {
+			var td TurnDetection
+			if err := json.Unmarshal(update.Session.TurnDetection, &td); err != nil {
+				sendErrorEvent(s.Conn, "invalid_request_error", "invalid_value", "Invalid 'session.turn_detection'.", "session.turn_detection", update.EventID)
+				return
+			}
+			if param, err := td.validate(); err != nil {
+				sendErrorEvent(s.Conn, "invalid_request_error", "invalid_value",
+					fmt.Sprintf("Invalid 'session.%s': %v.", param, err),
+					"session."+param, update.EventID)
+				return
+			}
+			if td.Type == "none" {
+				s.TurnDetection = nil
+			} else {
+				s.TurnDetection = td.withDefaults()
+			}
+		}
+		s.vad = vadState{}
+	}
+	if update.Session.InputAudioTranscription != nil {
+		s.InputTranscription = update.Session.InputAudioTranscription
+	}
+
+	if s.Transcription {
+		s.logf("Client %s: Transcription session updated (input format: %s, turn detection: %v)", s.Conn.RemoteAddr(), s.InputAudioFormat, s.vadEnabled())
+		sendJSONEvent(s.Conn, map[string]interface{}{
+			"type":     "transcription_session.updated",
+			"event_id": uuid.NewString(),
+			"session":  s.transcriptionSessionObject(),
+		})
+		return
+	}
+	s.logf("Client %s: Session updated (voice: %s, input format: %s, turn detection: %v)", s.Conn.RemoteAddr(), s.Voice, s.InputAudioFormat, s.vadEnabled())
+	sendJSONEvent(s.Conn, map[string]interface{}{
+		"type":     "session.updated",
+		"event_id": uuid.NewString(),
+		"session":  s.sessionObject(),
+	})
+}
+
+// appendInputAudio adds decoded audio to the input buffer and runs VAD if enabled.
+// Appends that would push the uncommitted buffer past mock.inputBufferMaxMs are rejected.
+func (s *MockSession) appendInputAudio(data []byte) error {
+	bytesPerMs := audioOutput{Format: s.InputAudioFormat, SampleRate: s.inputSampleRate()}.bytesPerMs()
+
+	s.audioMu.Lock()
+	if maxMs := s.config.Mock.InputBufferMaxMs; maxMs > 0 && s.inputBufferBytes+len(data) > maxMs*bytesPerMs {
+		bufferedMs := s.inputBufferBytes / bytesPerMs
+		s.audioMu.Unlock()
+		return fmt.Errorf("buffer exceeds the maximum of %dms of uncommitted audio (buffer has %dms, append adds %dms); commit or clear the buffer first",
+			maxMs, bufferedMs, len(data)/bytesPerMs)
+	}
+	s.inputBufferBytes += len(data)
+	if inputTranscriber != nil {
+		s.inputAudio = append(s.inputAudio, data...)
+	}
+	s.audioMu.Unlock()
+
+	if s.vadEnabled() {
+		s.processVAD(decodeToPCM16(data, s.InputAudioFormat))
+	}
+	return nil
+}
+
+// commitInputBuffer commits the input buffer as a user item.
+// itemID is the ID announced by speech_started when the commit comes from server VAD.
+func (s *MockSession) commitInputBuffer(clientEventID string, itemID string) {
+	audio, size := s.takeInputAudio()
+	if size == 0 {
+		sendErrorEvent(s.Conn, "invalid_request_error", "input_audio_buffer_commit_empty",
+			"Error committing input audio buffer: buffer too small. Expected at least 100ms of audio, but buffer only has 0.00ms of audio.",
+			"", clientEventID)
+		return
+	}
+
+	if itemID == "" {
+		itemID = "mock-item-input-" + uuid.NewString()
+	}
+	var previousItemID interface{}
+	if s.lastItemID != "" {
+		previousItemID = s.lastItemID
+	}
+
+	committed := map[string]interface{}{
+		"type":             "input_audio_buffer.committed",
+		"event_id":         uuid.NewString(),
+		"previous_item_id": previousItemID,
+		"item_id":          itemID,
+	}
+	if err := sendJSONEvent(s.Conn, committed); err != nil {
+		return
+	}
+
+	itemCreated := map[string]interface{}{
+		"type":             "conversation.item.created",
+		"event_id":         uuid.NewString(),
+		"previous_item_id": previousItemID,
+		"item": map[string]interface{}{
+			"id":     itemID,
+			"object": "realtime.item",
+			"type":   "message",
+			"status": "completed",
+			"role":   "user",
+			"content": []interface{}{
+				map[string]interface{}{
+					"type":       "input_audio",
+					"transcript": nil,
+				},
+			},
+		},
+	}
+	sendJSONEvent(s.Conn, itemCreated)
+	s.rememberItem(itemCreated["item"].(map[string]interface{}))
+
+	s.lastItemID = itemID
+
+	if s.Transcription {
+		// The read loop must not wait for a slow transcription backend, or it would stop reading
+		// the client's events; turns that don't fit the backlog fail instead.
+		select {
+		case s.transcriptionTurns <- transcriptionTurn{itemID: itemID, audio: audio}:
+		default:
+			s.logf("Client %s: %d turns are waiting to be transcribed, item %s fails", s.Conn.RemoteAddr(), transcriptionBacklog, itemID)
+			failed := transcriptionFailedEvent(itemID)
+			failed["error"] = map[string]interface{}{
+				"type":    "server_error",
+				"code":    "transcription_backlog_full",
+				"message": "Too many turns are waiting to be transcribed.",
+				"param":   nil,
+			}
+			sendJSONEvent(s.Conn, failed)
+		}
+		return
+	}
+	if inputTranscriber != nil {
+		go s.sendInputTranscription(itemID, audio, "", s.config.Mock.Transcription.Confidence)
+	}
+}
+
+// takeInputAudio returns the uncommitted input audio (if retained) and its size, and empties the buffer.
+func (s *MockSession) takeInputAudio() ([]byte, int) {
+	s.audioMu.Lock()
+	defer s.audioMu.Unlock()
+	audio, size := s.inputAudio, s.inputBufferBytes
+	s.inputAudio = nil
+	s.inputBufferBytes = 0
+	return audio, size
+}
+
+// sendInputTranscription transcribes audio for a user item and emits the completed (or failed) event.
+// fallback is used as the transcript when there is no audio to transcribe.
+// A non-nil confidence adds fake per-token logprobs to the completed event.
+func (s *MockSession) sendInputTranscription(itemID string, audio []byte, fallback string, confidence *float64) {
+	transcript := fallback
+	if len(audio) > 0 && inputTranscriber != nil {
+		text, err := transcribeInputAudio(decodeToPCM16(audio, s.InputAudioFormat), s.inputSampleRate())
+		if err != nil {
+			s.logf("Client %s: Input transcription failed: %v", s.Conn.RemoteAddr(), err)
+			if fallback == "" {
+				sendJSONEvent(s.Conn, transcriptionFailedEvent(itemID))
+				return
+			}
+		} else {
+			transcript = text
+		}
+	}
+
+	if err := sendJSONEvent(s.Conn, transcriptionCompletedEvent(itemID, transcript, confidence)); err != nil {
+		s.logf("Failed to send user transcription: %v", err)
+	}
+	s.rememberTranscript(itemID, transcript)
+}
+
+func transcriptionCompletedEvent(itemID, transcript string, confidence *float64) map[string]interface{} {
+	event := map[string]interface{}{
+		"type":          "conversation.item.input_audio_transcription.completed",
+		"event_id":      uuid.NewString(),
+		"item_id":       itemID,
+		"content_index": 0,
+		"transcript":    transcript,
+	}
+	if confidence != nil {
+		event["logprobs"] = transcriptionLogprobs(transcript, *confidence)
+	}
+	return event
+}
+
+func transcriptionFailedEvent(itemID string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":          "conversation.item.input_audio_transcription.failed",
+		"event_id":      uuid.NewString(),
+		"item_id":       itemID,
+		"content_index": 0,
+		"error": map[string]interface{}{
+			"type":    "transcription_error",
+			"code":    "audio_unintelligible",
+			"message": "The audio could not be transcribed.",
+			"param":   nil,
+		},
+	}
+}
+
+// clearInputBuffer discards the uncommitted input audio.
+func (s *MockSession) clearInputBuffer() {
+	s.takeInputAudio()
+	s.vad = vadState{cursorMs: s.vad.cursorMs}
+	sendJSONEvent(s.Conn, map[string]interface{}{
+		"type":     "input_audio_buffer.cleared",
+		"event_id": uuid.NewString(),
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// --- Runtime Mode Switching ---
+
+var modes = []string{"mock", "proxy", "vcr"}
+
+// currentMode returns the mode new connections are served in, changed at runtime with PUT /mode.
+func currentMode() string {
+	return currentConfig().Mode
+}
+
+// connectionMode returns the mode a connection is served in: ?mode= or the server's mode.
+func connectionMode(r *http.Request) string {
+	return cmp.Or(r.URL.Query().Get("mode"), requestConfig(r).Mode)
+}
+
+// checkMode reports whether connections can be served in mode with the config cfg.
+func checkMode(cfg *Config, mode string) error {
+	switch {
+	case !slices.Contains(modes, mode):
+		return fmt.Errorf("unknown mode: %s", mode)
+	case mode == "mock" && len(cfg.Scenarios) == 0 && !cfg.Library.enabled():
+		return fmt.Errorf("no scenarios defined for mock mode")
+	case mode != "mock" && cfg.Proxy.URL == "":
+		return fmt.Errorf("%s mode needs proxy.url", mode)
+	case mode == "vcr" && cmp.Or(cfg.Proxy.Storage.Backend, "files") != "files":
+		return fmt.Errorf("vcr mode needs the files storage backend")
+	}
+	return nil
+}
+
+// checkConnectionMode validates a ?mode= override against the config of the connection.
+func checkConnectionMode(r *http.Request, mode string) error {
+	return checkMode(requestConfig(r), mode)
+}
+
+// ModeSwitch is the body of PUT /mode and the answer to GET and PUT /mode.
+type ModeSwitch struct {
+	Mode     string `json:"mode"`
+	Previous string `json:"previous,omitempty"`
+}
+
+func handleGetMode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ModeSwitch{Mode: currentMode()})
+}
+
+// handlePutMode switches the server's mode, 409 Conflict if the config can't serve it. Connections already open keep the mode they
+// started in, new ones get the new mode unless they ask for another with ?mode=.
+func handlePutMode(w http.ResponseWriter, r *http.Request) {
+	var body ModeSwitch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(modes, body.Mode) {
+		http.Error(w, fmt.Sprintf("unknown mode: %s", body.Mode), http.StatusBadRequest)
+		return
+	}
+	configMu.Lock()
+	if err := checkMode(appConfig, body.Mode); err != nil {
+		configMu.Unlock()
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	next := *appConfig
+	body.Previous, next.Mode = appConfig.Mode, body.Mode
+	appConfig = &next
+	configMu.Unlock()
+	resetUpstreamCheck()
+
+	log.Printf("Admin: Mode switched from %s to %s", body.Previous, body.Mode)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
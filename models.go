@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// --- Models ---
+
+// SDKs validate the model with GET /v1/models/{id} before connecting, so the server lists the
+// models it answers for: the mock's own, the proxy's targets and proxy.allowedModels.
+
+const mockModel = "mock-model"
+
+// modelsCreated is reported as the creation time of every model.
+var modelsCreated = time.Now().Unix()
+
+// ModelObject is an entry of GET /v1/models.
+type ModelObject struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // "model"
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// configuredModels returns the IDs of the models the server answers for, in a stable order.
+func configuredModels() []string {
+	cfg := currentConfig()
+	models := []string{mockModel, defaultTranscriptionModel}
+	if cfg.Proxy.URL != "" {
+		models = append(models, cfg.Proxy.defaultTarget().model())
+	}
+	for _, target := range cfg.Proxy.Targets {
+		models = append(models, target.model())
+	}
+	models = append(models, cfg.Proxy.AllowedModels...)
+	slices.Sort(models[2:])
+	return slices.Compact(models)
+}
+
+func modelObject(id string) ModelObject {
+	owner := "openai"
+	if id == mockModel {
+		owner = "openai-realtime-mock"
+	}
+	return ModelObject{ID: id, Object: "model", Created: modelsCreated, OwnedBy: owner}
+}
+
+func handleListModels(w http.ResponseWriter, r *http.Request) {
+	var data []ModelObject
+	for _, id := range configuredModels() {
+		data = append(data, modelObject(id))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// handleGetModel answers like the API for unknown models: 404 with an error object.
+func handleGetModel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	w.Header().Set("Content-Type", "application/json")
+	if !slices.Contains(configuredModels(), id) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": "The model '" + id + "' does not exist",
+				"type":    "invalid_request_error",
+				"param":   "model",
+				"code":    "model_not_found",
+			},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(modelObject(id))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Session Observers ---
+
+// Messages buffered per observer before further ones are dropped for it
+const observerBuffer = 256
+
+// sessionObserver is a read-only WebSocket watching a live session.
+type sessionObserver struct {
+	send chan []byte
+}
+
+// broadcast sends a copy of a message passing the client connection to all observers, in
+// the recording format, and its type to the activity feed. Slow observers miss messages rather than slowing down the session.
+func (s *liveSession) broadcast(direction string, messageType int, data []byte) {
+	s.feedMessage(direction, messageType, data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.observers) == 0 {
+		return
+	}
+
+	event := RecordedEvent{Timestamp: time.Now().UnixMilli(), Direction: direction}
+	switch messageType {
+	case websocket.TextMessage:
+		if !json.Valid(data) {
+			return
+		}
+		event.Data = json.RawMessage(data)
+	case websocket.BinaryMessage:
+		event.Frame = "binary"
+		event.Data, _ = json.Marshal(base64.StdEncoding.EncodeToString(data))
+	default:
+		return
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for observer := range s.observers {
+		select {
+		case observer.send <- line:
+		default:
+		}
+	}
+}
+
+// addObserver registers an observer, or returns nil if the session already ended.
+func (s *liveSession) addObserver() *sessionObserver {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil
+	}
+	if s.observers == nil {
+		s.observers = make(map[*sessionObserver]bool)
+	}
+	observer := &sessionObserver{send: make(chan []byte, observerBuffer)}
+	s.observers[observer] = true
+	return observer
+}
+
+func (s *liveSession) removeObserver(observer *sessionObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.observers[observer] {
+		delete(s.observers, observer)
+		close(observer.send)
+	}
+}
+
+// end disconnects all observers when the session is over.
+func (s *liveSession) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+	for observer := range s.observers {
+		delete(s.observers, observer)
+		close(observer.send)
+	}
+}
+
+// handleObserve streams a read-only copy of a live session: GET /observe/{id} upgrades to a
+// WebSocket that receives every message the client sent ("client") and received ("server").
+// It is an admin endpoint, observers see everything recordings would hold, unencrypted.
+func handleObserve(w http.ResponseWriter, r *http.Request) {
+	session := findLiveSession(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, proxyUpgradeHeader(r)) // Browsers pass the token as a subprotocol
+	if err != nil {
+		log.Printf("Observer: WebSocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	observer := session.addObserver()
+	if observer == nil {
+		writeCloseFrame(conn, closeInfo{Code: websocket.CloseNormalClosure, Reason: "session ended"})
+		return
+	}
+	log.Printf("Observer %s: Watching session %s", conn.RemoteAddr(), session.ID)
+
+	// Observers are read-only, reading only notices when they go away
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				session.removeObserver(observer)
+				return
+			}
+		}
+	}()
+
+	for line := range observer.send {
+		if err := conn.WriteMessage(websocket.TextMessage, line); err != nil {
+			session.removeObserver(observer)
+			return
+		}
+	}
+	writeCloseFrame(conn, closeInfo{Code: websocket.CloseNormalClosure, Reason: "session ended"})
+	log.Printf("Observer %s: Session %s ended", conn.RemoteAddr(), session.ID)
+}
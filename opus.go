@@ -0,0 +1,19 @@
+package main
+
+// --- Opus Output ---
+
+const (
+	opusSampleRate = 48000
+	opusFrameMs    = 20
+	// Decoded PCM16 bytes per Opus frame, used to track streamed audio duration
+	opusFrameBytes = opusSampleRate * 2 * opusFrameMs / 1000
+)
+
+// encodeOpusFrames encodes mono 48kHz PCM16 into one Opus packet per 20ms frame.
+// It is nil unless the binary is built with -tags opus (see opus_libopus.go), which needs libopus.
+var encodeOpusFrames func(pcm []byte) ([][]byte, error)
+
+// isOpus reports whether the output format is Opus.
+func isOpus(format string) bool {
+	return format == "opus"
+}
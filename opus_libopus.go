@@ -0,0 +1,41 @@
+//go:build opus
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+func init() {
+	encodeOpusFrames = encodeOpusFramesLibopus
+}
+
+// encodeOpusFramesLibopus encodes with libopus through cgo. The last frame is padded with silence.
+func encodeOpusFramesLibopus(pcm []byte) ([][]byte, error) {
+	enc, err := opus.NewEncoder(opusSampleRate, 1, opus.AppVoIP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create opus encoder: %w", err)
+	}
+
+	samplesPerFrame := opusFrameBytes / 2
+	frame := make([]int16, samplesPerFrame)
+	buf := make([]byte, 4000) // Recommended max packet size
+	var packets [][]byte
+	for offset := 0; offset < len(pcm); offset += opusFrameBytes {
+		for i := range frame {
+			frame[i] = 0
+			if pos := offset + i*2; pos+1 < len(pcm) {
+				frame[i] = int16(binary.LittleEndian.Uint16(pcm[pos:]))
+			}
+		}
+		n, err := enc.Encode(frame, buf)
+		if err != nil {
+			return nil, fmt.Errorf("opus encode failed: %w", err)
+		}
+		packets = append(packets, append([]byte(nil), buf[:n]...))
+	}
+	return packets, nil
+}
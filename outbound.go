@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Outbound Network Settings ---
+
+// OutboundConfig controls how the proxy reaches the upstream from restricted networks.
+type OutboundConfig struct {
+	// http://, https:// or socks5:// proxy for upstream connections. Empty uses the
+	// HTTPS_PROXY / HTTP_PROXY / NO_PROXY environment variables.
+	ProxyURL string `yaml:"proxyUrl" json:"proxyUrl" redact:"url"`
+	// PEM bundle trusted in addition to the system roots (e.g. a corporate MITM CA)
+	CAFile string `yaml:"caFile" json:"caFile" redact:"path"`
+	// Skip upstream certificate verification entirely. Only for debugging.
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify" json:"insecureSkipVerify"`
+}
+
+// Used for every upstream WebSocket dial and REST request, replaced by configureOutbound
+var (
+	upstreamDialer    = websocket.DefaultDialer
+	sessionHTTPClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+func (c OutboundConfig) validate() error {
+	if c.ProxyURL == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(c.ProxyURL)
+	if err != nil {
+		return fmt.Errorf("proxy.outbound.proxyUrl is invalid: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("proxy.outbound.proxyUrl must be an http, https or socks5 URL: %s", c.ProxyURL)
+	}
+	return nil
+}
+
+// configureOutbound builds the upstream dialer and HTTP client from the outbound settings.
+func configureOutbound(c OutboundConfig) error {
+	proxy := http.ProxyFromEnvironment
+	if c.ProxyURL != "" {
+		proxyURL, err := url.Parse(c.ProxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy.outbound.proxyUrl: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+		log.Printf("Proxy: Reaching upstreams through %s", proxyURL.Redacted())
+	}
+
+	var tlsConfig *tls.Config
+	if c.CAFile != "" || c.InsecureSkipVerify {
+		tlsConfig = &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+		if c.InsecureSkipVerify {
+			log.Printf("WARNING: Upstream TLS certificates are not verified (proxy.outbound.insecureSkipVerify)")
+		}
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read proxy.outbound.caFile: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("proxy.outbound.caFile %s contains no PEM certificates", c.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+		log.Printf("Proxy: Trusting additional CAs from %s", c.CAFile)
+	}
+
+	upstreamDialer = &websocket.Dialer{
+		Proxy:            proxy,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		TLSClientConfig:  tlsConfig,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	sessionHTTPClient = &http.Client{Timeout: 30 * time.Second, Transport: transport}
+	return nil
+}
@@ -2,60 +2,113 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
 // --- Proxy Mode Logic ---
 
 func handleProxyWebSocket(w http.ResponseWriter, r *http.Request) {
+	logger := requestLog(r)
+	cfg := requestConfig(r)
+	breaker := cfg.Proxy.CircuitBreaker
+	if breaker.Enabled && !proxyCircuit.allow() {
+		logger.Printf("Proxy: Circuit open, serving fallback scenario to %s", r.RemoteAddr)
+		serveFallbackScenario(w, r)
+		return
+	}
+
 	// 1. Upgrade Client Connection
-	clientConn, err := upgrader.Upgrade(w, r, nil)
+	clientConn, err := upgrader.Upgrade(w, r, withCorrelationHeader(r, proxyUpgradeHeader(r)))
 	if err != nil {
-		log.Printf("Proxy: WebSocket upgrade error: %v", err)
+		logger.Printf("Proxy: WebSocket upgrade error: %v", err)
 		return
 	}
-	safeClientConn := &SafeWebSocket{Conn: clientConn}
+	safeClientConn := newClientConn(clientConn, r)
+	if cfg.Chaos.enabled() {
+		safeClientConn.enableChaos(cfg.Chaos)
+	}
 	defer safeClientConn.Close()
-	log.Printf("Proxy: Client connected: %s", safeClientConn.RemoteAddr())
+	logger.Printf("Proxy: Client connected: %s", safeClientConn.RemoteAddr())
+	var sequence *messageSequence
+	if cfg.Proxy.RecordMetadata {
+		sequence = newMessageSequence()
+	}
 
 	// 2. Connect to OpenAI Realtime API
-	apiKey := os.Getenv("OPENAI_API_KEY")
+	targetName, target, model, err := upstreamTarget(r)
+	if err != nil {
+		logger.Printf("Proxy: Rejected upstream override: %v", err)
+		safeClientConn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"type": "error", "error": {"message": %q}}`, err.Error())))
+		return
+	}
+	logger.Printf("Proxy: Routing to %s target", targetName)
+	upstreamVersion := target.apiVersion()
+	clientVersion := clientAPIVersion(r, upstreamVersion)
+	if clientVersion != upstreamVersion {
+		logger.Printf("Proxy: Translating between %s client and %s upstream", clientVersion, upstreamVersion)
+	}
+
+	apiKey, keySource := upstreamAPIKey(r, target)
 	if apiKey == "" {
-		log.Printf("Proxy: Error - OPENAI_API_KEY environment variable not set")
-		safeClientConn.WriteMessage(websocket.TextMessage, []byte(`{"type": "error", "error": {"message": "OPENAI_API_KEY not set on server"}}`))
+		if cfg.Proxy.APIKeyPassthrough {
+			logger.Printf("Proxy: Error - client sent no API key and %s environment variable not set", target.apiKeyEnv())
+			safeClientConn.WriteMessage(websocket.TextMessage, []byte(`{"type": "error", "error": {"message": "No API key: send an Authorization header or openai-insecure-api-key subprotocol"}}`))
+			return
+		}
+		logger.Printf("Proxy: Error - %s environment variable not set", target.apiKeyEnv())
+		safeClientConn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"type": "error", "error": {"message": "%s not set on server"}}`, target.apiKeyEnv())))
 		return
 	}
+	logger.Printf("Proxy: Using %s API key", keySource)
 
-	model := appConfig.Proxy.Model
-	if model == "" {
-		model = "gpt-4o-mini-realtime-preview-2024-12-17" // Fallback default
+	targetURL, err := upstreamURL(target, model)
+	if err != nil {
+		logger.Printf("Proxy: Invalid URL of the %s target: %v", targetName, err)
+		sendErrorEvent(safeClientConn, "server_error", "invalid_upstream_url", "The upstream URL configured on the server is invalid.", "", "")
+		return
 	}
-	targetURL := fmt.Sprintf("%s?model=%s", appConfig.Proxy.URL, model)
-	log.Printf("Proxy: Connecting to OpenAI at %s", targetURL)
+	if isTranscriptionSession(r) {
+		targetURL += "&intent=" + transcriptionIntent
+	}
+	// proxy.query may carry credentials (e.g. Azure's api-key), the log gets the URL as /config shows it
+	logger.Printf("Proxy: Connecting to OpenAI at %s", redactSetting(redactURL, targetURL))
 
-	header := http.Header{}
-	header.Set("Authorization", "Bearer "+apiKey)
-	header.Set("OpenAI-Beta", "realtime=v1")
+	header := upstreamHeader(target, apiKey)
 
-	openaiConn, _, err := websocket.DefaultDialer.Dial(targetURL, header)
+	dialStart := time.Now()
+	openaiConn, err := dialUpstream(targetURL, header, logger)
+	metrics.observeDial(time.Since(dialStart), err)
 	if err != nil {
-		log.Printf("Proxy: Failed to connect to OpenAI: %v", err)
-		safeClientConn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"type": "error", "error": {"message": "Failed to connect to OpenAI: %v"}}`, err)))
+		logger.Printf("Proxy: Failed to connect to OpenAI: %v", err)
+		sendDialError(safeClientConn, err)
+		if breaker.Enabled {
+			proxyCircuit.failure(breaker, err.Error())
+		}
 		return
 	}
 	defer openaiConn.Close()
-	log.Printf("Proxy: Connected to OpenAI")
+	logger.Printf("Proxy: Connected to OpenAI in %v", time.Since(dialStart).Round(time.Millisecond))
+	metrics.sessionStarted()
+	defer metrics.sessionEnded()
+	openaiConn.startKeepalive(cfg.Proxy.Keepalive)
+
+	usage := proxyUsage.startSession(targetName, model)
+	defer proxyUsage.endSession(usage, logger)
+	rateLimit := cfg.Proxy.RateLimit
+	limiter := limiterFor(rateLimit)
 
 	// 3. Setup Recording based on config
 	recordingName := r.URL.Query().Get("recording_name")
-	recordingDir := appConfig.Proxy.RecordingPath
+	recordingDir := cfg.Proxy.RecordingPath
 	if recordingDir == "" {
 		recordingDir = "recordings"
 	}
@@ -68,31 +121,99 @@ func handleProxyWebSocket(w http.ResponseWriter, r *http.Request) {
 		baseName = time.Now().Format("2006-01-02_15-04-05")
 	}
 
+	// With sessionDirs every session gets its own directory, finished once the recorders are closed
+	var sessionDir *sessionRecording
+	mode := connectionMode(r)
+	if cfg.Proxy.SessionDirs && mode != "vcr" {
+		sessionDir = newSessionRecording(recordingDir, recordingName, baseName, usage, safeClientConn.RemoteAddr())
+	}
+	var liveID string
+	defer func() { sessionDir.finish(liveID) }()
+
+	// Every recording gets a .meta.json sidecar describing the session
+	recordingMeta := func(kind string) *RecordingMeta {
+		meta := newRecordingMeta(kind, mode)
+		meta.Model, meta.Target = model, targetName
+		return meta
+	}
+
+	// Combined Recorder (both directions in one file) - controlled by recordingMode config,
+	// always written in vcr mode where it is the cassette
+	recordingMode := cfg.Proxy.RecordingMode
+	var combinedRecorder *Recorder
+	if recordingMode == "combined" || recordingMode == "both" || mode == "vcr" {
+		combinedRecorder, err = sessionDir.recorder(recordingDir, "session", baseName)
+		if err != nil {
+			logger.Printf("Proxy: Failed to initialize combined recorder: %v", err)
+		} else {
+			combinedRecorder.SetDirectionFilter(directionClient, cfg.RecordingFilters.Inbound)
+			combinedRecorder.SetDirectionFilter(directionServer, cfg.RecordingFilters.Outbound)
+			combinedRecorder.SetRedaction(&cfg.RecordingFilters.Redact)
+			combinedRecorder.SetMeta(recordingMeta("session"))
+			defer combinedRecorder.Close()
+		}
+	}
+	splitRecording := recordingMode != "combined"
+
 	// Inbound Recorder (Client -> Server) - controlled by logInbound config
 	var inboundRecorder *Recorder
-	if appConfig.LogInbound {
-		inboundName := "inbound_" + baseName
-		inboundRecorder, err = NewRecorder(recordingDir, "inbound", inboundName)
+	if cfg.LogInbound && splitRecording {
+		inboundRecorder, err = sessionDir.recorder(recordingDir, "inbound", baseName)
 		if err != nil {
-			log.Printf("Proxy: Failed to initialize inbound recorder: %v", err)
+			logger.Printf("Proxy: Failed to initialize inbound recorder: %v", err)
 		} else {
+			inboundRecorder.SetFilter(cfg.RecordingFilters.Inbound)
+			inboundRecorder.SetRedaction(&cfg.RecordingFilters.Redact)
+			inboundRecorder.SetMeta(recordingMeta("inbound"))
 			defer inboundRecorder.Close()
 		}
 	}
 
 	// Outbound Recorder (Server -> Client) - controlled by logOutbound config (proxy mode only)
 	var outboundRecorder *Recorder
-	if appConfig.LogOutbound {
-		outboundName := "outbound_" + baseName
-		outboundRecorder, err = NewRecorder(recordingDir, "outbound", outboundName)
+	if cfg.LogOutbound && splitRecording {
+		outboundRecorder, err = sessionDir.recorder(recordingDir, "outbound", baseName)
 		if err != nil {
-			log.Printf("Proxy: Failed to initialize outbound recorder: %v", err)
+			logger.Printf("Proxy: Failed to initialize outbound recorder: %v", err)
 		} else {
+			outboundRecorder.SetFilter(cfg.RecordingFilters.Outbound)
+			outboundRecorder.SetRedaction(&cfg.RecordingFilters.Redact)
+			outboundRecorder.SetMeta(recordingMeta("outbound"))
 			defer outboundRecorder.Close()
 		}
 	}
 
+	// Recording can be turned off with ?record=false and toggled while the session runs
+	recording := newRecordingToggle(r)
+	recording.add(combinedRecorder)
+	recording.add(inboundRecorder)
+	recording.add(outboundRecorder)
+	live := &liveSession{
+		ID: "proxy-sess-" + uuid.NewString(), recording: recording, usage: usage,
+		conn: safeClientConn, mode: mode, target: targetName, model: model, connectedAt: time.Now(),
+	}
+	liveID = live.ID
+	registerLiveSession(live)
+	defer unregisterLiveSession(live)
+	safeClientConn.tap = live
+	logger.Printf("Proxy: Session %s, recording %s", live.ID, enabledText(recording.isEnabled()))
+
+	// Output audio capture (OpenAI -> client audio deltas to WAV) - controlled by captureAudio config
+	var audioCapture *AudioCapture
+	if cfg.Proxy.CaptureAudio {
+		audioCapture, err = sessionDir.audioCapture(recordingDir, baseName)
+		if err != nil {
+			logger.Printf("Proxy: Failed to initialize audio capture: %v", err)
+		} else {
+			defer audioCapture.Close()
+		}
+	}
+
 	// 4. Bi-directional Forwarding
+	upstreamFailed := false // Set by the OpenAI -> client loop on a server_error
+	// The side that closes first ends the session, the other side gets the same close frame
+	var closeOnce sync.Once
+	var latency responseLatency
 	var wg sync.WaitGroup
 	wg.Add(2)
 
@@ -102,19 +223,66 @@ func handleProxyWebSocket(w http.ResponseWriter, r *http.Request) {
 		for {
 			msgType, msg, err := safeClientConn.ReadMessage()
 			if err != nil {
-				log.Printf("Proxy: Client read error: %v", err)
+				logger.Printf("Proxy: Client read error: %v", err)
+				closeOnce.Do(func() {
+					info := closeFromError(err)
+					if inboundRecorder != nil {
+						inboundRecorder.RecordClose("", info)
+					}
+					if combinedRecorder != nil {
+						combinedRecorder.RecordClose(directionClient, info)
+					}
+					openaiConn.WriteClose(info)
+				})
 				openaiConn.Close() // Close upstream to stop the other loop
 				break
 			}
 
+			if msgType == websocket.TextMessage && handleRecordingControl(safeClientConn, recording, msg) {
+				continue
+			}
+			latency.track(metrics.countEvent(directionClient, msgType, msg))
+
+			// Enforce the configured session settings
+			if msgType == websocket.TextMessage {
+				if msg, err = translateEvent(msg, clientVersion, upstreamVersion); err != nil {
+					logger.Printf("Proxy: %v", err)
+				}
+				rewritten, changed, err := rewriteSessionUpdate(msg, cfg.Proxy.SessionOverrides)
+				if err != nil {
+					logger.Printf("Proxy: %v", err)
+				} else if changed {
+					logger.Printf("Proxy: Applied session overrides to session.update")
+					msg = rewritten
+				}
+				if rewritten, changed, err := applyTransforms(cfg.Proxy.Transforms, "inbound", msg); err != nil {
+					logger.Printf("Proxy: %v", err)
+				} else if changed {
+					msg = rewritten
+				}
+				if isCreate, eventID := isResponseCreate(msg); isCreate && rateLimit.enabled() {
+					if exceeded, status := limiter.request(rateLimit); exceeded != nil {
+						sendRateLimitExceeded(safeClientConn, exceeded, status, eventID)
+						continue // Never reaches the upstream
+					}
+				}
+			}
+
 			// Record inbound message (client -> OpenAI)
-			if inboundRecorder != nil && msgType == websocket.TextMessage {
-				inboundRecorder.RecordMessage(msg)
+			meta := sequence.next(directionClient, msg)
+			if inboundRecorder != nil {
+				inboundRecorder.RecordFrameMeta("", msgType, msg, meta)
+			}
+			if combinedRecorder != nil {
+				combinedRecorder.RecordFrameMeta(directionClient, msgType, msg, meta)
 			}
 
 			// Forward to OpenAI
 			if err := openaiConn.WriteMessage(msgType, msg); err != nil {
-				log.Printf("Proxy: Error writing to OpenAI: %v", err)
+				logger.Printf("Proxy: Error writing to OpenAI: %v", err)
+				if cfg.Proxy.Reconnect.Enabled && !openaiConn.isClosed() {
+					continue // The reader side reconnects, this message is lost
+				}
 				break
 			}
 		}
@@ -126,24 +294,141 @@ func handleProxyWebSocket(w http.ResponseWriter, r *http.Request) {
 		for {
 			msgType, msg, err := openaiConn.ReadMessage()
 			if err != nil {
-				log.Printf("Proxy: OpenAI read error: %v", err)
+				logger.Printf("Proxy: OpenAI read error: %v", err)
+				if cfg.Proxy.Reconnect.Enabled && !openaiConn.isClosed() {
+					err := openaiConn.reconnect(cfg.Proxy.Reconnect)
+					if err == nil {
+						continue
+					}
+					logger.Printf("Proxy: Reconnect to OpenAI failed: %v", err)
+				}
+				closeOnce.Do(func() {
+					info := closeFromError(err)
+					if outboundRecorder != nil {
+						outboundRecorder.RecordClose("", info)
+					}
+					if combinedRecorder != nil {
+						combinedRecorder.RecordClose(directionServer, info)
+					}
+					safeClientConn.WriteClose(info)
+				})
 				safeClientConn.Close() // Close downstream
 				break
 			}
+			if msgType == websocket.TextMessage && openaiConn.suppressed(msg) {
+				continue
+			}
+			serverEvent := metrics.countEvent(directionServer, msgType, msg)
+			if d, ok := latency.track(serverEvent); ok {
+				metrics.observeFirstResponse(d)
+				logger.Printf("Proxy: First response delta after %v", d.Round(time.Millisecond))
+			}
+			if msgType == websocket.TextMessage {
+				proxyUsage.observe(usage, msg)
+				if rateLimit.enabled() {
+					if serverEvent == "rate_limits.updated" {
+						continue // The simulated limits replace the upstream's
+					}
+					limiter.spend(responseTokens(msg))
+				}
+				if breaker.Enabled && !upstreamFailed && isServerError(msg) {
+					upstreamFailed = true
+					proxyCircuit.failure(breaker, "upstream server_error")
+				}
+				if rewritten, changed, err := applyTransforms(cfg.Proxy.Transforms, "outbound", msg); err != nil {
+					logger.Printf("Proxy: %v", err)
+				} else if changed {
+					msg = rewritten
+				}
+				if msg, err = translateEvent(msg, upstreamVersion, clientVersion); err != nil {
+					logger.Printf("Proxy: %v", err)
+				}
+			}
 
 			// Record outbound message (OpenAI -> client)
-			if outboundRecorder != nil && msgType == websocket.TextMessage {
-				outboundRecorder.RecordMessage(msg)
+			meta := sequence.next(directionServer, msg)
+			if outboundRecorder != nil {
+				outboundRecorder.RecordFrameMeta("", msgType, msg, meta)
+			}
+			if combinedRecorder != nil {
+				combinedRecorder.RecordFrameMeta(directionServer, msgType, msg, meta)
+			}
+			if audioCapture != nil && msgType == websocket.TextMessage {
+				audioCapture.HandleServerEvent(msg)
 			}
 
 			// Forward to Client
 			if err := safeClientConn.WriteMessage(msgType, msg); err != nil {
-				log.Printf("Proxy: Error writing to Client: %v", err)
+				logger.Printf("Proxy: Error writing to Client: %v", err)
 				break
 			}
+			if rateLimit.enabled() && serverEvent == "response.created" {
+				sendRateLimits(safeClientConn, limiter.current(rateLimit))
+			}
 		}
 	}()
 
 	wg.Wait()
-	log.Printf("Proxy: Session ended")
+	if breaker.Enabled && !upstreamFailed {
+		proxyCircuit.success()
+	}
+	logger.Printf("Proxy: Session ended")
+}
+
+// upstreamTarget picks the upstream target and model for a client connection: the routed
+// target, with ?model= overriding its model (restricted to proxy.allowedModels if set) and
+// ?upstream_url= overriding its URL if it is one of proxy.allowedUrls.
+func upstreamTarget(r *http.Request) (string, UpstreamTarget, string, error) {
+	query := r.URL.Query()
+	name, target := routeTarget(r)
+	cfg := requestConfig(r)
+
+	model := target.model()
+	if requested := query.Get("model"); requested != "" {
+		if len(cfg.Proxy.AllowedModels) > 0 && !slices.Contains(cfg.Proxy.AllowedModels, requested) {
+			return "", target, "", fmt.Errorf("model %s is not allowed by this proxy", requested)
+		}
+		model = requested
+	}
+
+	if requested := query.Get("upstream_url"); requested != "" {
+		if !slices.Contains(cfg.Proxy.AllowedURLs, requested) {
+			return "", target, "", fmt.Errorf("upstream URL %s is not allowed by this proxy", requested)
+		}
+		target.URL = requested
+	}
+	return name, target, model, nil
+}
+
+// upstreamURL builds the upstream dial URL from the target's URL, the model and the target's query.
+func upstreamURL(upstream UpstreamTarget, model string) (string, error) {
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		return "", err.(*url.Error).Err // Without the URL, which may carry credentials
+	}
+	query := target.Query()
+	query.Set("model", model)
+	for key, value := range upstream.Query {
+		query.Set(key, os.ExpandEnv(value))
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// upstreamHeader returns the headers for the upstream dial. The target's headers are applied
+// on top of the defaults, so they can override OpenAI-Beta or drop it with an empty value.
+func upstreamHeader(upstream UpstreamTarget, apiKey string) http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiKey)
+	if upstream.apiVersion() == apiVersionBeta {
+		header.Set("OpenAI-Beta", "realtime=v1")
+	}
+	for key, value := range upstream.Headers {
+		if value = os.ExpandEnv(value); value == "" {
+			header.Del(key)
+		} else {
+			header.Set(key, value)
+		}
+	}
+	return header
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Proxy Authentication ---
+
+// Browser clients can't set headers on a WebSocket, so they pass the key as a subprotocol.
+const insecureAPIKeyProtocol = "openai-insecure-api-key."
+
+// clientAPIKey extracts the API key a client sent with the upgrade request, either as
+// an "Authorization: Bearer" header or as an openai-insecure-api-key.<key> subprotocol.
+func clientAPIKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(protocol, insecureAPIKeyProtocol) {
+			return strings.TrimPrefix(protocol, insecureAPIKeyProtocol)
+		}
+	}
+	return ""
+}
+
+// upstreamAPIKey returns the key to authenticate the upstream connection with and where it came from.
+// With proxy.apiKeyPassthrough the client's own key wins, the target's server key is the fallback.
+func upstreamAPIKey(r *http.Request, target UpstreamTarget) (key string, source string) {
+	if requestConfig(r).Proxy.APIKeyPassthrough {
+		if key := clientAPIKey(r); key != "" {
+			return key, "client"
+		}
+	}
+	return os.Getenv(target.apiKeyEnv()), "server"
+}
+
+// proxyUpgradeHeader selects the "realtime" subprotocol when the client offered it,
+// which browsers require whenever they sent subprotocols (e.g. to pass their API key).
+func proxyUpgradeHeader(r *http.Request) http.Header {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if protocol == "realtime" {
+			return http.Header{"Sec-Websocket-Protocol": []string{"realtime"}}
+		}
+	}
+	return nil
+}
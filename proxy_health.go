@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Upstream Health & Dial Errors ---
+
+// upstreamDialError explains why the upstream WebSocket could not be opened.
+type upstreamDialError struct {
+	Status  int    // HTTP status of a rejected handshake, 0 if the upstream wasn't reached
+	Code    string // Error code reported to the client
+	Message string
+	err     error
+}
+
+func (e *upstreamDialError) Error() string { return e.Message }
+func (e *upstreamDialError) Unwrap() error { return e.err }
+
+// dialWebSocket dials the upstream and turns failures into an upstreamDialError that
+// includes the HTTP status and response body of a rejected handshake.
+func dialWebSocket(targetURL string, header http.Header) (*websocket.Conn, error) {
+	conn, resp, err := upstreamDialer.Dial(targetURL, header)
+	if err != nil {
+		return nil, describeDialError(err, resp)
+	}
+	return conn, nil
+}
+
+func describeDialError(err error, resp *http.Response) *upstreamDialError {
+	dialErr := &upstreamDialError{Code: "upstream_unreachable", err: err}
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	switch {
+	case resp != nil:
+		dialErr.Status = resp.StatusCode
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			dialErr.Code = "upstream_unauthorized"
+		case http.StatusForbidden:
+			dialErr.Code = "upstream_forbidden"
+		case http.StatusNotFound:
+			dialErr.Code = "upstream_not_found"
+		case http.StatusTooManyRequests:
+			dialErr.Code = "upstream_rate_limited"
+		default:
+			dialErr.Code = "upstream_rejected"
+		}
+		dialErr.Message = fmt.Sprintf("upstream rejected the connection with %s", resp.Status)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if detail := apiErrorMessage(body); detail != "" {
+			dialErr.Message += ": " + detail
+		}
+	case errors.As(err, &dnsErr):
+		dialErr.Code = "upstream_dns_error"
+		dialErr.Message = fmt.Sprintf("could not resolve upstream host %s: %v", dnsErr.Name, dnsErr.Err)
+	case errors.As(err, &certErr):
+		dialErr.Code = "upstream_tls_error"
+		dialErr.Message = fmt.Sprintf("upstream certificate not trusted (see proxy.outbound.caFile): %v", certErr.Err)
+	default:
+		dialErr.Message = fmt.Sprintf("could not reach upstream: %v", err)
+	}
+	return dialErr
+}
+
+// apiErrorMessage extracts error.message from an OpenAI error body, or returns the raw body.
+func apiErrorMessage(body []byte) string {
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+		return apiErr.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// sendDialError reports a failed upstream dial to the client as an error event.
+func sendDialError(conn *SafeWebSocket, err error) {
+	var dialErr *upstreamDialError
+	if !errors.As(err, &dialErr) {
+		sendErrorEvent(conn, "server_error", "upstream_unreachable", "Failed to connect to OpenAI: "+err.Error(), "", "")
+		return
+	}
+	errType := "server_error"
+	if dialErr.Status >= 400 && dialErr.Status < 500 {
+		errType = "invalid_request_error"
+	}
+	sendErrorEvent(conn, errType, dialErr.Code, "Failed to connect to OpenAI: "+dialErr.Message, "", "")
+}
+
+// checkUpstream checks the default target and every named target in proxy.targets.
+func checkUpstream() error {
+	proxy := currentConfig().Proxy
+	if err := checkTarget(proxy.defaultTarget(), proxy.APIKeyPassthrough); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(proxy.Targets))
+	for name := range proxy.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkTarget(proxy.Targets[name], proxy.APIKeyPassthrough); err != nil {
+			return fmt.Errorf("target %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkTarget opens and closes an upstream connection with the server's API key to verify
+// the proxy can reach and authenticate against it. With proxy.apiKeyPassthrough and no
+// server key only reachability is checked: a 401 counts as healthy.
+func checkTarget(target UpstreamTarget, passthrough bool) error {
+	apiKey := os.Getenv(target.apiKeyEnv())
+	if apiKey == "" && !passthrough {
+		return fmt.Errorf("%s environment variable not set", target.apiKeyEnv())
+	}
+
+	targetURL, err := upstreamURL(target, target.model())
+	if err != nil {
+		return fmt.Errorf("invalid upstream URL: %w", err)
+	}
+	header := upstreamHeader(target, apiKey)
+	if apiKey == "" {
+		header.Del("Authorization")
+	}
+
+	conn, err := dialWebSocket(targetURL, header)
+	if err != nil {
+		var dialErr *upstreamDialError
+		if apiKey == "" && errors.As(err, &dialErr) && dialErr.Status == http.StatusUnauthorized {
+			return nil
+		}
+		return err
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+)
+
+// --- Proxy Routing ---
+
+const (
+	defaultTargetName = "default"
+	defaultProxyModel = "gpt-4o-mini-realtime-preview-2024-12-17"
+)
+
+// UpstreamTarget is an upstream the proxy can connect clients to. The proxy's own url, model,
+// headers and query form the default target, proxy.targets adds named ones.
+type UpstreamTarget struct {
+	URL     string            `yaml:"url" json:"url" redact:"url"`
+	Model   string            `yaml:"model" json:"model"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" redact:"secret"`
+	Query   map[string]string `yaml:"query,omitempty" json:"query,omitempty" redact:"secret"`
+	// Environment variable holding the server's API key for this target (default OPENAI_API_KEY)
+	APIKeyEnv string `yaml:"apiKeyEnv,omitempty" json:"apiKeyEnv,omitempty"`
+	// "beta" (default): OpenAI-Beta: realtime=v1 and the beta event set, "ga": no beta header, GA events
+	APIVersion string `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+}
+
+// ProxyRoute sends matching connections to a named target. Every condition that is set must match.
+type ProxyRoute struct {
+	Target string            `yaml:"target" json:"target"`
+	Query  map[string]string `yaml:"query,omitempty" json:"query,omitempty"`   // Client query parameters, e.g. provider: azure
+	Header map[string]string `yaml:"header,omitempty" json:"header,omitempty"` // Client request headers
+	Model  string            `yaml:"model,omitempty" json:"model,omitempty"`   // Requested ?model=, may use * wildcards
+}
+
+// model returns the target's model or the fallback default.
+func (t UpstreamTarget) model() string {
+	if t.Model == "" {
+		return defaultProxyModel
+	}
+	return t.Model
+}
+
+// apiKeyEnv returns the environment variable holding the target's server API key.
+func (t UpstreamTarget) apiKeyEnv() string {
+	if t.APIKeyEnv == "" {
+		return "OPENAI_API_KEY"
+	}
+	return t.APIKeyEnv
+}
+
+// defaultTarget returns the target described by the top-level proxy settings.
+func (p ProxyConfig) defaultTarget() UpstreamTarget {
+	return UpstreamTarget{URL: p.URL, Model: p.Model, Headers: p.Headers, Query: p.Query, APIVersion: p.APIVersion}
+}
+
+// routeTarget returns the target of the first route matching the request, or the default target.
+func routeTarget(r *http.Request) (string, UpstreamTarget) {
+	cfg := requestConfig(r)
+	for _, route := range cfg.Proxy.Routes {
+		if route.matches(r) {
+			return route.Target, cfg.Proxy.Targets[route.Target]
+		}
+	}
+	return defaultTargetName, cfg.Proxy.defaultTarget()
+}
+
+func (rt ProxyRoute) matches(r *http.Request) bool {
+	query := r.URL.Query()
+	for key, value := range rt.Query {
+		if query.Get(key) != value {
+			return false
+		}
+	}
+	for key, value := range rt.Header {
+		if r.Header.Get(key) != value {
+			return false
+		}
+	}
+	if rt.Model != "" {
+		if matched, _ := path.Match(rt.Model, query.Get("model")); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRouting checks that targets have a URL and routes point at existing targets.
+func (p ProxyConfig) validateRouting() error {
+	for name, target := range p.Targets {
+		if name == defaultTargetName {
+			return fmt.Errorf("proxy.targets: %q is reserved for the top-level proxy settings", name)
+		}
+		if target.URL == "" {
+			return fmt.Errorf("proxy.targets.%s has no url", name)
+		}
+		if err := validateAPIVersion("proxy.targets."+name+".apiVersion", target.APIVersion, false); err != nil {
+			return err
+		}
+	}
+	for i, route := range p.Routes {
+		if _, ok := p.Targets[route.Target]; !ok {
+			return fmt.Errorf("proxy.routes[%d] has unknown target: %s", i, route.Target)
+		}
+		if _, err := path.Match(route.Model, ""); err != nil {
+			return fmt.Errorf("proxy.routes[%d] has invalid model pattern %q: %w", i, route.Model, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// --- Proxy Session Endpoints ---
+
+// Ephemeral tokens issued by the mock are useless against the real API, so in proxy mode the
+// REST session endpoints are forwarded to the upstream and the real token is returned.
+
+// proxySessionRequest forwards a POST to /v1/realtime/sessions, /v1/realtime/transcription_sessions
+// or /v1/realtime/client_secrets
+// to the upstream host, authenticated like the WebSocket connection, and copies back the response.
+func proxySessionRequest(w http.ResponseWriter, r *http.Request) {
+	_, upstream := routeTarget(r)
+	apiKey, keySource := upstreamAPIKey(r, upstream)
+	if apiKey == "" {
+		log.Printf("Proxy: Error - no API key to forward %s", r.URL.Path)
+		http.Error(w, upstream.apiKeyEnv()+" not set on server", http.StatusInternalServerError)
+		return
+	}
+
+	target, err := upstreamRESTURL(upstream, r.URL.Path)
+	if err != nil {
+		log.Printf("Proxy: Invalid upstream URL: %v", err)
+		http.Error(w, "Invalid upstream URL", http.StatusInternalServerError)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, target, r.Body)
+	if err != nil {
+		http.Error(w, "Failed to build upstream request", http.StatusInternalServerError)
+		return
+	}
+	req.Header = upstreamHeader(upstream, apiKey)
+	if r.URL.Path == "/v1/realtime/client_secrets" {
+		req.Header.Del("OpenAI-Beta") // GA endpoint
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Printf("Proxy: Forwarding %s to %s using %s API key", r.URL.Path, redactSetting(redactURL, target), keySource)
+	resp, err := sessionHTTPClient.Do(req)
+	if err != nil {
+		log.Printf("Proxy: Failed to reach OpenAI: %v", err)
+		http.Error(w, "Failed to reach OpenAI: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("Proxy: Error copying session response: %v", err)
+		return
+	}
+	log.Printf("Proxy: Upstream answered %s with %s", r.URL.Path, resp.Status)
+}
+
+// upstreamRESTURL maps a REST path onto the host of the target's URL, e.g.
+// wss://api.openai.com/v1/realtime -> https://api.openai.com/v1/realtime/sessions.
+func upstreamRESTURL(upstream UpstreamTarget, path string) (string, error) {
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		return "", err
+	}
+	switch target.Scheme {
+	case "wss", "https":
+		target.Scheme = "https"
+	case "ws", "http":
+		target.Scheme = "http"
+	default:
+		return "", fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+	target.Path = path
+	target.RawQuery = ""
+	return target.String(), nil
+}
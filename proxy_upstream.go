@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Proxy Upstream Connection (reconnect & resume) ---
+
+const (
+	defaultReconnectAttempts  = 5
+	defaultReconnectBackoffMs = 500
+	defaultKeepaliveTimeout   = 10
+)
+
+// ReconnectConfig controls re-dialing the upstream when it drops mid-session.
+type ReconnectConfig struct {
+	Enabled     bool `yaml:"enabled" json:"enabled"`
+	MaxAttempts int  `yaml:"maxAttempts" json:"maxAttempts"` // Default 5
+	BackoffMs   int  `yaml:"backoffMs" json:"backoffMs"`     // Delay before the first retry, doubled per attempt (default 500)
+}
+
+// KeepaliveConfig makes the proxy ping the upstream, so quiet sessions aren't dropped by
+// load balancers in between and a dead upstream is noticed.
+type KeepaliveConfig struct {
+	IntervalSeconds int `yaml:"intervalSeconds" json:"intervalSeconds"` // Ping interval, 0 disables
+	TimeoutSeconds  int `yaml:"timeoutSeconds" json:"timeoutSeconds"`   // Give up if nothing, not even a pong, arrives for interval + timeout (default 10)
+}
+
+// upstreamConn is the proxy's connection to OpenAI. It can be replaced by a fresh
+// connection when the current one drops, replaying the last session.update on it.
+type upstreamConn struct {
+	targetURL string
+	header    http.Header
+	logger    *log.Logger // The client connection's
+
+	mu                sync.Mutex
+	conn              *websocket.Conn
+	closed            bool   // The client is gone, don't reconnect
+	lastSessionUpdate []byte // Last session.update forwarded, replayed after a reconnect
+	suppress          []string
+	keepalive         KeepaliveConfig
+}
+
+// dialUpstream opens the first upstream connection.
+func dialUpstream(targetURL string, header http.Header, logger *log.Logger) (*upstreamConn, error) {
+	conn, err := dialWebSocket(targetURL, header)
+	if err != nil {
+		return nil, err
+	}
+	return &upstreamConn{targetURL: targetURL, header: header, conn: conn, logger: logger}, nil
+}
+
+func (u *upstreamConn) current() *websocket.Conn {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.conn
+}
+
+// WriteMessage forwards a client message to the current upstream connection.
+func (u *upstreamConn) WriteMessage(msgType int, msg []byte) error {
+	if msgType == websocket.TextMessage {
+		var event BaseEvent
+		if json.Unmarshal(msg, &event) == nil && event.Type == "session.update" {
+			u.mu.Lock()
+			u.lastSessionUpdate = msg
+			u.mu.Unlock()
+		}
+	}
+	return u.current().WriteMessage(msgType, msg)
+}
+
+// ReadMessage reads from the current upstream connection.
+func (u *upstreamConn) ReadMessage() (int, []byte, error) {
+	u.mu.Lock()
+	conn, keepalive := u.conn, u.keepalive
+	u.mu.Unlock()
+	msgType, msg, err := conn.ReadMessage()
+	if err == nil && keepalive.IntervalSeconds > 0 {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(keepalive.IntervalSeconds+keepalive.TimeoutSeconds) * time.Second))
+	}
+	return msgType, msg, err
+}
+
+// suppressed reports whether a message belongs to the handshake of a reconnect and
+// should be hidden from the client (the new session.created and the replayed update's reply).
+func (u *upstreamConn) suppressed(msg []byte) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.suppress) == 0 {
+		return false
+	}
+	var event BaseEvent
+	if json.Unmarshal(msg, &event) != nil || event.Type != u.suppress[0] {
+		return false
+	}
+	u.suppress = u.suppress[1:]
+	return true
+}
+
+// reconnect re-dials the upstream with exponential backoff and replays the last session.update.
+func (u *upstreamConn) reconnect(cfg ReconnectConfig) error {
+	attempts := cfg.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultReconnectAttempts
+	}
+	backoff := time.Duration(cfg.BackoffMs) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultReconnectBackoffMs * time.Millisecond
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		time.Sleep(backoff)
+		backoff *= 2
+
+		u.mu.Lock()
+		closed := u.closed
+		u.mu.Unlock()
+		if closed {
+			return fmt.Errorf("client disconnected")
+		}
+
+		conn, err := dialWebSocket(u.targetURL, u.header)
+		if err != nil {
+			u.logger.Printf("Proxy: Reconnect attempt %d/%d failed: %v", attempt, attempts, err)
+			continue
+		}
+
+		u.mu.Lock()
+		suppress := []string{"session.created"}
+		if u.lastSessionUpdate != nil {
+			if err := conn.WriteMessage(websocket.TextMessage, u.lastSessionUpdate); err != nil {
+				u.mu.Unlock()
+				conn.Close()
+				u.logger.Printf("Proxy: Reconnect attempt %d/%d failed to replay session.update: %v", attempt, attempts, err)
+				continue
+			}
+			suppress = append(suppress, "session.updated")
+		}
+		u.conn.Close()
+		u.conn = conn
+		u.suppress = suppress
+		u.armKeepalive(conn)
+		u.mu.Unlock()
+
+		u.logger.Printf("Proxy: Reconnected to OpenAI (attempt %d/%d)", attempt, attempts)
+		return nil
+	}
+	return fmt.Errorf("gave up after %d attempts", attempts)
+}
+
+// startKeepalive pings the upstream every interval until the connection is closed. A read
+// deadline, pushed out by every pong and message, turns a silent upstream into a read error.
+func (u *upstreamConn) startKeepalive(cfg KeepaliveConfig) {
+	if cfg.IntervalSeconds <= 0 {
+		return
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = defaultKeepaliveTimeout
+	}
+	u.mu.Lock()
+	u.keepalive = cfg
+	u.armKeepalive(u.conn)
+	u.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.IntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			if u.isClosed() {
+				return
+			}
+			deadline := time.Now().Add(time.Duration(cfg.TimeoutSeconds) * time.Second)
+			if err := u.current().WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				u.logger.Printf("Proxy: Keepalive ping to OpenAI failed: %v", err)
+			}
+		}
+	}()
+}
+
+// armKeepalive sets the read deadline and pong handler on a connection. Callers hold u.mu.
+func (u *upstreamConn) armKeepalive(conn *websocket.Conn) {
+	if u.keepalive.IntervalSeconds <= 0 {
+		return
+	}
+	window := time.Duration(u.keepalive.IntervalSeconds+u.keepalive.TimeoutSeconds) * time.Second
+	conn.SetReadDeadline(time.Now().Add(window))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(window))
+	})
+}
+
+// WriteClose sends a close frame to the current upstream connection.
+func (u *upstreamConn) WriteClose(info closeInfo) error {
+	return writeCloseFrame(u.current(), info)
+}
+
+// Close closes the upstream for good, e.g. when the client disconnects.
+func (u *upstreamConn) Close() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	u.conn.Close()
+}
+
+// isClosed reports whether Close was called.
+func (u *upstreamConn) isClosed() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.closed
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Proxy Rate Limit Simulation ---
+
+const defaultRateLimitWindowSeconds = 60
+
+// RateLimitConfig simulates the API's rate limits at the proxy. A response.create over the limit
+// never reaches the upstream, the client gets the rate limit error the API would send instead.
+type RateLimitConfig struct {
+	Requests      int  `yaml:"requests" json:"requests"`           // response.create events per window (0: unlimited)
+	Tokens        int  `yaml:"tokens" json:"tokens"`               // Tokens of finished responses per window (0: unlimited)
+	WindowSeconds int  `yaml:"windowSeconds" json:"windowSeconds"` // Sliding window length (default 60)
+	PerConnection bool `yaml:"perConnection" json:"perConnection"` // Separate limits per client connection instead of one shared budget
+}
+
+func (c RateLimitConfig) enabled() bool {
+	return c.Requests > 0 || c.Tokens > 0
+}
+
+func (c RateLimitConfig) window() time.Duration {
+	if c.WindowSeconds <= 0 {
+		return defaultRateLimitWindowSeconds * time.Second
+	}
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+func (c RateLimitConfig) validate() error {
+	if c.Requests < 0 || c.Tokens < 0 || c.WindowSeconds < 0 {
+		return fmt.Errorf("proxy.rateLimit values must not be negative")
+	}
+	return nil
+}
+
+type tokenSpend struct {
+	at     time.Time
+	tokens int
+}
+
+// rateLimiter keeps what was spent within the sliding window.
+type rateLimiter struct {
+	mu       sync.Mutex
+	requests []time.Time
+	tokens   []tokenSpend
+}
+
+var proxyRateLimit = &rateLimiter{}
+
+// rateLimitStatus is one entry of a rate_limits.updated event.
+type rateLimitStatus struct {
+	Name         string  `json:"name"`
+	Limit        int     `json:"limit"`
+	Remaining    int     `json:"remaining"`
+	ResetSeconds float64 `json:"reset_seconds"`
+}
+
+// limiterFor returns the limiter a connection spends from.
+func limiterFor(cfg RateLimitConfig) *rateLimiter {
+	if cfg.PerConnection {
+		return &rateLimiter{}
+	}
+	return proxyRateLimit
+}
+
+// prune drops everything that left the window. Callers hold the lock.
+func (l *rateLimiter) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	for len(l.requests) > 0 && !l.requests[0].After(cutoff) {
+		l.requests = l.requests[1:]
+	}
+	for len(l.tokens) > 0 && !l.tokens[0].at.After(cutoff) {
+		l.tokens = l.tokens[1:]
+	}
+}
+
+// request spends a request if both limits allow it. Otherwise it returns the exhausted limit.
+func (l *rateLimiter) request(cfg RateLimitConfig) (exceeded *rateLimitStatus, status []rateLimitStatus) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now, cfg.window())
+
+	status = l.status(cfg, now)
+	for i := range status {
+		if status[i].Remaining <= 0 {
+			return &status[i], status
+		}
+	}
+	l.requests = append(l.requests, now)
+	return nil, l.status(cfg, now)
+}
+
+// spend counts the tokens of a finished response.
+func (l *rateLimiter) spend(tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = append(l.tokens, tokenSpend{at: time.Now(), tokens: tokens})
+}
+
+// current returns the limits as they stand now.
+func (l *rateLimiter) current(cfg RateLimitConfig) []rateLimitStatus {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now, cfg.window())
+	return l.status(cfg, now)
+}
+
+// status describes each configured limit. Callers hold the lock.
+func (l *rateLimiter) status(cfg RateLimitConfig, now time.Time) []rateLimitStatus {
+	window := cfg.window()
+	var status []rateLimitStatus
+	if cfg.Requests > 0 {
+		entry := rateLimitStatus{Name: "requests", Limit: cfg.Requests, Remaining: max(cfg.Requests-len(l.requests), 0)}
+		if len(l.requests) > 0 {
+			entry.ResetSeconds = resetSeconds(l.requests[0].Add(window).Sub(now))
+		}
+		status = append(status, entry)
+	}
+	if cfg.Tokens > 0 {
+		used := 0
+		for _, spend := range l.tokens {
+			used += spend.tokens
+		}
+		entry := rateLimitStatus{Name: "tokens", Limit: cfg.Tokens, Remaining: max(cfg.Tokens-used, 0)}
+		if len(l.tokens) > 0 {
+			entry.ResetSeconds = resetSeconds(l.tokens[0].at.Add(window).Sub(now))
+		}
+		status = append(status, entry)
+	}
+	return status
+}
+
+func resetSeconds(d time.Duration) float64 {
+	return math.Round(max(d.Seconds(), 0)*100) / 100
+}
+
+// isResponseCreate reports whether a client event asks for a response.
+func isResponseCreate(msg []byte) (bool, string) {
+	var event BaseEvent
+	if json.Unmarshal(msg, &event) != nil {
+		return false, ""
+	}
+	return event.Type == "response.create", event.EventID
+}
+
+// responseTokens returns the total tokens of a response.done event.
+func responseTokens(msg []byte) int {
+	var event struct {
+		Type     string `json:"type"`
+		Response struct {
+			Usage *responseUsage `json:"usage"`
+		} `json:"response"`
+	}
+	if json.Unmarshal(msg, &event) != nil || event.Type != "response.done" || event.Response.Usage == nil {
+		return 0
+	}
+	return event.Response.Usage.TotalTokens
+}
+
+// sendRateLimits sends a rate_limits.updated event with the simulated limits.
+func sendRateLimits(conn *SafeWebSocket, status []rateLimitStatus) error {
+	return sendJSONEvent(conn, map[string]interface{}{
+		"type":        "rate_limits.updated",
+		"event_id":    uuid.NewString(),
+		"rate_limits": status,
+	})
+}
+
+// sendRateLimitExceeded answers a rejected response.create like the API does.
+func sendRateLimitExceeded(conn *SafeWebSocket, exceeded *rateLimitStatus, status []rateLimitStatus, clientEventID string) {
+	unit := "requests per min (RPM)"
+	if exceeded.Name == "tokens" {
+		unit = "tokens per min (TPM)"
+	}
+	conn.logf("Proxy: Simulated %s rate limit reached, retry in %.2fs", exceeded.Name, exceeded.ResetSeconds)
+	sendRateLimits(conn, status)
+	sendErrorEvent(conn, exceeded.Name, "rate_limit_exceeded",
+		fmt.Sprintf("Rate limit reached for %s: Limit %d, Used %d, Requested 1. Please try again in %.3gs.",
+			unit, exceeded.Limit, exceeded.Limit-exceeded.Remaining, exceeded.ResetSeconds),
+		"", clientEventID)
+}
@@ -1,19 +1,44 @@
 package main
 
 import (
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // Recorder handles logging of messages to an NDJSON file.
 type Recorder struct {
-	file *os.File
-	mu   sync.Mutex
+	path    string // Of the first part, later parts get a .002, .003, ... suffix
+	part    int
+	file    *os.File
+	gz      *gzip.Writer // Set for .ndjson.gz recordings
+	out     io.Writer    // The file, encrypting with proxy.encryption
+	size    int64        // Size of the file when it was opened
+	lines   int          // Lines written since
+	written int64        // Bytes written since
+	opened  time.Time
+	mu      sync.Mutex
+	filters map[string]RecordingFilter // By direction, "" applies to untagged messages and as fallback
+	redact  *RedactionConfig
+	paused  bool           // Recording turned off at runtime
+	meta    *RecordingMeta // Written to the .meta.json sidecar
+	store   recordingStore // Set instead of file when proxy.storage is not files
+	name    string         // Of the recording in the store
+	stored  int            // Lines of the recording in the store
+	config  *Config        // The config when recording started, kept through reloads
+	local   bool           // Kept out of the bucket, for tenants with their own directory
+
+	followers map[*recordingFollower]bool
 }
 
 // NewRecorder creates a new Recorder instance.
@@ -29,10 +54,22 @@ func NewRecorder(baseDir string, prefix string, name string) (*Recorder, error)
 	}
 
 	// Always save new recordings to 'recorded' subdirectory
-	targetDir := filepath.Join(baseDir, "recorded")
+	return newRecorderIn(filepath.Join(baseDir, "recorded"), prefix, name)
+}
+
+// newRecorderIn creates a Recorder writing <name>.ndjson (or <prefix>_<timestamp>.ndjson) in targetDir,
+// gzip-compressed with a .gz suffix when proxy.compressRecordings is set.
+func newRecorderIn(targetDir string, prefix string, name string) (*Recorder, error) {
+	return newFileRecorder(targetDir, prefix, name, false)
+}
+
+// newFileRecorder is newRecorderIn, writing a file in targetDir even with a storage backend
+// when local is set. Local recordings are not uploaded to the bucket either.
+func newFileRecorder(targetDir string, prefix string, name string, local bool) (*Recorder, error) {
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create recording directory: %w", err)
 	}
+	cfg := currentConfig()
 
 	var filename string
 	if name != "" {
@@ -44,34 +81,228 @@ func NewRecorder(baseDir string, prefix string, name string) (*Recorder, error)
 		filename = fmt.Sprintf("%s_%s.ndjson", prefix, timestamp)
 	}
 
+	if eventStore != nil && !local {
+		name := strings.TrimSuffix(filename, ".ndjson")
+		existing, _ := eventStore.stat(name)
+		recorder := &Recorder{path: storedPrefix + name, store: eventStore, name: name, size: existing.Size, config: cfg}
+		if existing.Size > 0 {
+			// Appending to an existing recording, whose lines followers get as history
+			if rc, err := openStoredRecording(recorder.path, eventFilter{}); err == nil {
+				scanLines(rc, -1, func([]byte) error { recorder.stored++; return nil })
+				rc.Close()
+			}
+		}
+		registerLiveRecorder(recorder)
+		log.Printf("Recording %s messages to %s%s", prefix, storedPrefix, name)
+		return recorder, nil
+	}
+
+	if cfg.Proxy.CompressRecordings {
+		filename += ".gz"
+	}
+
 	path := filepath.Join(targetDir, filename)
 
+	recorder := &Recorder{path: path, part: 1, local: local, config: cfg}
+	if err := recorder.openPart(path); err != nil {
+		return nil, err
+	}
+	registerLiveRecorder(recorder)
+
+	log.Printf("Recording %s messages to %s", prefix, path)
+	return recorder, nil
+}
+
+// openPart opens the file the next lines go to. Callers hold the lock.
+func (r *Recorder) openPart(path string) error {
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open recording file: %w", err)
+		return fmt.Errorf("failed to open recording file: %w", err)
+	}
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
 	}
+	// An existing file is only appended to if it matches the encryption setting
+	if size > 0 && isEncryptedRecording(path) != (recordingAEAD != nil) {
+		f.Close()
+		if recordingAEAD != nil {
+			return fmt.Errorf("cannot append encrypted lines to unencrypted recording %s", path)
+		}
+		return fmt.Errorf("cannot append to encrypted recording %s without proxy.encryption", path)
+	}
+	r.file, r.gz = f, nil
+	r.size, r.lines, r.written, r.opened = size, 0, 0, time.Now()
+	r.out = countingWriter{f, &r.written}
+	if recordingAEAD != nil {
+		r.out = encryptingWriter{r.out, recordingAEAD}
+	}
+	if r.config.Proxy.CompressRecordings {
+		// Appending to an existing file adds a gzip member, which readers treat as one stream
+		r.gz = gzip.NewWriter(r.out)
+	}
+	return nil
+}
 
-	log.Printf("Recording %s messages to %s", prefix, path)
-	return &Recorder{file: f}, nil
+// closePart finishes the current file, removing it if nothing was ever recorded to it.
+// Callers hold the lock.
+func (r *Recorder) closePart() {
+	if r.gz != nil && r.lines > 0 {
+		if err := r.gz.Close(); err != nil {
+			log.Printf("Error finishing compressed recording: %v", err)
+		}
+	}
+	r.file.Close()
+	if r.lines == 0 && r.size == 0 {
+		os.Remove(r.file.Name())
+	}
+	r.file = nil
+}
+
+// rotateIfDue moves on to the next part once the current one reached proxy.rotation's limits.
+// Callers hold the lock.
+func (r *Recorder) rotateIfDue() {
+	if r.lines == 0 || !r.config.Proxy.Rotation.due(r.written, r.opened) {
+		return
+	}
+	r.closePart()
+	r.part++
+	path := partPath(r.path, r.part)
+	if err := r.openPart(path); err != nil {
+		log.Printf("Recording stopped, %v", err)
+		return
+	}
+	log.Printf("Recording continues in %s", path)
+}
+
+// writeLine appends one NDJSON line. Callers hold the lock.
+func (r *Recorder) writeLine(line []byte) {
+	if r.store != nil {
+		if err := r.store.append(r.name, line); err != nil {
+			log.Printf("Error writing to recording store: %v", err)
+			return
+		}
+		r.lines++
+		r.stored++
+		r.notifyFollowers(line)
+		return
+	}
+	r.rotateIfDue()
+	if r.file == nil {
+		return
+	}
+	line = append(line, '\n')
+	var err error
+	if r.gz != nil {
+		_, err = r.gz.Write(line)
+	} else {
+		_, err = r.out.Write(line)
+	}
+	if err != nil {
+		log.Printf("Error writing to recording file: %v", err)
+		return
+	}
+	r.lines++
+	r.notifyFollowers(line[:len(line)-1])
+}
+
+// countingWriter adds the bytes written through it to n.
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// RecordingRotation starts a new part of a recording once the current one gets too big or old.
+type RecordingRotation struct {
+	MaxSizeMB  int `yaml:"maxSizeMB" json:"maxSizeMB"`   // 0: no size limit
+	MaxMinutes int `yaml:"maxMinutes" json:"maxMinutes"` // 0: no time limit
+}
+
+func (c RecordingRotation) due(written int64, opened time.Time) bool {
+	return (c.MaxSizeMB > 0 && written >= int64(c.MaxSizeMB)<<20) ||
+		(c.MaxMinutes > 0 && time.Since(opened) >= time.Duration(c.MaxMinutes)*time.Minute)
+}
+
+// partPath names part n (from 2 on) of a recording: session_x.ndjson -> session_x.002.ndjson
+func partPath(path string, n int) string {
+	dir, name := filepath.Split(path)
+	base, ext, found := strings.Cut(name, ".ndjson")
+	if !found {
+		return fmt.Sprintf("%s.%03d", path, n)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s.%03d.ndjson%s", base, n, ext))
 }
 
 // RecordMessage logs a JSON message to the file.
 func (r *Recorder) RecordMessage(msg []byte) {
+	r.RecordFrame("", websocket.TextMessage, msg)
+}
+
+// RecordFrame logs a WebSocket message of any type, tagged with the direction it travelled in
+// so a single file can hold both sides of a session in their true order. Binary frames
+// (e.g. raw audio) are stored base64-encoded and marked with frame "binary".
+func (r *Recorder) RecordFrame(direction string, messageType int, msg []byte) {
+	r.RecordFrameMeta(direction, messageType, msg, nil)
+}
+
+// RecordFrameMeta is RecordFrame with optional per-message metadata.
+func (r *Recorder) RecordFrameMeta(direction string, messageType int, msg []byte, meta *RecordMeta) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if r.file == nil {
+	if !r.isOpen() || r.paused {
 		return
 	}
 
-	// Ensure it's valid JSON
-	if !json.Valid(msg) {
-		return
+	filter, ok := r.filters[direction]
+	if !ok {
+		filter = r.filters[""]
 	}
 
 	event := RecordedEvent{
 		Timestamp: time.Now().UnixMilli(),
-		Data:      json.RawMessage(msg),
+		Direction: direction,
+		Meta:      meta,
+	}
+	switch messageType {
+	case websocket.TextMessage:
+		// Ensure it's valid JSON
+		if !json.Valid(msg) {
+			return
+		}
+		msg, keep := filter.apply(msg)
+		if !keep {
+			return
+		}
+		msg, keep = r.redact.apply(msg)
+		if !keep {
+			return
+		}
+		r.observeMeta(msg)
+		event.Data = json.RawMessage(msg)
+	case websocket.BinaryMessage:
+		if !filter.keeps(binaryFrameType) {
+			return
+		}
+		event.Frame = "binary"
+		payload := ""
+		if filter.StripAudio {
+			event.Bytes = len(msg)
+		} else {
+			payload = base64.StdEncoding.EncodeToString(msg)
+		}
+		event.Data, _ = json.Marshal(payload)
+	default:
+		return
+	}
+	if r.config.Proxy.Integrity.LineChecksums {
+		event.CRC32 = lineChecksum(event.Data)
 	}
 
 	line, err := json.Marshal(event)
@@ -79,20 +310,200 @@ func (r *Recorder) RecordMessage(msg []byte) {
 		log.Printf("Error marshaling recorded event: %v", err)
 		return
 	}
+	r.writeLine(line)
+}
 
-	line = append(line, '\n')
-	if _, err := r.file.Write(line); err != nil {
-		log.Printf("Error writing to recording file: %v", err)
+// RecordClose logs a close frame received from the given direction.
+func (r *Recorder) RecordClose(direction string, info closeInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.isOpen() || r.paused {
+		return
+	}
+	filter, ok := r.filters[direction]
+	if !ok {
+		filter = r.filters[""]
+	}
+	if !filter.keeps(closeFrameType) {
+		return
+	}
+
+	event := RecordedEvent{
+		Timestamp: time.Now().UnixMilli(),
+		Direction: direction,
+		Frame:     closeFrameType,
+	}
+	event.Data, _ = json.Marshal(info)
+	if r.config.Proxy.Integrity.LineChecksums {
+		event.CRC32 = lineChecksum(event.Data)
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling recorded event: %v", err)
+		return
+	}
+	r.writeLine(line)
+}
+
+// messageSequence numbers the messages of a proxied session for RecordMeta.
+type messageSequence struct {
+	mu      sync.Mutex
+	started time.Time
+	seq     int64
+}
+
+func newMessageSequence() *messageSequence {
+	return &messageSequence{started: time.Now()}
+}
+
+// next returns the metadata of the next message, nil when metadata is off.
+func (s *messageSequence) next(direction string, msg []byte) *RecordMeta {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return &RecordMeta{
+		Seq:       s.seq,
+		Direction: direction,
+		Size:      len(msg),
+		ElapsedMs: time.Since(s.started).Milliseconds(),
 	}
 }
 
-// Close closes the underlying file.
+// SetFilter limits which messages are recorded and how.
+func (r *Recorder) SetFilter(filter RecordingFilter) {
+	r.SetDirectionFilter("", filter)
+}
+
+// SetDirectionFilter sets the filter for messages recorded with the given direction.
+func (r *Recorder) SetDirectionFilter(direction string, filter RecordingFilter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.filters == nil {
+		r.filters = make(map[string]RecordingFilter)
+	}
+	r.filters[direction] = filter
+}
+
+// SetRedaction sets the redaction applied to every JSON message after the filters.
+func (r *Recorder) SetRedaction(redact *RedactionConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.redact = redact
+}
+
+// SetEnabled pauses or resumes recording.
+func (r *Recorder) SetEnabled(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = !enabled
+}
+
+// isOpen reports whether the recorder still takes messages. Callers hold the lock.
+func (r *Recorder) isOpen() bool {
+	return r.file != nil || r.store != nil
+}
+
+// Close closes the underlying file, removing it if nothing was ever recorded to it.
 func (r *Recorder) Close() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if r.isOpen() {
+		r.closeFollowers()
+		unregisterLiveRecorder(r)
+	}
+	if r.store != nil {
+		if r.lines == 0 && r.size == 0 {
+			if err := r.store.remove(r.name); err != nil {
+				log.Printf("Error removing empty recording %s: %v", r.name, err)
+			}
+		} else if r.meta != nil {
+			endedAt := time.Now()
+			r.meta.EndedAt = &endedAt
+			r.writeMeta()
+		}
+		r.store = nil
+		return
+	}
 	if r.file != nil {
-		r.file.Close()
-		r.file = nil
+		r.closePart()
+		if r.meta != nil {
+			if _, err := os.Stat(r.path); os.IsNotExist(err) {
+				os.Remove(metaPath(r.path)) // The recording stayed empty
+			} else {
+				endedAt := time.Now()
+				r.meta.EndedAt = &endedAt
+				if r.part > 1 {
+					r.meta.Parts = r.part
+				}
+				if r.config.Proxy.Integrity.enabled() {
+					r.meta.Checksums = fileChecksums(recordingParts(r.path))
+				}
+				r.writeMeta()
+			}
+		}
+		if !r.local {
+			uploadRecording(r.path, r.part)
+		}
+	}
+}
+
+// recordingFile returns path, or its .gz twin when only the compressed recording exists.
+func recordingFile(path string) (string, bool) {
+	for _, candidate := range []string{path, path + ".gz"} {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
 	}
+	return "", false
+}
+
+// openRecording opens a recording for reading, decrypting and decompressing .gz files on the fly.
+// Paths with the store: prefix are read from the storage backend.
+func openRecording(path string) (io.ReadCloser, error) {
+	if strings.HasPrefix(path, storedPrefix) {
+		return openStoredRecording(path, eventFilter{})
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := decryptRecording(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return recordingReader{rd, f}, nil
+	}
+	gz, err := gzip.NewReader(rd)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read compressed recording %s: %w", path, err)
+	}
+	return gzipFile{gz, f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// recordingReader reads an uncompressed recording file.
+type recordingReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r recordingReader) Close() error {
+	return r.file.Close()
 }
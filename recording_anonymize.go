@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// --- Recording Anonymizer ---
+
+// anonymizeOptions controls how much of a recording survives anonymization.
+type anonymizeOptions struct {
+	StripAudio      bool // Remove audio entirely instead of replacing it with silence of the same length
+	HashTranscripts bool // Replace transcripts and text by a SHA-256 prefix
+}
+
+// anonymizeRecording copies a recording with audio removed or silenced, e-mail addresses and phone
+// numbers masked, and events carrying credentials dropped. It returns the number of dropped events.
+func anonymizeRecording(in io.Reader, out io.Writer, opts anonymizeOptions) (int, error) {
+	redact := &RedactionConfig{
+		DropSecrets:     true,
+		Patterns:        []string{"email", "phone"},
+		HashTranscripts: opts.HashTranscripts,
+	}
+	if err := redact.compile(); err != nil {
+		return 0, err
+	}
+	strip := RecordingFilter{StripAudio: true}
+
+	scanner := bufio.NewScanner(in)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	w := bufio.NewWriter(out)
+	dropped := 0
+	for scanner.Scan() {
+		var event RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			dropped++ // Unparseable lines can't be checked for secrets
+			continue
+		}
+
+		switch event.Frame {
+		case "":
+			msg := []byte(event.Data)
+			if opts.StripAudio {
+				msg, _ = strip.apply(msg)
+			} else {
+				msg = silenceAudio(msg)
+			}
+			msg, keep := redact.apply(msg)
+			if !keep {
+				dropped++
+				continue
+			}
+			event.Data = msg
+		case binaryFrameType:
+			var payload string
+			json.Unmarshal(event.Data, &payload)
+			size := base64DecodedLen(payload)
+			if opts.StripAudio {
+				event.Bytes, payload = size, ""
+			} else if payload != "" {
+				payload = base64.StdEncoding.EncodeToString(make([]byte, size))
+			}
+			event.Data, _ = json.Marshal(payload)
+		}
+		if event.CRC32 != "" {
+			event.CRC32 = lineChecksum(event.Data)
+		}
+
+		line, err := json.Marshal(event)
+		if err != nil {
+			return dropped, err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return dropped, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return dropped, w.Flush()
+}
+
+// silenceAudio replaces the audio of input appends and audio deltas by zeroed samples of the
+// same length, so the anonymized recording keeps its timing when replayed.
+func silenceAudio(msg []byte) []byte {
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return msg
+	}
+	eventType, _ := event["type"].(string)
+	field := ""
+	switch {
+	case eventType == "input_audio_buffer.append":
+		field = "audio"
+	case strings.HasSuffix(eventType, "audio.delta"):
+		field = "delta"
+	}
+	payload, ok := event[field].(string)
+	if field == "" || !ok || payload == "" {
+		return msg
+	}
+	event[field] = base64.StdEncoding.EncodeToString(make([]byte, base64DecodedLen(payload)))
+	silenced, err := json.Marshal(event)
+	if err != nil {
+		return msg
+	}
+	return silenced
+}
+
+// anonymizeCommand writes an anonymized copy of a recording file as <name>.anonymized.ndjson
+// next to it, for convert -to anonymized.
+func anonymizeCommand(path string, opts anonymizeOptions) error {
+	in, err := openRecording(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	base, _, _ := strings.Cut(path, ".ndjson")
+	outPath := base + ".anonymized.ndjson"
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	dropped, err := anonymizeRecording(in, out, opts)
+	if err != nil {
+		os.Remove(outPath)
+		return err
+	}
+	log.Printf("Wrote anonymized recording to %s (%d events dropped)", outPath, dropped)
+	return nil
+}
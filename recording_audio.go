@@ -0,0 +1,314 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Recording Audio Extraction ---
+
+// audioTrack is the decoded audio of one conversation item.
+type audioTrack struct {
+	Name       string // File name without extension: the item ID, input_<item ID> for input audio
+	ItemID     string
+	Input      bool
+	SampleRate int
+	PCM        []byte
+}
+
+// audioExtractor rebuilds per-item audio from the events of a recording.
+type audioExtractor struct {
+	withInput    bool
+	inputFormat  string
+	outputFormat string
+
+	tracks  []*audioTrack
+	byItem  map[string]*audioTrack // Output tracks by item ID
+	input   []byte                 // Input audio appended since the last commit or clear
+	pending []byte                 // Audio committed by the client, waiting for its item ID
+	unnamed int                    // Input segments that never got an item ID
+}
+
+func newAudioExtractor(withInput bool) *audioExtractor {
+	return &audioExtractor{withInput: withInput, byItem: make(map[string]*audioTrack)}
+}
+
+// extractRecordingAudio decodes the output audio (and optionally the input audio) of a recording
+// into one track per item, in the order the items started.
+func extractRecordingAudio(path string, withInput bool) ([]*audioTrack, error) {
+	file, err := openRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+
+	x := newAudioExtractor(withInput)
+	for scanner.Scan() {
+		var event RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Frame != "" {
+			continue // Binary frames carry no format information to decode them with
+		}
+		x.handle(event.Data)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	x.finish()
+	return x.tracks, nil
+}
+
+// handle processes one recorded event. Client and server events are told apart by their type,
+// so split and combined recordings work alike.
+func (x *audioExtractor) handle(data json.RawMessage) {
+	var event struct {
+		Type    string          `json:"type"`
+		ItemID  string          `json:"item_id"`
+		Delta   string          `json:"delta"`
+		Audio   string          `json:"audio"`
+		Session json.RawMessage `json:"session"`
+	}
+	if json.Unmarshal(data, &event) != nil {
+		return
+	}
+
+	switch event.Type {
+	case "session.created", "session.updated", "session.update":
+		input, output := sessionAudioFormats(event.Session)
+		if input != "" {
+			x.inputFormat = input
+		}
+		if output != "" {
+			x.outputFormat = output
+		}
+	case "response.audio.delta", "response.output_audio.delta":
+		audio, err := base64.StdEncoding.DecodeString(event.Delta)
+		if err != nil || isOpus(x.outputFormat) {
+			return
+		}
+		track, ok := x.byItem[event.ItemID]
+		if !ok {
+			track = &audioTrack{Name: event.ItemID, ItemID: event.ItemID, SampleRate: audioSampleRate(x.outputFormat)}
+			x.byItem[event.ItemID] = track
+			x.tracks = append(x.tracks, track)
+		}
+		track.PCM = append(track.PCM, decodeToPCM16(audio, x.outputFormat)...)
+	}
+
+	if !x.withInput {
+		return
+	}
+	switch event.Type {
+	case "input_audio_buffer.append":
+		audio, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err == nil {
+			x.input = append(x.input, decodeToPCM16(audio, x.inputFormat)...)
+		}
+	case "input_audio_buffer.clear":
+		x.input = nil
+	case "input_audio_buffer.commit":
+		x.flushPending()
+		x.pending, x.input = x.input, nil
+	case "input_audio_buffer.committed":
+		// Answers a client commit, or commits the buffer itself with server VAD
+		audio := x.pending
+		if audio == nil {
+			audio, x.input = x.input, nil
+		}
+		x.pending = nil
+		x.addInput(event.ItemID, audio)
+	}
+}
+
+// sessionAudioFormats returns the audio formats a beta or GA session configuration sets, "" if unset.
+func sessionAudioFormats(session json.RawMessage) (input, output string) {
+	var s struct {
+		InputAudioFormat  string `json:"input_audio_format"`
+		OutputAudioFormat string `json:"output_audio_format"`
+		Audio             struct {
+			Input struct {
+				Format struct {
+					Type string `json:"type"`
+				} `json:"format"`
+			} `json:"input"`
+			Output struct {
+				Format struct {
+					Type string `json:"type"`
+				} `json:"format"`
+			} `json:"output"`
+		} `json:"audio"`
+	}
+	if json.Unmarshal(session, &s) != nil {
+		return "", ""
+	}
+	input, output = s.InputAudioFormat, s.OutputAudioFormat
+	if s.Audio.Input.Format.Type != "" {
+		input = s.Audio.Input.Format.Type
+	}
+	if s.Audio.Output.Format.Type != "" {
+		output = s.Audio.Output.Format.Type
+	}
+	return input, output
+}
+
+// addInput adds a committed input segment, numbered if the recording lacks its item ID
+// (e.g. an inbound-only recording).
+func (x *audioExtractor) addInput(itemID string, audio []byte) {
+	if len(audio) == 0 {
+		return
+	}
+	name := "input_" + itemID
+	if itemID == "" {
+		x.unnamed++
+		name = fmt.Sprintf("input_%03d", x.unnamed)
+	}
+	x.tracks = append(x.tracks, &audioTrack{Name: name, ItemID: itemID, Input: true, SampleRate: audioSampleRate(x.inputFormat), PCM: audio})
+}
+
+func (x *audioExtractor) flushPending() {
+	x.addInput("", x.pending)
+	x.pending = nil
+}
+
+// finish keeps input audio that was never committed, e.g. when the session ended mid-utterance.
+func (x *audioExtractor) finish() {
+	x.flushPending()
+	x.addInput("", x.input)
+	x.input = nil
+}
+
+// audioSampleRate returns the sample rate of decoded audio in a session audio format.
+func audioSampleRate(format string) int {
+	if isG711(format) {
+		return 8000
+	}
+	return sourceSampleRate
+}
+
+// wav returns the track as a WAV file.
+func (t *audioTrack) wav() []byte {
+	return encodeWAV(t.PCM, t.SampleRate)
+}
+
+// resolveRecording finds a recording by its listed name in the recordings directory, its recorded/
+// subdirectory or the storage backend. Recordings in a bucket are fetched into the local cache.
+func resolveRecording(filename string) (string, bool) {
+	if path, ok := storedRecording(filename); ok {
+		return path, true
+	}
+	recordingDir := currentConfig().Proxy.RecordingPath
+	if recordingDir == "" {
+		recordingDir = "recordings"
+	}
+	for _, dir := range []string{recordingDir, filepath.Join(recordingDir, "recorded")} {
+		if path, ok := recordingFile(filepath.Join(dir, filename)); ok {
+			return path, true
+		}
+	}
+	if dir, ok := sessionDirectory(filename); ok {
+		if path, ok := sessionMainRecording(dir); ok {
+			return path, true
+		}
+	}
+	return fetchRemoteRecording(filename)
+}
+
+// handleRecordingAudio serves the audio of a recording as a zip of WAV files, one per item, with the
+// captions of items that have a transcript.
+// ?input=true includes the client's input audio, ?item=<id> returns the WAV of a single item.
+func handleRecordingAudio(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	item := r.URL.Query().Get("item")
+	tracks, err := extractRecordingAudio(path, r.URL.Query().Get("input") == "true" || strings.HasPrefix(item, "input_"))
+	if err != nil {
+		log.Printf("Failed to extract audio from %s: %v", path, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	if len(tracks) == 0 {
+		http.Error(w, "Recording contains no decodable audio", http.StatusNotFound)
+		return
+	}
+
+	if item != "" {
+		for _, track := range tracks {
+			if track.Name == item {
+				w.Header().Set("Content-Type", "audio/wav")
+				w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", track.Name+".wav"))
+				w.Write(track.wav())
+				return
+			}
+		}
+		http.Error(w, "Item not found in recording", http.StatusNotFound)
+		return
+	}
+
+	t, _ := readTranscript(path)
+	base, _, _ := strings.Cut(name, ".ndjson")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", base+"_audio.zip"))
+	archive := zip.NewWriter(w)
+	for _, track := range tracks {
+		entry, err := archive.Create(track.Name + ".wav")
+		if err != nil {
+			break
+		}
+		entry.Write(track.wav())
+		if captions := trackCaptions(t, track); captions != "" {
+			if entry, err := archive.Create(track.Name + ".vtt"); err == nil {
+				entry.Write([]byte(captions))
+			}
+		}
+	}
+	archive.Close()
+}
+
+// extractAudioCommand writes the audio of a recording file as WAV files (and their captions) into
+// a <name>_audio directory next to it, for convert -to wav.
+func extractAudioCommand(path string, withInput bool) error {
+	tracks, err := extractRecordingAudio(path, withInput)
+	if err != nil {
+		return err
+	}
+	base, _, _ := strings.Cut(path, ".ndjson")
+	outDir := base + "_audio"
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	t, _ := readTranscript(path)
+	for _, track := range tracks {
+		out := filepath.Join(outDir, track.Name+".wav")
+		if err := os.WriteFile(out, track.wav(), 0644); err != nil {
+			return err
+		}
+		log.Printf("Wrote %d bytes of audio to %s", len(track.PCM), out)
+		if captions := trackCaptions(t, track); captions != "" {
+			if err := os.WriteFile(filepath.Join(outDir, track.Name+".vtt"), []byte(captions), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	log.Printf("Extracted %d audio tracks from %s", len(tracks), path)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// --- Caption Export (WebVTT / SRT) ---
+
+// caption is one subtitle cue, in milliseconds from the start of the audio it belongs to.
+type caption struct {
+	Start, End int64
+	Speaker    string
+	Text       string
+}
+
+const (
+	captionMaxChars  = 84 // Two lines of a typical subtitle
+	captionMsPerChar = 60 // Minimum display time, so bursts of deltas stay readable
+	captionMinMs     = 1000
+)
+
+// captionSegment is the text of a cue with the time its first delta arrived.
+type captionSegment struct {
+	at   int64
+	text string
+}
+
+// segments splits the text of a turn into cues at sentence ends, timed by its deltas. Turns whose
+// deltas do not add up to the final text (e.g. only a done event was recorded) become one cue.
+func (turn *transcriptTurn) segments() []captionSegment {
+	var joined strings.Builder
+	for _, d := range turn.Deltas {
+		joined.WriteString(d.Text)
+	}
+	if turn.Role == "user" || strings.TrimSpace(joined.String()) != strings.TrimSpace(turn.Text) {
+		return []captionSegment{{at: turn.Start, text: strings.TrimSpace(turn.Text)}}
+	}
+
+	var segments []captionSegment
+	var current strings.Builder
+	var at int64
+	for _, d := range turn.Deltas {
+		if current.Len() == 0 {
+			at = d.At
+		}
+		current.WriteString(d.Text)
+		text := strings.TrimSpace(current.String())
+		if strings.HasSuffix(text, ".") || strings.HasSuffix(text, "!") || strings.HasSuffix(text, "?") ||
+			utf8.RuneCountInString(text) >= captionMaxChars {
+			segments = append(segments, captionSegment{at: at, text: text})
+			current.Reset()
+		}
+	}
+	if text := strings.TrimSpace(current.String()); text != "" {
+		segments = append(segments, captionSegment{at: at, text: text})
+	}
+	return segments
+}
+
+// captions times the spoken and written messages of the transcript from the start of the
+// recording, or from the start of one item. Cues stay at least long enough to read and never overlap.
+func (t *transcript) captions(itemID string) []caption {
+	var cues []caption
+	var prevEnd int64
+	for _, turn := range t.Turns {
+		if turn.Type != "message" || turn.Text == "" || (itemID != "" && turn.ItemID != itemID) {
+			continue
+		}
+		base := t.Started
+		if itemID != "" {
+			base = turn.Start
+		}
+		turnEnd := turn.End
+		if turn.SpeechEnd > 0 {
+			turnEnd = turn.SpeechEnd
+		}
+
+		segments := turn.segments()
+		for i, segment := range segments {
+			end := turnEnd
+			if i+1 < len(segments) {
+				end = segments[i+1].at
+			}
+			cue := caption{Start: max(segment.at-base, prevEnd), Speaker: turn.label(), Text: segment.text}
+			readMs := max(int64(utf8.RuneCountInString(segment.text))*captionMsPerChar, captionMinMs)
+			cue.End = max(end-base, cue.Start+readMs)
+			cues = append(cues, cue)
+			prevEnd = cue.End
+		}
+	}
+	return cues
+}
+
+// captionTime formats milliseconds as hh:mm:ss.mmm, with a comma before the milliseconds for SRT.
+func captionTime(ms int64, sep string) string {
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// vtt renders the cues as WebVTT, with the speaker as a voice span.
+func vtt(cues []caption) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	for i, cue := range cues {
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n<v %s>%s\n", i+1, captionTime(cue.Start, "."), captionTime(cue.End, "."),
+			cue.Speaker, escape.Replace(cue.Text))
+	}
+	return b.String()
+}
+
+// srt renders the cues as SubRip, with the speaker in front of the text.
+func srt(cues []caption) string {
+	var b strings.Builder
+	for i, cue := range cues {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s: %s\n\n", i+1, captionTime(cue.Start, ","), captionTime(cue.End, ","),
+			cue.Speaker, cue.Text)
+	}
+	return b.String()
+}
+
+// handleRecordingCaptions serves the captions of a recording as WebVTT, or as SRT with ?format=srt.
+// ?item=<id> times the captions of one item from its start, to play along with its extracted WAV.
+func handleRecordingCaptions(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "vtt" && format != "srt" {
+		http.Error(w, "format must be vtt or srt", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	t, err := readTranscript(path)
+	if err != nil {
+		log.Printf("Failed to read transcript of %s: %v", path, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	item := strings.TrimPrefix(r.URL.Query().Get("item"), "input_") // As named in the audio zip
+	cues := t.captions(item)
+	if item != "" && len(cues) == 0 {
+		http.Error(w, "Item has no transcript", http.StatusNotFound)
+		return
+	}
+
+	base, _, _ := strings.Cut(name, ".ndjson")
+	if item != "" {
+		base = r.URL.Query().Get("item")
+	}
+	if format == "srt" {
+		w.Header().Set("Content-Type", "application/x-subrip; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", base+".srt"))
+		w.Write([]byte(srt(cues)))
+		return
+	}
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", base+".vtt"))
+	w.Write([]byte(vtt(cues)))
+}
+
+// trackCaptions returns the WebVTT captions of an extracted audio track, "" if it has no transcript.
+// Written next to the WAV with the same name, media players load them with the audio.
+func trackCaptions(t *transcript, track *audioTrack) string {
+	if t == nil || track.ItemID == "" {
+		return ""
+	}
+	cues := t.captions(track.ItemID)
+	if len(cues) == 0 {
+		return ""
+	}
+	return vtt(cues)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Runtime Recording Control ---
+
+// recordingControlEvent is the client event that turns recording on or off for its own
+// session. It is answered by the mock and never forwarded or recorded.
+const recordingControlEvent = "mock.recording.update"
+
+// recordingToggle switches all recorders of a session together.
+type recordingToggle struct {
+	mu        sync.Mutex
+	enabled   bool
+	recorders []*Recorder
+}
+
+// newRecordingToggle starts enabled unless the client connected with ?record=false.
+func newRecordingToggle(r *http.Request) *recordingToggle {
+	return &recordingToggle{enabled: r.URL.Query().Get("record") != "false"}
+}
+
+// add puts a recorder under the toggle, nil recorders are ignored.
+func (t *recordingToggle) add(recorder *Recorder) {
+	if recorder == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	recorder.SetEnabled(t.enabled)
+	t.recorders = append(t.recorders, recorder)
+}
+
+func (t *recordingToggle) set(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.enabled = enabled
+	for _, recorder := range t.recorders {
+		recorder.SetEnabled(enabled)
+	}
+}
+
+func (t *recordingToggle) isEnabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.enabled
+}
+
+// handleRecordingControl applies a recording control event and acknowledges it. It reports
+// false for any other message.
+func handleRecordingControl(conn *SafeWebSocket, toggle *recordingToggle, msg []byte) bool {
+	var event struct {
+		Type    string `json:"type"`
+		EventID string `json:"event_id"`
+		Enabled *bool  `json:"enabled"`
+	}
+	if json.Unmarshal(msg, &event) != nil || event.Type != recordingControlEvent {
+		return false
+	}
+	if event.Enabled == nil {
+		sendErrorEvent(conn, "invalid_request_error", "missing_required_parameter", "Missing required parameter: 'enabled'.", "enabled", event.EventID)
+		return true
+	}
+
+	toggle.set(*event.Enabled)
+	conn.logf("Client %s: Recording %s", conn.RemoteAddr(), enabledText(*event.Enabled))
+	sendJSONEvent(conn, map[string]interface{}{
+		"type":     "mock.recording.updated",
+		"event_id": uuid.NewString(),
+		"enabled":  *event.Enabled,
+	})
+	return true
+}
+
+func enabledText(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// --- Live Proxy Sessions ---
+
+// liveSession is a mock or proxied session that can be controlled and observed over HTTP while it runs.
+type liveSession struct {
+	ID          string
+	recording   *recordingToggle
+	usage       *SessionUsage  // Proxied sessions only
+	conn        *SafeWebSocket // The client's connection
+	mode        string         // The mode the connection is served in
+	scenario    string         // Mock scenario
+	replay      string         // Replayed recording
+	target      string         // Proxy target
+	model       string
+	connectedAt time.Time
+
+	mu        sync.Mutex
+	observers map[*sessionObserver]bool
+	ended     bool
+}
+
+var liveSessions = struct {
+	sync.Mutex
+	byID map[string]*liveSession
+}{byID: make(map[string]*liveSession)}
+
+func registerLiveSession(session *liveSession) {
+	liveSessions.Lock()
+	liveSessions.byID[session.ID] = session
+	liveSessions.Unlock()
+	feedSession(feedConnected, session)
+}
+
+func unregisterLiveSession(session *liveSession) {
+	liveSessions.Lock()
+	delete(liveSessions.byID, session.ID)
+	liveSessions.Unlock()
+	feedSession(feedDisconnected, session)
+	session.end()
+}
+
+// findLiveSession looks a session up by its proxy ID or its upstream session ID.
+func findLiveSession(id string) *liveSession {
+	liveSessions.Lock()
+	defer liveSessions.Unlock()
+	if session, ok := liveSessions.byID[id]; ok {
+		return session
+	}
+	for _, session := range liveSessions.byID {
+		if session.usage != nil && proxyUsage.sessionID(session.usage) == id {
+			return session
+		}
+	}
+	return nil
+}
+
+// handleSessionRecording turns recording of a live session on or off:
+// POST /sessions/{id}/recording with {"enabled": false}.
+func handleSessionRecording(w http.ResponseWriter, r *http.Request) {
+	session := findLiveSession(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, `Body must be {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+
+	session.recording.set(*body.Enabled)
+	log.Printf("Recording of session %s %s via API", session.ID, enabledText(*body.Enabled))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        session.ID,
+		"recording": *body.Enabled,
+	})
+}
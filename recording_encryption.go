@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// --- Recording Encryption ---
+
+// RecordingEncryption encrypts recordings at rest with AES-GCM, since they can hold what callers said.
+// Replay and the CLI tools decrypt them transparently; over HTTP only requests with the admin token can read them.
+type RecordingEncryption struct {
+	Enabled       bool   `yaml:"enabled" json:"enabled"`
+	KeyEnv        string `yaml:"keyEnv" json:"keyEnv"`               // Default RECORDING_ENCRYPTION_KEY, a base64 AES key of 16, 24 or 32 bytes
+	AdminTokenEnv string `yaml:"adminTokenEnv" json:"adminTokenEnv"` // Default RECORDING_ADMIN_TOKEN, the bearer token for reading recordings
+}
+
+const defaultRecordingKeyEnv = "RECORDING_ENCRYPTION_KEY"
+
+func (c RecordingEncryption) keyEnv() string {
+	return cmp.Or(c.KeyEnv, defaultRecordingKeyEnv)
+}
+
+func (c RecordingEncryption) adminTokenEnv() string {
+	return cmp.Or(c.AdminTokenEnv, "RECORDING_ADMIN_TOKEN")
+}
+
+func (c RecordingEncryption) validate(cfg *Config) error {
+	if !c.Enabled {
+		return nil
+	}
+	if _, err := newRecordingAEAD(c.keyEnv()); err != nil {
+		return fmt.Errorf("proxy.encryption: %w", err)
+	}
+	if cfg.Proxy.Storage.Backend == "sqlite" {
+		return fmt.Errorf("proxy.encryption needs the files or s3 storage backend")
+	}
+	if cfg.Proxy.CaptureAudio {
+		return fmt.Errorf("proxy.encryption cannot be combined with proxy.captureAudio, which writes unencrypted WAV files")
+	}
+	return nil
+}
+
+// recordingAEAD encrypts new recordings, set when proxy.encryption is enabled.
+var recordingAEAD cipher.AEAD
+
+func newRecordingAEAD(keyEnv string) (cipher.AEAD, error) {
+	encoded := os.Getenv(keyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("key not set (%s)", keyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key in %s is not base64: %v", keyEnv, err)
+	}
+	block, err := aes.NewCipher(key) // Checks the key length
+	if err != nil {
+		return nil, fmt.Errorf("key in %s: %v", keyEnv, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// configureEncryption loads the key recordings are encrypted with.
+func configureEncryption(c RecordingEncryption) error {
+	if !c.Enabled {
+		return nil
+	}
+	aead, err := newRecordingAEAD(c.keyEnv())
+	if err != nil {
+		return err
+	}
+	recordingAEAD = aead
+	return nil
+}
+
+// decryptionAEAD returns the key to read encrypted recordings with. The CLI tools run without a
+// configuration and take it from RECORDING_ENCRYPTION_KEY.
+func decryptionAEAD() (cipher.AEAD, error) {
+	if recordingAEAD != nil {
+		return recordingAEAD, nil
+	}
+	aead, err := newRecordingAEAD(defaultRecordingKeyEnv)
+	if err != nil {
+		return nil, fmt.Errorf("recording is encrypted: %w", err)
+	}
+	return aead, nil
+}
+
+// Encrypted recordings are a sequence of records, each sealed on its own so recordings can be appended to,
+// flushed and followed as before: the magic, the length of the rest (4 bytes, big-endian), a nonce and the
+// ciphertext with its tag. Gzip compression happens before encryption.
+const (
+	encryptedRecordMagic = "RME1"
+	maxEncryptedRecord   = 64 << 20
+)
+
+var errRecordingDecrypt = errors.New("failed to decrypt recording, wrong key or damaged file")
+
+// encryptingWriter seals every write as one record, written to w in a single call.
+type encryptingWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+func (e encryptingWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	nonceSize := e.aead.NonceSize()
+	size := nonceSize + len(p) + e.aead.Overhead()
+	record := make([]byte, 8+nonceSize, 8+size)
+	copy(record, encryptedRecordMagic)
+	binary.BigEndian.PutUint32(record[4:8], uint32(size))
+	if _, err := rand.Read(record[8 : 8+nonceSize]); err != nil {
+		return 0, err
+	}
+	record = e.aead.Seal(record, record[8:8+nonceSize], p, nil)
+	if _, err := e.w.Write(record); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decryptingReader opens the records of an encrypted recording one by one. A record cut short
+// reads as io.ErrUnexpectedEOF, like a truncated gzip stream.
+type decryptingReader struct {
+	r    io.Reader
+	aead cipher.AEAD
+	buf  []byte
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		var header [8]byte
+		if _, err := io.ReadFull(d.r, header[:]); err != nil {
+			return 0, err // io.EOF between records, io.ErrUnexpectedEOF within one
+		}
+		size := binary.BigEndian.Uint32(header[4:])
+		if string(header[:4]) != encryptedRecordMagic || size < uint32(d.aead.NonceSize()+d.aead.Overhead()) || size > maxEncryptedRecord {
+			return 0, errRecordingDecrypt
+		}
+		record := make([]byte, size)
+		if _, err := io.ReadFull(d.r, record); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		nonce, ciphertext := record[:d.aead.NonceSize()], record[d.aead.NonceSize():]
+		plain, err := d.aead.Open(ciphertext[:0], nonce, ciphertext, nil)
+		if err != nil {
+			return 0, errRecordingDecrypt
+		}
+		d.buf = plain
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// decryptRecording returns r decrypted if it is an encrypted recording, as it is otherwise.
+func decryptRecording(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(encryptedRecordMagic))
+	if !bytes.Equal(magic, []byte(encryptedRecordMagic)) {
+		return br, nil
+	}
+	aead, err := decryptionAEAD()
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{r: br, aead: aead}, nil
+}
+
+// isEncryptedRecording reports whether the file at path is an encrypted recording.
+func isEncryptedRecording(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(encryptedRecordMagic))
+	_, err = io.ReadFull(f, magic)
+	return err == nil && string(magic) == encryptedRecordMagic
+}
+
+// authorizeRecordingRead lets a request read the contents of recordings. With proxy.encryption it
+// needs the admin token, as "Authorization: Bearer <token>" or ?token=<token> for links and EventSource.
+func authorizeRecordingRead(w http.ResponseWriter, r *http.Request) bool {
+	c := requestConfig(r).Proxy.Encryption
+	if !c.Enabled {
+		return true
+	}
+	token := os.Getenv(c.adminTokenEnv())
+	if token == "" {
+		http.Error(w, fmt.Sprintf("Recordings are encrypted, set %s to read them over HTTP", c.adminTokenEnv()), http.StatusForbidden)
+		return false
+	}
+	given := cmp.Or(clientAPIKey(r), r.URL.Query().Get("token"))
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Admin token required to read encrypted recordings", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// requireRecordingAdmin guards a handler that returns the contents of recordings.
+func requireRecordingAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorizeRecordingRead(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// serveEncryptedRecording sends a recording decrypted. Asked for by its .gz name, the compressed
+// stream is sent as it was before encryption, otherwise NDJSON.
+func serveEncryptedRecording(w http.ResponseWriter, filename, path string) {
+	var rd io.ReadCloser
+	var err error
+	if strings.HasSuffix(filename, ".gz") {
+		var f *os.File
+		if f, err = os.Open(path); err == nil {
+			var decrypted io.Reader
+			if decrypted, err = decryptRecording(f); err == nil {
+				rd = recordingReader{decrypted, f}
+			} else {
+				f.Close()
+			}
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+	} else {
+		rd, err = openRecording(path)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	if err != nil {
+		log.Printf("Failed to open encrypted recording %s: %v", path, err)
+		w.Header().Del("Content-Type")
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	defer rd.Close()
+	if _, err := io.Copy(w, rd); err != nil {
+		log.Printf("Failed to send encrypted recording %s: %v", path, err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testAEAD(t *testing.T, key string) cipher.AEAD {
+	t.Helper()
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+// encryptRecords writes each line as one record, like a Recorder does.
+func encryptRecords(t *testing.T, aead cipher.AEAD, lines ...string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := encryptingWriter{&out, aead}
+	for _, line := range lines {
+		if n, err := w.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("Write(%q) = %d, %v", line, n, err)
+		}
+	}
+	return out.Bytes()
+}
+
+func decryptAll(aead cipher.AEAD, data []byte) (string, error) {
+	plain, err := io.ReadAll(&decryptingReader{r: bytes.NewReader(data), aead: aead})
+	return string(plain), err
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	aead := testAEAD(t, "0123456789abcdef")
+	lines := []string{`{"type":"session.created"}` + "\n", `{"type":"response.done"}` + "\n", strings.Repeat("x", 100_000)}
+	data := encryptRecords(t, aead, lines...)
+
+	got, err := decryptAll(aead, data)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if want := strings.Join(lines, ""); got != want {
+		t.Errorf("round trip returned %d bytes, want %d", len(got), len(want))
+	}
+	if bytes.Contains(data, []byte("session.created")) {
+		t.Error("encrypted recording contains plaintext")
+	}
+}
+
+func TestEncryptionRecordFraming(t *testing.T) {
+	aead := testAEAD(t, "0123456789abcdef")
+	lines := []string{"first\n", "second line\n"}
+	data := encryptRecords(t, aead, lines...)
+
+	for i, line := range lines {
+		if len(data) < 8 || string(data[:4]) != encryptedRecordMagic {
+			t.Fatalf("record %d does not start with %s", i, encryptedRecordMagic)
+		}
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		if want := aead.NonceSize() + len(line) + aead.Overhead(); size != want {
+			t.Errorf("record %d has size %d, want %d", i, size, want)
+		}
+		data = data[8+size:]
+	}
+	if len(data) != 0 {
+		t.Errorf("%d bytes after the last record", len(data))
+	}
+
+	if n, err := (encryptingWriter{io.Discard, aead}).Write(nil); n != 0 || err != nil {
+		t.Errorf("empty Write = %d, %v, want no record", n, err)
+	}
+}
+
+func TestDecryptTruncatedRecording(t *testing.T) {
+	aead := testAEAD(t, "0123456789abcdef")
+	first := encryptRecords(t, aead, "first\n")
+	data := append(first, encryptRecords(t, aead, "second\n")...)
+
+	tests := []struct {
+		name    string
+		size    int
+		want    string
+		wantErr error
+	}{
+		{"between records", len(first), "first\n", nil},
+		{"within a header", len(first) + 5, "first\n", io.ErrUnexpectedEOF},
+		{"within a record", len(data) - 1, "first\n", io.ErrUnexpectedEOF},
+		{"empty", 0, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptAll(aead, data[:tt.size])
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decrypted %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptCorruptedRecording(t *testing.T) {
+	aead := testAEAD(t, "0123456789abcdef")
+	data := encryptRecords(t, aead, "secret\n")
+
+	corrupt := func(change func([]byte)) []byte {
+		c := bytes.Clone(data)
+		change(c)
+		return c
+	}
+	tests := []struct {
+		name string
+		data []byte
+		aead cipher.AEAD
+	}{
+		{"flipped ciphertext", corrupt(func(c []byte) { c[len(c)-1] ^= 1 }), aead},
+		{"flipped nonce", corrupt(func(c []byte) { c[8] ^= 1 }), aead},
+		{"bad magic", corrupt(func(c []byte) { c[0] = 'X' }), aead},
+		{"size too small", corrupt(func(c []byte) { binary.BigEndian.PutUint32(c[4:8], 4) }), aead},
+		{"size too large", corrupt(func(c []byte) { binary.BigEndian.PutUint32(c[4:8], maxEncryptedRecord+1) }), aead},
+		{"wrong key", data, testAEAD(t, "fedcba9876543210")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := decryptAll(tt.aead, tt.data); err != errRecordingDecrypt {
+				t.Errorf("decrypted %q, %v, want %v", got, err, errRecordingDecrypt)
+			}
+		})
+	}
+}
+
+func TestDecryptRecordingDetectsEncryption(t *testing.T) {
+	aead := testAEAD(t, "0123456789abcdef")
+	defer func(saved cipher.AEAD) { recordingAEAD = saved }(recordingAEAD)
+	recordingAEAD = aead
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"encrypted", encryptRecords(t, aead, "line\n")},
+		{"plain", []byte("line\n")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decryptRecording(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := io.ReadAll(r); err != nil || string(got) != "line\n" {
+				t.Errorf("read %q, %v, want %q", got, err, "line\n")
+			}
+		})
+	}
+}
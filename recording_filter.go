@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"path"
+	"strings"
+)
+
+// --- Recording Filters ---
+
+// RecordingFilter limits what a recorder writes. Event type patterns use path.Match
+// globs, e.g. "response.*.delta"; binary frames match the type "binary".
+type RecordingFilter struct {
+	Include    []string `yaml:"include,omitempty" json:"include,omitempty"` // Only record matching types (empty records all)
+	Exclude    []string `yaml:"exclude,omitempty" json:"exclude,omitempty"` // Never record matching types
+	StripAudio bool     `yaml:"stripAudio" json:"stripAudio"`               // Replace base64 audio with its decoded byte length
+}
+
+// RecordingFilters holds the filters of the inbound and outbound recorders and
+// the redaction applied to both.
+type RecordingFilters struct {
+	Inbound  RecordingFilter `yaml:"inbound" json:"inbound"`
+	Outbound RecordingFilter `yaml:"outbound" json:"outbound"`
+	Redact   RedactionConfig `yaml:"redact" json:"redact"`
+}
+
+func (f RecordingFilter) validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f RecordingFilter) enabled() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0 || f.StripAudio
+}
+
+// apply returns the message to record, or false if it should be skipped.
+func (f RecordingFilter) apply(msg []byte) ([]byte, bool) {
+	if !f.enabled() {
+		return msg, true
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return msg, true
+	}
+	eventType, _ := event["type"].(string)
+	if !f.keeps(eventType) {
+		return nil, false
+	}
+
+	if !f.StripAudio {
+		return msg, true
+	}
+	// Audio travels in "delta" (response audio) and "audio" (input_audio_buffer.append)
+	field := ""
+	switch {
+	case eventType == "input_audio_buffer.append":
+		field = "audio"
+	case strings.HasSuffix(eventType, "audio.delta"):
+		field = "delta"
+	}
+	payload, ok := event[field].(string)
+	if field == "" || !ok {
+		return msg, true
+	}
+	event[field] = ""
+	event[field+"_bytes"] = base64DecodedLen(payload)
+	stripped, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error stripping audio from recorded event: %v", err)
+		return msg, true
+	}
+	return stripped, true
+}
+
+// binaryFrameType is the event type binary WebSocket frames are filtered as.
+const binaryFrameType = "binary"
+
+// keeps reports whether events of this type pass the include/exclude lists.
+func (f RecordingFilter) keeps(eventType string) bool {
+	if len(f.Include) > 0 && !matchesAny(f.Include, eventType) {
+		return false
+	}
+	return !matchesAny(f.Exclude, eventType)
+}
+
+func matchesAny(patterns []string, eventType string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, eventType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// base64DecodedLen returns the number of bytes a padded base64 string decodes to.
+func base64DecodedLen(s string) int {
+	n := len(s) / 4 * 3
+	if strings.HasSuffix(s, "==") {
+		n -= 2
+	} else if strings.HasSuffix(s, "=") {
+		n--
+	}
+	return n
+}
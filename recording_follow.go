@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Following Recordings ---
+
+// recordingFollower receives the lines of a recording as they are written.
+type recordingFollower struct {
+	send chan []byte
+}
+
+// liveRecorders are the open recorders by the path of their recording.
+var liveRecorders = struct {
+	sync.Mutex
+	byPath map[string]*Recorder
+}{byPath: make(map[string]*Recorder)}
+
+// listedName is the name the recording of r has in GET /recordings.
+func (r *Recorder) listedName() string {
+	if r.store != nil {
+		return r.name + ".ndjson"
+	}
+	return filepath.Base(r.path)
+}
+
+func registerLiveRecorder(r *Recorder) {
+	liveRecorders.Lock()
+	defer liveRecorders.Unlock()
+	liveRecorders.byPath[r.path] = r
+}
+
+func unregisterLiveRecorder(r *Recorder) {
+	liveRecorders.Lock()
+	defer liveRecorders.Unlock()
+	if liveRecorders.byPath[r.path] == r {
+		delete(liveRecorders.byPath, r.path)
+	}
+}
+
+// findLiveRecorder returns the open recorder of a listed recording. Recorders in session
+// directories are not listed and so never found.
+func findLiveRecorder(name string) *Recorder {
+	sessionPath := ""
+	if dir, ok := sessionDirectory(name); ok {
+		sessionPath, _ = sessionMainRecording(dir)
+	}
+	liveRecorders.Lock()
+	defer liveRecorders.Unlock()
+	if r, ok := liveRecorders.byPath[sessionPath]; ok {
+		return r // A session directory stands for its main recording
+	}
+	for _, r := range liveRecorders.byPath {
+		if r.listedName() == name && !r.inSessionDir() {
+			return r
+		}
+	}
+	return nil
+}
+
+// inSessionDir reports whether the recording is a file in a session directory.
+func (r *Recorder) inSessionDir() bool {
+	return r.store == nil && filepath.Base(filepath.Dir(filepath.Dir(r.path))) == "recorded"
+}
+
+// liveRecordingPaths returns the paths of all open recordings, with every part written so far.
+func liveRecordingPaths() map[string]bool {
+	liveRecorders.Lock()
+	recorders := make([]*Recorder, 0, len(liveRecorders.byPath))
+	for _, r := range liveRecorders.byPath {
+		recorders = append(recorders, r)
+	}
+	liveRecorders.Unlock()
+
+	paths := make(map[string]bool)
+	for _, r := range recorders {
+		r.mu.Lock()
+		paths[r.path] = true
+		for part := 2; part <= r.part; part++ {
+			paths[partPath(r.path, part)] = true
+		}
+		r.mu.Unlock()
+	}
+	return paths
+}
+
+// isLiveRecording reports whether a listed recording is still being written.
+func isLiveRecording(name string) bool {
+	return findLiveRecorder(name) != nil
+}
+
+// followCut is where the history of a followed recording ends and the lines sent to the follower start.
+type followCut struct {
+	paths  []string // Parts of a file recording, the last one being written
+	offset int64    // Bytes of the last part that belong to the history
+	store  string   // Path of a stored recording
+	lines  int      // Lines of the stored recording that belong to the history
+}
+
+// follow registers a follower, or returns nil if the recording is already closed. Compressed
+// recordings are flushed so their history can be read up to the cut.
+func (r *Recorder) follow() (*recordingFollower, followCut) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.isOpen() {
+		return nil, followCut{}
+	}
+
+	var cut followCut
+	if r.store != nil {
+		cut.store, cut.lines = r.path, r.stored
+	} else {
+		if r.gz != nil {
+			if err := r.gz.Flush(); err != nil {
+				log.Printf("Error flushing compressed recording: %v", err)
+			}
+		}
+		cut.paths = append(cut.paths, r.path)
+		for part := 2; part <= r.part; part++ {
+			cut.paths = append(cut.paths, partPath(r.path, part))
+		}
+		cut.offset = r.size + r.written
+	}
+
+	if r.followers == nil {
+		r.followers = make(map[*recordingFollower]bool)
+	}
+	follower := &recordingFollower{send: make(chan []byte, observerBuffer)}
+	r.followers[follower] = true
+	return follower, cut
+}
+
+func (r *Recorder) unfollow(follower *recordingFollower) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.followers[follower] {
+		delete(r.followers, follower)
+		close(follower.send)
+	}
+}
+
+// notifyFollowers passes a written line on. Slow followers miss lines rather than slowing down
+// the session. Callers hold the lock.
+func (r *Recorder) notifyFollowers(line []byte) {
+	for follower := range r.followers {
+		select {
+		case follower.send <- line:
+		default:
+		}
+	}
+}
+
+// closeFollowers ends the streams of all followers. Callers hold the lock.
+func (r *Recorder) closeFollowers() {
+	for follower := range r.followers {
+		delete(r.followers, follower)
+		close(follower.send)
+	}
+}
+
+// history reads the lines of a recording up to the cut. A flushed gzip stream ends without its
+// trailer, which is expected here.
+func (c followCut) history(fn func(line []byte) error) error {
+	if c.store != "" {
+		rc, err := openRecording(c.store)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return scanLines(rc, c.lines, fn)
+	}
+	for i, path := range c.paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		var rd io.Reader = f
+		if i == len(c.paths)-1 {
+			rd = io.LimitReader(f, c.offset)
+		}
+		if rd, err = decryptRecording(rd); err != nil {
+			f.Close()
+			return err
+		}
+		if strings.HasSuffix(path, ".gz") {
+			gz, err := gzip.NewReader(rd)
+			if err == io.EOF {
+				f.Close()
+				continue // Nothing flushed yet
+			}
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("failed to read compressed recording %s: %w", path, err)
+			}
+			rd = gz
+		}
+		err = scanLines(rd, -1, fn)
+		f.Close()
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanLines calls fn for the first n lines (all for n < 0).
+func scanLines(rd io.Reader, n int, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(rd)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	for i := 0; n < 0 || i < n; i++ {
+		if !scanner.Scan() {
+			break
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handleFollowRecording streams a recording as server-sent events, one "data:" per NDJSON line:
+// first what was recorded so far, then every line as it is written. An "end" event follows once
+// the recording is closed, right after the history for recordings that are not being written.
+func handleFollowRecording(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var follower *recordingFollower
+	var cut followCut
+	recorder := findLiveRecorder(name)
+	if recorder != nil {
+		follower, cut = recorder.follow()
+	}
+	if follower == nil {
+		path, ok := resolveRecording(name)
+		if !ok {
+			http.Error(w, "Recording not found", http.StatusNotFound)
+			return
+		}
+		cut = followCut{store: path, lines: -1}
+		if !strings.HasPrefix(path, storedPrefix) {
+			cut = followCut{paths: []string{path}, offset: 1<<63 - 1}
+		}
+	} else {
+		defer recorder.unfollow(follower)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream
+	send := func(line []byte) error {
+		_, err := fmt.Fprintf(w, "data: %s\n\n", line)
+		return err
+	}
+	if err := cut.history(send); err != nil {
+		log.Printf("Follow %s: Failed to read recording: %v", name, err)
+	}
+	flusher.Flush()
+
+	if follower != nil {
+		log.Printf("Follow %s: Streaming to %s", name, r.RemoteAddr)
+		keepAlive := time.NewTicker(15 * time.Second)
+		defer keepAlive.Stop()
+	stream:
+		for {
+			select {
+			case line, ok := <-follower.send:
+				if !ok {
+					break stream
+				}
+				if send(line) != nil {
+					return
+				}
+				flusher.Flush()
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+	fmt.Fprint(w, "event: end\ndata: {}\n\n")
+	flusher.Flush()
+}
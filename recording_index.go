@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --- Recording Search ---
+
+// recordingSummary is what the search knows about a recording file.
+type recordingSummary struct {
+	modTime    time.Time // Of the file when it was indexed
+	size       int64
+	startedAt  time.Time
+	eventTypes map[string]bool
+	transcript string // Lowercased text and transcripts of both sides
+}
+
+// recordingIndex caches recording summaries by path. Files are indexed the first time a search
+// needs them and again whenever they change.
+type recordingIndex struct {
+	mu      sync.Mutex
+	entries map[string]*recordingSummary
+}
+
+var recordingSearchIndex = &recordingIndex{entries: make(map[string]*recordingSummary)}
+
+// summary returns the summary of a recording, indexing it if it is new or has changed.
+func (idx *recordingIndex) summary(path string) (*recordingSummary, error) {
+	size, modTime, err := statRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	idx.mu.Lock()
+	cached, ok := idx.entries[path]
+	idx.mu.Unlock()
+	if ok && cached.modTime.Equal(modTime) && cached.size == size {
+		return cached, nil
+	}
+
+	summary, err := summarizeRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	summary.modTime, summary.size = modTime, size
+	if summary.startedAt.IsZero() {
+		summary.startedAt = modTime
+	}
+	idx.mu.Lock()
+	idx.entries[path] = summary
+	idx.mu.Unlock()
+	return summary, nil
+}
+
+// summarizeRecording reads a recording and collects its start time, event types and transcript.
+func summarizeRecording(path string) (*recordingSummary, error) {
+	file, err := openRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+
+	summary := &recordingSummary{eventTypes: make(map[string]bool)}
+	var text []string
+	deltas := make(map[string]*strings.Builder) // Streamed text by item, in case the done events were filtered
+	var deltaOrder []string
+	for scanner.Scan() {
+		var event RecordedEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			continue
+		}
+		if summary.startedAt.IsZero() && event.Timestamp > 0 {
+			summary.startedAt = time.UnixMilli(event.Timestamp)
+		}
+		if event.Frame != "" {
+			summary.eventTypes[event.Frame] = true
+			continue
+		}
+
+		var data struct {
+			Type       string `json:"type"`
+			ItemID     string `json:"item_id"`
+			Delta      string `json:"delta"`
+			Text       string `json:"text"`
+			Transcript string `json:"transcript"`
+			Item       struct {
+				Content []struct {
+					Text       string `json:"text"`
+					Transcript string `json:"transcript"`
+				} `json:"content"`
+			} `json:"item"`
+		}
+		if json.Unmarshal(event.Data, &data) != nil {
+			continue
+		}
+		summary.eventTypes[data.Type] = true
+
+		switch data.Type {
+		case "response.text.delta", "response.output_text.delta",
+			"response.audio_transcript.delta", "response.output_audio_transcript.delta":
+			b, ok := deltas[data.ItemID]
+			if !ok {
+				b = &strings.Builder{}
+				deltas[data.ItemID] = b
+				deltaOrder = append(deltaOrder, data.ItemID)
+			}
+			b.WriteString(data.Delta)
+		case "response.text.done", "response.output_text.done":
+			text = append(text, data.Text)
+		case "response.audio_transcript.done", "response.output_audio_transcript.done",
+			"conversation.item.input_audio_transcription.completed":
+			text = append(text, data.Transcript)
+		case "conversation.item.create", "conversation.item.created":
+			for _, content := range data.Item.Content {
+				text = append(text, content.Text, content.Transcript)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	for _, itemID := range deltaOrder {
+		text = append(text, deltas[itemID].String())
+	}
+	summary.transcript = strings.ToLower(strings.Join(text, "\n"))
+	return summary, nil
+}
+
+// recordingQuery holds the filters of GET /recordings.
+type recordingQuery struct {
+	since, until time.Time
+	contains     string
+	eventTypes   []string
+}
+
+// parseRecordingQuery reads since/until (RFC 3339 or YYYY-MM-DD), contains and event_type
+// (comma-separated, all must occur). ok is false when no filter was given.
+func parseRecordingQuery(query url.Values) (q recordingQuery, ok bool, err error) {
+	for _, bound := range []struct {
+		param string
+		t     *time.Time
+	}{{"since", &q.since}, {"until", &q.until}} {
+		value := query.Get(bound.param)
+		if value == "" {
+			continue
+		}
+		if *bound.t, err = parseQueryTime(value); err != nil {
+			return q, false, fmt.Errorf("invalid %s: %s", bound.param, value)
+		}
+		// A bare date includes the whole day
+		if bound.param == "until" && len(value) == len(time.DateOnly) {
+			*bound.t = bound.t.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+	q.contains = strings.ToLower(query.Get("contains"))
+	for _, eventType := range strings.Split(query.Get("event_type"), ",") {
+		if eventType = strings.TrimSpace(eventType); eventType != "" {
+			q.eventTypes = append(q.eventTypes, eventType)
+		}
+	}
+	ok = !q.since.IsZero() || !q.until.IsZero() || q.contains != "" || len(q.eventTypes) > 0
+	return q, ok, nil
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+// matches reports whether a recording passes all filters of the query.
+func (q recordingQuery) matches(summary *recordingSummary) bool {
+	if !q.since.IsZero() && summary.startedAt.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && summary.startedAt.After(q.until) {
+		return false
+	}
+	if q.contains != "" && !strings.Contains(summary.transcript, q.contains) {
+		return false
+	}
+	for _, eventType := range q.eventTypes {
+		if !summary.eventTypes[eventType] {
+			return false
+		}
+	}
+	return true
+}
+
+// searchRecordings keeps the listed recordings that match the query. Other files, such as
+// captured audio, and recordings that cannot be read are left out.
+func searchRecordings(files []RecordingFile, q recordingQuery) []RecordingFile {
+	matched := []RecordingFile{}
+	for _, file := range files {
+		if !strings.Contains(file.Name, ".ndjson") && file.Files == nil {
+			continue // Session directories are searched by their main recording
+		}
+		if file.Store == "s3" {
+			if _, ok := fetchRemoteRecording(file.Name); !ok {
+				continue
+			}
+		}
+		summary, err := recordingSearchIndex.summary(file.path)
+		if err == nil && q.matches(summary) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// --- Recording Listing Order & Pages ---
+
+// recordingPage holds the sorting and pagination of GET /recordings.
+type recordingPage struct {
+	sort   string // "name", "mtime" or "size"
+	desc   bool
+	offset int
+	limit  int // 0 lists everything after offset
+}
+
+// parseRecordingPage reads sort, order (asc or desc), offset and limit.
+func parseRecordingPage(query url.Values) (recordingPage, error) {
+	p := recordingPage{sort: query.Get("sort")}
+	switch p.sort {
+	case "":
+		p.sort = "name"
+	case "name", "mtime", "size":
+	default:
+		return p, fmt.Errorf("invalid sort: %s (use name, mtime or size)", p.sort)
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		p.desc = true
+	default:
+		return p, fmt.Errorf("invalid order: %s (use asc or desc)", query.Get("order"))
+	}
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"offset", &p.offset}, {"limit", &p.limit}} {
+		if value := query.Get(param.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return p, fmt.Errorf("invalid %s: %s", param.name, value)
+			}
+			*param.value = n
+		}
+	}
+	return p, nil
+}
+
+// apply sorts the recordings and returns the requested page. Ties are broken by name, so pages are stable.
+func (p recordingPage) apply(files []RecordingFile) []RecordingFile {
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if p.desc {
+			a, b = b, a
+		}
+		switch {
+		case p.sort == "mtime" && !a.ModTime.Equal(b.ModTime):
+			return a.ModTime.Before(b.ModTime)
+		case p.sort == "size" && a.Size != b.Size:
+			return a.Size < b.Size
+		}
+		return a.Name < b.Name
+	})
+	if p.offset >= len(files) {
+		return []RecordingFile{}
+	}
+	files = files[p.offset:]
+	if p.limit > 0 && p.limit < len(files) {
+		files = files[:p.limit]
+	}
+	return files
+}
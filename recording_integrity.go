@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Recording Integrity ---
+
+// IntegrityConfig adds checksums to recordings, so recordings truncated or damaged by a crash
+// can be told apart from complete ones before a test relies on them.
+type IntegrityConfig struct {
+	Checksums     bool `yaml:"checksums" json:"checksums"`         // SHA-256 of every file in the .meta.json when the recording closes
+	LineChecksums bool `yaml:"lineChecksums" json:"lineChecksums"` // Also a crc32 of the data of every line
+}
+
+func (c IntegrityConfig) enabled() bool {
+	return c.Checksums || c.LineChecksums
+}
+
+// FileChecksum is the checksum of one file of a recording, compressed as it is on disk.
+type FileChecksum struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// lineChecksum returns the CRC-32 of the data of a recorded line, as it appears in the line.
+func lineChecksum(data json.RawMessage) string {
+	canonical, err := json.Marshal(data) // Compacted and escaped like in the line
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE(canonical))
+}
+
+// fileChecksums hashes the files of a closed recording.
+func fileChecksums(paths []string) []FileChecksum {
+	var sums []FileChecksum
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		h := sha256.New()
+		size, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			log.Printf("Error computing checksum of %s: %v", path, err)
+			continue
+		}
+		sums = append(sums, FileChecksum{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(h.Sum(nil))})
+	}
+	return sums
+}
+
+// recordingParts returns the files of a recording: the first part and the rotated ones after it.
+func recordingParts(path string) []string {
+	paths := []string{path}
+	for part := 2; ; part++ {
+		next := partPath(path, part)
+		if _, err := os.Stat(next); err != nil {
+			return paths
+		}
+		paths = append(paths, next)
+	}
+}
+
+// RecordingVerification is the result of checking a recording.
+type RecordingVerification struct {
+	Name               string             `json:"name"`
+	OK                 bool               `json:"ok"`
+	Live               bool               `json:"live,omitempty"`
+	Closed             bool               `json:"closed"` // The metadata has an ended_at
+	Files              []FileVerification `json:"files,omitempty"`
+	Events             int                `json:"events"`
+	InvalidLines       int                `json:"invalid_lines"`
+	LineChecksumErrors int                `json:"line_checksum_errors"`
+	LineErrors         []LineError        `json:"line_errors,omitempty"` // The first maxLineErrors bad lines
+	Truncated          bool               `json:"truncated"`             // A file ends mid-line or mid-stream
+	Problems           []string           `json:"problems,omitempty"`
+}
+
+// FileVerification compares a file with its checksum from the metadata, if there is one.
+type FileVerification struct {
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	SHA256         string `json:"sha256"`
+	ExpectedSHA256 string `json:"expected_sha256,omitempty"`
+	ChecksumOK     *bool  `json:"checksum_ok,omitempty"`
+}
+
+// LineError is a line of a recording that replay would skip or that was changed.
+type LineError struct {
+	File  string `json:"file"`
+	Line  int    `json:"line"` // 1-based, within the file
+	Error string `json:"error"`
+}
+
+const maxLineErrors = 100
+
+// lastByteReader remembers the last byte read through it.
+type lastByteReader struct {
+	r    io.Reader
+	last byte
+}
+
+func (l *lastByteReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if n > 0 {
+		l.last = p[n-1]
+	}
+	return n, err
+}
+
+// verifyRecording checks that every line of a recording parses and matches its checksum, that
+// no file was cut short and that the files match the checksums written when it was closed.
+func verifyRecording(name, path string) (*RecordingVerification, error) {
+	v := &RecordingVerification{Name: name, Live: isLiveRecording(name)}
+	meta := readRecordingMeta(path)
+	v.Closed = meta != nil && meta.EndedAt != nil
+
+	if strings.HasPrefix(path, storedPrefix) {
+		rc, err := openRecording(path)
+		if err != nil {
+			return nil, err
+		}
+		err = v.checkLines(rc, name)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		expected := make(map[string]string)
+		if meta != nil {
+			for _, sum := range meta.Checksums {
+				expected[sum.Name] = sum.SHA256
+			}
+		}
+		for _, part := range recordingParts(path) {
+			file, err := v.checkFile(part)
+			if err != nil {
+				return nil, err
+			}
+			if want, ok := expected[file.Name]; ok {
+				match := want == file.SHA256
+				file.ExpectedSHA256, file.ChecksumOK = want, &match
+				if !match {
+					v.Problems = append(v.Problems, fmt.Sprintf("%s does not match its checksum", file.Name))
+				}
+			}
+			v.Files = append(v.Files, *file)
+		}
+		if meta != nil && len(meta.Checksums) > 0 && len(meta.Checksums) != len(v.Files) {
+			v.Problems = append(v.Problems, fmt.Sprintf("%d files recorded, %d found", len(meta.Checksums), len(v.Files)))
+		}
+	}
+
+	switch {
+	case v.Live:
+		v.Problems = append(v.Problems, "recording is still being written")
+	case meta != nil && !v.Closed:
+		v.Problems = append(v.Problems, "recording was never closed")
+	}
+	if v.Truncated {
+		v.Problems = append(v.Problems, "recording is truncated")
+	}
+	if v.InvalidLines > 0 {
+		v.Problems = append(v.Problems, fmt.Sprintf("%d lines are not valid events", v.InvalidLines))
+	}
+	if v.LineChecksumErrors > 0 {
+		v.Problems = append(v.Problems, fmt.Sprintf("%d lines do not match their checksum", v.LineChecksumErrors))
+	}
+	v.OK = len(v.Problems) == 0
+	return v, nil
+}
+
+// checkFile hashes a file as it is on disk while checking its lines.
+func (v *RecordingVerification) checkFile(path string) (*FileVerification, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	raw := io.TeeReader(f, h)
+
+	content, err := decryptRecording(raw)
+	if err != nil {
+		return nil, err
+	}
+	lines := &lastByteReader{r: content}
+	if strings.HasSuffix(path, ".gz") {
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(content); err == nil {
+			lines.r = gz
+		}
+	}
+	if err == nil {
+		err = v.checkLines(lines, filepath.Base(path))
+	}
+	switch {
+	case err == nil, err == io.EOF: // io.EOF: nothing was written
+	case err == io.ErrUnexpectedEOF:
+		v.Truncated = true // The gzip stream or an encrypted record ends early
+	case errors.Is(err, errRecordingDecrypt), errors.Is(err, gzip.ErrHeader), errors.Is(err, gzip.ErrChecksum):
+		v.Problems = append(v.Problems, fmt.Sprintf("%s: %v", filepath.Base(path), err))
+	default:
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if lines.last != 0 && lines.last != '\n' {
+		v.Truncated = true
+	}
+	size, err := io.Copy(io.Discard, raw) // The rest of the file, for the checksum
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+	return &FileVerification{Name: filepath.Base(path), Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// checkLines validates every line of a file like an upload, noting the line numbers of bad ones.
+// Empty lines are skipped, as in replay.
+func (v *RecordingVerification) checkLines(r io.Reader, file string) error {
+	scanner := bufio.NewScanner(r)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := validateRecordedLine(line); err != nil {
+			v.InvalidLines++
+			v.lineError(file, lineNo, err.Error())
+			continue
+		}
+		v.Events++
+		var event RecordedEvent
+		json.Unmarshal(line, &event)
+		if event.CRC32 != "" && event.CRC32 != lineChecksum(event.Data) {
+			v.LineChecksumErrors++
+			v.lineError(file, lineNo, "does not match its checksum")
+		}
+	}
+	return scanner.Err()
+}
+
+func (v *RecordingVerification) lineError(file string, line int, msg string) {
+	if len(v.LineErrors) < maxLineErrors {
+		v.LineErrors = append(v.LineErrors, LineError{File: file, Line: line, Error: msg})
+	}
+}
+
+// handleVerifyRecording checks a recording, answering 422 Unprocessable Entity when it has problems.
+func handleVerifyRecording(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	v, err := verifyRecording(name, path)
+	if err != nil {
+		log.Printf("Failed to verify %s: %v", path, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !v.OK {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(v)
+}
+
+// verifyCommand checks recording files for the validate command, failing if any has problems.
+func verifyCommand(paths []string) error {
+	failed := 0
+	for _, path := range paths {
+		v, err := verifyRecording(filepath.Base(path), path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if v.OK {
+			log.Printf("%s: OK (%d events)", path, v.Events)
+			continue
+		}
+		failed++
+		log.Printf("%s: %s", path, strings.Join(v.Problems, ", "))
+		for _, lineErr := range v.LineErrors {
+			log.Printf("  %s:%d: %s", lineErr.File, lineErr.Line, lineErr.Error)
+		}
+		if v.InvalidLines+v.LineChecksumErrors > len(v.LineErrors) {
+			log.Printf("  ... and %d more", v.InvalidLines+v.LineChecksumErrors-len(v.LineErrors))
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d recordings have problems", failed, len(paths))
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordedLine returns a line of a recording with the line checksum of its data.
+func recordedLine(t *testing.T, eventType string) string {
+	t.Helper()
+	data := json.RawMessage(`{"type":"` + eventType + `"}`)
+	line, err := json.Marshal(RecordedEvent{Timestamp: 1, Data: data, CRC32: lineChecksum(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(line) + "\n"
+}
+
+// writeClosedRecording writes the parts of a recording and a .meta.json with their checksums,
+// like a Recorder with proxy.integrity.checksums does when it closes.
+func writeClosedRecording(t *testing.T, name string, parts ...[]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	for i, content := range parts {
+		part := path
+		if i > 0 {
+			part = partPath(path, i+1)
+		}
+		if err := os.WriteFile(part, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	endedAt := time.Now()
+	meta := RecordingMeta{Kind: "session", EndedAt: &endedAt, Checksums: fileChecksums(recordingParts(path))}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(metaPath(path), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(content))
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestLineChecksum(t *testing.T) {
+	compact := lineChecksum(json.RawMessage(`{"type":"response.done"}`))
+	if spaced := lineChecksum(json.RawMessage(`{ "type": "response.done" }`)); spaced != compact {
+		t.Errorf("checksum depends on whitespace: %s != %s", spaced, compact)
+	}
+	if other := lineChecksum(json.RawMessage(`{"type":"response.created"}`)); other == compact {
+		t.Errorf("different data has the same checksum %s", compact)
+	}
+	if len(compact) != 8 {
+		t.Errorf("checksum %q is not 8 hex digits", compact)
+	}
+}
+
+func TestVerifyIntactRecording(t *testing.T) {
+	lines := recordedLine(t, "session.created") + recordedLine(t, "response.done")
+	tests := []struct {
+		name  string
+		file  string
+		parts [][]byte
+	}{
+		{"plain", "rec.ndjson", [][]byte{[]byte(lines)}},
+		{"gzip", "rec.ndjson.gz", [][]byte{gzipped(t, lines)}},
+		{"rotated", "rec.ndjson", [][]byte{[]byte(lines), []byte(lines)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeClosedRecording(t, tt.file, tt.parts...)
+			v, err := verifyRecording("rec", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !v.OK || !v.Closed {
+				t.Fatalf("verification failed: %+v", v)
+			}
+			if want := 2 * len(tt.parts); v.Events != want {
+				t.Errorf("%d events, want %d", v.Events, want)
+			}
+			for _, file := range v.Files {
+				if file.ChecksumOK == nil || !*file.ChecksumOK {
+					t.Errorf("%s: checksum not verified", file.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyDamagedRecording(t *testing.T) {
+	lines := recordedLine(t, "session.created") + recordedLine(t, "response.done")
+	tests := []struct {
+		name    string
+		file    string
+		parts   [][]byte
+		damage  func(t *testing.T, path string)
+		problem string
+		check   func(v *RecordingVerification) bool
+	}{
+		{
+			name:  "changed after closing",
+			file:  "rec.ndjson",
+			parts: [][]byte{[]byte(lines)},
+			damage: func(t *testing.T, path string) {
+				appendFile(t, path, recordedLine(t, "response.created"))
+			},
+			problem: "rec.ndjson does not match its checksum",
+			check:   func(v *RecordingVerification) bool { return !*v.Files[0].ChecksumOK },
+		},
+		{
+			name:    "line data changed",
+			file:    "rec.ndjson",
+			parts:   [][]byte{[]byte(strings.Replace(lines, "response.done", "response.dome", 1))},
+			problem: "1 lines do not match their checksum",
+			check: func(v *RecordingVerification) bool {
+				return v.LineChecksumErrors == 1 && v.LineErrors[0] == LineError{File: "rec.ndjson", Line: 2, Error: "does not match its checksum"}
+			},
+		},
+		{
+			name:    "invalid line",
+			file:    "rec.ndjson",
+			parts:   [][]byte{[]byte(lines + "not json\n")},
+			problem: "1 lines are not valid events",
+			check:   func(v *RecordingVerification) bool { return v.InvalidLines == 1 && v.Events == 2 },
+		},
+		{
+			name:    "cut mid-line",
+			file:    "rec.ndjson",
+			parts:   [][]byte{[]byte(lines[:len(lines)-5])},
+			problem: "recording is truncated",
+			check:   func(v *RecordingVerification) bool { return v.Truncated },
+		},
+		{
+			name:  "cut gzip stream",
+			file:  "rec.ndjson.gz",
+			parts: [][]byte{gzipped(t, lines)},
+			damage: func(t *testing.T, path string) {
+				data, _ := os.ReadFile(path)
+				os.WriteFile(path, data[:len(data)-10], 0644)
+			},
+			problem: "recording is truncated",
+			check:   func(v *RecordingVerification) bool { return v.Truncated },
+		},
+		{
+			name:  "missing part",
+			file:  "rec.ndjson",
+			parts: [][]byte{[]byte(lines), []byte(lines)},
+			damage: func(t *testing.T, path string) {
+				os.Remove(partPath(path, 2))
+			},
+			problem: "2 files recorded, 1 found",
+			check:   func(v *RecordingVerification) bool { return len(v.Files) == 1 },
+		},
+		{
+			name:  "never closed",
+			file:  "rec.ndjson",
+			parts: [][]byte{[]byte(lines)},
+			damage: func(t *testing.T, path string) {
+				os.WriteFile(metaPath(path), []byte(`{"kind":"session"}`), 0644)
+			},
+			problem: "recording was never closed",
+			check:   func(v *RecordingVerification) bool { return !v.Closed },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeClosedRecording(t, tt.file, tt.parts...)
+			if tt.damage != nil {
+				tt.damage(t, path)
+			}
+			v, err := verifyRecording("rec", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.OK || !tt.check(v) {
+				t.Errorf("damage not detected: %+v", v)
+			}
+			if !strings.Contains(strings.Join(v.Problems, "; "), tt.problem) {
+				t.Errorf("problems %q, want %q", v.Problems, tt.problem)
+			}
+		})
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
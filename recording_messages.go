@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// --- Chat Completions & Evals Export ---
+
+// chatMessage is a message of the chat completions API.
+type chatMessage struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatTool is a realtime function tool in the nested form of the chat completions API.
+type chatTool struct {
+	Type     string `json:"type"` // "function"
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+// chatConversation is one line of a chat fine-tuning dataset.
+type chatConversation struct {
+	Messages []chatMessage `json:"messages"`
+	Tools    []chatTool    `json:"tools,omitempty"`
+}
+
+// evalSample is one line of an openai/evals dataset: the conversation up to an assistant
+// message, and that message as the ideal answer.
+type evalSample struct {
+	Input []chatMessage `json:"input"`
+	Ideal string        `json:"ideal"`
+}
+
+// chatMessages converts the transcript into chat completions messages. The session instructions
+// become the system message; function calls of one response are merged into one assistant message.
+func (t *transcript) chatMessages() []chatMessage {
+	var messages []chatMessage
+	if t.Instructions != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: t.Instructions})
+	}
+	for _, turn := range t.Turns {
+		switch turn.Type {
+		case "message":
+			if turn.Text == "" {
+				continue // Audio without a transcription
+			}
+			messages = append(messages, chatMessage{Role: turn.Role, Content: turn.Text})
+		case "function_call":
+			call := chatToolCall{ID: turn.CallID, Type: "function"}
+			call.Function.Name, call.Function.Arguments = turn.Name, turn.Arguments
+			if last := len(messages) - 1; last >= 0 && messages[last].Role == "assistant" && len(messages[last].ToolCalls) > 0 {
+				messages[last].ToolCalls = append(messages[last].ToolCalls, call)
+				continue
+			}
+			messages = append(messages, chatMessage{Role: "assistant", ToolCalls: []chatToolCall{call}})
+		case "function_call_output":
+			messages = append(messages, chatMessage{Role: "tool", Content: turn.Output, ToolCallID: turn.CallID})
+		}
+	}
+	return messages
+}
+
+// chatTools converts the function tools of the session, skipping other tool types.
+func (t *transcript) chatTools() []chatTool {
+	var tools []struct {
+		Type        string          `json:"type"`
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	}
+	json.Unmarshal(t.Tools, &tools)
+	var out []chatTool
+	for _, tool := range tools {
+		if tool.Type != "function" {
+			continue
+		}
+		var c chatTool
+		c.Type = "function"
+		c.Function.Name, c.Function.Description, c.Function.Parameters = tool.Name, tool.Description, tool.Parameters
+		out = append(out, c)
+	}
+	return out
+}
+
+// evalSamples returns a sample for every assistant text message, with the conversation before it as input.
+func (t *transcript) evalSamples() []evalSample {
+	messages := t.chatMessages()
+	var samples []evalSample
+	for i, message := range messages {
+		if message.Role == "assistant" && message.Content != "" && len(message.ToolCalls) == 0 && i > 0 {
+			samples = append(samples, evalSample{Input: messages[:i:i], Ideal: message.Content})
+		}
+	}
+	return samples
+}
+
+// writeChatExport writes the transcript as JSONL: one conversation for "chat", one sample per
+// assistant answer for "evals".
+func writeChatExport(w io.Writer, t *transcript, format string) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if format == "evals" {
+		for _, sample := range t.evalSamples() {
+			if err := enc.Encode(sample); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	messages := t.chatMessages()
+	if len(messages) == 0 {
+		return nil
+	}
+	return enc.Encode(chatConversation{Messages: messages, Tools: t.chatTools()})
+}
+
+// handleRecordingMessages exports a recording as a chat completions conversation, or as evals
+// samples with ?format=evals, as JSONL to append to a dataset.
+func handleRecordingMessages(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "chat" && format != "evals" {
+		http.Error(w, "format must be chat or evals", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	t, err := readTranscript(path)
+	if err != nil {
+		log.Printf("Failed to read transcript of %s: %v", path, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	writeChatExport(w, t, format)
+}
+
+// exportMessagesCommand appends the recordings to a JSONL dataset, for convert -to chat or evals.
+func exportMessagesCommand(out string, paths []string, format string) error {
+	if format != "chat" && format != "evals" {
+		return fmt.Errorf("-messages-format must be chat or evals")
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no recording files given")
+	}
+	file, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	for _, path := range paths {
+		t, err := readTranscript(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := writeChatExport(w, t, format); err != nil {
+			return err
+		}
+		log.Printf("Exported %s", path)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	log.Printf("Wrote %s dataset %s", format, out)
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// --- Recording Metadata ---
+
+// serverVersion is set at build time with -ldflags "-X main.serverVersion=v1.2.3".
+var serverVersion = ""
+
+// version returns the server version, falling back to the VCS revision of the build.
+func version() string {
+	if serverVersion != "" {
+		return serverVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+				return "dev-" + setting.Value[:12]
+			}
+		}
+	}
+	return "dev"
+}
+
+// RecordingMeta is written next to a recording as <name>.meta.json, so recordings can be
+// identified without opening them. It is rewritten with the final state when the recording ends.
+type RecordingMeta struct {
+	Kind      string          `json:"kind"` // "session", "inbound" or "outbound"
+	Mode      string          `json:"mode"`
+	Version   string          `json:"version"` // Server version
+	StartedAt time.Time       `json:"started_at"`
+	EndedAt   *time.Time      `json:"ended_at,omitempty"`
+	Model     string          `json:"model,omitempty"`
+	Target    string          `json:"target,omitempty"`   // Proxy target
+	Scenario  string          `json:"scenario,omitempty"` // Mock scenario
+	Replay    string          `json:"replay,omitempty"`   // Replayed recording
+	SessionID string          `json:"session_id,omitempty"`
+	Session   json.RawMessage `json:"session,omitempty"`   // Latest recorded session configuration
+	Parts     int             `json:"parts,omitempty"`     // Number of files once rotated
+	Checksums []FileChecksum  `json:"checksums,omitempty"` // Of every part, written when the recording closes
+}
+
+func newRecordingMeta(kind, mode string) *RecordingMeta {
+	return &RecordingMeta{Kind: kind, Mode: mode, Version: version(), StartedAt: time.Now()}
+}
+
+// metaPath returns the sidecar path of a recording: x.ndjson(.gz) -> x.meta.json
+func metaPath(path string) string {
+	base, _, _ := strings.Cut(path, ".ndjson")
+	return base + ".meta.json"
+}
+
+// readRecordingMeta loads the sidecar of a recording, nil if there is none.
+func readRecordingMeta(path string) *RecordingMeta {
+	if name, ok := strings.CutPrefix(path, storedPrefix); ok && eventStore != nil {
+		file, _ := eventStore.stat(name)
+		return file.Meta
+	}
+	data, err := os.ReadFile(metaPath(path))
+	if err != nil {
+		return nil
+	}
+	var meta RecordingMeta
+	if json.Unmarshal(data, &meta) != nil {
+		return nil
+	}
+	return &meta
+}
+
+// SetMeta attaches metadata to the recording and writes its sidecar.
+func (r *Recorder) SetMeta(meta *RecordingMeta) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.meta = meta
+	r.writeMeta()
+}
+
+// observeMeta keeps the session configuration of recorded session events. Callers hold the lock.
+func (r *Recorder) observeMeta(msg []byte) {
+	if r.meta == nil || !bytes.Contains(msg, []byte(`"session.`)) {
+		return
+	}
+	var event struct {
+		Type    string          `json:"type"`
+		Session json.RawMessage `json:"session"`
+	}
+	if json.Unmarshal(msg, &event) != nil || len(event.Session) == 0 {
+		return
+	}
+	switch event.Type {
+	case "session.created", "session.updated", "session.update":
+		r.meta.Session = event.Session
+		var session struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+		}
+		json.Unmarshal(event.Session, &session)
+		if session.ID != "" && r.meta.SessionID == "" {
+			r.meta.SessionID = session.ID
+		}
+		if session.Model != "" {
+			r.meta.Model = session.Model
+		}
+	}
+}
+
+// writeMeta writes the sidecar. Callers hold the lock.
+func (r *Recorder) writeMeta() {
+	if r.meta == nil {
+		return
+	}
+	if r.store != nil {
+		if err := r.store.saveMeta(r.name, r.meta); err != nil {
+			log.Printf("Error writing recording metadata: %v", err)
+		}
+		return
+	}
+	data, err := json.MarshalIndent(r.meta, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(metaPath(r.path), append(data, '\n'), 0644); err != nil {
+		log.Printf("Error writing recording metadata: %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- Recording Retention ---
+
+// RecordingRetention prunes the oldest recordings of recorded/ (and of the storage backend) once
+// they exceed any of the limits. Hand-made recordings in examples/ and the root are never touched.
+type RecordingRetention struct {
+	MaxAgeHours     int `yaml:"maxAgeHours" json:"maxAgeHours"`         // 0: no age limit
+	MaxTotalSizeMB  int `yaml:"maxTotalSizeMB" json:"maxTotalSizeMB"`   // 0: no size limit
+	MaxFiles        int `yaml:"maxFiles" json:"maxFiles"`               // 0: no count limit, counts recordings with their parts
+	IntervalMinutes int `yaml:"intervalMinutes" json:"intervalMinutes"` // Between janitor runs, default 10
+}
+
+func (c RecordingRetention) enabled() bool {
+	return c.MaxAgeHours > 0 || c.MaxTotalSizeMB > 0 || c.MaxFiles > 0
+}
+
+func (c RecordingRetention) validate() error {
+	if c.MaxAgeHours < 0 || c.MaxTotalSizeMB < 0 || c.MaxFiles < 0 || c.IntervalMinutes < 0 {
+		return fmt.Errorf("proxy.retention limits must not be negative")
+	}
+	return nil
+}
+
+func (c RecordingRetention) interval() time.Duration {
+	if c.IntervalMinutes > 0 {
+		return time.Duration(c.IntervalMinutes) * time.Minute
+	}
+	return 10 * time.Minute
+}
+
+// retainedRecording is what the janitor deletes as one: a recording with its parts and sidecar,
+// a session directory or a stored recording.
+type retainedRecording struct {
+	name    string
+	paths   []string // Files, or the session directory
+	size    int64
+	modTime time.Time // Of the newest file
+	live    bool
+	remove  func() error
+}
+
+// partSuffix matches the .002, .003, ... of rotated parts.
+var partSuffix = regexp.MustCompile(`\.\d{3}$`)
+
+// recordingGroup returns the name files of one recording share: x.ndjson(.gz), x.002.ndjson(.gz)
+// and x.meta.json all belong to x. Other files (e.g. captured WAVs) stand alone.
+func recordingGroup(filename string) string {
+	if base, _, found := strings.Cut(filename, ".ndjson"); found {
+		return partSuffix.ReplaceAllString(base, "")
+	}
+	if base, found := strings.CutSuffix(filename, ".meta.json"); found {
+		return base
+	}
+	return filename
+}
+
+// collectRecordings lists the recordings of a directory as the janitor sees them.
+func collectRecordings(dir string, live map[string]bool) []*retainedRecording {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	groups := make(map[string]*retainedRecording)
+	var recordings []*retainedRecording
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if storePath != "" && strings.HasPrefix(path, filepath.Clean(storePath)) {
+			continue // The database itself
+		}
+		if entry.IsDir() {
+			rec := &retainedRecording{name: entry.Name(), paths: []string{path}}
+			filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return nil
+				}
+				if info, err := d.Info(); err == nil {
+					rec.size += info.Size()
+					if info.ModTime().After(rec.modTime) {
+						rec.modTime = info.ModTime()
+					}
+				}
+				rec.live = rec.live || live[p]
+				return nil
+			})
+			rec.remove = func() error { return os.RemoveAll(path) }
+			recordings = append(recordings, rec)
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		group := recordingGroup(entry.Name())
+		rec, ok := groups[group]
+		if !ok {
+			rec = &retainedRecording{name: group}
+			rec.remove = func() error {
+				for _, p := range rec.paths {
+					if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+						return err
+					}
+				}
+				return nil
+			}
+			groups[group] = rec
+			recordings = append(recordings, rec)
+		}
+		rec.paths = append(rec.paths, path)
+		rec.size += info.Size()
+		if info.ModTime().After(rec.modTime) {
+			rec.modTime = info.ModTime()
+		}
+		rec.live = rec.live || live[path]
+	}
+	return recordings
+}
+
+// collectStoredRecordings lists the recordings of the storage backend.
+func collectStoredRecordings(live map[string]bool) []*retainedRecording {
+	if eventStore == nil {
+		return nil
+	}
+	files, err := eventStore.list()
+	if err != nil {
+		log.Printf("Retention: Failed to list stored recordings: %v", err)
+		return nil
+	}
+	var recordings []*retainedRecording
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name, ".ndjson")
+		recordings = append(recordings, &retainedRecording{
+			name:    file.Name,
+			size:    file.Size,
+			modTime: file.ModTime,
+			live:    live[file.path],
+			remove:  func() error { return eventStore.remove(name) },
+		})
+	}
+	return recordings
+}
+
+// prune deletes recordings older than the age limit, then the oldest ones until the count and
+// size limits are met. Live recordings are kept but count towards the limits.
+func (c RecordingRetention) prune(recordings []*retainedRecording, now time.Time) (removed int, freed int64) {
+	sort.Slice(recordings, func(i, j int) bool { return recordings[i].modTime.Before(recordings[j].modTime) })
+	var total int64
+	for _, rec := range recordings {
+		total += rec.size
+	}
+	count := len(recordings)
+	maxAge := time.Duration(c.MaxAgeHours) * time.Hour
+	maxSize := int64(c.MaxTotalSizeMB) << 20
+
+	for _, rec := range recordings {
+		expired := c.MaxAgeHours > 0 && now.Sub(rec.modTime) > maxAge
+		overCount := c.MaxFiles > 0 && count > c.MaxFiles
+		overSize := c.MaxTotalSizeMB > 0 && total > maxSize
+		if !expired && !overCount && !overSize {
+			break // The rest is newer
+		}
+		if rec.live {
+			continue
+		}
+		if err := rec.remove(); err != nil {
+			log.Printf("Retention: Failed to remove %s: %v", rec.name, err)
+			continue
+		}
+		log.Printf("Retention: Removed %s (%d bytes, last written %s)", rec.name, rec.size, rec.modTime.Format(time.RFC3339))
+		removed++
+		freed += rec.size
+		count--
+		total -= rec.size
+	}
+	return removed, freed
+}
+
+// enforceRetention runs the janitor once over recorded/, the storage backend and the bucket cache.
+func enforceRetention(c RecordingRetention) {
+	recordingDir := currentConfig().Proxy.RecordingPath
+	if recordingDir == "" {
+		recordingDir = "recordings"
+	}
+	live := liveRecordingPaths()
+	var removed int
+	var freed int64
+	for _, recordings := range [][]*retainedRecording{
+		collectRecordings(filepath.Join(recordingDir, "recorded"), live),
+		collectStoredRecordings(live),
+	} {
+		n, size := c.prune(recordings, time.Now())
+		removed, freed = removed+n, freed+size
+	}
+	if objects != nil {
+		// Cached copies are fetched again when needed
+		n, size := c.prune(collectRecordings(objects.cacheDir, live), time.Now())
+		removed, freed = removed+n, freed+size
+	}
+	if removed > 0 {
+		log.Printf("Retention: Removed %d recordings, freed %.1f MB", removed, float64(freed)/(1<<20))
+	}
+}
+
+// startRetentionJanitor prunes recordings now and then every interval, if any limit is set.
+func startRetentionJanitor(c RecordingRetention) {
+	if !c.enabled() {
+		return
+	}
+	log.Printf("Retention: Keeping recordings for %dh, up to %d MB and %d recordings (0: unlimited), checking every %s",
+		c.MaxAgeHours, c.MaxTotalSizeMB, c.MaxFiles, c.interval())
+	go func() {
+		enforceRetention(c)
+		for range time.Tick(c.interval()) {
+			enforceRetention(c)
+		}
+	}()
+}
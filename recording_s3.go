@@ -0,0 +1,393 @@
+package main
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --- Object Storage (S3 / GCS) ---
+
+// S3Config points the s3 storage backend at an S3-compatible bucket. GCS works through its
+// XML API at https://storage.googleapis.com with HMAC keys.
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint" json:"endpoint" redact:"url"` // Default https://s3.<region>.amazonaws.com, e.g. http://minio:9000
+	Region          string `yaml:"region" json:"region"`                  // Default us-east-1, "auto" for GCS
+	Bucket          string `yaml:"bucket" json:"bucket"`
+	Prefix          string `yaml:"prefix" json:"prefix"`                   // Prepended to every object key, e.g. "ci/"
+	AccessKeyEnv    string `yaml:"accessKeyEnv" json:"accessKeyEnv"`       // Default AWS_ACCESS_KEY_ID
+	SecretKeyEnv    string `yaml:"secretKeyEnv" json:"secretKeyEnv"`       // Default AWS_SECRET_ACCESS_KEY
+	SessionTokenEnv string `yaml:"sessionTokenEnv" json:"sessionTokenEnv"` // Default AWS_SESSION_TOKEN, optional
+	KeepLocal       bool   `yaml:"keepLocal" json:"keepLocal"`             // Keep the local file after uploading it
+}
+
+func (c S3Config) region() string {
+	return cmp.Or(c.Region, "us-east-1")
+}
+
+func (c S3Config) validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("proxy.storage.s3.bucket is required")
+	}
+	if c.Endpoint != "" {
+		if u, err := url.Parse(c.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("proxy.storage.s3.endpoint must be an http or https URL: %s", c.Endpoint)
+		}
+	}
+	accessKeyEnv, secretKeyEnv := cmp.Or(c.AccessKeyEnv, "AWS_ACCESS_KEY_ID"), cmp.Or(c.SecretKeyEnv, "AWS_SECRET_ACCESS_KEY")
+	if os.Getenv(accessKeyEnv) == "" || os.Getenv(secretKeyEnv) == "" {
+		return fmt.Errorf("proxy.storage.s3 credentials not set (%s / %s)", accessKeyEnv, secretKeyEnv)
+	}
+	return nil
+}
+
+// objectStore uploads finished recordings to a bucket and fetches them back into a local cache.
+// Recordings are written as files first, so compression and rotation work as usual.
+type objectStore struct {
+	cfg      S3Config
+	cacheDir string
+	client   *http.Client
+}
+
+// objects is the configured object store, nil unless proxy.storage.backend is s3.
+var objects *objectStore
+
+func newObjectStore(cfg S3Config, recordingDir string) *objectStore {
+	return &objectStore{
+		cfg:      cfg,
+		cacheDir: filepath.Join(recordingDir, "s3cache"),
+		client:   &http.Client{Timeout: 5 * time.Minute, Transport: sessionHTTPClient.Transport},
+	}
+}
+
+// --- Requests & Signature V4 ---
+
+// objectURL addresses a key: virtual-hosted on AWS, path-style on custom endpoints.
+func (s *objectStore) objectURL(key string) (base, path string) {
+	if s.cfg.Endpoint == "" {
+		return "https://" + s.cfg.Bucket + ".s3." + s.cfg.region() + ".amazonaws.com", "/" + key
+	}
+	return strings.TrimSuffix(s.cfg.Endpoint, "/"), "/" + s.cfg.Bucket + "/" + key
+}
+
+func (s *objectStore) do(method, key string, query map[string]string, body io.Reader, size int64) (*http.Response, error) {
+	base, path := s.objectURL(key)
+	rawURL := base + awsEscape(path, false)
+	if len(query) > 0 {
+		rawURL += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign adds an AWS Signature Version 4 Authorization header. Unless X-Amz-Content-Sha256 is set the
+// payload is left unsigned, which S3, GCS and MinIO accept, so uploads can be streamed from disk.
+func (s *objectStore) sign(req *http.Request, now time.Time) {
+	accessKey := os.Getenv(cmp.Or(s.cfg.AccessKeyEnv, "AWS_ACCESS_KEY_ID"))
+	secretKey := os.Getenv(cmp.Or(s.cfg.SecretKeyEnv, "AWS_SECRET_ACCESS_KEY"))
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = "UNSIGNED-PAYLOAD"
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	if token := os.Getenv(cmp.Or(s.cfg.SessionTokenEnv, "AWS_SESSION_TOKEN")); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	names := sortedKeys(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := map[string]string{}
+	for key, values := range req.URL.Query() {
+		query[key] = values[0]
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.region() + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, s.cfg.region(), "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscape percent-encodes everything but unreserved characters, and '/' unless encodeSlash.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(query map[string]string) string {
+	parts := make([]string, 0, len(query))
+	for _, key := range sortedKeys(query) {
+		parts = append(parts, awsEscape(key, true)+"="+awsEscape(query[key], true))
+	}
+	return strings.Join(parts, "&")
+}
+
+// --- Operations ---
+
+func (s *objectStore) key(name string) string {
+	return s.cfg.Prefix + name
+}
+
+func (s *objectStore) put(name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPut, s.key(name), nil, f, info.Size())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("upload of %s failed with %s: %s", name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// fetch downloads an object into the cache, reporting false if it does not exist. Names are
+// plain file names, anything with a directory would be written outside the cache.
+func (s *objectStore) fetch(name string) (string, bool, error) {
+	if filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return "", false, fmt.Errorf("invalid recording name %q", name)
+	}
+	path := filepath.Join(s.cacheDir, name)
+	if _, err := os.Stat(path); err == nil {
+		return path, true, nil
+	}
+	resp, err := s.do(http.MethodGet, s.key(name), nil, nil, 0)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("download of %s failed with %s", name, resp.Status)
+	}
+	if err := os.MkdirAll(s.cacheDir, 0755); err != nil {
+		return "", false, err
+	}
+	tmp, err := os.CreateTemp(s.cacheDir, ".fetch-*")
+	if err != nil {
+		return "", false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		tmp.Close()
+		return "", false, err
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", false, err
+	}
+	return path, true, nil
+}
+
+func (s *objectStore) delete(name string) error {
+	resp, err := s.do(http.MethodDelete, s.key(name), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete of %s failed with %s", name, resp.Status)
+	}
+	os.Remove(filepath.Join(s.cacheDir, name))
+	return nil
+}
+
+// list returns the recordings in the bucket, without their sidecars.
+func (s *objectStore) list() ([]RecordingFile, error) {
+	var files []RecordingFile
+	query := map[string]string{"list-type": "2", "prefix": s.cfg.Prefix}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("listing the bucket failed with %s", resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket listing: %w", err)
+		}
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.cfg.Prefix)
+			if strings.Contains(name, "/") || !strings.Contains(name, ".ndjson") {
+				continue
+			}
+			files = append(files, RecordingFile{
+				Name:    name,
+				Size:    object.Size,
+				ModTime: object.LastModified,
+				Store:   "s3",
+				path:    filepath.Join(s.cacheDir, name),
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query["continuation-token"] = result.NextContinuationToken
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// --- Recording Hooks ---
+
+// uploadRecording copies a closed recording, its rotated parts and its sidecar to the bucket,
+// removing the local files afterwards unless proxy.storage.s3.keepLocal is set.
+func uploadRecording(path string, parts int) {
+	if objects == nil {
+		return
+	}
+	paths := []string{path}
+	for part := 2; part <= parts; part++ {
+		paths = append(paths, partPath(path, part))
+	}
+	paths = append(paths, metaPath(path))
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if err := objects.put(filepath.Base(p), p); err != nil {
+			log.Printf("Error uploading recording: %v", err)
+			continue // The local file stays
+		}
+		log.Printf("Uploaded %s to bucket %s", filepath.Base(p), objects.cfg.Bucket)
+		if !objects.cfg.KeepLocal {
+			os.Remove(p)
+		}
+	}
+}
+
+// fetchRemoteRecording finds a recording (or its .gz twin) in the bucket and returns its cached copy.
+func fetchRemoteRecording(name string) (string, bool) {
+	if objects == nil {
+		return "", false
+	}
+	for _, candidate := range []string{name, name + ".gz"} {
+		path, ok, err := objects.fetch(candidate)
+		if err != nil {
+			log.Printf("Error fetching recording: %v", err)
+			return "", false
+		}
+		if ok {
+			objects.fetch(filepath.Base(metaPath(candidate)))
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// deleteRemoteRecording removes a recording and its sidecar from the bucket.
+func deleteRemoteRecording(name string) error {
+	if objects == nil {
+		return nil
+	}
+	if err := objects.delete(name); err != nil {
+		return err
+	}
+	return objects.delete(filepath.Base(metaPath(name)))
+}
+
+// remoteRecordings lists the recordings in the bucket that are not among the local ones.
+func remoteRecordings(local []RecordingFile) []RecordingFile {
+	remote, err := objects.list()
+	if err != nil {
+		log.Printf("Failed to list recordings in bucket %s: %v", objects.cfg.Bucket, err)
+		return nil
+	}
+	seen := make(map[string]bool, len(local))
+	for _, file := range local {
+		seen[file.Name] = true
+	}
+	var missing []RecordingFile
+	for _, file := range remote {
+		if !seen[file.Name] {
+			missing = append(missing, file)
+		}
+	}
+	return missing
+}
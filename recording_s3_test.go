@@ -0,0 +1,12 @@
+package main
+
+import "testing"
+
+func TestObjectStoreFetchRejectsPaths(t *testing.T) {
+	s := &objectStore{cacheDir: t.TempDir()}
+	for _, name := range []string{"../outside.ndjson", "sub/rec.ndjson", "..", ".hidden"} {
+		if _, ok, err := s.fetch(name); err == nil || ok {
+			t.Errorf("fetch(%q) = %v, %v, want an error", name, ok, err)
+		}
+	}
+}
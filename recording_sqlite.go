@@ -0,0 +1,232 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	openSQLiteStore = newSQLiteStore
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS events (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	recording  TEXT    NOT NULL,
+	session_id TEXT    NOT NULL DEFAULT '',
+	timestamp  INTEGER NOT NULL,
+	direction  TEXT    NOT NULL DEFAULT '',
+	frame      TEXT    NOT NULL DEFAULT '',
+	type       TEXT    NOT NULL DEFAULT '',
+	line       TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_recording ON events (recording, id);
+CREATE INDEX IF NOT EXISTS events_session ON events (session_id, timestamp);
+CREATE INDEX IF NOT EXISTS events_type ON events (type, timestamp);
+CREATE TABLE IF NOT EXISTS recordings (
+	name TEXT PRIMARY KEY,
+	meta TEXT NOT NULL
+);`
+
+// sqliteStore keeps one row per recorded event, with the NDJSON line it would have in a file.
+type sqliteStore struct {
+	db *sql.DB
+	ro *sql.DB // Read-only, for replays
+}
+
+// newSQLiteStore opens (or creates) the database. modernc.org/sqlite needs no cgo.
+func newSQLiteStore(path string) (recordingStore, error) {
+	dsn := func(pragmas ...string) string {
+		query := url.Values{"_pragma": append([]string{"busy_timeout(5000)"}, pragmas...)}
+		return "file:" + path + "?" + query.Encode()
+	}
+	db, err := sql.Open("sqlite", dsn("journal_mode(WAL)", "synchronous(NORMAL)"))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // SQLite has a single writer
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	ro, err := sql.Open("sqlite", dsn("query_only(1)"))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db, ro: ro}, nil
+}
+
+func (s *sqliteStore) append(recording string, line []byte) error {
+	var event RecordedEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return err
+	}
+	var base BaseEvent
+	if event.Frame == "" {
+		json.Unmarshal(event.Data, &base)
+	}
+	_, err := s.db.Exec(`INSERT INTO events (recording, timestamp, direction, frame, type, line) VALUES (?, ?, ?, ?, ?, ?)`,
+		recording, event.Timestamp, event.Direction, event.Frame, base.Type, string(line))
+	return err
+}
+
+// saveMeta stores the metadata and tags the events with the session ID once it is known.
+func (s *sqliteStore) saveMeta(recording string, meta *RecordingMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`INSERT INTO recordings (name, meta) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET meta = excluded.meta`,
+		recording, string(data)); err != nil {
+		return err
+	}
+	if meta.SessionID != "" {
+		if _, err := tx.Exec(`UPDATE events SET session_id = ? WHERE recording = ? AND session_id = ''`,
+			meta.SessionID, recording); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) stat(recording string) (RecordingFile, bool) {
+	var size, last, count int64
+	var meta sql.NullString
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(LENGTH(line) + 1), 0), COALESCE(MAX(timestamp), 0), COUNT(*),
+		(SELECT meta FROM recordings WHERE name = ?) FROM events WHERE recording = ?`, recording, recording).
+		Scan(&size, &last, &count, &meta)
+	if err != nil || count == 0 {
+		return RecordingFile{}, false
+	}
+	file := storedRecordingFile(recording, size, last)
+	if meta.Valid {
+		file.Meta = &RecordingMeta{}
+		if json.Unmarshal([]byte(meta.String), file.Meta) != nil {
+			file.Meta = nil
+		}
+	}
+	return file, true
+}
+
+func (s *sqliteStore) list() ([]RecordingFile, error) {
+	rows, err := s.db.Query(`SELECT recording, SUM(LENGTH(line) + 1), MAX(timestamp) FROM events GROUP BY recording`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var files []RecordingFile
+	for rows.Next() {
+		var name string
+		var size, last int64
+		if err := rows.Scan(&name, &size, &last); err != nil {
+			return nil, err
+		}
+		files = append(files, storedRecordingFile(name, size, last))
+	}
+	return files, rows.Err()
+}
+
+// storedRecordingFile lists a stored recording like a file; its size is that of the NDJSON it reads as.
+func storedRecordingFile(name string, size, lastTimestamp int64) RecordingFile {
+	return RecordingFile{
+		Name:    name + ".ndjson",
+		Size:    size,
+		ModTime: time.UnixMilli(lastTimestamp),
+		Store:   "sqlite",
+		path:    storedPrefix + name,
+	}
+}
+
+// open streams the lines of a recording in the order they were recorded, on a read-only connection.
+func (s *sqliteStore) open(recording string, filter eventFilter) (io.ReadCloser, error) {
+	where, args := filter.sql(recording)
+	rows, err := s.ro.Query(`SELECT line FROM events WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer rows.Close()
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.WriteString(pw, line+"\n"); err != nil {
+				return // Reader closed
+			}
+		}
+		pw.CloseWithError(rows.Err())
+	}()
+	return pr, nil
+}
+
+// sql returns the condition selecting the events of a recording the filter keeps, every value
+// bound as a parameter.
+func (f eventFilter) sql(recording string) (string, []any) {
+	conditions, args := []string{`recording = ?`}, []any{recording}
+	if len(f.Types) > 0 {
+		matches := make([]string, len(f.Types))
+		for i, t := range f.Types {
+			matches[i] = `type LIKE ? ESCAPE '\'`
+			args = append(args, likePattern(t))
+		}
+		conditions = append(conditions, `(`+strings.Join(matches, ` OR `)+`)`)
+	}
+	for _, t := range f.SkipTypes {
+		conditions = append(conditions, `type NOT LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(t))
+	}
+	if f.Direction != "" {
+		conditions = append(conditions, `direction = ?`)
+		args = append(args, f.Direction)
+	}
+	const start = `(SELECT MIN(timestamp) FROM events WHERE recording = ?)`
+	if f.FromMs > 0 {
+		conditions = append(conditions, `timestamp >= `+start+` + ?`)
+		args = append(args, recording, f.FromMs)
+	}
+	if f.ToMs > 0 {
+		conditions = append(conditions, `timestamp <= `+start+` + ?`)
+		args = append(args, recording, f.ToMs)
+	}
+	return strings.Join(conditions, ` AND `), args
+}
+
+// likePattern turns an event type with * wildcards into a LIKE pattern.
+func likePattern(eventType string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(eventType)
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+// remove deletes the events and metadata of a recording in one transaction.
+func (s *sqliteStore) remove(recording string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM events WHERE recording = ?`, recording); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recordings WHERE name = ?`, recording); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
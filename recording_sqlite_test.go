@@ -0,0 +1,77 @@
+//go:build sqlite
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteReplayFilter(t *testing.T) {
+	store, err := newSQLiteStore(filepath.Join(t.TempDir(), "recordings.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []struct {
+		recording, direction, eventType string
+		timestamp                       int64
+	}{
+		{"a", directionServer, "session.created", 1000},
+		{"a", directionClient, "input_audio_buffer.append", 1500},
+		{"a", directionServer, "response.audio.delta", 2000},
+		{"a", directionServer, "response_done", 2500},
+		{"a", directionServer, "response.done", 3000},
+		{"b", directionServer, "session.created", 1000},
+	}
+	for _, e := range events {
+		line := fmt.Sprintf(`{"timestamp":%d,"direction":%q,"data":{"type":%q}}`, e.timestamp, e.direction, e.eventType)
+		if err := store.append(e.recording, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"session.created", "input_audio_buffer.append", "response.audio.delta", "response_done", "response.done"}},
+		{"replay_types=response.*", []string{"response.audio.delta", "response.done"}},
+		{"replay_types=session.created,response.done", []string{"session.created", "response.done"}},
+		{"replay_skip_types=response.audio*,input_audio*", []string{"session.created", "response_done", "response.done"}},
+		{"replay_direction=client", []string{"input_audio_buffer.append"}},
+		{"replay_from_ms=1000&replay_to_ms=1500", []string{"response.audio.delta", "response_done"}},
+		// Values are never SQL: this matches no event type rather than every recording
+		{"replay_types=" + url.QueryEscape("x') OR (1=1"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			filter, err := replayEventFilter(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rc, err := store.open("a", filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				if _, rest, ok := strings.Cut(line, `"type":"`); ok {
+					got = append(got, strings.TrimSuffix(rest, `"}}`))
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("replayed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
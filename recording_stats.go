@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// --- Recording Statistics ---
+
+// RecordingStats summarizes a recording for dashboards.
+type RecordingStats struct {
+	Name               string         `json:"name"`
+	Size               int64          `json:"size"`       // Of the file, compressed for .gz recordings
+	DataBytes          int64          `json:"data_bytes"` // Uncompressed NDJSON
+	Events             int            `json:"events"`
+	EventCounts        map[string]int `json:"event_counts"` // By type, binary and close frames as "binary" and "close"
+	StartedAt          *time.Time     `json:"started_at,omitempty"`
+	EndedAt            *time.Time     `json:"ended_at,omitempty"`
+	DurationSeconds    float64        `json:"duration_seconds"`
+	Responses          int            `json:"responses"`
+	OutputAudioSeconds float64        `json:"output_audio_seconds"`
+	InputAudioSeconds  float64        `json:"input_audio_seconds"`
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// recordingStats computes the statistics of a recording in one pass over the file.
+func recordingStats(path string) (*RecordingStats, error) {
+	size, _, err := statRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := openRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	counter := &countingReader{r: file}
+	scanner := bufio.NewScanner(counter)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+
+	stats := &RecordingStats{Size: size, EventCounts: make(map[string]int)}
+	var first, last int64
+	var inputFormat, outputFormat string
+	var inputSeconds, outputSeconds float64 // Summed per event, as the format may change mid-session
+	for scanner.Scan() {
+		var event RecordedEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			continue
+		}
+		stats.Events++
+		if event.Timestamp > 0 {
+			if first == 0 {
+				first = event.Timestamp
+			}
+			last = event.Timestamp
+		}
+		if event.Frame != "" {
+			stats.EventCounts[event.Frame]++
+			continue
+		}
+
+		var data struct {
+			Type       string          `json:"type"`
+			Delta      string          `json:"delta"`
+			DeltaBytes int             `json:"delta_bytes"`
+			Audio      string          `json:"audio"`
+			AudioBytes int             `json:"audio_bytes"`
+			Session    json.RawMessage `json:"session"`
+		}
+		if json.Unmarshal(event.Data, &data) != nil {
+			continue
+		}
+		stats.EventCounts[data.Type]++
+
+		switch data.Type {
+		case "session.created", "session.updated", "session.update":
+			input, output := sessionAudioFormats(data.Session)
+			if input != "" {
+				inputFormat = input
+			}
+			if output != "" {
+				outputFormat = output
+			}
+		case "response.done":
+			stats.Responses++
+		case "response.audio.delta", "response.output_audio.delta":
+			// Stripped recordings keep only the decoded size
+			outputSeconds += audioSeconds(base64DecodedLen(data.Delta)+data.DeltaBytes, outputFormat)
+		case "input_audio_buffer.append":
+			inputSeconds += audioSeconds(base64DecodedLen(data.Audio)+data.AudioBytes, inputFormat)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	stats.DataBytes = counter.n
+	stats.OutputAudioSeconds = roundSeconds(outputSeconds)
+	stats.InputAudioSeconds = roundSeconds(inputSeconds)
+	if first > 0 {
+		startedAt, endedAt := time.UnixMilli(first), time.UnixMilli(last)
+		stats.StartedAt, stats.EndedAt = &startedAt, &endedAt
+		stats.DurationSeconds = roundSeconds(endedAt.Sub(startedAt).Seconds())
+	}
+	return stats, nil
+}
+
+// audioSeconds returns the playback time of encoded audio, 0 for Opus whose packets vary in size.
+func audioSeconds(size int, format string) float64 {
+	switch {
+	case isOpus(format):
+		return 0
+	case isG711(format):
+		return float64(size) / 8000
+	}
+	return float64(size) / (sourceSampleRate * 2)
+}
+
+func roundSeconds(s float64) float64 {
+	return float64(int64(s*1000+0.5)) / 1000
+}
+
+// handleRecordingStats serves the statistics of a recording without sending the recording itself.
+func handleRecordingStats(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := recordingStats(path)
+	if err != nil {
+		log.Printf("Failed to compute stats of %s: %v", path, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	stats.Name = name
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Recording Storage Backends ---
+
+// RecordingStorage selects where recordings are written. NDJSON files are the default.
+type RecordingStorage struct {
+	Backend string   `yaml:"backend" json:"backend"`         // "files" (default), "sqlite" or "s3"
+	Path    string   `yaml:"path" json:"path" redact:"path"` // SQLite database, default <recordingPath>/recordings.db
+	S3      S3Config `yaml:"s3" json:"s3"`
+}
+
+func (c RecordingStorage) validate(cfg *Config) error {
+	switch c.Backend {
+	case "", "files":
+		return nil
+	case "sqlite":
+		if openSQLiteStore == nil {
+			return fmt.Errorf("proxy.storage.backend sqlite needs SQLite support, rebuild with -tags sqlite")
+		}
+	case "s3":
+		if err := c.S3.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("proxy.storage.backend has unknown value: %s", c.Backend)
+	}
+	if cfg.Proxy.SessionDirs {
+		return fmt.Errorf("proxy.sessionDirs needs the files storage backend")
+	}
+	if cfg.Mode == "vcr" {
+		return fmt.Errorf("vcr mode needs the files storage backend")
+	}
+	return nil
+}
+
+// recordingStore keeps recordings outside of NDJSON files. Recordings are addressed by
+// name, e.g. "session_2025-11-26_14-30-00", and read back as NDJSON.
+type recordingStore interface {
+	append(recording string, line []byte) error
+	saveMeta(recording string, meta *RecordingMeta) error
+	stat(recording string) (RecordingFile, bool) // Including the metadata
+	list() ([]RecordingFile, error)              // Without the metadata
+	// open streams the recording as NDJSON, only the events the filter keeps
+	open(recording string, filter eventFilter) (io.ReadCloser, error)
+	remove(recording string) error
+}
+
+// openSQLiteStore is set in builds with -tags sqlite.
+var openSQLiteStore func(path string) (recordingStore, error)
+
+// eventStore is the configured store, nil when recordings are files. storePath is its database,
+// which the listing leaves out along with its -wal and -shm files.
+var (
+	eventStore recordingStore
+	storePath  string
+)
+
+// storedPrefix marks paths that refer to a recording in the eventStore.
+const storedPrefix = "store:"
+
+// configureStorage opens the storage backend selected in proxy.storage.
+func configureStorage(c RecordingStorage) error {
+	recordingDir := appConfig.Proxy.RecordingPath
+	if recordingDir == "" {
+		recordingDir = "recordings"
+	}
+	if c.Backend == "s3" {
+		objects = newObjectStore(c.S3, recordingDir)
+		log.Printf("Uploading recordings to bucket %s", c.S3.Bucket)
+		return nil
+	}
+	if c.Backend != "sqlite" {
+		return nil
+	}
+	path := c.Path
+	if path == "" {
+		path = filepath.Join(recordingDir, "recordings.db")
+	}
+	store, err := openSQLiteStore(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording database %s: %w", path, err)
+	}
+	eventStore, storePath = store, path
+	log.Printf("Storing recordings in SQLite database %s", path)
+	return nil
+}
+
+// storedRecording returns the path of a recording in the eventStore, named with or without .ndjson.
+func storedRecording(name string) (string, bool) {
+	if eventStore == nil {
+		return "", false
+	}
+	name = strings.TrimSuffix(name, ".ndjson")
+	if _, ok := eventStore.stat(name); !ok {
+		return "", false
+	}
+	return storedPrefix + name, true
+}
+
+// openStoredRecording opens a recording of the eventStore, keeping only the events the filter keeps.
+func openStoredRecording(path string, filter eventFilter) (io.ReadCloser, error) {
+	name, ok := strings.CutPrefix(path, storedPrefix)
+	if !ok || eventStore == nil {
+		return nil, fmt.Errorf("%s is not a stored recording", path)
+	}
+	return eventStore.open(name, filter)
+}
+
+// eventFilter selects the events of a stored recording to replay. The zero value keeps all of them.
+type eventFilter struct {
+	Types     []string // Event types to keep, * matches any characters (e.g. response.*)
+	SkipTypes []string // Event types to leave out, with * as in Types
+	Direction string   // Only events of this direction, directionClient or directionServer
+	FromMs    int64    // Only events at least this long after the first one
+	ToMs      int64    // Only events at most this long after the first one, 0 for no limit
+}
+
+func (f eventFilter) empty() bool {
+	return len(f.Types) == 0 && len(f.SkipTypes) == 0 && f.Direction == "" && f.FromMs == 0 && f.ToMs == 0
+}
+
+// replayEventFilter reads the filter of a replay from ?replay_types=, ?replay_skip_types=,
+// ?replay_direction=, ?replay_from_ms= and ?replay_to_ms=. Types are comma-separated.
+func replayEventFilter(query url.Values) (eventFilter, error) {
+	var filter eventFilter
+	split := func(value string) []string {
+		var types []string
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+		return types
+	}
+	filter.Types = split(query.Get("replay_types"))
+	filter.SkipTypes = split(query.Get("replay_skip_types"))
+	switch filter.Direction = query.Get("replay_direction"); filter.Direction {
+	case "", directionClient, directionServer:
+	default:
+		return filter, fmt.Errorf("replay_direction must be %s or %s", directionClient, directionServer)
+	}
+	for _, bound := range []struct {
+		name  string
+		value *int64
+	}{{"replay_from_ms", &filter.FromMs}, {"replay_to_ms", &filter.ToMs}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms < 0 {
+			return filter, fmt.Errorf("%s must be a number of milliseconds", bound.name)
+		}
+		*bound.value = ms
+	}
+	return filter, nil
+}
+
+// statRecording returns the size and modification time of a recording file or stored recording.
+func statRecording(path string) (int64, time.Time, error) {
+	if name, ok := strings.CutPrefix(path, storedPrefix); ok && eventStore != nil {
+		if file, ok := eventStore.stat(name); ok {
+			return file.Size, file.ModTime, nil
+		}
+		return 0, time.Time{}, os.ErrNotExist
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return info.Size(), info.ModTime(), nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestReplayEventFilterRejectsInvalidValues(t *testing.T) {
+	for _, query := range []string{"replay_direction=both", "replay_from_ms=soon", "replay_to_ms=-1"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := replayEventFilter(values); err == nil {
+			t.Errorf("%s: no error", query)
+		}
+	}
+}
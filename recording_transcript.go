@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- Recording Transcripts ---
+
+// transcriptTurn is one conversation item of a recording, or an error the server sent.
+type transcriptTurn struct {
+	ItemID    string
+	Type      string // "message", "function_call", "function_call_output" or "error"
+	Role      string // "user", "assistant" or "system" for messages
+	Text      string // Message text or audio transcript, error message
+	Name      string // Function name
+	CallID    string
+	Arguments string
+	Output    string
+	Start     int64 // Unix milliseconds of the first and last event of the item
+	End       int64
+	SpeechEnd int64             // Unix milliseconds of input_audio_buffer.speech_stopped
+	Deltas    []transcriptDelta // Text as it arrived, for captions
+
+	final bool // Text was set from a done event, later deltas are ignored
+}
+
+// transcriptDelta is a piece of text with the time it arrived.
+type transcriptDelta struct {
+	At   int64
+	Text string
+}
+
+// transcript is the conversation of a recording, in the order the items appeared.
+type transcript struct {
+	Turns   []*transcriptTurn
+	Started int64 // Unix milliseconds of the first event
+	Ended   int64
+
+	Instructions string          // Of the latest session configuration that set them
+	Tools        json.RawMessage // Likewise
+
+	byItem  map[string]*transcriptTurn
+	created []*transcriptTurn // Items the client created without an ID, until the server echoes them
+}
+
+// transcriptItem is the part of a conversation item the transcript needs.
+type transcriptItem struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Role      string `json:"role"`
+	Name      string `json:"name"`
+	CallID    string `json:"call_id"`
+	Arguments string `json:"arguments"`
+	Output    string `json:"output"`
+	Content   []struct {
+		Type       string  `json:"type"`
+		Text       string  `json:"text"`
+		Transcript *string `json:"transcript"`
+	} `json:"content"`
+}
+
+// text returns the text of a message, audio content by its transcript.
+func (item transcriptItem) text() string {
+	var parts []string
+	for _, content := range item.Content {
+		text := content.Text
+		if content.Transcript != nil {
+			text = *content.Transcript
+		}
+		if text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// readTranscript rebuilds the conversation of a recording from its events. Items the client creates
+// are taken from the server's echo when there is one, so split and combined recordings read alike.
+func readTranscript(path string) (*transcript, error) {
+	file, err := openRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+
+	t := &transcript{byItem: make(map[string]*transcriptTurn)}
+	for scanner.Scan() {
+		var event RecordedEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Frame != "" {
+			continue
+		}
+		if event.Timestamp > 0 {
+			if t.Started == 0 {
+				t.Started = event.Timestamp
+			}
+			t.Ended = event.Timestamp
+		}
+		t.handle(event.Timestamp, event.Data)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return t, nil
+}
+
+// turn returns the turn of an item, adding it at the end on its first event.
+func (t *transcript) turn(itemID, itemType, role string, ts int64) *transcriptTurn {
+	turn, ok := t.byItem[itemID]
+	if !ok {
+		turn = &transcriptTurn{ItemID: itemID, Type: itemType, Role: role, Start: ts}
+		t.byItem[itemID] = turn
+		t.Turns = append(t.Turns, turn)
+	}
+	if turn.Role == "" {
+		turn.Role = role
+	}
+	if ts > turn.End {
+		turn.End = ts
+	}
+	return turn
+}
+
+func (t *transcript) handle(ts int64, data json.RawMessage) {
+	var event struct {
+		Type       string         `json:"type"`
+		ItemID     string         `json:"item_id"`
+		Delta      string         `json:"delta"`
+		Text       string         `json:"text"`
+		Transcript string         `json:"transcript"`
+		Name       string         `json:"name"`
+		CallID     string         `json:"call_id"`
+		Arguments  string         `json:"arguments"`
+		Item       transcriptItem `json:"item"`
+		Session    struct {
+			Instructions *string         `json:"instructions"`
+			Tools        json.RawMessage `json:"tools"`
+		} `json:"session"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &event) != nil {
+		return
+	}
+
+	switch event.Type {
+	case "session.created", "session.updated", "session.update":
+		if event.Session.Instructions != nil {
+			t.Instructions = *event.Session.Instructions
+		}
+		if len(event.Session.Tools) > 0 && string(event.Session.Tools) != "null" {
+			t.Tools = event.Session.Tools
+		}
+	case "input_audio_buffer.speech_started", "input_audio_buffer.committed":
+		if event.ItemID != "" {
+			t.turn(event.ItemID, "message", "user", ts)
+		}
+	case "input_audio_buffer.speech_stopped":
+		if event.ItemID != "" {
+			t.turn(event.ItemID, "message", "user", ts).SpeechEnd = ts
+		}
+	case "conversation.item.created", "conversation.item.added", "conversation.item.done",
+		"response.output_item.added", "response.output_item.done":
+		if event.Item.ID != "" {
+			t.claim(event.Item)
+			t.turn(event.Item.ID, event.Item.Type, event.Item.Role, ts).update(event.Item)
+		}
+	case "conversation.item.create":
+		if event.Item.ID != "" {
+			t.turn(event.Item.ID, event.Item.Type, event.Item.Role, ts).update(event.Item)
+			return
+		}
+		turn := &transcriptTurn{Type: cmp.Or(event.Item.Type, "message"), Role: event.Item.Role, Start: ts, End: ts}
+		turn.update(event.Item)
+		t.Turns = append(t.Turns, turn)
+		t.created = append(t.created, turn)
+	case "conversation.item.input_audio_transcription.delta":
+		t.turn(event.ItemID, "message", "user", ts).delta(ts, event.Delta)
+	case "conversation.item.input_audio_transcription.completed":
+		turn := t.turn(event.ItemID, "message", "user", ts)
+		turn.Text, turn.final = event.Transcript, true
+	case "response.audio_transcript.delta", "response.output_audio_transcript.delta",
+		"response.text.delta", "response.output_text.delta":
+		t.turn(event.ItemID, "message", "assistant", ts).delta(ts, event.Delta)
+	case "response.audio_transcript.done", "response.output_audio_transcript.done":
+		turn := t.turn(event.ItemID, "message", "assistant", ts)
+		turn.Text, turn.final = event.Transcript, true
+	case "response.text.done", "response.output_text.done":
+		turn := t.turn(event.ItemID, "message", "assistant", ts)
+		turn.Text, turn.final = event.Text, true
+	case "response.function_call_arguments.delta":
+		turn := t.turn(event.ItemID, "function_call", "", ts)
+		turn.Arguments += event.Delta
+	case "response.function_call_arguments.done":
+		turn := t.turn(event.ItemID, "function_call", "", ts)
+		turn.Name = cmp.Or(turn.Name, event.Name)
+		turn.CallID = cmp.Or(turn.CallID, event.CallID)
+		turn.Arguments = event.Arguments
+	case "error":
+		t.Turns = append(t.Turns, &transcriptTurn{Type: "error", Text: event.Error.Message, Start: ts, End: ts})
+	}
+}
+
+// delta appends streamed text, unless the complete text is already known.
+func (turn *transcriptTurn) delta(ts int64, text string) {
+	if turn.final {
+		return
+	}
+	turn.Text += text
+	turn.Deltas = append(turn.Deltas, transcriptDelta{At: ts, Text: text})
+}
+
+// update copies what a conversation item carries into its turn.
+func (turn *transcriptTurn) update(item transcriptItem) {
+	if text := item.text(); text != "" && !turn.final {
+		turn.Text = text
+	}
+	turn.Name = cmp.Or(turn.Name, item.Name)
+	turn.CallID = cmp.Or(turn.CallID, item.CallID)
+	turn.Arguments = cmp.Or(item.Arguments, turn.Arguments)
+	turn.Output = cmp.Or(item.Output, turn.Output)
+}
+
+// claim gives the server's ID to the first matching item the client created without one.
+func (t *transcript) claim(item transcriptItem) {
+	if _, ok := t.byItem[item.ID]; ok {
+		return
+	}
+	for i, turn := range t.created {
+		if turn.Type == item.Type && turn.Role == item.Role && turn.CallID == item.CallID &&
+			(turn.Text == item.text() || turn.Output == item.Output && item.Output != "") {
+			turn.ItemID = item.ID
+			t.byItem[item.ID] = turn
+			t.created = append(t.created[:i], t.created[i+1:]...)
+			return
+		}
+	}
+}
+
+// offset formats the time of a turn relative to the start of the recording as mm:ss.s.
+func (t *transcript) offset(ms int64) string {
+	if ms == 0 || t.Started == 0 {
+		return "--:--.-"
+	}
+	d := time.Duration(ms-t.Started) * time.Millisecond
+	return fmt.Sprintf("%02d:%04.1f", int(d.Minutes()), (d % time.Minute).Seconds())
+}
+
+// duration returns how long a turn took in seconds, 0 for a single event.
+func (turn *transcriptTurn) duration() float64 {
+	if turn.Start == 0 || turn.End <= turn.Start {
+		return 0
+	}
+	return roundSeconds(float64(turn.End-turn.Start) / 1000)
+}
+
+// label names the speaker of a turn.
+func (turn *transcriptTurn) label() string {
+	switch turn.Type {
+	case "function_call":
+		return "Function call"
+	case "function_call_output":
+		return "Function output"
+	case "error":
+		return "Error"
+	}
+	if turn.Role == "" {
+		return "Message"
+	}
+	return strings.ToUpper(turn.Role[:1]) + turn.Role[1:]
+}
+
+// prettyJSON indents JSON arguments and outputs, leaving anything else as it is.
+func prettyJSON(s string) string {
+	var out bytes.Buffer
+	if json.Indent(&out, []byte(s), "", "  ") != nil {
+		return s
+	}
+	return out.String()
+}
+
+// markdown renders the transcript for reading in a repository or ticket.
+func (t *transcript) markdown(name string, meta *RecordingMeta) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Transcript of %s\n\n", name)
+	for _, line := range t.summary(meta) {
+		fmt.Fprintf(&b, "- %s\n", line)
+	}
+	for _, turn := range t.Turns {
+		if turn.Type == "message" && turn.Text == "" {
+			continue // E.g. audio without transcription
+		}
+		fmt.Fprintf(&b, "\n**[%s] %s", t.offset(turn.Start), turn.label())
+		if turn.Name != "" {
+			fmt.Fprintf(&b, " `%s`", turn.Name)
+		}
+		if turn.CallID != "" {
+			fmt.Fprintf(&b, " (%s)", turn.CallID)
+		}
+		b.WriteString(":**")
+		if d := turn.duration(); d > 0 {
+			fmt.Fprintf(&b, " _%gs_", d)
+		}
+		switch turn.Type {
+		case "function_call":
+			fmt.Fprintf(&b, "\n\n```json\n%s\n```\n", prettyJSON(turn.Arguments))
+		case "function_call_output":
+			fmt.Fprintf(&b, "\n\n```json\n%s\n```\n", prettyJSON(turn.Output))
+		default:
+			fmt.Fprintf(&b, " %s\n", turn.Text)
+		}
+	}
+	return b.String()
+}
+
+// summary lists what is known about the recorded session.
+func (t *transcript) summary(meta *RecordingMeta) []string {
+	var lines []string
+	if t.Started > 0 {
+		lines = append(lines, fmt.Sprintf("Started %s, %gs", time.UnixMilli(t.Started).UTC().Format(time.RFC3339),
+			roundSeconds(float64(t.Ended-t.Started)/1000)))
+	}
+	if meta != nil {
+		if meta.Model != "" {
+			lines = append(lines, "Model "+meta.Model)
+		}
+		if meta.Scenario != "" {
+			lines = append(lines, "Scenario "+meta.Scenario)
+		}
+		if meta.SessionID != "" {
+			lines = append(lines, "Session "+meta.SessionID)
+		}
+	}
+	return lines
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Transcript of {{.Name}}</title>
+<style>
+body { font-family: -apple-system, 'Segoe UI', sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; color: #222; }
+.summary { color: #666; }
+.turn { margin: 1em 0; padding: 0.6em 0.9em; border-radius: 6px; background: #f4f4f4; }
+.user { background: #e8f0fe; }
+.assistant { background: #eef7ee; }
+.error { background: #fdecea; }
+.head { font-size: 0.85em; color: #555; margin-bottom: 0.3em; }
+.text { white-space: pre-wrap; }
+pre { margin: 0; font-family: 'Menlo', 'Monaco', 'Courier New', monospace; font-size: 0.85em; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Transcript of {{.Name}}</h1>
+<ul class="summary">{{range .Summary}}<li>{{.}}</li>{{end}}</ul>
+{{range .Turns}}<div class="turn {{.Class}}">
+<div class="head">{{.Offset}} <b>{{.Label}}</b>{{with .Name}} <code>{{.}}</code>{{end}}{{with .CallID}} ({{.}}){{end}}{{if .Duration}} &middot; {{.Duration}}s{{end}}</div>
+{{if .Code}}<pre>{{.Code}}</pre>{{else}}<div class="text">{{.Text}}</div>{{end}}
+</div>
+{{end}}</body>
+</html>
+`))
+
+// html renders the transcript as a standalone page.
+func (t *transcript) html(name string, meta *RecordingMeta) ([]byte, error) {
+	type htmlTurn struct {
+		Class, Offset, Label, Name, CallID, Text, Code string
+		Duration                                       float64
+	}
+	page := struct {
+		Name    string
+		Summary []string
+		Turns   []htmlTurn
+	}{Name: name, Summary: t.summary(meta)}
+	for _, turn := range t.Turns {
+		if turn.Type == "message" && turn.Text == "" {
+			continue
+		}
+		h := htmlTurn{Class: cmp.Or(turn.Role, turn.Type), Offset: t.offset(turn.Start), Label: turn.label(),
+			Name: turn.Name, CallID: turn.CallID, Text: turn.Text, Duration: turn.duration()}
+		switch turn.Type {
+		case "function_call":
+			h.Code = prettyJSON(turn.Arguments)
+		case "function_call_output":
+			h.Code = prettyJSON(turn.Output)
+		}
+		page.Turns = append(page.Turns, h)
+	}
+	var out bytes.Buffer
+	if err := transcriptTemplate.Execute(&out, page); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// handleRecordingTranscript serves the conversation of a recording as an HTML page, or as
+// Markdown with ?format=markdown.
+func handleRecordingTranscript(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if filepath.Base(name) != name {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "html" && format != "markdown" {
+		http.Error(w, "format must be html or markdown", http.StatusBadRequest)
+		return
+	}
+	path, ok := resolveRecording(name)
+	if !ok {
+		http.Error(w, "Recording not found", http.StatusNotFound)
+		return
+	}
+
+	t, err := readTranscript(path)
+	if err != nil {
+		log.Printf("Failed to read transcript of %s: %v", path, err)
+		http.Error(w, "Failed to read recording", http.StatusInternalServerError)
+		return
+	}
+	meta := readRecordingMeta(path)
+	if format == "markdown" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(t.markdown(name, meta)))
+		return
+	}
+	page, err := t.html(name, meta)
+	if err != nil {
+		log.Printf("Failed to render transcript of %s: %v", path, err)
+		http.Error(w, "Failed to render transcript", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
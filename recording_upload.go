@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- Recording Uploads ---
+
+const maxUploadBytes = 512 << 20 // 512MB, recordings with audio get large
+
+// validateRecordedLine checks that a line of an uploaded recording is a RecordedEvent the replay can use.
+func validateRecordedLine(line []byte) error {
+	var event RecordedEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return fmt.Errorf("not a recorded event: %v", err)
+	}
+	if event.Timestamp <= 0 {
+		return errors.New("missing timestamp")
+	}
+	switch event.Direction {
+	case "", directionClient, directionServer:
+	default:
+		return fmt.Errorf("unknown direction %q", event.Direction)
+	}
+	if event.Trigger != "" && !validTurnTrigger(event.Trigger) {
+		return fmt.Errorf("unknown trigger %q", event.Trigger)
+	}
+	switch event.Frame {
+	case "":
+		var base BaseEvent
+		if err := json.Unmarshal(event.Data, &base); err != nil || base.Type == "" {
+			return errors.New("data is not an event with a type")
+		}
+	case binaryFrameType:
+		var data string
+		if json.Unmarshal(event.Data, &data) != nil {
+			return errors.New("binary frame data is not a string")
+		}
+		if _, err := base64.StdEncoding.DecodeString(data); err != nil && event.Bytes == 0 {
+			return errors.New("binary frame data is not base64")
+		}
+	case closeFrameType:
+		var data struct {
+			Code int `json:"code"`
+		}
+		if json.Unmarshal(event.Data, &data) != nil {
+			return errors.New("close frame data has no code")
+		}
+	default:
+		return fmt.Errorf("unknown frame %q", event.Frame)
+	}
+	return nil
+}
+
+// handleUploadRecording stores an NDJSON recording sent in the body as recorded/<name>.ndjson, so it
+// can be replayed with ?replaySession=<name>. It is an admin endpoint. The body may be gzip-compressed (Content-Encoding: gzip).
+// Every line is validated; an existing recording is only replaced with ?overwrite=true.
+func handleUploadRecording(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(r.URL.Query().Get("name"), ".ndjson")
+	if name == "" {
+		name = "upload_" + time.Now().Format("2006-01-02_15-04-05")
+	}
+	if filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		http.Error(w, "Invalid name", http.StatusBadRequest)
+		return
+	}
+
+	recordingDir := currentConfig().Proxy.RecordingPath
+	if recordingDir == "" {
+		recordingDir = "recordings"
+	}
+	targetDir := filepath.Join(recordingDir, "recorded")
+	path := filepath.Join(targetDir, name+".ndjson")
+	if _, exists := recordingFile(path); exists && r.URL.Query().Get("overwrite") != "true" {
+		http.Error(w, fmt.Sprintf("Recording %s already exists, use ?overwrite=true to replace it", name), http.StatusConflict)
+		return
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		http.Error(w, "Failed to create recording directory", http.StatusInternalServerError)
+		return
+	}
+
+	var body io.Reader = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			http.Error(w, "Body is not gzip-compressed", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		// The limit applies to what the body expands to as well, not only to what was sent
+		body = http.MaxBytesReader(w, gz, maxUploadBytes)
+	}
+
+	// Written to a temporary file first, so a rejected upload never replaces a recording
+	tmp, err := os.CreateTemp(targetDir, ".upload-*")
+	if err != nil {
+		http.Error(w, "Failed to store recording", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	scanner := bufio.NewScanner(body)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	var dst io.Writer = tmp
+	if recordingAEAD != nil {
+		dst = encryptingWriter{tmp, recordingAEAD}
+	}
+	out := bufio.NewWriterSize(dst, 64<<10)
+	lines := 0
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := validateRecordedLine(line); err != nil {
+			http.Error(w, fmt.Sprintf("Line %d: %v", lineNo, err), http.StatusBadRequest)
+			return
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("Recording exceeds %d MB", maxUploadBytes>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to read recording: %v", err), http.StatusBadRequest)
+		return
+	}
+	if lines == 0 {
+		http.Error(w, "Recording is empty", http.StatusBadRequest)
+		return
+	}
+	if err := out.Flush(); err != nil {
+		http.Error(w, "Failed to store recording", http.StatusInternalServerError)
+		return
+	}
+	tmp.Close()
+	os.Chmod(tmp.Name(), 0644)
+
+	// Drop a compressed recording of the same name, it would be listed next to the new one
+	os.Remove(path + ".gz")
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		http.Error(w, "Failed to store recording", http.StatusInternalServerError)
+		return
+	}
+	info, _ := os.Stat(path)
+	log.Printf("Uploaded recording %s (%d events) from %s", path, lines, r.RemoteAddr)
+	uploadRecording(path, 1)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(RecordingFile{Name: filepath.Base(path), Size: info.Size()})
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// --- Recording Redaction ---
+
+const redactedValue = "[REDACTED]"
+
+// Patterns that can be referenced by name in redact.patterns
+var builtinRedactionPatterns = map[string]string{
+	"email": `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"phone": `\+?\d[\d\s().-]{7,}\d`,
+}
+
+// Keys and values that carry credentials
+var (
+	secretKeys    = []string{"authorization", "api_key", "apikey", "client_secret", "access_token"}
+	secretPattern = regexp.MustCompile(`^Bearer\s|\bsk-[A-Za-z0-9_-]{20,}|\bek_[A-Za-z0-9_-]{16,}`)
+)
+
+// RedactionConfig makes recordings safe to share. It applies to all recorders, after the filters.
+type RedactionConfig struct {
+	DropSecrets     bool     `yaml:"dropSecrets" json:"dropSecrets"`               // Skip events carrying credentials (Authorization values, API keys, client secrets)
+	Fields          []string `yaml:"fields,omitempty" json:"fields,omitempty"`     // Paths replaced by [REDACTED], as in proxy.transforms
+	Patterns        []string `yaml:"patterns,omitempty" json:"patterns,omitempty"` // Regexes masked in every string, "email" and "phone" are built in
+	HashTranscripts bool     `yaml:"hashTranscripts" json:"hashTranscripts"`       // Replace transcripts and text by a SHA-256 prefix
+
+	patterns []*regexp.Regexp
+}
+
+// compile validates the patterns and prepares them for apply.
+func (c *RedactionConfig) compile() error {
+	c.patterns = nil
+	for _, pattern := range c.Patterns {
+		if builtin, ok := builtinRedactionPatterns[pattern]; ok {
+			pattern = builtin
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+	return nil
+}
+
+func (c *RedactionConfig) enabled() bool {
+	return c.DropSecrets || len(c.Fields) > 0 || len(c.patterns) > 0 || c.HashTranscripts
+}
+
+// apply returns the redacted message, or false if it must not be recorded.
+func (c *RedactionConfig) apply(msg []byte) ([]byte, bool) {
+	if c == nil || !c.enabled() {
+		return msg, true
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return msg, true
+	}
+	if c.DropSecrets && containsSecret(event) {
+		return nil, false
+	}
+
+	for _, p := range c.Fields {
+		eachPath(event, p, false, func(parent interface{}, key string) {
+			setAt(parent, key, redactedValue)
+		})
+	}
+	eventType, _ := event["type"].(string)
+	for key, value := range event {
+		if key != "type" {
+			event[key] = c.redactValue(key, value, eventType)
+		}
+	}
+
+	redacted, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error redacting recorded event: %v", err)
+		return nil, false
+	}
+	return redacted, true
+}
+
+func (c *RedactionConfig) redactValue(key string, value interface{}, eventType string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = c.redactValue(k, child, eventType)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = c.redactValue(key, child, eventType)
+		}
+	case string:
+		if v == "" || v == redactedValue {
+			return v
+		}
+		textDelta := key == "delta" && (strings.Contains(eventType, "transcript") || strings.Contains(eventType, "text"))
+		if c.HashTranscripts && (key == "transcript" || key == "text" || textDelta) {
+			sum := sha256.Sum256([]byte(v))
+			return "sha256:" + hex.EncodeToString(sum[:8])
+		}
+		// IDs and base64 audio may contain digit runs that look like phone numbers
+		if key == "id" || strings.HasSuffix(key, "_id") || key == "audio" || strings.HasSuffix(eventType, "audio.delta") {
+			return v
+		}
+		for _, re := range c.patterns {
+			v = re.ReplaceAllString(v, redactedValue)
+		}
+		return v
+	}
+	return value
+}
+
+// containsSecret reports whether any credential key or value appears in the event.
+func containsSecret(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			for _, secretKey := range secretKeys {
+				if strings.EqualFold(key, secretKey) && child != nil && child != "" {
+					return true
+				}
+			}
+			if containsSecret(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if containsSecret(child) {
+				return true
+			}
+		}
+	case string:
+		return secretPattern.MatchString(v)
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// --- Replay ID Remapping ---
+
+// replayIDs rewrites the IDs in replayed events to fresh ones, consistently for the whole
+// replay: every occurrence of a recorded ID gets the same new ID. The recorded session and
+// conversation map to the ones the mock announced in its welcome events.
+type replayIDs struct {
+	sessionID      string
+	conversationID string
+	ids            map[string]string // Recorded ID -> fresh ID
+}
+
+func newReplayIDs(sessionID, conversationID string) *replayIDs {
+	return &replayIDs{sessionID: sessionID, conversationID: conversationID, ids: make(map[string]string)}
+}
+
+// rewrite returns the event with every "id" and "*_id" string replaced. A nil mapper keeps them.
+func (m *replayIDs) rewrite(data []byte) []byte {
+	if m == nil {
+		return data
+	}
+	var event interface{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return data
+	}
+	m.walk(event, "")
+	rewritten, err := json.Marshal(event)
+	if err != nil {
+		return data
+	}
+	return rewritten
+}
+
+func (m *replayIDs) walk(node interface{}, parentKey string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if id, ok := value.(string); ok && id != "" && (key == "id" || strings.HasSuffix(key, "_id")) {
+				n[key] = m.fresh(id, parentKey, key)
+				continue
+			}
+			m.walk(value, key)
+		}
+	case []interface{}:
+		for _, value := range n {
+			m.walk(value, parentKey)
+		}
+	}
+}
+
+// fresh returns the new ID for a recorded one, creating it on first sight.
+func (m *replayIDs) fresh(id, parentKey, key string) string {
+	if mapped, ok := m.ids[id]; ok {
+		return mapped
+	}
+	var mapped string
+	switch {
+	case parentKey == "session" && key == "id":
+		mapped = m.sessionID
+	case (parentKey == "conversation" && key == "id") || key == "conversation_id":
+		mapped = m.conversationID
+	default:
+		mapped = freshID(id)
+	}
+	m.ids[id] = mapped
+	return mapped
+}
+
+// freshID generates an ID shaped like the recorded one: OpenAI style IDs keep their prefix
+// (resp_, item_, ...), the mock's own IDs keep everything before their UUID.
+func freshID(id string) string {
+	if i := strings.Index(id, "_"); i >= 0 {
+		return id[:i+1] + strings.ReplaceAll(uuid.NewString(), "-", "")[:20]
+	}
+	if len(id) >= 36 {
+		if _, err := uuid.Parse(id[len(id)-36:]); err == nil {
+			return id[:len(id)-36] + uuid.NewString()
+		}
+	}
+	return id + "-" + uuid.NewString()[:8]
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// --- Interactive Replay Turns ---
+
+// What a recorded response waits for in an interactive replay, set on its response.created line
+// with "trigger" or detected from the recording.
+const (
+	turnTriggerAny      = "any"             // Any client action that starts a response, the default without a mapping
+	turnTriggerCommit   = "commit"          // The client's audio being committed, by server VAD or input_audio_buffer.commit
+	turnTriggerResponse = "response.create" // The client's response.create
+	turnTriggerNone     = "none"            // Nothing, e.g. a response the server started on its own
+)
+
+// Modes of mock.replayTurns / ?replay_turns=
+const (
+	replayTurnsAuto = "auto" // Annotations, else what the recording shows (default)
+	replayTurnsAny  = "any"  // Every response waits for any trigger
+)
+
+func validTurnTrigger(trigger string) bool {
+	switch trigger {
+	case turnTriggerAny, turnTriggerCommit, turnTriggerResponse, turnTriggerNone:
+		return true
+	}
+	return false
+}
+
+// replayTurns returns how interactive replays map responses to triggers, ?replay_turns= or the configured default.
+func replayTurns(r *http.Request) string {
+	if turns := r.URL.Query().Get("replay_turns"); turns != "" {
+		return turns
+	}
+	if turns := requestConfig(r).Mock.ReplayTurns; turns != "" {
+		return turns
+	}
+	return replayTurnsAuto
+}
+
+// replayTrigger is a client action that can release a held response.
+type replayTrigger struct {
+	event  string // turnTriggerCommit or turnTriggerResponse
+	starts bool   // Whether it starts a response outside of replays, which is all turnTriggerAny waits for
+}
+
+// awaitTurn waits for the trigger a response needs, discarding others: a manual commit followed
+// by response.create releases a response.create turn once. It reports false once the client is gone.
+func awaitTurn(triggers <-chan replayTrigger, want string) bool {
+	for trigger := range triggers {
+		switch want {
+		case turnTriggerAny:
+			if trigger.starts {
+				return true
+			}
+		case turnTriggerCommit:
+			return true // An explicit response.create also ends the client's turn
+		case turnTriggerResponse:
+			if trigger.event == turnTriggerResponse {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// turnMapper finds out what each recorded response was triggered by. It looks at the events since
+// the previous response.created: a client response.create makes it a response.create turn, otherwise
+// committed audio a commit turn. Client events come from a combined recording or from the inbound
+// recording next to a replayed outbound one; without them a turn without committed audio waits for any trigger.
+type turnMapper struct {
+	mode       string
+	clientSide bool // Client events were seen, so a missing response.create means none was sent
+	committed  bool
+	requested  bool
+	last       int64 // Timestamp of the previous response.created
+
+	// Timestamps of the client events in the inbound recording
+	inboundRequests, inboundCommits []int64
+}
+
+func newTurnMapper(mode, path string) *turnMapper {
+	m := &turnMapper{mode: mode}
+	if mode == replayTurnsAuto {
+		m.readInbound(path)
+	}
+	return m
+}
+
+// inboundSibling returns the inbound recording of the session of an outbound one:
+// outbound_x.ndjson -> inbound_x.ndjson, or inbound.ndjson in a session directory.
+func inboundSibling(path string) (string, bool) {
+	if strings.HasPrefix(path, storedPrefix) {
+		return "", false
+	}
+	dir, name := filepath.Split(path)
+	base, _, _ := strings.Cut(name, ".ndjson")
+	switch {
+	case base == "outbound":
+		base = "inbound"
+	case strings.HasPrefix(base, "outbound_"):
+		base = "inbound_" + strings.TrimPrefix(base, "outbound_")
+	default:
+		return "", false
+	}
+	return recordingFile(filepath.Join(dir, base+".ndjson"))
+}
+
+// readInbound loads the client's response.create and commit timestamps from the inbound sibling.
+func (m *turnMapper) readInbound(path string) {
+	inbound, ok := inboundSibling(path)
+	if !ok {
+		return
+	}
+	file, err := openRecording(inbound)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	for scanner.Scan() {
+		var event RecordedEvent
+		var base BaseEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Frame != "" || json.Unmarshal(event.Data, &base) != nil {
+			continue
+		}
+		switch base.Type {
+		case "response.create":
+			m.inboundRequests = append(m.inboundRequests, event.Timestamp)
+		case "input_audio_buffer.commit":
+			m.inboundCommits = append(m.inboundCommits, event.Timestamp)
+		}
+	}
+	m.clientSide = scanner.Err() == nil
+}
+
+// observe notes an event of the recording, client events included.
+func (m *turnMapper) observe(event RecordedEvent, eventType string) {
+	if event.Direction == directionClient {
+		m.clientSide = true
+		switch eventType {
+		case "response.create":
+			m.requested = true
+		case "input_audio_buffer.commit":
+			m.committed = true
+		}
+		return
+	}
+	if eventType == "input_audio_buffer.committed" {
+		m.committed = true
+	}
+}
+
+// trigger returns what the response created by event waits for and starts the next turn.
+func (m *turnMapper) trigger(event RecordedEvent) string {
+	requested, committed := m.requested, m.committed
+	for _, ts := range m.inboundRequests {
+		requested = requested || (ts > m.last && ts <= event.Timestamp)
+	}
+	for _, ts := range m.inboundCommits {
+		committed = committed || (ts > m.last && ts <= event.Timestamp)
+	}
+	m.requested, m.committed, m.last = false, false, event.Timestamp
+
+	switch {
+	case event.Trigger != "":
+		return event.Trigger
+	case m.mode == replayTurnsAny:
+		return turnTriggerAny
+	case requested:
+		return turnTriggerResponse
+	case committed:
+		return turnTriggerCommit
+	case m.clientSide:
+		return turnTriggerNone
+	}
+	return turnTriggerAny
+}
+
+func validateReplayTurns(turns string) error {
+	switch turns {
+	case "", replayTurnsAuto, replayTurnsAny:
+		return nil
+	}
+	return fmt.Errorf("mock.replayTurns has unknown value: %s", turns)
+}
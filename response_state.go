@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// --- Response Tracking (cancel / truncate) ---
+
+// activeResponse is a response currently being streamed to the client.
+type activeResponse struct {
+	id        string
+	seq       int  // 1-based number of the response within the session
+	marked    bool // Whether the response marker was already overlaid on an audio part
+	cancelled chan struct{}
+	once      sync.Once
+}
+
+func (r *activeResponse) cancel() {
+	r.once.Do(func() { close(r.cancelled) })
+}
+
+func (r *activeResponse) isCancelled() bool {
+	select {
+	case <-r.cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConversationItemTruncateEvent is the client event used to cut assistant audio at playback position.
+type ConversationItemTruncateEvent struct {
+	Type         string `json:"type"`
+	EventID      string `json:"event_id,omitempty"`
+	ItemID       string `json:"item_id"`
+	ContentIndex int    `json:"content_index"`
+	AudioEndMs   int    `json:"audio_end_ms"`
+}
+
+// beginResponse registers a response as the session's active response.
+func (s *MockSession) beginResponse(responseID string) *activeResponse {
+	s.responseMu.Lock()
+	s.responseCount++
+	resp := &activeResponse{id: responseID, seq: s.responseCount, cancelled: make(chan struct{})}
+	s.activeResponse = resp
+	s.responseMu.Unlock()
+	return resp
+}
+
+// endResponse clears the active response once it has finished streaming.
+func (s *MockSession) endResponse(resp *activeResponse) {
+	s.responseMu.Lock()
+	if s.activeResponse == resp {
+		s.activeResponse = nil
+	}
+	s.responseMu.Unlock()
+}
+
+// cancelResponse handles response.cancel from the client.
+func (s *MockSession) cancelResponse(clientEventID string) {
+	s.responseMu.Lock()
+	resp := s.activeResponse
+	s.responseMu.Unlock()
+
+	if resp == nil {
+		sendErrorEvent(s.Conn, "invalid_request_error", "response_cancel_not_active",
+			"Cancellation failed: no active response found", "", clientEventID)
+		return
+	}
+	s.logf("Client %s: Cancelling response %s", s.Conn.RemoteAddr(), resp.id)
+	resp.cancel()
+}
+
+// addItemAudio accounts output audio bytes that were actually delivered for a content part of an item.
+// Items with several content parts may carry audio in any of them.
+func (s *MockSession) addItemAudio(itemID string, contentIndex, n int) {
+	s.responseMu.Lock()
+	defer s.responseMu.Unlock()
+	if s.itemAudioBytes == nil {
+		s.itemAudioBytes = make(map[string]map[int]int)
+	}
+	if s.itemAudioBytes[itemID] == nil {
+		s.itemAudioBytes[itemID] = make(map[int]int)
+	}
+	s.itemAudioBytes[itemID][contentIndex] += n
+}
+
+// itemAudioMs returns how many milliseconds of output audio were streamed for an item, all parts together.
+func (s *MockSession) itemAudioMs(itemID string) int {
+	s.responseMu.Lock()
+	defer s.responseMu.Unlock()
+	total := 0
+	for _, n := range s.itemAudioBytes[itemID] {
+		total += n
+	}
+	return total / s.output().bytesPerMs()
+}
+
+// handleTruncate validates a conversation.item.truncate against the streamed audio and confirms it.
+func (s *MockSession) handleTruncate(message []byte) {
+	var truncate ConversationItemTruncateEvent
+	if err := json.Unmarshal(message, &truncate); err != nil {
+		sendErrorEvent(s.Conn, "invalid_request_error", "invalid_event", "Invalid conversation.item.truncate payload.", "", "")
+		return
+	}
+
+	s.responseMu.Lock()
+	parts, ok := s.itemAudioBytes[truncate.ItemID]
+	streamedBytes, isAudio := parts[truncate.ContentIndex]
+	s.responseMu.Unlock()
+	bytesPerMs := s.output().bytesPerMs()
+	streamedMs := streamedBytes / bytesPerMs
+
+	if !ok {
+		sendErrorEvent(s.Conn, "invalid_request_error", "invalid_value",
+			fmt.Sprintf("Item with item_id '%s' not found or has no audio.", truncate.ItemID), "item_id", truncate.EventID)
+		return
+	}
+	if !isAudio {
+		sendErrorEvent(s.Conn, "invalid_request_error", "invalid_value",
+			fmt.Sprintf("Content index %d is not an audio content part.", truncate.ContentIndex), "content_index", truncate.EventID)
+		return
+	}
+	if truncate.AudioEndMs < 0 || truncate.AudioEndMs > streamedMs {
+		sendErrorEvent(s.Conn, "invalid_request_error", "invalid_value",
+			fmt.Sprintf("Audio content of %dms is already shorter than %dms", streamedMs, truncate.AudioEndMs), "audio_end_ms", truncate.EventID)
+		return
+	}
+
+	s.responseMu.Lock()
+	s.itemAudioBytes[truncate.ItemID][truncate.ContentIndex] = truncate.AudioEndMs * bytesPerMs
+	s.responseMu.Unlock()
+
+	s.logf("Client %s: Truncated item %s at %dms (of %dms streamed)", s.Conn.RemoteAddr(), truncate.ItemID, truncate.AudioEndMs, streamedMs)
+	sendJSONEvent(s.Conn, map[string]interface{}{
+		"type":          "conversation.item.truncated",
+		"event_id":      uuid.NewString(),
+		"item_id":       truncate.ItemID,
+		"content_index": truncate.ContentIndex,
+		"audio_end_ms":  truncate.AudioEndMs,
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Session Resume ---
+
+// A mock session that loses its client is kept for mock.resumeTTLSeconds. A client reconnecting
+// with ?resume=SESSION_ID gets the same session and conversation back, with the items created so
+// far, and the scenario goes on where it stopped.
+
+const defaultResumeTTLSeconds = 300
+
+// suspendedSession is a disconnected session waiting to be resumed.
+type suspendedSession struct {
+	session   *MockSession
+	scenario  Scenario
+	convID    string
+	expiresAt time.Time
+}
+
+var suspendedSessions = struct {
+	sync.Mutex
+	byID map[string]*suspendedSession
+}{byID: make(map[string]*suspendedSession)}
+
+// suspendSession keeps a disconnected session for resumeTTLSeconds, unless resuming is disabled.
+func suspendSession(session *MockSession, scenario Scenario, convID string) {
+	ttl := session.config.Mock.ResumeTTLSeconds
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	suspendedSessions.Lock()
+	defer suspendedSessions.Unlock()
+	for id, suspended := range suspendedSessions.byID {
+		if now.After(suspended.expiresAt) {
+			delete(suspendedSessions.byID, id)
+		}
+	}
+	suspendedSessions.byID[session.ID] = &suspendedSession{
+		session:   session,
+		scenario:  scenario,
+		convID:    convID,
+		expiresAt: now.Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+// takeSuspendedSession hands out a suspended session once, until it is suspended again.
+func takeSuspendedSession(id string) (*suspendedSession, bool) {
+	suspendedSessions.Lock()
+	defer suspendedSessions.Unlock()
+	suspended, ok := suspendedSessions.byID[id]
+	if !ok {
+		return nil, false
+	}
+	delete(suspendedSessions.byID, id)
+	if time.Now().After(suspended.expiresAt) {
+		return nil, false
+	}
+	return suspended, true
+}
+
+// resumeFrom carries the settings, conversation and scenario position of a suspended session over.
+func (s *MockSession) resumeFrom(prev *MockSession) {
+	s.Tenant = prev.Tenant
+	s.Profile = prev.Profile
+	s.InputAudioFormat = prev.InputAudioFormat
+	s.OutputAudioFormat = prev.OutputAudioFormat
+	s.Voice = prev.Voice
+	s.Model = prev.Model
+	s.Modalities = prev.Modalities
+	s.Instructions = prev.Instructions
+	s.TurnDetection = prev.TurnDetection
+	s.InputTranscription = prev.InputTranscription
+	s.lastItemID = prev.lastItemID
+	prev.historyMu.Lock()
+	s.history = prev.history
+	prev.historyMu.Unlock()
+	s.scenarioPos.Store(prev.scenarioPos.Load())
+	s.scenarioStarted.Store(prev.scenarioStarted.Load())
+}
+
+// rememberItem adds an item to the conversation resumed sessions get back.
+func (s *MockSession) rememberItem(item map[string]interface{}) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	s.history = append(s.history, item)
+}
+
+// rememberTranscript fills in the transcript of a user audio item.
+func (s *MockSession) rememberTranscript(itemID, transcript string) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+	for _, item := range s.history {
+		if item["id"] != itemID {
+			continue
+		}
+		if content, ok := item["content"].([]interface{}); ok && len(content) > 0 {
+			if part, ok := content[0].(map[string]interface{}); ok {
+				part["transcript"] = transcript
+			}
+		}
+	}
+}
+
+// sendHistory sends the items of a resumed conversation as conversation.item.created events.
+func (s *MockSession) sendHistory() error {
+	s.historyMu.Lock()
+	history := append([]map[string]interface{}(nil), s.history...)
+	s.historyMu.Unlock()
+	var previousItemID interface{}
+	for _, item := range history {
+		itemCreated := map[string]interface{}{
+			"type":             "conversation.item.created",
+			"event_id":         uuid.NewString(),
+			"previous_item_id": previousItemID,
+			"item":             item,
+		}
+		if err := sendJSONEvent(s.Conn, itemCreated); err != nil {
+			return err
+		}
+		previousItemID = item["id"]
+	}
+	s.logf("Client %s: Resumed session %s with %d conversation items", s.Conn.RemoteAddr(), s.ID, len(history))
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// --- Admin API ---
+
+func (c ServerConfig) adminTokenEnv() string {
+	return cmp.Or(c.AdminTokenEnv, "ADMIN_TOKEN")
+}
+
+// authorizeAdmin lets a request change the server at runtime. It needs the admin token as
+// "Authorization: Bearer <token>"; without a token configured the admin API is off.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	env := requestConfig(r).Server.adminTokenEnv()
+	token := os.Getenv(env)
+	if token == "" {
+		http.Error(w, fmt.Sprintf("Admin API disabled, set %s to enable it", env), http.StatusForbidden)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(clientAPIKey(r)), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Admin token required", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// requireAdmin guards a handler of the admin API.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorizeAdmin(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// --- Scenario Management ---
+
+// currentScenarios returns the scenarios as they are now, those of the config first, then those
+// of the library that the config doesn't override.
+func currentScenarios() []Scenario {
+	return configScenarios(currentConfig())
+}
+
+// configScenarios returns the scenarios of cfg merged with those of the library. The slices are
+// never modified in place, so the result can be read without a lock.
+func configScenarios(cfg *Config) []Scenario {
+	scenarios := cfg.Scenarios
+	shared := libraryScenarios()
+	if len(shared) == 0 {
+		return scenarios
+	}
+	merged := slices.Clone(scenarios)
+	for _, s := range shared {
+		if !slices.ContainsFunc(scenarios, func(own Scenario) bool { return own.Name == s.Name }) {
+			merged = append(merged, s)
+		}
+	}
+	return merged
+}
+
+// findScenario returns the scenario with the given name.
+func findScenario(name string) (Scenario, bool) {
+	for _, s := range currentScenarios() {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// updateScenarios applies change to a copy of the scenarios and swaps it in if the result is valid.
+func updateScenarios(change func([]Scenario) ([]Scenario, error)) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	scenarios, err := change(slices.Clone(appConfig.Scenarios))
+	if err != nil {
+		return err
+	}
+	if len(scenarios) == 0 && appConfig.Mode == "mock" && !appConfig.Library.enabled() {
+		return fmt.Errorf("mock mode needs at least one scenario")
+	}
+	if err := appConfig.Proxy.CircuitBreaker.validate(scenarios); err != nil {
+		return err
+	}
+	next := *appConfig
+	next.Scenarios = scenarios
+	appConfig = &next
+	return nil
+}
+
+func handleListScenarios(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentScenarios())
+}
+
+func handleGetScenario(w http.ResponseWriter, r *http.Request) {
+	scenario, ok := findScenario(r.PathValue("name"))
+	if !ok {
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scenario)
+}
+
+// readScenario decodes the scenario in a request body, JSON or with a YAML Content-Type YAML
+// like in config.yaml. The name comes from the path; a name in the body must match it.
+func readScenario(r *http.Request) (Scenario, error) {
+	var scenario Scenario
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, 10<<20))
+	if err != nil {
+		return scenario, fmt.Errorf("failed to read body: %v", err)
+	}
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		err = yaml.Unmarshal(body, &scenario)
+	} else {
+		err = json.Unmarshal(body, &scenario)
+	}
+	if err != nil {
+		return scenario, fmt.Errorf("invalid scenario: %v", err)
+	}
+	name := r.PathValue("name")
+	if scenario.Name != "" && scenario.Name != name {
+		return scenario, fmt.Errorf("scenario name %q does not match the path", scenario.Name)
+	}
+	scenario.Name = name
+	return scenario, scenario.validate()
+}
+
+// handlePutScenario creates a scenario, or replaces it with PUT. POST answers 409 Conflict
+// if the scenario exists. Sessions already running keep the scenario they started with.
+func handlePutScenario(w http.ResponseWriter, r *http.Request) {
+	scenario, err := readScenario(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := http.StatusCreated
+	err = updateScenarios(func(scenarios []Scenario) ([]Scenario, error) {
+		i := slices.IndexFunc(scenarios, func(s Scenario) bool { return s.Name == scenario.Name })
+		switch {
+		case i < 0:
+			return append(scenarios, scenario), nil
+		case r.Method == http.MethodPost:
+			status = http.StatusConflict
+			return nil, fmt.Errorf("scenario %s already exists", scenario.Name)
+		}
+		status = http.StatusOK
+		scenarios[i] = scenario
+		return scenarios, nil
+	})
+	if err != nil {
+		if status != http.StatusConflict {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	log.Printf("Admin: Scenario %s saved (%d events)", scenario.Name, len(scenario.Events))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(scenario)
+}
+
+func handleDeleteScenario(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	found := false
+	err := updateScenarios(func(scenarios []Scenario) ([]Scenario, error) {
+		before := len(scenarios)
+		scenarios = slices.DeleteFunc(scenarios, func(s Scenario) bool { return s.Name == name })
+		found = len(scenarios) < before
+		return scenarios, nil
+	})
+	switch {
+	case !found:
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Admin: Scenario %s deleted", name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
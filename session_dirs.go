@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Per-Session Recording Directories ---
+
+const sessionManifestFile = "manifest.json"
+
+// SessionManifest describes the artifacts of one proxied session.
+type SessionManifest struct {
+	SessionID     string    `json:"session_id,omitempty"` // Upstream session ID from session.created
+	LiveSessionID string    `json:"live_session_id"`      // ID used by /sessions and /observe
+	Target        string    `json:"target"`
+	Model         string    `json:"model"`
+	Client        string    `json:"client"`
+	StartedAt     time.Time `json:"started_at"`
+	EndedAt       time.Time `json:"ended_at"`
+	Files         []string  `json:"files"`
+}
+
+// sessionRecording keeps all recordings of a proxied session in one directory under recorded/.
+// Until the session ends the directory has a unique temporary name, then it is renamed to the
+// upstream session ID (unless recording_name chose the name) and a manifest is written.
+type sessionRecording struct {
+	root     string // <recordingPath>/recorded
+	dir      string
+	named    bool // Named by recording_name, never renamed
+	usage    *SessionUsage
+	manifest SessionManifest
+}
+
+func newSessionRecording(recordingDir, recordingName, baseName string, usage *SessionUsage, client string) *sessionRecording {
+	s := &sessionRecording{
+		root:  filepath.Join(recordingDir, "recorded"),
+		usage: usage,
+		manifest: SessionManifest{
+			Target:    usage.Target,
+			Model:     usage.Model,
+			Client:    client,
+			StartedAt: usage.StartedAt,
+		},
+	}
+	if recordingName != "" {
+		s.dir = filepath.Join(s.root, filepath.Base(recordingName))
+		s.named = true
+	} else {
+		s.dir = filepath.Join(s.root, baseName+"_"+uuid.NewString()[:8])
+	}
+	return s
+}
+
+// recorder opens the NDJSON file for prefix ("session", "inbound" or "outbound"). Without a
+// session directory it is the usual <prefix>_<baseName>.ndjson in recorded/.
+func (s *sessionRecording) recorder(recordingDir, prefix, baseName string) (*Recorder, error) {
+	if s == nil {
+		return NewRecorder(recordingDir, prefix, prefix+"_"+baseName)
+	}
+	return newRecorderIn(s.dir, prefix, prefix)
+}
+
+// audioCapture opens the WAV capture, writing audio_<response id>.wav in a session directory.
+func (s *sessionRecording) audioCapture(recordingDir, baseName string) (*AudioCapture, error) {
+	if s == nil {
+		return NewAudioCapture(recordingDir, baseName)
+	}
+	return newAudioCaptureIn(s.dir, "audio")
+}
+
+// finish renames the directory after the upstream session and writes the manifest. It must run
+// after every recorder was closed. A directory left empty (recording was off) is removed.
+func (s *sessionRecording) finish(liveID string) {
+	if s == nil {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return // Nothing was ever recorded
+	}
+	if len(entries) == 0 {
+		os.Remove(s.dir)
+		return
+	}
+
+	s.manifest.SessionID = proxyUsage.sessionID(s.usage)
+	s.manifest.LiveSessionID = liveID
+	s.manifest.EndedAt = time.Now()
+	if !s.named && s.manifest.SessionID != "" {
+		target := filepath.Join(s.root, filepath.Base(s.manifest.SessionID))
+		if _, err := os.Stat(target); err == nil {
+			log.Printf("Proxy: %s already exists, keeping recordings in %s", target, s.dir)
+		} else if err := os.Rename(s.dir, target); err != nil {
+			log.Printf("Proxy: Failed to rename session directory: %v", err)
+		} else {
+			s.dir = target
+		}
+	}
+
+	s.manifest.Files = []string{}
+	for _, entry := range entries {
+		if name := entry.Name(); name != sessionManifestFile && !entry.IsDir() {
+			s.manifest.Files = append(s.manifest.Files, name)
+		}
+	}
+	sort.Strings(s.manifest.Files)
+	data, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, sessionManifestFile), append(data, '\n'), 0644); err != nil {
+		log.Printf("Proxy: Failed to write session manifest: %v", err)
+		return
+	}
+	log.Printf("Proxy: Session recordings in %s", s.dir)
+}
+
+// --- Session Listing ---
+
+// sessionDirectory returns the session directory under recorded/ with the given name.
+func sessionDirectory(name string) (string, bool) {
+	recordingDir := currentConfig().Proxy.RecordingPath
+	if recordingDir == "" {
+		recordingDir = "recordings"
+	}
+	dir := filepath.Join(recordingDir, "recorded", name)
+	info, err := os.Stat(dir)
+	return dir, err == nil && info.IsDir()
+}
+
+// sessionMainRecording returns the recording that stands for a session directory, the one
+// replayed for ?replaySession=: session.ndjson, else outbound.ndjson.
+func sessionMainRecording(dir string) (string, bool) {
+	for _, prefix := range []string{"session", "outbound"} {
+		if path, ok := recordingFile(filepath.Join(dir, prefix+".ndjson")); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// sessionEntry lists a session directory as one entry of GET /recordings, with its manifest and
+// files. Directories without a manifest or recording are not sessions.
+func sessionEntry(dir string, live map[string]bool) (RecordingFile, bool) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return RecordingFile{}, false
+	}
+	session := RecordingFile{Name: filepath.Base(dir), Files: []RecordingFile{}}
+	recordings := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, ".meta.json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if name == sessionManifestFile {
+			var manifest SessionManifest
+			if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &manifest) == nil {
+				session.Session = &manifest
+			}
+			continue
+		}
+		file := RecordingFile{Name: name, Size: info.Size(), ModTime: info.ModTime(), Live: live[path], path: path}
+		if strings.Contains(name, ".ndjson") {
+			file.Meta = readRecordingMeta(path)
+			recordings++
+		}
+		session.Files = append(session.Files, file)
+		session.Size += file.Size
+		if file.ModTime.After(session.ModTime) {
+			session.ModTime = file.ModTime
+		}
+		session.Live = session.Live || file.Live
+	}
+	if session.Session == nil && recordings == 0 {
+		return RecordingFile{}, false
+	}
+	session.path, _ = sessionMainRecording(dir)
+	return session, true
+}
+
+// handleGetSessionFile serves a file of a session directory, e.g. /recordings/sess_abc/files/inbound.ndjson.
+func handleGetSessionFile(w http.ResponseWriter, r *http.Request) {
+	name, filename := r.PathValue("name"), r.PathValue("file")
+	if filepath.Base(name) != name || filepath.Base(filename) != filename {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	dir, ok := sessionDirectory(name)
+	if !ok {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	serveRecordingFile(w, r, dir, filename)
+}
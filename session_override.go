@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// --- Proxy session.update Overrides ---
+
+// SessionOverrideConfig rewrites client session.update events before they are forwarded upstream.
+type SessionOverrideConfig struct {
+	// Fields forced into every session.update (nested objects are merged)
+	Set map[string]interface{} `yaml:"set,omitempty" json:"set,omitempty"`
+	// Fields added only when the client did not set them
+	Defaults map[string]interface{} `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+	// Upper bound for max_response_output_tokens / max_output_tokens, 0 disables the cap
+	MaxOutputTokens int `yaml:"maxOutputTokens,omitempty" json:"maxOutputTokens,omitempty"`
+	// Text appended to the client's instructions
+	AppendInstructions string `yaml:"appendInstructions,omitempty" json:"appendInstructions,omitempty"`
+}
+
+func (c SessionOverrideConfig) enabled() bool {
+	return len(c.Set) > 0 || len(c.Defaults) > 0 || c.MaxOutputTokens > 0 || c.AppendInstructions != ""
+}
+
+// rewriteSessionUpdate applies the configured overrides to a client message if it is a
+// session.update. Other messages, and everything when no overrides are configured, pass unchanged.
+func rewriteSessionUpdate(msg []byte, cfg SessionOverrideConfig) ([]byte, bool, error) {
+	// Cheap check first, most client traffic is audio
+	if !cfg.enabled() || !bytes.Contains(msg, []byte(`"session.update"`)) {
+		return msg, false, nil
+	}
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg, &event); err != nil || event["type"] != "session.update" {
+		return msg, false, nil
+	}
+	session, _ := event["session"].(map[string]interface{})
+	if session == nil {
+		session = map[string]interface{}{}
+		event["session"] = session
+	}
+
+	for key, value := range cfg.Defaults {
+		if _, ok := session[key]; !ok {
+			session[key] = copyJSONValue(value) // Set may be merged into it below
+		}
+	}
+	mergeInto(session, cfg.Set)
+
+	if cfg.AppendInstructions != "" {
+		if instructions, _ := session["instructions"].(string); instructions != "" {
+			session["instructions"] = instructions + "\n\n" + cfg.AppendInstructions
+		} else {
+			session["instructions"] = cfg.AppendInstructions
+		}
+	}
+
+	if cfg.MaxOutputTokens > 0 {
+		capped := false
+		for _, key := range []string{"max_response_output_tokens", "max_output_tokens"} {
+			if value, ok := session[key]; ok {
+				// "inf" and anything above the cap become the cap
+				if n, isNumber := value.(float64); !isNumber || int(n) > cfg.MaxOutputTokens {
+					session[key] = cfg.MaxOutputTokens
+				}
+				capped = true
+			}
+		}
+		if !capped {
+			session["max_response_output_tokens"] = cfg.MaxOutputTokens
+		}
+	}
+
+	rewritten, err := json.Marshal(event)
+	if err != nil {
+		return msg, false, fmt.Errorf("failed to marshal rewritten session.update: %w", err)
+	}
+	return rewritten, true, nil
+}
+
+// mergeInto copies src into dst, merging nested objects instead of replacing them.
+// dst never shares objects or arrays with src, which is the config and used by every session.
+func mergeInto(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeInto(dstMap, srcMap)
+			continue
+		}
+		dst[key] = copyJSONValue(value)
+	}
+}
+
+// copyJSONValue returns a deep copy of a decoded JSON or YAML value.
+func copyJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = copyJSONValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = copyJSONValue(item)
+		}
+		return out
+	}
+	return value
+}
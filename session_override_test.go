@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRewriteSessionUpdateLeavesConfigUnchanged(t *testing.T) {
+	cfg := SessionOverrideConfig{
+		Defaults: map[string]interface{}{"turn_detection": map[string]interface{}{"type": "server_vad", "threshold": 0.5}},
+		Set:      map[string]interface{}{"turn_detection": map[string]interface{}{"silence_duration_ms": 800}},
+	}
+	want := map[string]interface{}{"turn_detection": map[string]interface{}{"type": "server_vad", "threshold": 0.5}}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rewritten, changed, err := rewriteSessionUpdate([]byte(`{"type":"session.update","session":{}}`), cfg)
+			if err != nil || !changed {
+				t.Errorf("rewrite = %v, %v", changed, err)
+				return
+			}
+			var event struct {
+				Session map[string]map[string]interface{} `json:"session"`
+			}
+			json.Unmarshal(rewritten, &event)
+			if got := event.Session["turn_detection"]; got["type"] != "server_vad" || got["silence_duration_ms"] != 800.0 {
+				t.Errorf("turn_detection = %v", got)
+			}
+		}()
+	}
+	wg.Wait()
+	if !reflect.DeepEqual(cfg.Defaults, want) {
+		t.Errorf("defaults changed to %v", cfg.Defaults)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// --- Client Simulator ---
+
+// simulateOptions are the settings of the simulate command, which plays the client side of a recording
+// against a realtime endpoint: the mock, the proxy or the real API.
+type simulateOptions struct {
+	Target     string        // WebSocket URL of the endpoint
+	Model      string        // Sent as ?model=, default the model of the recording
+	APIVersion string        // "beta" sends OpenAI-Beta: realtime=v1
+	Speed      float64       // 1 keeps the recorded timing, 2 plays twice as fast, 0 sends without pauses
+	Clients    int           // Sessions played in parallel
+	Linger     time.Duration // How long the server may stay quiet after the last message before the session is closed
+	Out        string        // Combined recording of what was sent and received, suffixed _1, _2, ... with several clients
+}
+
+// simulatedMessage is a client message of a recording with its time from the first one.
+type simulatedMessage struct {
+	offset      time.Duration
+	messageType int
+	data        []byte
+	close       *closeInfo // Set for a close frame, which ends the session
+}
+
+// readClientMessages returns what the client sent in a recording: the lines tagged "client" of a
+// combined recording, or every line of an inbound one.
+func readClientMessages(path string) ([]simulatedMessage, error) {
+	if meta := readRecordingMeta(path); meta != nil && meta.Kind == "outbound" {
+		return nil, fmt.Errorf("%s holds server messages only, use a session or inbound recording", path)
+	}
+	file, err := openRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 1024 * 1024 * 10 // 10MB, audio chunks can be large
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+	var events []RecordedEvent
+	combined := false
+	for scanner.Scan() {
+		var event RecordedEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Data == nil {
+			continue
+		}
+		combined = combined || event.Direction != ""
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var messages []simulatedMessage
+	var clock replayClock // Timestamps that go back don't pause
+	var offset time.Duration
+	for _, event := range events {
+		if combined && event.Direction != directionClient {
+			continue
+		}
+		offset += clock.wait(event.Timestamp)
+		message := simulatedMessage{offset: offset, messageType: websocket.TextMessage, data: event.Data}
+		switch event.Frame {
+		case binaryFrameType:
+			var payload string
+			json.Unmarshal(event.Data, &payload)
+			if event.Bytes > 0 && payload == "" {
+				continue // Stripped audio
+			}
+			message.messageType = websocket.BinaryMessage
+			if message.data, err = base64.StdEncoding.DecodeString(payload); err != nil {
+				continue
+			}
+		case closeFrameType:
+			var info closeInfo
+			json.Unmarshal(event.Data, &info)
+			message.close = &info
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("%s has no client messages", path)
+	}
+	if !combined {
+		var base BaseEvent
+		json.Unmarshal(messages[0].data, &base)
+		if base.Type == "session.created" {
+			return nil, fmt.Errorf("%s holds server messages only, use a session or inbound recording", path)
+		}
+	}
+	return messages, nil
+}
+
+// simulationResult is what one simulated client saw.
+type simulationResult struct {
+	client   int
+	sent     int
+	received map[string]int // Server events by type
+	duration time.Duration
+	closed   closeInfo
+	err      error
+}
+
+func (r simulationResult) String() string {
+	if r.err != nil {
+		return fmt.Sprintf("Client %d: %v", r.client, r.err)
+	}
+	total := 0
+	for _, n := range r.received {
+		total += n
+	}
+	return fmt.Sprintf("Client %d: sent %d messages, received %d events (%d responses, %d errors) in %s, closed %d",
+		r.client, r.sent, total, r.received["response.done"], r.received["error"], r.duration.Round(time.Millisecond), r.closed.Code)
+}
+
+// simulateClient plays the messages in one session and waits for the server to go quiet.
+func simulateClient(client int, messages []simulatedMessage, dialURL string, opts simulateOptions, rec *Recorder) simulationResult {
+	result := simulationResult{client: client, received: make(map[string]int)}
+	header := upstreamHeader(UpstreamTarget{APIVersion: opts.APIVersion}, os.Getenv("OPENAI_API_KEY"))
+	if os.Getenv("OPENAI_API_KEY") == "" {
+		header.Del("Authorization")
+	}
+	conn, err := dialWebSocket(dialURL, header)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	var lastEvent atomic.Int64
+	lastEvent.Store(start.UnixNano())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				result.closed = closeFromError(err)
+				if rec != nil {
+					rec.RecordClose(directionServer, result.closed)
+				}
+				return
+			}
+			lastEvent.Store(time.Now().UnixNano())
+			if rec != nil {
+				rec.RecordFrame(directionServer, messageType, data)
+			}
+			if messageType == websocket.TextMessage {
+				var base BaseEvent
+				json.Unmarshal(data, &base)
+				result.received[base.Type]++
+			}
+		}
+	}()
+
+	// The session ends like the recorded one, or with a normal closure, once the server went quiet
+	closeWith := closeInfo{Code: websocket.CloseNormalClosure}
+	interrupted := false
+send:
+	for _, message := range messages {
+		if opts.Speed > 0 {
+			wait := time.Until(start.Add(time.Duration(float64(message.offset) / opts.Speed)))
+			select {
+			case <-time.After(wait):
+			case <-done:
+				interrupted = true
+				break send
+			}
+		}
+		if message.close != nil {
+			closeWith = *message.close
+			break
+		}
+		if err := conn.WriteMessage(message.messageType, message.data); err != nil {
+			interrupted = true
+			break
+		}
+		if rec != nil {
+			rec.RecordFrame(directionClient, message.messageType, message.data)
+		}
+		result.sent++
+	}
+
+	if !interrupted {
+		ticker := time.NewTicker(100 * time.Millisecond)
+	linger:
+		for {
+			select {
+			case <-done:
+				break linger
+			case <-ticker.C:
+				if time.Since(time.Unix(0, lastEvent.Load())) >= opts.Linger {
+					if rec != nil {
+						rec.RecordClose(directionClient, closeWith)
+					}
+					writeCloseFrame(conn, closeWith)
+					break linger
+				}
+			}
+		}
+		ticker.Stop()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second): // The server never answered the close frame
+		conn.Close()
+		<-done
+	}
+	result.duration = time.Since(start)
+	if interrupted {
+		result.err = fmt.Errorf("server closed the session after %d of %d messages (%d %s)",
+			result.sent, len(messages), result.closed.Code, result.closed.Reason)
+	}
+	return result
+}
+
+// simulateCommand plays the client side of a recording against opts.Target for the simulate command,
+// failing if a session could not be played to its end.
+func simulateCommand(path string, opts simulateOptions) error {
+	if err := validateAPIVersion("-api-version", opts.APIVersion, false); err != nil {
+		return err
+	}
+	if opts.Clients < 1 || opts.Speed < 0 {
+		return fmt.Errorf("-clients must be at least 1 and -speed must not be negative")
+	}
+	messages, err := readClientMessages(path)
+	if err != nil {
+		return err
+	}
+
+	dialURL := opts.Target
+	model := opts.Model
+	if meta := readRecordingMeta(path); model == "" && meta != nil {
+		model = meta.Model
+	}
+	if model != "" && !strings.Contains(opts.Target, "model=") {
+		if dialURL, err = upstreamURL(UpstreamTarget{URL: opts.Target}, model); err != nil {
+			return fmt.Errorf("invalid -target: %w", err)
+		}
+	}
+
+	recorders := make([]*Recorder, opts.Clients)
+	if opts.Out != "" {
+		dir, name := filepath.Split(strings.TrimSuffix(opts.Out, ".ndjson"))
+		for i := range recorders {
+			outName := name
+			if opts.Clients > 1 {
+				outName = fmt.Sprintf("%s_%d", name, i+1)
+			}
+			if _, exists := recordingFile(filepath.Join(dir, outName+".ndjson")); exists {
+				return fmt.Errorf("%s.ndjson already exists", filepath.Join(dir, outName))
+			}
+			rec, err := newRecorderIn(cmp.Or(dir, "."), "simulated", outName)
+			if err != nil {
+				return err
+			}
+			meta := newRecordingMeta("session", "simulate")
+			meta.Target, meta.Model, meta.Replay = opts.Target, model, filepath.Base(path)
+			rec.SetMeta(meta)
+			recorders[i] = rec
+		}
+	}
+
+	log.Printf("Simulating %d client(s) with %d messages from %s against %s", opts.Clients, len(messages), path, dialURL)
+	results := make([]simulationResult, opts.Clients)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = simulateClient(i+1, messages, dialURL, opts, recorders[i])
+			if recorders[i] != nil {
+				recorders[i].Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	received := make(map[string]int)
+	for _, result := range results {
+		log.Print(result)
+		if result.err != nil {
+			failed++
+		}
+		for eventType, n := range result.received {
+			received[eventType] += n
+		}
+	}
+	if opts.Clients > 1 {
+		types := make([]string, 0, len(received))
+		for eventType := range received {
+			types = append(types, eventType)
+		}
+		sort.Strings(types)
+		for _, eventType := range types {
+			log.Printf("  %-50s %d", eventType, received[eventType])
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d clients failed", failed, opts.Clients)
+	}
+	return nil
+}
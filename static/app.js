@@ -8,6 +8,11 @@ document.addEventListener('DOMContentLoaded', () => {
     const viewerTitle = document.getElementById('viewer-title');
     const viewerContent = document.getElementById('viewer-content');
     const closeViewerBtn = document.getElementById('close-viewer');
+    const liveSessionsList = document.getElementById('live-sessions');
+    const activityFeed = document.getElementById('activity-feed');
+    const clearActivityBtn = document.getElementById('clear-activity');
+    let followSource = null; // EventSource of a recording being followed
+    let serverConfig = null; // From /config, the realtime port may differ from the page's
 
     // Helper to format JSON
     function syntaxHighlight(json) {
@@ -37,6 +42,7 @@ document.addEventListener('DOMContentLoaded', () => {
             const res = await fetch('/config');
             if (!res.ok) throw new Error('Failed to fetch config');
             const config = await res.json();
+            serverConfig = config;
 
             renderConfig(config);
             renderScenarios(config.scenarios);
@@ -102,19 +108,21 @@ document.addEventListener('DOMContentLoaded', () => {
         // Sort by name (assuming timestamped names) descending
         recordings.sort((a, b) => b.name.localeCompare(a.name));
 
-        recordingsList.innerHTML = recordings.map(r => `
+        recordingsList.innerHTML = recordings.map(r => r.files ? sessionRows(r) : `
             <tr class="hover:bg-gray-700 transition">
-                <td class="py-2 font-mono text-xs text-gray-300 truncate max-w-xs" title="${r.name}">${r.name}</td>
+                <td class="py-2 font-mono text-xs text-gray-300 truncate max-w-xs" title="${r.name}">${r.live ? '<span class="text-red-400 font-semibold mr-1">LIVE</span>' : ''}${r.name}</td>
                 <td class="py-2 text-right text-xs text-gray-400">${formatBytes(r.size)}</td>
                 <td class="py-2 text-right">
-                    <button class="text-xs bg-blue-600 hover:bg-blue-500 text-white px-2 py-1 rounded view-recording-btn" data-name="${r.name}">View</button>
+                    <button class="text-xs bg-blue-600 hover:bg-blue-500 text-white px-2 py-1 rounded view-recording-btn" data-name="${r.name}" data-live="${r.live ? 'true' : ''}">${r.live ? 'Follow' : 'View'}</button>
                     <button class="text-xs bg-green-600 hover:bg-green-500 text-white px-2 py-1 rounded replay-btn ml-1" data-name="${r.name}">Replay</button>
                 </td>
             </tr>
         `).join('');
 
         document.querySelectorAll('.view-recording-btn').forEach(btn => {
-            btn.addEventListener('click', (e) => viewRecording(e.target.dataset.name));
+            btn.addEventListener('click', (e) => e.target.dataset.live
+                ? followRecording(e.target.dataset.name)
+                : viewRecording(e.target.dataset.name));
         });
 
         document.querySelectorAll('.replay-btn').forEach(btn => {
@@ -122,13 +130,96 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
+    // A session directory is one row with its files below it. Following or replaying the session uses its main recording.
+    function sessionRows(r) {
+        const files = r.files.filter(f => f.name.includes('.ndjson'));
+        return `
+            <tr class="hover:bg-gray-700 transition">
+                <td class="py-2 font-mono text-xs text-gray-300 truncate max-w-xs" title="${r.name}">${r.live ? '<span class="text-red-400 font-semibold mr-1">LIVE</span>' : ''}<span class="text-purple-400 font-semibold mr-1">SESSION</span>${r.name}</td>
+                <td class="py-2 text-right text-xs text-gray-400">${formatBytes(r.size)}</td>
+                <td class="py-2 text-right">
+                    ${r.live ? `<button class="text-xs bg-blue-600 hover:bg-blue-500 text-white px-2 py-1 rounded view-recording-btn" data-name="${r.name}" data-live="true">Follow</button>` : ''}
+                    <button class="text-xs bg-green-600 hover:bg-green-500 text-white px-2 py-1 rounded replay-btn ml-1" data-name="${r.name}">Replay</button>
+                </td>
+            </tr>
+        ` + files.map(f => `
+            <tr class="hover:bg-gray-700 transition">
+                <td class="py-1 pl-6 font-mono text-xs text-gray-400 truncate max-w-xs" title="${f.name}">${f.name}</td>
+                <td class="py-1 text-right text-xs text-gray-500">${formatBytes(f.size)}</td>
+                <td class="py-1 text-right">
+                    <button class="text-xs bg-blue-600 hover:bg-blue-500 text-white px-2 py-1 rounded view-recording-btn" data-name="${r.name}/files/${f.name}">View</button>
+                </td>
+            </tr>
+        `).join('');
+    }
+
+    // Shows a recording that is still being written, adding events as they are recorded
+    function followRecording(name) {
+        stopFollowing();
+        viewerTitle.textContent = `Recording: ${name} (live)`;
+        viewerContent.innerHTML = '<span class="text-gray-400">Waiting for events...</span>';
+        recordingViewer.classList.remove('hidden');
+
+        const events = [];
+        let renderPending = false;
+        followSource = new EventSource(withAdminToken(`/recordings/${name}/follow`));
+        followSource.onmessage = (e) => {
+            try {
+                events.push(JSON.parse(e.data));
+            } catch (err) {
+                console.warn('Failed to parse line:', e.data);
+                return;
+            }
+            // Audio arrives in bursts, render at most a few times per second
+            if (!renderPending) {
+                renderPending = true;
+                setTimeout(() => {
+                    renderPending = false;
+                    renderEvents(events);
+                    viewerContent.scrollTop = viewerContent.scrollHeight;
+                }, 250);
+            }
+        };
+        followSource.addEventListener('end', () => {
+            stopFollowing();
+            viewerTitle.textContent = `Recording: ${name}`;
+            renderEvents(events);
+            fetchRecordings();
+        });
+        followSource.onerror = () => {
+            stopFollowing();
+            viewerTitle.textContent = `Recording: ${name} (disconnected)`;
+        };
+    }
+
+    // Encrypted recordings are only readable with the admin token, asked for once per browser session
+    function withAdminToken(url) {
+        const token = sessionStorage.getItem('recordingAdminToken');
+        return token ? `${url}?token=${encodeURIComponent(token)}` : url;
+    }
+
+    function stopFollowing() {
+        if (followSource) {
+            followSource.close();
+            followSource = null;
+        }
+    }
+
     async function viewRecording(name) {
+        stopFollowing();
         viewerTitle.textContent = `Recording: ${name}`;
         viewerContent.innerHTML = '<span class="text-gray-400">Loading...</span>';
         recordingViewer.classList.remove('hidden');
 
         try {
-            const res = await fetch(`/recordings/${name}`);
+            let res = await fetch(withAdminToken(`/recordings/${name}`));
+            if (res.status === 401) {
+                const token = prompt('Recordings are encrypted. Admin token:');
+                if (token) {
+                    sessionStorage.setItem('recordingAdminToken', token);
+                    res = await fetch(withAdminToken(`/recordings/${name}`));
+                }
+            }
             if (!res.ok) throw new Error('Failed to load recording');
             const text = await res.text();
 
@@ -258,7 +349,9 @@ document.addEventListener('DOMContentLoaded', () => {
 
     function replayRecording(name) {
         // Just show an alert for now, or maybe copy the replay URL
-        const replayUrl = `ws://${window.location.host}/v1/realtime?replaySession=${name}`;
+        const scheme = window.location.protocol === 'https:' ? 'wss' : 'ws';
+        const host = serverConfig ? `${window.location.hostname}:${serverConfig.server.port}` : window.location.host;
+        const replayUrl = `${scheme}://${host}/v1/realtime?replaySession=${name}`;
         alert(`To replay this session, connect to:\n${replayUrl}`);
     }
 
@@ -271,6 +364,119 @@ document.addEventListener('DOMContentLoaded', () => {
         return parseFloat((bytes / Math.pow(k, i)).toFixed(dm)) + ' ' + sizes[i];
     }
 
+    // --- Live Activity ---
+
+    const liveSessions = new Map(); // Session ID -> {info, progress}
+    const maxActivityLines = 300;
+
+    // Follows /feed: connects, disconnects, event types, scenario progress and errors of all sessions.
+    // EventSource reconnects on its own and resumes after the last entry it received.
+    async function followActivity() {
+        // Sessions that connected before the feed's history starts
+        try {
+            const res = await fetch('/sessions');
+            if (res.ok) {
+                for (const info of await res.json()) {
+                    liveSessions.set(info.id, { info, progress: '' });
+                }
+                renderLiveSessions();
+            }
+        } catch (err) {
+            console.error(err);
+        }
+
+        const feed = new EventSource('/feed');
+        feed.onopen = () => updateStatus(true);
+        feed.onerror = () => updateStatus(false);
+        feed.onmessage = (e) => {
+            let entry;
+            try {
+                entry = JSON.parse(e.data);
+            } catch (err) {
+                console.warn('Failed to parse feed entry:', e.data);
+                return;
+            }
+            trackSession(entry);
+            appendActivity(entry);
+        };
+    }
+
+    function trackSession(entry) {
+        switch (entry.kind) {
+            case 'session.connected':
+                liveSessions.set(entry.session_id, { info: entry.session, progress: '' });
+                break;
+            case 'session.disconnected':
+                liveSessions.delete(entry.session_id);
+                fetchRecordings();
+                break;
+            case 'scenario': {
+                const live = liveSessions.get(entry.session_id);
+                if (live) live.progress = `${escapeHtml(entry.scenario)} ${entry.step}/${entry.steps} ${entry.status}`;
+                break;
+            }
+            default:
+                return;
+        }
+        renderLiveSessions();
+    }
+
+    function renderLiveSessions() {
+        if (liveSessions.size === 0) {
+            liveSessionsList.innerHTML = '<li class="text-gray-500 italic">No live sessions</li>';
+            return;
+        }
+        liveSessionsList.innerHTML = [...liveSessions.entries()].map(([id, live]) => `
+            <li class="bg-gray-700 px-2 py-1 rounded flex justify-between">
+                <span class="font-mono text-gray-300 truncate" title="${escapeHtml(id)}">${escapeHtml(live.info.correlation_id || id)}</span>
+                <span class="text-gray-400">${live.info.mode}${live.info.scenario ? ' &middot; ' + escapeHtml(live.info.scenario) : ''}${live.info.target ? ' &middot; ' + escapeHtml(live.info.target) : ''}</span>
+                <span class="text-yellow-300">${live.progress}</span>
+            </li>
+        `).join('');
+    }
+
+    // Event types and error messages come from clients and upstreams
+    function escapeHtml(text) {
+        return String(text ?? '').replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+    }
+
+    function appendActivity(entry) {
+        const time = new Date(entry.time).toLocaleTimeString();
+        const who = escapeHtml(entry.correlation_id || entry.session_id);
+        let text, cls;
+        switch (entry.kind) {
+            case 'session.connected':
+                text = `connected (${entry.session.mode})`;
+                cls = 'text-green-400';
+                break;
+            case 'session.disconnected':
+                text = `disconnected after ${entry.session.events.client} client / ${entry.session.events.server} server events`;
+                cls = 'text-gray-400';
+                break;
+            case 'scenario':
+                text = `scenario ${escapeHtml(entry.scenario)} ${entry.status} ${entry.step}/${entry.steps} (${escapeHtml(entry.event_type)})`;
+                cls = 'text-purple-300';
+                break;
+            case 'error':
+                text = `${entry.direction} error ${escapeHtml(entry.code)}: ${escapeHtml(entry.error)}`;
+                cls = 'text-red-400';
+                break;
+            default:
+                text = `${entry.direction === 'client' ? '&rarr;' : '&larr;'} ${escapeHtml(entry.event_type)}`;
+                cls = entry.direction === 'client' ? 'text-blue-300' : 'text-gray-300';
+        }
+        const line = document.createElement('div');
+        line.className = cls;
+        line.innerHTML = `<span class="text-gray-500">${time}</span> <span class="text-gray-400">[${who}]</span> ${text}`;
+
+        const atBottom = activityFeed.scrollTop + activityFeed.clientHeight >= activityFeed.scrollHeight - 4;
+        activityFeed.appendChild(line);
+        while (activityFeed.childElementCount > maxActivityLines) {
+            activityFeed.firstElementChild.remove();
+        }
+        if (atBottom) activityFeed.scrollTop = activityFeed.scrollHeight;
+    }
+
     function updateStatus(connected) {
         if (connected) {
             connectionStatus.textContent = 'Connected';
@@ -282,12 +488,15 @@ document.addEventListener('DOMContentLoaded', () => {
     }
 
     closeViewerBtn.addEventListener('click', () => {
+        stopFollowing();
         recordingViewer.classList.add('hidden');
     });
 
     refreshRecordingsBtn.addEventListener('click', fetchRecordings);
+    clearActivityBtn.addEventListener('click', () => { activityFeed.innerHTML = ''; });
 
     // Initial load
     fetchConfig();
     fetchRecordings();
+    followActivity();
 });
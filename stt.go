@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// --- Input Transcription (STT) ---
+
+const (
+	defaultOpenAITranscriptionURL   = "https://api.openai.com/v1/audio/transcriptions"
+	defaultOpenAITranscriptionModel = "gpt-4o-mini-transcribe"
+	transcriptionTimeout            = 60 * time.Second
+)
+
+// Transcriber turns committed input audio into text.
+type Transcriber interface {
+	// Transcribe receives mono PCM16 audio at the given sample rate.
+	Transcribe(pcm []byte, sampleRate int) (string, error)
+}
+
+// inputTranscriber is the configured STT backend, nil when transcription is disabled.
+var inputTranscriber Transcriber
+
+// newTranscriber builds the Transcriber selected in the mock config.
+func newTranscriber(cfg TranscriptionConfig) (Transcriber, error) {
+	switch cfg.Backend {
+	case "", "none":
+		return nil, nil
+	case "whisper_cpp":
+		if cfg.WhisperBinary == "" || cfg.WhisperModel == "" {
+			return nil, fmt.Errorf("whisper_cpp backend requires whisperBinary and whisperModel")
+		}
+		return &whisperCppTranscriber{binary: cfg.WhisperBinary, model: cfg.WhisperModel}, nil
+	case "openai":
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("openai backend requires the OPENAI_API_KEY environment variable")
+		}
+		url := cfg.OpenAIURL
+		if url == "" {
+			url = defaultOpenAITranscriptionURL
+		}
+		model := cfg.OpenAIModel
+		if model == "" {
+			model = defaultOpenAITranscriptionModel
+		}
+		return &openAITranscriber{
+			url:    url,
+			model:  model,
+			apiKey: apiKey,
+			client: &http.Client{Timeout: transcriptionTimeout},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown transcription backend: %s", cfg.Backend)
+	}
+}
+
+// whisperCppTranscriber shells out to a whisper.cpp CLI binary (whisper-cli / main).
+type whisperCppTranscriber struct {
+	binary string
+	model  string
+}
+
+func (t *whisperCppTranscriber) Transcribe(pcm []byte, sampleRate int) (string, error) {
+	// whisper.cpp only accepts 16kHz WAV input
+	wav := encodeWAV(resamplePCM16(pcm, sampleRate, 16000), 16000)
+
+	tmp, err := os.CreateTemp("", "mock-stt-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp WAV: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(wav); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temp WAV: %w", err)
+	}
+	tmp.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcriptionTimeout)
+	defer cancel()
+
+	// -nt: no timestamps, -np: no progress/system prints, so stdout is just the text
+	cmd := exec.CommandContext(ctx, t.binary, "-m", t.model, "-f", tmp.Name(), "-nt", "-np")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("whisper.cpp failed: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// openAITranscriber calls the OpenAI audio transcription REST API.
+type openAITranscriber struct {
+	url    string
+	model  string
+	apiKey string
+	client *http.Client
+}
+
+func (t *openAITranscriber) Transcribe(pcm []byte, sampleRate int) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", t.model)
+	form.WriteField("response_format", "json")
+	part, err := form.CreateFormFile("file", "input.wav")
+	if err != nil {
+		return "", err
+	}
+	part.Write(encodeWAV(pcm, sampleRate))
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPost, t.url, &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("transcription request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("transcription API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("failed to parse transcription response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// transcribeInputAudio runs the configured backend over PCM16 input audio.
+func transcribeInputAudio(pcm []byte, sampleRate int) (string, error) {
+	if inputTranscriber == nil {
+		return "", fmt.Errorf("no transcription backend configured")
+	}
+
+	start := time.Now()
+	text, err := inputTranscriber.Transcribe(pcm, sampleRate)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Transcribed %d bytes of input audio in %v: %q", len(pcm), time.Since(start).Round(time.Millisecond), text)
+	return text, nil
+}
+
+// transcriptionLogprobs fakes the logprobs of a transcript, one token per word,
+// each with the log of the configured confidence.
+func transcriptionLogprobs(transcript string, confidence float64) []map[string]interface{} {
+	logprob := math.Log(confidence)
+	logprobs := []map[string]interface{}{}
+	for i, word := range strings.Fields(transcript) {
+		token := word
+		if i > 0 {
+			token = " " + word
+		}
+		tokenBytes := make([]int, 0, len(token))
+		for _, b := range []byte(token) {
+			tokenBytes = append(tokenBytes, int(b))
+		}
+		logprobs = append(logprobs, map[string]interface{}{
+			"token":   token,
+			"logprob": logprob,
+			"bytes":   tokenBytes,
+		})
+	}
+	return logprobs
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// --- Tenants ---
+
+// Tenants share one mock without sharing scenario names. Each has its own scenarios, recordings
+// and audio, and defaults for its sessions. Clients pick theirs with the X-Mock-Tenant header or
+// the /tenants/{name}/v1/... path prefix, which browsers can use too.
+
+const tenantHeader = "X-Mock-Tenant"
+
+// TenantConfig is a tenant of the mock. Settings left empty are the top-level ones.
+type TenantConfig struct {
+	Scenarios []Scenario `yaml:"scenarios" json:"scenarios"`
+	// Where replays are looked up and inbound recordings written, like proxy.recordingPath
+	RecordingPath string `yaml:"recordingPath" json:"recordingPath" redact:"path"`
+	// Audio assets like mock.audioWavPath and mock.voiceAudioDirs
+	AudioWavPath   string            `yaml:"audioWavPath" json:"audioWavPath" redact:"path"`
+	VoiceAudioDirs map[string]string `yaml:"voiceAudioDirs,omitempty" json:"voiceAudioDirs,omitempty" redact:"path"`
+	// Session defaults like those of mock
+	ResponseDelaySeconds *int           `yaml:"responseDelaySeconds,omitempty" json:"responseDelaySeconds,omitempty"`
+	AudioProfile         string         `yaml:"audioProfile" json:"audioProfile"`
+	TurnDetection        *TurnDetection `yaml:"turnDetection,omitempty" json:"turnDetection,omitempty"`
+}
+
+func validateTenants(tenants map[string]TenantConfig) error {
+	for name, tenant := range tenants {
+		if name == "" || strings.ContainsAny(name, "/?#") {
+			return fmt.Errorf("tenants: invalid name %q", name)
+		}
+		if err := validateScenarios(tenant.Scenarios); err != nil {
+			return fmt.Errorf("tenants.%s: %w", name, err)
+		}
+		if tenant.AudioProfile != "" {
+			if _, err := lookupAudioProfile(tenant.AudioProfile); err != nil {
+				return fmt.Errorf("tenants.%s.audioProfile: %w", name, err)
+			}
+		}
+		if td := tenant.TurnDetection; td != nil && td.Type != "" {
+			if param, err := td.validate(); err != nil {
+				return fmt.Errorf("invalid tenants.%s.turnDetection (%s): %w", name, param, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolvePaths makes the tenant's paths relative to the config file absolute.
+func (t *TenantConfig) resolvePaths(configDir string) {
+	for _, path := range []*string{&t.RecordingPath, &t.AudioWavPath} {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(configDir, *path)
+		}
+	}
+	for voice, dir := range t.VoiceAudioDirs {
+		if dir != "" && !filepath.IsAbs(dir) {
+			t.VoiceAudioDirs[voice] = filepath.Join(configDir, dir)
+		}
+	}
+}
+
+// requestTenant returns the tenant a request asked for, nil without one.
+func requestTenant(r *http.Request) (*TenantConfig, error) {
+	name := r.Header.Get(tenantHeader)
+	if name == "" {
+		return nil, nil
+	}
+	tenant, ok := requestConfig(r).Tenants[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant %s", name)
+	}
+	return &tenant, nil
+}
+
+// applyTenant gives a session the defaults of its tenant.
+func (s *MockSession) applyTenant(t *TenantConfig) {
+	if t == nil {
+		return
+	}
+	s.Tenant = t
+	if t.AudioProfile != "" {
+		if err := s.setAudioProfile(t.AudioProfile); err != nil {
+			s.logf("Tenant: %v. Keeping default audio profile.", err)
+		}
+	}
+	if td := t.TurnDetection; td != nil && td.Type != "" {
+		s.TurnDetection = nil
+		if td.Type != "none" {
+			s.TurnDetection = td.withDefaults()
+		}
+	}
+}
+
+// The settings below fall back to those of cfg, the config a session started with, outside
+// tenants and for what the tenant leaves out.
+
+// scenarios returns the scenarios of the tenant's sessions.
+func (t *TenantConfig) scenarios(cfg *Config) []Scenario {
+	if t == nil || len(t.Scenarios) == 0 {
+		return configScenarios(cfg)
+	}
+	return t.Scenarios
+}
+
+// findScenario looks up a scenario of the tenant by name.
+func (t *TenantConfig) findScenario(cfg *Config, name string) (Scenario, bool) {
+	for _, s := range t.scenarios(cfg) {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// responseDelay returns how long the tenant's scenarios wait before responding.
+func (t *TenantConfig) responseDelay(cfg *Config) time.Duration {
+	seconds := cfg.Mock.ResponseDelaySeconds
+	if t != nil && t.ResponseDelaySeconds != nil {
+		seconds = *t.ResponseDelaySeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// recordingPath returns the directory of the tenant's recordings.
+func (t *TenantConfig) recordingPath(cfg *Config) string {
+	if t == nil || t.RecordingPath == "" {
+		return cfg.Proxy.RecordingPath
+	}
+	return t.RecordingPath
+}
+
+// ownRecordings reports whether the tenant keeps its recordings apart, in its recordingPath.
+func (t *TenantConfig) ownRecordings() bool {
+	return t != nil && t.RecordingPath != ""
+}
+
+// newRecorder opens a recording of the tenant's sessions. Those of a tenant with its own
+// recordingPath stay files there, out of the storage backend and bucket the others share.
+func (t *TenantConfig) newRecorder(cfg *Config, prefix, name string) (*Recorder, error) {
+	if !t.ownRecordings() {
+		return NewRecorder(cfg.Proxy.RecordingPath, prefix, name)
+	}
+	return newFileRecorder(filepath.Join(t.RecordingPath, "recorded"), prefix, name, true)
+}
+
+// audioPath returns the WAV asset to play for a voice of the tenant's sessions.
+func (t *TenantConfig) audioPath(cfg *Config, voice string) string {
+	if t == nil || t.AudioWavPath == "" {
+		return cfg.Mock.voiceAudioPath(voice)
+	}
+	return voiceAudioFile(t.AudioWavPath, t.VoiceAudioDirs, voice)
+}
+
+// withTenantPrefix serves /tenants/{tenant}/v1/... as /v1/... of the tenant.
+func withTenantPrefix(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("tenant")
+		r = r.Clone(r.Context())
+		r.Header.Set(tenantHeader, name)
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, "/tenants/"+name)
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMockRejectsReplayPaths(t *testing.T) {
+	for _, name := range []string{"../other/recorded/rec", "recorded/rec", "/tmp/rec", ".."} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/realtime?replaySession="+name, nil)
+			w := httptest.NewRecorder()
+			handleMockWebSocket(w, r)
+			// Refused before the upgrade, which would fail with 400 too
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid replaySession") {
+				t.Errorf("status %d %q, want the name refused", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// appendOnlyStore is a storage backend that only counts what is appended to it.
+type appendOnlyStore struct{ appended int }
+
+func (s *appendOnlyStore) append(string, []byte) error           { s.appended++; return nil }
+func (s *appendOnlyStore) saveMeta(string, *RecordingMeta) error { return nil }
+func (s *appendOnlyStore) stat(string) (RecordingFile, bool)     { return RecordingFile{}, false }
+func (s *appendOnlyStore) list() ([]RecordingFile, error)        { return nil, nil }
+func (s *appendOnlyStore) remove(string) error                   { return nil }
+func (s *appendOnlyStore) open(string, eventFilter) (io.ReadCloser, error) {
+	return nil, errors.New("not stored")
+}
+
+func TestTenantRecordingsStayInTheirDirectory(t *testing.T) {
+	store := &appendOnlyStore{}
+	defer func(saved recordingStore) { eventStore = saved }(eventStore)
+	eventStore = store
+
+	tenant := &TenantConfig{RecordingPath: t.TempDir()}
+	rec, err := tenant.newRecorder(currentConfig(), "inbound", "inbound_rec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.RecordMessage([]byte(`{"type":"session.update"}`))
+	rec.Close()
+
+	if store.appended != 0 {
+		t.Errorf("%d lines went to the shared store", store.appended)
+	}
+	if _, err := os.Stat(filepath.Join(tenant.RecordingPath, "recorded", "inbound_rec.ndjson")); err != nil {
+		t.Errorf("recording not in the tenant's directory: %v", err)
+	}
+
+	// Tenants without a directory of their own share the top-level recordings
+	var shared *TenantConfig
+	if rec, err = shared.newRecorder(currentConfig(), "inbound", "inbound_shared"); err != nil {
+		t.Fatal(err)
+	}
+	rec.RecordMessage([]byte(`{"type":"session.update"}`))
+	rec.Close()
+	if store.appended != 1 {
+		t.Errorf("%d lines went to the shared store, want 1", store.appended)
+	}
+}
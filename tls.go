@@ -0,0 +1,83 @@
+package main
+
+import (
+	"cmp"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net/http"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// --- TLS Listener ---
+
+// TLSConfig serves wss:// and https:// instead of plaintext, with a certificate from files
+// or one obtained from Let's Encrypt for the configured hostnames.
+type TLSConfig struct {
+	CertFile string         `yaml:"certFile" json:"certFile" redact:"path"` // PEM certificate chain
+	KeyFile  string         `yaml:"keyFile" json:"keyFile" redact:"path"`
+	Autocert AutocertConfig `yaml:"autocert" json:"autocert"`
+}
+
+// AutocertConfig gets certificates from Let's Encrypt with the TLS-ALPN challenge, which
+// needs the server reachable on port 443 under every host.
+type AutocertConfig struct {
+	Hosts    []string `yaml:"hosts" json:"hosts"`
+	Email    string   `yaml:"email" json:"email"`                     // Contact for expiry notices, optional
+	CacheDir string   `yaml:"cacheDir" json:"cacheDir" redact:"path"` // Default autocert-cache, keeps certificates across restarts
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || len(c.Autocert.Hosts) > 0
+}
+
+func (c TLSConfig) validate() error {
+	switch {
+	case (c.CertFile == "") != (c.KeyFile == ""):
+		return fmt.Errorf("server.tls needs both certFile and keyFile")
+	case c.CertFile != "" && len(c.Autocert.Hosts) > 0:
+		return fmt.Errorf("server.tls takes certFile/keyFile or autocert, not both")
+	}
+	return nil
+}
+
+func (c AutocertConfig) cacheDir() string {
+	return cmp.Or(c.CacheDir, "autocert-cache")
+}
+
+// serverTLSConfig builds the listener's TLS settings, nil without TLS.
+func (c TLSConfig) serverTLSConfig() (*tls.Config, error) {
+	if !c.enabled() {
+		return nil, nil
+	}
+	if len(c.Autocert.Hosts) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.Autocert.Hosts...),
+			Email:      c.Autocert.Email,
+			Cache:      autocert.DirCache(c.Autocert.cacheDir()),
+		}
+		log.Printf("TLS: Certificates for %v from Let's Encrypt, cached in %s", c.Autocert.Hosts, c.Autocert.cacheDir())
+		return manager.TLSConfig(), nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server.tls certificate: %w", err)
+	}
+	log.Printf("TLS: Certificate from %s", c.CertFile)
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// listenAndServe serves handler on addr, over TLS if server.tls is configured.
+func listenAndServe(addr string, handler http.Handler) error {
+	tlsConfig, err := appConfig.Server.TLS.serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+	if tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// --- Transcription Sessions ---
+
+// transcriptionIntent is the ?intent= of a transcription-only connection. It accepts input
+// audio and answers every committed turn with transcription events, never with a response.
+const transcriptionIntent = "transcription"
+
+// Default model announced for transcription sessions
+const defaultTranscriptionModel = "gpt-4o-transcribe"
+
+func isTranscriptionSession(r *http.Request) bool {
+	return r.URL.Query().Get("intent") == transcriptionIntent
+}
+
+// InputAudioTranscription is the input_audio_transcription object of a session.
+type InputAudioTranscription struct {
+	Model    string `json:"model"`
+	Language string `json:"language,omitempty"`
+	Prompt   string `json:"prompt,omitempty"`
+}
+
+// TranscriptionSessionObject is the session of transcription_session.created/updated and
+// POST /v1/realtime/transcription_sessions.
+type TranscriptionSessionObject struct {
+	ID                      string                   `json:"id"`
+	Object                  string                   `json:"object"` // "realtime.transcription_session"
+	ClientSecret            *ClientSecret            `json:"client_secret,omitempty"`
+	InputAudioFormat        string                   `json:"input_audio_format"`
+	InputAudioTranscription *InputAudioTranscription `json:"input_audio_transcription"`
+	TurnDetection           *TurnDetection           `json:"turn_detection"`
+	Include                 []string                 `json:"include,omitempty"`
+}
+
+// Committed turns waiting for the transcription backend before further ones fail
+const transcriptionBacklog = 16
+
+// transcriptionTurn is committed audio waiting to be transcribed.
+type transcriptionTurn struct {
+	itemID string
+	audio  []byte
+}
+
+// startTranscription makes the session transcription-only. Turns are transcribed by the
+// mock.transcription backend if there is one, else they get the scenario's texts in turn.
+// Server VAD is on unless mock.turnDetection says otherwise, as with the real API.
+func (s *MockSession) startTranscription(scenario Scenario) {
+	s.Transcription = true
+	s.InputTranscription = &InputAudioTranscription{Model: defaultTranscriptionModel}
+	if s.config.Mock.TurnDetection == nil || s.config.Mock.TurnDetection.Type == "" {
+		s.TurnDetection = (&TurnDetection{Type: "server_vad"}).withDefaults()
+	}
+	s.transcripts = scenarioTranscripts(scenario)
+	s.transcriptionTurns = make(chan transcriptionTurn, transcriptionBacklog)
+	go func() {
+		for turn := range s.transcriptionTurns {
+			s.sendTranscription(turn)
+		}
+	}()
+}
+
+// stopTranscription ends the transcription of a session once its client is gone.
+func (s *MockSession) stopTranscription() {
+	if s.transcriptionTurns != nil {
+		close(s.transcriptionTurns)
+	}
+}
+
+// scenarioTranscripts returns the texts transcription turns get without a transcription
+// backend: the scenario's user_transcription texts, or its message texts if it has none.
+func scenarioTranscripts(scenario Scenario) []string {
+	var transcripts, messages []string
+	for _, event := range scenario.Events {
+		switch {
+		case event.Text == "":
+		case event.Type == "user_transcription":
+			transcripts = append(transcripts, event.Text)
+		case event.Type == "message":
+			messages = append(messages, event.Text)
+		}
+	}
+	if len(transcripts) == 0 {
+		return messages
+	}
+	return transcripts
+}
+
+func (s *MockSession) transcriptionSessionObject() TranscriptionSessionObject {
+	return TranscriptionSessionObject{
+		ID:                      s.ID,
+		Object:                  "realtime.transcription_session",
+		InputAudioFormat:        s.InputAudioFormat,
+		InputAudioTranscription: s.InputTranscription,
+		TurnDetection:           s.TurnDetection,
+	}
+}
+
+// sendTranscription transcribes a committed turn and streams the transcript as deltas, one
+// word per chunk interval, followed by the completed event.
+func (s *MockSession) sendTranscription(turn transcriptionTurn) {
+	var transcript string
+	if len(s.transcripts) > 0 {
+		transcript = s.transcripts[s.transcriptIndex%len(s.transcripts)]
+		s.transcriptIndex++
+	}
+	if len(turn.audio) > 0 && inputTranscriber != nil {
+		text, err := transcribeInputAudio(decodeToPCM16(turn.audio, s.InputAudioFormat), s.inputSampleRate())
+		if err != nil {
+			s.logf("Client %s: Input transcription failed: %v", s.Conn.RemoteAddr(), err)
+			sendJSONEvent(s.Conn, transcriptionFailedEvent(turn.itemID))
+			return
+		}
+		transcript = text
+	}
+
+	words := strings.SplitAfter(transcript, " ")
+	for i, word := range words {
+		if word == "" {
+			continue
+		}
+		if i > 0 {
+			time.Sleep(s.chunkInterval(Event{}))
+		}
+		delta := map[string]interface{}{
+			"type":          "conversation.item.input_audio_transcription.delta",
+			"event_id":      uuid.NewString(),
+			"item_id":       turn.itemID,
+			"content_index": 0,
+			"delta":         word,
+		}
+		if err := sendJSONEvent(s.Conn, delta); err != nil {
+			return
+		}
+	}
+	sendJSONEvent(s.Conn, transcriptionCompletedEvent(turn.itemID, transcript, s.config.Mock.Transcription.Confidence))
+}
+
+// handleCreateTranscriptionSession issues a token for a transcription session, echoing the
+// settings of the body. In proxy mode it is forwarded to the upstream.
+func handleCreateTranscriptionSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if connectionMode(r) == "proxy" {
+		proxySessionRequest(w, r)
+		return
+	}
+	session := TranscriptionSessionObject{
+		InputAudioFormat:        "pcm16",
+		InputAudioTranscription: &InputAudioTranscription{Model: defaultTranscriptionModel},
+		TurnDetection:           (&TurnDetection{Type: "server_vad"}).withDefaults(),
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&session); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !isSupportedAudioFormat(session.InputAudioFormat) {
+		http.Error(w, "Invalid 'input_audio_format': unsupported value '"+session.InputAudioFormat+"'.", http.StatusBadRequest)
+		return
+	}
+	session.ID = "mock-transcription-sess-" + uuid.NewString()
+	session.Object = "realtime.transcription_session"
+	session.ClientSecret = &ClientSecret{
+		Value:     "ek_mock_" + uuid.NewString(),
+		ExpiresAt: time.Now().Add(1 * time.Minute).Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+	log.Printf("Issued mock transcription session token for session: %s", session.ID)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// --- Event Transformation ---
+
+const transformMask = "***"
+
+// TransformRule rewrites proxied events whose type matches one of Types (path.Match globs,
+// empty matches all). Paths are dot-separated keys and array indexes, "*" matches every
+// element, e.g. "response.output.*.content.*.transcript". Actions run in the order
+// replace, set, mask, delete, renameType.
+type TransformRule struct {
+	Direction  string                       `yaml:"direction" json:"direction"` // "inbound" (client -> upstream), "outbound" (upstream -> client) or "both" (default)
+	Types      []string                     `yaml:"types,omitempty" json:"types,omitempty"`
+	Replace    map[string]map[string]string `yaml:"replace,omitempty" json:"replace,omitempty"` // Per path: old string value -> new value
+	Set        map[string]interface{}       `yaml:"set,omitempty" json:"set,omitempty"`         // Per path: value, missing objects are created
+	Mask       []string                     `yaml:"mask,omitempty" json:"mask,omitempty"`       // String values replaced by "***"
+	Delete     []string                     `yaml:"delete,omitempty" json:"delete,omitempty"`
+	RenameType string                       `yaml:"renameType,omitempty" json:"renameType,omitempty"`
+}
+
+func (t TransformRule) validate() error {
+	switch t.Direction {
+	case "", "both", "inbound", "outbound":
+	default:
+		return fmt.Errorf("unknown direction: %s", t.Direction)
+	}
+	for _, pattern := range t.Types {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	if len(t.Replace) == 0 && len(t.Set) == 0 && len(t.Mask) == 0 && len(t.Delete) == 0 && t.RenameType == "" {
+		return fmt.Errorf("rule has no action")
+	}
+	return nil
+}
+
+func (t TransformRule) appliesTo(direction, eventType string) bool {
+	if t.Direction != "" && t.Direction != "both" && t.Direction != direction {
+		return false
+	}
+	return len(t.Types) == 0 || matchesAny(t.Types, eventType)
+}
+
+// applyTransforms runs the rules for the direction ("inbound" or "outbound") over a JSON
+// event. Messages no rule applies to are returned untouched.
+func applyTransforms(rules []TransformRule, direction string, msg []byte) ([]byte, bool, error) {
+	if len(rules) == 0 {
+		return msg, false, nil
+	}
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		return msg, false, nil
+	}
+
+	changed := false
+	for _, rule := range rules {
+		eventType, _ := event["type"].(string)
+		if !rule.appliesTo(direction, eventType) {
+			continue
+		}
+		changed = true
+		rule.apply(event)
+	}
+	if !changed {
+		return msg, false, nil
+	}
+
+	rewritten, err := json.Marshal(event)
+	if err != nil {
+		return msg, false, fmt.Errorf("failed to re-encode transformed event: %w", err)
+	}
+	return rewritten, true, nil
+}
+
+func (t TransformRule) apply(event map[string]interface{}) {
+	for _, p := range sortedKeys(t.Replace) {
+		mapping := t.Replace[p]
+		eachPath(event, p, false, func(parent interface{}, key string) {
+			if value, ok := getAt(parent, key).(string); ok {
+				if replacement, ok := mapping[value]; ok {
+					setAt(parent, key, replacement)
+				}
+			}
+		})
+	}
+	for _, p := range sortedKeys(t.Set) {
+		value := t.Set[p]
+		eachPath(event, p, true, func(parent interface{}, key string) {
+			setAt(parent, key, value)
+		})
+	}
+	for _, p := range t.Mask {
+		eachPath(event, p, false, func(parent interface{}, key string) {
+			if value, ok := getAt(parent, key).(string); ok && value != "" {
+				setAt(parent, key, transformMask)
+			}
+		})
+	}
+	for _, p := range t.Delete {
+		eachPath(event, p, false, func(parent interface{}, key string) {
+			if object, ok := parent.(map[string]interface{}); ok {
+				delete(object, key)
+			}
+		})
+	}
+	if t.RenameType != "" {
+		event["type"] = t.RenameType
+	}
+}
+
+// eachPath calls fn with the containing object or array and the final key of every value the
+// path matches. With create, missing objects along a path without wildcards are created.
+func eachPath(node interface{}, p string, create bool, fn func(parent interface{}, key string)) {
+	walkPath(node, strings.Split(p, "."), create, fn)
+}
+
+func walkPath(node interface{}, segments []string, create bool, fn func(parent interface{}, key string)) {
+	segment, last := segments[0], len(segments) == 1
+	switch n := node.(type) {
+	case map[string]interface{}:
+		keys := []string{segment}
+		if segment == "*" {
+			keys = sortedKeys(n)
+		}
+		for _, key := range keys {
+			if last {
+				if _, ok := n[key]; ok || (create && segment != "*") {
+					fn(n, key)
+				}
+				continue
+			}
+			child, ok := n[key]
+			if !ok && create && segment != "*" {
+				child = map[string]interface{}{}
+				n[key] = child
+			}
+			walkPath(child, segments[1:], create, fn)
+		}
+	case []interface{}:
+		for i := range n {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				continue
+			}
+			if last {
+				fn(n, strconv.Itoa(i))
+			} else {
+				walkPath(n[i], segments[1:], create, fn)
+			}
+		}
+	}
+}
+
+func getAt(parent interface{}, key string) interface{} {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		return p[key]
+	case []interface{}:
+		i, _ := strconv.Atoi(key)
+		return p[i]
+	}
+	return nil
+}
+
+func setAt(parent interface{}, key string, value interface{}) {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[key] = value
+	case []interface{}:
+		i, _ := strconv.Atoi(key)
+		p[i] = value
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package main
+
+import "testing"
+
+func TestApplyTransforms(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  TransformRule
+		event string
+		want  string
+	}{
+		{
+			name:  "set nested path",
+			rule:  TransformRule{Set: map[string]interface{}{"session.audio.output.voice": "verse"}},
+			event: `{"type":"session.update","session":{"audio":{"output":{"voice":"alloy","speed":1}}}}`,
+			want:  `{"type":"session.update","session":{"audio":{"output":{"voice":"verse","speed":1}}}}`,
+		},
+		{
+			name:  "set creates missing objects",
+			rule:  TransformRule{Set: map[string]interface{}{"session.turn_detection.type": "semantic_vad"}},
+			event: `{"type":"session.update","session":{}}`,
+			want:  `{"type":"session.update","session":{"turn_detection":{"type":"semantic_vad"}}}`,
+		},
+		{
+			name:  "set array index",
+			rule:  TransformRule{Set: map[string]interface{}{"item.content.1.text": "second"}},
+			event: `{"type":"conversation.item.create","item":{"content":[{"text":"a"},{"text":"b"}]}}`,
+			want:  `{"type":"conversation.item.create","item":{"content":[{"text":"a"},{"text":"second"}]}}`,
+		},
+		{
+			name:  "set every array element",
+			rule:  TransformRule{Set: map[string]interface{}{"item.content.*.text": "x"}},
+			event: `{"type":"conversation.item.create","item":{"content":[{"text":"a"},{"type":"input_audio"}]}}`,
+			want:  `{"type":"conversation.item.create","item":{"content":[{"text":"x"},{"type":"input_audio","text":"x"}]}}`,
+		},
+		{
+			name:  "set does not create under wildcards",
+			rule:  TransformRule{Set: map[string]interface{}{"item.*.text": "x"}},
+			event: `{"type":"conversation.item.create","item":{}}`,
+			want:  `{"type":"conversation.item.create","item":{}}`,
+		},
+		{
+			name:  "mask nested arrays",
+			rule:  TransformRule{Mask: []string{"response.output.*.content.*.transcript"}},
+			event: `{"type":"response.done","response":{"output":[{"content":[{"transcript":"secret"},{"transcript":""}]},{"content":[{"transcript":"other"}]}]}}`,
+			want:  `{"type":"response.done","response":{"output":[{"content":[{"transcript":"***"},{"transcript":""}]},{"content":[{"transcript":"***"}]}]}}`,
+		},
+		{
+			name:  "mask array elements",
+			rule:  TransformRule{Mask: []string{"session.tools.*"}},
+			event: `{"type":"session.update","session":{"tools":["lookup",{"name":"call"}]}}`,
+			want:  `{"type":"session.update","session":{"tools":["***",{"name":"call"}]}}`,
+		},
+		{
+			name:  "mask keeps other values",
+			rule:  TransformRule{Mask: []string{"session.instructions", "session.voice"}},
+			event: `{"type":"session.update","session":{"instructions":42}}`,
+			want:  `{"type":"session.update","session":{"instructions":42}}`,
+		},
+		{
+			name:  "delete nested field",
+			rule:  TransformRule{Delete: []string{"session.audio.input.transcription"}},
+			event: `{"type":"session.update","session":{"audio":{"input":{"transcription":{"model":"whisper-1"},"format":"pcm16"}}}}`,
+			want:  `{"type":"session.update","session":{"audio":{"input":{"format":"pcm16"}}}}`,
+		},
+		{
+			name:  "delete in every array element",
+			rule:  TransformRule{Delete: []string{"item.content.*.audio"}},
+			event: `{"type":"conversation.item.create","item":{"content":[{"type":"input_audio","audio":"AAA="},{"type":"input_text","text":"hi"}]}}`,
+			want:  `{"type":"conversation.item.create","item":{"content":[{"type":"input_audio"},{"type":"input_text","text":"hi"}]}}`,
+		},
+		{
+			name:  "delete keeps array elements",
+			rule:  TransformRule{Delete: []string{"item.content.0"}},
+			event: `{"type":"conversation.item.create","item":{"content":[{"type":"input_text"}]}}`,
+			want:  `{"type":"conversation.item.create","item":{"content":[{"type":"input_text"}]}}`,
+		},
+		{
+			name: "actions in order",
+			rule: TransformRule{
+				Replace:    map[string]map[string]string{"session.voice": {"alloy": "verse"}},
+				Set:        map[string]interface{}{"session.instructions": "Be brief."},
+				Mask:       []string{"session.instructions"},
+				Delete:     []string{"session.temperature"},
+				RenameType: "session.updated",
+			},
+			event: `{"type":"session.update","session":{"voice":"alloy","temperature":0.8}}`,
+			want:  `{"type":"session.updated","session":{"voice":"verse","instructions":"***"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := applyTransforms([]TransformRule{tt.rule}, "inbound", []byte(tt.event))
+			if err != nil || !changed {
+				t.Fatalf("applyTransforms = %s, %v, %v", got, changed, err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyTransformsSkipsOtherEvents(t *testing.T) {
+	rule := TransformRule{Direction: "outbound", Types: []string{"response.*"}, Delete: []string{"response"}}
+	tests := []struct {
+		name      string
+		direction string
+		event     string
+		changed   bool
+	}{
+		{"matching", "outbound", `{"type":"response.done","response":{}}`, true},
+		{"other direction", "inbound", `{"type":"response.done","response":{}}`, false},
+		{"other type", "outbound", `{"type":"session.updated","response":{}}`, false},
+		{"not JSON", "outbound", `response.done`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := applyTransforms([]TransformRule{rule}, tt.direction, []byte(tt.event))
+			if err != nil || changed != tt.changed {
+				t.Fatalf("applyTransforms = %s, %v, %v, want changed %v", got, changed, err, tt.changed)
+			}
+			if !changed && string(got) != tt.event {
+				t.Errorf("untouched event returned as %s", got)
+			}
+		})
+	}
+}
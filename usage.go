@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// --- Usage Tracking ---
+
+// Number of finished sessions kept for /usage
+const maxTrackedSessions = 200
+
+// ModelPricing is the price in USD per 1M tokens, used to estimate the cost of proxied traffic.
+type ModelPricing struct {
+	TextInput   float64 `yaml:"textInput" json:"textInput"`
+	AudioInput  float64 `yaml:"audioInput" json:"audioInput"`
+	CachedInput float64 `yaml:"cachedInput" json:"cachedInput"`
+	TextOutput  float64 `yaml:"textOutput" json:"textOutput"`
+	AudioOutput float64 `yaml:"audioOutput" json:"audioOutput"`
+}
+
+// TokenUsage sums the usage reported by upstream response.done events.
+type TokenUsage struct {
+	Responses         int     `json:"responses"`
+	InputTokens       int     `json:"input_tokens"`
+	OutputTokens      int     `json:"output_tokens"`
+	TotalTokens       int     `json:"total_tokens"`
+	InputTextTokens   int     `json:"input_text_tokens"`
+	InputAudioTokens  int     `json:"input_audio_tokens"`
+	CachedTokens      int     `json:"cached_tokens"`
+	OutputTextTokens  int     `json:"output_text_tokens"`
+	OutputAudioTokens int     `json:"output_audio_tokens"`
+	CostUSD           float64 `json:"cost_usd"` // Estimate, 0 without proxy.pricing for the model
+}
+
+// SessionUsage is the usage of one proxied session.
+type SessionUsage struct {
+	SessionID string     `json:"session_id"` // Upstream session ID
+	Target    string     `json:"target"`
+	Model     string     `json:"model"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Usage     TokenUsage `json:"usage"`
+}
+
+// responseUsage is the usage object of a response.done event.
+type responseUsage struct {
+	TotalTokens       int `json:"total_tokens"`
+	InputTokens       int `json:"input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	InputTokenDetails struct {
+		TextTokens         int `json:"text_tokens"`
+		AudioTokens        int `json:"audio_tokens"`
+		CachedTokens       int `json:"cached_tokens"`
+		CachedTokenDetails struct {
+			TextTokens  int `json:"text_tokens"`
+			AudioTokens int `json:"audio_tokens"`
+		} `json:"cached_tokens_details"`
+	} `json:"input_token_details"`
+	OutputTokenDetails struct {
+		TextTokens  int `json:"text_tokens"`
+		AudioTokens int `json:"audio_tokens"`
+	} `json:"output_token_details"`
+}
+
+type usageTracker struct {
+	mu       sync.Mutex
+	total    TokenUsage
+	days     map[string]*TokenUsage // By date (YYYY-MM-DD, local time)
+	sessions []*SessionUsage        // Oldest first
+}
+
+var proxyUsage = &usageTracker{days: make(map[string]*TokenUsage)}
+
+// startSession registers a new proxied session.
+func (t *usageTracker) startSession(target, model string) *SessionUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	session := &SessionUsage{Target: target, Model: model, StartedAt: time.Now()}
+	t.sessions = append(t.sessions, session)
+	if len(t.sessions) > maxTrackedSessions {
+		t.sessions = t.sessions[len(t.sessions)-maxTrackedSessions:]
+	}
+	return session
+}
+
+// observe picks the session ID and response usage out of an upstream event.
+func (t *usageTracker) observe(session *SessionUsage, msg []byte) {
+	var event struct {
+		Type    string `json:"type"`
+		Session struct {
+			ID string `json:"id"`
+		} `json:"session"`
+		Response struct {
+			Usage *responseUsage `json:"usage"`
+		} `json:"response"`
+	}
+	if json.Unmarshal(msg, &event) != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch event.Type {
+	case "session.created":
+		if session.SessionID == "" {
+			session.SessionID = event.Session.ID
+		}
+	case "response.done":
+		if event.Response.Usage == nil {
+			return
+		}
+		usage := event.Response.Usage.tokenUsage(session.Model)
+		session.Usage.add(usage)
+		t.total.add(usage)
+		day := time.Now().Format("2006-01-02")
+		if t.days[day] == nil {
+			t.days[day] = &TokenUsage{}
+		}
+		t.days[day].add(usage)
+	}
+}
+
+// sessionID returns the upstream session ID once session.created was seen.
+func (t *usageTracker) sessionID(session *SessionUsage) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return session.SessionID
+}
+
+// endSession marks the session finished and logs its usage to the session's logger.
+func (t *usageTracker) endSession(session *SessionUsage, logger *log.Logger) {
+	t.mu.Lock()
+	now := time.Now()
+	session.EndedAt = &now
+	usage := session.Usage
+	t.mu.Unlock()
+
+	logger.Printf("Proxy: Session usage: %d responses, %d input / %d output tokens, ~$%.4f",
+		usage.Responses, usage.InputTokens, usage.OutputTokens, usage.CostUSD)
+}
+
+func (u responseUsage) tokenUsage(model string) TokenUsage {
+	usage := TokenUsage{
+		Responses:         1,
+		InputTokens:       u.InputTokens,
+		OutputTokens:      u.OutputTokens,
+		TotalTokens:       u.TotalTokens,
+		InputTextTokens:   u.InputTokenDetails.TextTokens,
+		InputAudioTokens:  u.InputTokenDetails.AudioTokens,
+		CachedTokens:      u.InputTokenDetails.CachedTokens,
+		OutputTextTokens:  u.OutputTokenDetails.TextTokens,
+		OutputAudioTokens: u.OutputTokenDetails.AudioTokens,
+	}
+
+	pricing, ok := pricingFor(model)
+	if !ok {
+		return usage
+	}
+	// Cached tokens are part of the text/audio input counts but billed at the cached rate
+	cachedText := u.InputTokenDetails.CachedTokenDetails.TextTokens
+	cachedAudio := u.InputTokenDetails.CachedTokenDetails.AudioTokens
+	if cachedText+cachedAudio == 0 {
+		cachedText = u.InputTokenDetails.CachedTokens
+	}
+	usage.CostUSD = (float64(usage.InputTextTokens-cachedText)*pricing.TextInput +
+		float64(usage.InputAudioTokens-cachedAudio)*pricing.AudioInput +
+		float64(usage.CachedTokens)*pricing.CachedInput +
+		float64(usage.OutputTextTokens)*pricing.TextOutput +
+		float64(usage.OutputAudioTokens)*pricing.AudioOutput) / 1e6
+	return usage
+}
+
+// pricingFor looks up proxy.pricing by exact model name, then by glob pattern.
+func pricingFor(model string) (ModelPricing, bool) {
+	prices := currentConfig().Proxy.Pricing
+	if pricing, ok := prices[model]; ok {
+		return pricing, true
+	}
+	for _, pattern := range sortedKeys(prices) {
+		if matched, _ := path.Match(pattern, model); matched {
+			return prices[pattern], true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+func (u *TokenUsage) add(other TokenUsage) {
+	u.Responses += other.Responses
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.TotalTokens += other.TotalTokens
+	u.InputTextTokens += other.InputTextTokens
+	u.InputAudioTokens += other.InputAudioTokens
+	u.CachedTokens += other.CachedTokens
+	u.OutputTextTokens += other.OutputTextTokens
+	u.OutputAudioTokens += other.OutputAudioTokens
+	u.CostUSD += other.CostUSD
+}
+
+// handleGetUsage serves the usage totals, per day and of the most recent sessions.
+func handleGetUsage(w http.ResponseWriter, r *http.Request) {
+	proxyUsage.mu.Lock()
+	body, err := json.Marshal(map[string]interface{}{
+		"total":    proxyUsage.total,
+		"days":     proxyUsage.days,
+		"sessions": proxyUsage.sessions,
+	})
+	proxyUsage.mu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to encode usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
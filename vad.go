@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// --- Simulated Server VAD ---
+
+const (
+	vadFrameMs                  = 20  // Analysis window size
+	defaultVADThreshold         = 0.5 // Same scale as the API's turn_detection.threshold (0.0 - 1.0)
+	defaultVADPrefixPaddingMs   = 300
+	defaultVADSilenceDurationMs = 500
+
+	// vadEnergyScale maps the API threshold onto normalized RMS energy.
+	// The default threshold of 0.5 corresponds to an RMS of 0.05 (about -26 dBFS).
+	vadEnergyScale = 0.1
+)
+
+// semanticVADSilenceMs approximates semantic_vad eagerness with a fixed end-of-turn silence.
+// There is no semantic model here, so "high" simply ends turns sooner than "low".
+var semanticVADSilenceMs = map[string]int{
+	"high":   250,
+	"medium": 500,
+	"auto":   500,
+	"low":    1000,
+}
+
+// vadSettings returns the effective threshold, prefix padding and silence duration for a turn detection config.
+func vadSettings(td *TurnDetection) (threshold float64, prefixPaddingMs int, silenceDurationMs int) {
+	threshold = defaultVADThreshold
+	prefixPaddingMs = defaultVADPrefixPaddingMs
+	silenceDurationMs = defaultVADSilenceDurationMs
+
+	if td.Type == "semantic_vad" {
+		if ms, ok := semanticVADSilenceMs[td.Eagerness]; ok {
+			silenceDurationMs = ms
+		}
+		return
+	}
+	if td.Threshold != nil {
+		threshold = *td.Threshold
+	}
+	if td.PrefixPaddingMs != nil {
+		prefixPaddingMs = *td.PrefixPaddingMs
+	}
+	if td.SilenceDurationMs != nil {
+		silenceDurationMs = *td.SilenceDurationMs
+	}
+	return
+}
+
+// vadState tracks speech detection across appended audio chunks.
+type vadState struct {
+	cursorMs     int    // Milliseconds of audio analysed during the session
+	pending      []byte // Leftover bytes that did not fill a whole frame
+	speaking     bool
+	silenceMs    int
+	speechItemID string
+}
+
+// processVAD runs a simple energy-based VAD over newly appended audio (decoded to PCM16),
+// emitting speech_started/speech_stopped and auto-committing the buffer once
+// silence_duration_ms of silence follows speech.
+func (s *MockSession) processVAD(data []byte) {
+	frameBytes := s.inputSampleRate() * 2 * vadFrameMs / 1000
+	threshold, prefixPaddingMs, silenceDurationMs := vadSettings(s.TurnDetection)
+	buf := append(s.vad.pending, data...)
+
+	for len(buf) >= frameBytes {
+		frame := buf[:frameBytes]
+		buf = buf[frameBytes:]
+
+		frameStartMs := s.vad.cursorMs
+		s.vad.cursorMs += vadFrameMs
+		voiced := pcm16RMS(frame) >= threshold*vadEnergyScale
+
+		if !s.vad.speaking {
+			if voiced {
+				s.vad.speaking = true
+				s.vad.silenceMs = 0
+				s.vad.speechItemID = "mock-item-input-" + uuid.NewString()
+				audioStartMs := frameStartMs - prefixPaddingMs
+				if audioStartMs < 0 {
+					audioStartMs = 0
+				}
+				s.logf("Client %s: VAD speech started at %dms", s.Conn.RemoteAddr(), audioStartMs)
+				sendJSONEvent(s.Conn, map[string]interface{}{
+					"type":           "input_audio_buffer.speech_started",
+					"event_id":       uuid.NewString(),
+					"audio_start_ms": audioStartMs,
+					"item_id":        s.vad.speechItemID,
+				})
+			}
+			continue
+		}
+
+		if voiced {
+			s.vad.silenceMs = 0
+			continue
+		}
+
+		s.vad.silenceMs += vadFrameMs
+		if s.vad.silenceMs >= silenceDurationMs {
+			s.finishSpeech()
+		}
+	}
+
+	s.vad.pending = append([]byte(nil), buf...)
+}
+
+// finishSpeech emits speech_stopped, commits the buffer and optionally starts a response.
+func (s *MockSession) finishSpeech() {
+	itemID := s.vad.speechItemID
+	s.vad.speaking = false
+	s.vad.silenceMs = 0
+	s.vad.speechItemID = ""
+
+	s.logf("Client %s: VAD speech stopped at %dms", s.Conn.RemoteAddr(), s.vad.cursorMs)
+	if err := sendJSONEvent(s.Conn, map[string]interface{}{
+		"type":         "input_audio_buffer.speech_stopped",
+		"event_id":     uuid.NewString(),
+		"audio_end_ms": s.vad.cursorMs,
+		"item_id":      itemID,
+	}); err != nil {
+		return
+	}
+
+	s.commitInputBuffer("", itemID)
+
+	if s.TurnDetection.createResponse() && s.StartResponse != nil {
+		s.StartResponse(turnTriggerCommit)
+	}
+}
+
+// pcm16RMS returns the RMS energy of little-endian PCM16 samples, normalized to 0.0 - 1.0.
+func pcm16RMS(frame []byte) float64 {
+	samples := len(frame) / 2
+	if samples == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < samples; i++ {
+		v := float64(int16(binary.LittleEndian.Uint16(frame[i*2:]))) / 32768.0
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(samples))
+}
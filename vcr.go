@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+)
+
+// --- VCR Mode Logic ---
+
+// In "vcr" mode a connection with ?recording_name= is served from the cassette recorded under
+// that name if there is one, and otherwise proxied to OpenAI while the cassette is recorded.
+// Cassettes are combined session_ recordings, so they can be replayed like any other.
+
+func handleVCRWebSocket(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	recordingName := query.Get("recording_name")
+	if recordingName == "" {
+		requestLog(r).Printf("VCR: No recording_name given, proxying without a cassette")
+		handleProxyWebSocket(w, r)
+		return
+	}
+
+	cassette := "session_" + filepath.Base(recordingName)
+	path := cassettePath(cassette)
+	if _, ok := recordingFile(path); ok {
+		requestLog(r).Printf("VCR: Replaying cassette %s", path)
+		query.Set("replaySession", cassette)
+		r.URL.RawQuery = query.Encode()
+		handleMockWebSocket(w, r)
+		return
+	}
+
+	requestLog(r).Printf("VCR: No cassette for '%s', proxying and recording to %s", recordingName, path)
+	handleProxyWebSocket(w, r)
+}
+
+// cassettePath returns where the proxy records, and replay finds, the named cassette.
+func cassettePath(cassette string) string {
+	recordingDir := currentConfig().Proxy.RecordingPath
+	if recordingDir == "" {
+		recordingDir = "recordings"
+	}
+	return filepath.Join(recordingDir, "recorded", cassette+".ndjson")
+}
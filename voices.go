@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// --- Voice Audio Variants ---
+
+const defaultVoice = "alloy"
+
+// voiceAudioPath returns the WAV asset to play for a voice.
+// A voice directory is searched for a file named like mock.audioWavPath; voices without
+// a mapping (or without that file) fall back to mock.audioWavPath itself.
+func (c MockConfig) voiceAudioPath(voice string) string {
+	return voiceAudioFile(c.AudioWavPath, c.VoiceAudioDirs, voice)
+}
+
+// voiceAudioFile looks up the audio of a voice in dirs, falling back to wavPath.
+func voiceAudioFile(wavPath string, dirs map[string]string, voice string) string {
+	dir, ok := dirs[voice]
+	if !ok || wavPath == "" {
+		return wavPath
+	}
+	candidate := filepath.Join(dir, filepath.Base(wavPath))
+	if _, err := os.Stat(candidate); err != nil {
+		return wavPath
+	}
+	return candidate
+}
+
+// checkVoiceAudioDirs logs which voice variants are available and preloads them.
+func checkVoiceAudioDirs(chunkSize int, out audioOutput) {
+	for voice := range appConfig.Mock.VoiceAudioDirs {
+		path := appConfig.Mock.voiceAudioPath(voice)
+		if path == appConfig.Mock.AudioWavPath {
+			log.Printf("WARNING: Voice '%s' has no %s in %s, using the default audio", voice, filepath.Base(appConfig.Mock.AudioWavPath), appConfig.Mock.VoiceAudioDirs[voice])
+			continue
+		}
+		if err := validateWavFormat(path); err != nil {
+			log.Printf("WARNING: Audio for voice '%s' failed validation: %v", voice, err)
+		}
+		if err := audioCache.Preload(audioAsset{path, appConfig.Mock.AudioNormalize}, chunkSize, out); err != nil {
+			log.Printf("WARNING: %v", err)
+			continue
+		}
+		log.Printf("Voice '%s' uses audio %s", voice, path)
+	}
+}